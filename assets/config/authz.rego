@@ -12,12 +12,32 @@ allow = response {
     #input.method == "GET"
     pathstart := array.slice(input.path, 0, 2)
     pathstart == ["api", "v0"]
+    not is_admin_request
 
     response := {
         "tenants": token.payload.tenants
     }
 }
 
+allow = response {
+    is_valid_token
+    is_admin_request
+    is_admin
+
+    response := {
+        "tenants": token.payload.tenants
+    }
+}
+
+is_admin_request {
+    pathstart := array.slice(input.path, 0, 3)
+    pathstart == ["api", "v0", "admin"]
+}
+
+is_admin {
+    token.payload.realm_access.roles[_] == "admin"
+}
+
 issuers := {"https://iam.diwise.io/realms/diwise-test"}
 
 metadata_discovery(issuer) := http.send({
@@ -10,6 +10,7 @@ import (
 	"os"
 	"os/signal"
 	"syscall"
+	"time"
 
 	"github.com/diwise/iot-things/internal/app/api"
 	app "github.com/diwise/iot-things/internal/app/iot-things"
@@ -21,6 +22,12 @@ import (
 	"github.com/diwise/service-chassis/pkg/infrastructure/o11y"
 	"github.com/diwise/service-chassis/pkg/infrastructure/o11y/logging"
 	"github.com/go-chi/chi/v5"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetrichttp"
+	"go.opentelemetry.io/otel/exporters/prometheus"
+	"go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/resource"
+	semconv "go.opentelemetry.io/otel/semconv/v1.4.0"
 )
 
 const serviceName string = "iot-things"
@@ -34,6 +41,13 @@ func main() {
 	ctx, log, cleanup := o11y.Init(ctx, serviceName, serviceVersion, "json")
 	defer cleanup()
 
+	cleanupMetrics, err := initMetrics(ctx, serviceVersion)
+	if err != nil {
+		log.Error("could not configure metrics", "err", err.Error())
+		os.Exit(1)
+	}
+	defer cleanupMetrics()
+
 	var opa, fp, cfgFile string
 
 	flag.StringVar(&opa, "policies", "/opt/diwise/config/authz.rego", "An authorization policy file")
@@ -94,6 +108,41 @@ func main() {
 	s.Close()
 }
 
+// initMetrics exports metrics for /metrics to collect, and pushes them to the OTLP endpoint if one is configured.
+// The instruments are created from this provider explicitly, since o11y.Init may already have set a global
+// provider and the global provider only delegates to the first provider set.
+func initMetrics(ctx context.Context, serviceVersion string) (func(), error) {
+	exporter, err := prometheus.New()
+	if err != nil {
+		return nil, err
+	}
+
+	options := []metric.Option{
+		metric.WithResource(resource.NewWithAttributes(
+			semconv.SchemaURL,
+			semconv.ServiceNameKey.String(serviceName),
+			semconv.ServiceVersionKey.String(serviceVersion),
+		)),
+		metric.WithReader(exporter),
+	}
+
+	if os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT") != "" {
+		otlp, err := otlpmetrichttp.New(ctx)
+		if err != nil {
+			return nil, err
+		}
+		options = append(options, metric.WithReader(metric.NewPeriodicReader(otlp, metric.WithInterval(10*time.Second))))
+	}
+
+	meterProvider := metric.NewMeterProvider(options...)
+	otel.SetMeterProvider(meterProvider)
+
+	app.InitMetrics(meterProvider)
+	storage.InitMetrics(meterProvider)
+
+	return func() { meterProvider.Shutdown(ctx) }, nil
+}
+
 func newApp(ctx context.Context, r app.ThingsReader, w app.ThingsWriter, m messaging.MsgContext, cfgFilePath string) (app.ThingsApp, error) {
 	f, err := os.Open(cfgFilePath)
 	if err != nil {
@@ -137,5 +186,17 @@ func seed(ctx context.Context, fp string, a app.ThingsApp) error {
 	}
 	defer things.Close()
 
-	return a.Seed(ctx, things)
+	report, err := a.Seed(ctx, things, false, nil)
+	if err != nil {
+		return err
+	}
+
+	for _, e := range report.Errors {
+		log.Error("could not seed thing", "row", e.Row, "id", e.ID, "err", e.Error)
+	}
+	if len(report.Errors) > 0 {
+		return fmt.Errorf("%d things could not be seeded", len(report.Errors))
+	}
+
+	return nil
 }
@@ -1,26 +1,34 @@
 package api
 
 import (
+	"bytes"
 	"context"
+	"encoding/csv"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"hash/fnv"
 	"io"
 	"log/slog"
 	"net/http"
 	"slices"
+	"strconv"
 	"strings"
 	"time"
 
 	app "github.com/diwise/iot-things/internal/app/iot-things"
 	"github.com/diwise/iot-things/internal/app/iot-things/things"
 	"github.com/diwise/iot-things/internal/pkg/auth"
+	"github.com/diwise/senml"
 	"github.com/diwise/service-chassis/pkg/infrastructure/o11y"
 	"github.com/diwise/service-chassis/pkg/infrastructure/o11y/logging"
 	"github.com/diwise/service-chassis/pkg/infrastructure/o11y/tracing"
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
+	"github.com/google/uuid"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"go.opentelemetry.io/otel"
+	"gopkg.in/yaml.v2"
 )
 
 var tracer = otel.Tracer("iot-things/api/things")
@@ -34,7 +42,7 @@ func Register(ctx context.Context, app app.ThingsApp, policies io.Reader) (*chi.
 	r.Use(middleware.RealIP)
 	r.Use(middleware.Logger)
 	r.Use(middleware.Recoverer)
-	r.Use(middleware.Timeout(60 * time.Second))
+	r.Use(timeout(requestTimeouts(ctx)))
 
 	authenticator, err := auth.NewAuthenticator(ctx, log, policies)
 	if err != nil {
@@ -50,15 +58,51 @@ func Register(ctx context.Context, app app.ThingsApp, policies io.Reader) (*chi.
 				r.Get("/{id}", getByIDHandler(log, app))
 				r.Post("/", addHandler(log, app))
 				r.Put("/{id}", updateHandler(log, app))
+				r.Patch("/", bulkPatchHandler(log, app))
 				r.Patch("/{id}", patchHandler(log, app))
+				r.Post("/{id}/clone", cloneHandler(log, app))
+				r.Post("/{id}/relations", addRelationHandler(log, app))
+				r.Get("/{id}/relations", getRelationsHandler(log, app))
 				r.Delete("/{id}", deleteHandler(log, app))
+				r.Post("/{id}/restore", restoreHandler(log, app))
+				r.Post("/{id}/values", addValuesHandler(log, app))
+				r.Delete("/{id}/values", deleteValuesHandler(log, app))
+				r.Get("/{id}/urns", getUrnsHandler(log, app))
+				r.Get("/{id}/values/recent", getRecentValuesHandler(log, app))
+				r.Get("/{id}/values/latest", getLatestValuesHandler(log, app))
+				r.Get("/{id}/values/range", getValueRangeHandler(log, app))
+				r.Get("/{id}/utilization", getUtilizationHandler(log, app))
+				r.Get("/{id}/completeness", getCompletenessHandler(log, app))
+				r.Get("/{id}/timeline", getTimelineHandler(log, app))
 				r.Get("/tags", getTagsHandler(log, app))
+				r.Get("/attention", getAttentionHandler(log, app))
 				r.Get("/types", getTypesHandler(log, app))
+				r.Get("/count", getCountHandler(log, app))
 				r.Get("/values", getValuesHandler(log, app))
 			})
+
+			r.Post("/batch", batchHandler(log, app))
+			r.Get("/units", getUnitsHandler(log))
+
+			exports := newExports(ctx, defaultExportPageSize)
+			r.Route("/exports", func(r chi.Router) {
+				r.Post("/", exports.createHandler(log, app))
+				r.Get("/{id}", exports.statusHandler(log))
+				r.Get("/{id}/file", exports.fileHandler(log))
+			})
+
+			r.Route("/admin", func(r chi.Router) {
+				r.Post("/compact", compactHandler(log, app))
+				r.Get("/tenants", getTenantsHandler(log, app))
+				r.Get("/duplicates", getDuplicatesHandler(log, app))
+				r.Post("/merge", mergeHandler(log, app))
+				r.Post("/measurements", ingestHandler(log, app))
+			})
 		})
 	})
 
+	r.Get("/metrics", promhttp.Handler().ServeHTTP)
+
 	r.Get("/health", func(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusOK)
 	})
@@ -76,7 +120,19 @@ func queryHandler(log *slog.Logger, a app.ThingsApp) http.HandlerFunc {
 
 		w.Header().Set("Content-Type", "application/vnd.api+json")
 
-		result, err := a.QueryThings(ctx, r.URL.Query())
+		tenants := auth.GetAllowedTenantsFromContext(ctx)
+
+		result, err := a.QueryThings(ctx, r.URL.Query(), tenants)
+		if err != nil && errors.Is(err, app.ErrForbiddenTenant) {
+			w.WriteHeader(http.StatusForbidden)
+			w.Write([]byte(err.Error()))
+			return
+		}
+		if err != nil && errors.Is(err, app.ErrInvalidParams) {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(err.Error()))
+			return
+		}
 		if err != nil {
 			logger.Error("could not query things", "err", err.Error())
 			w.WriteHeader(http.StatusInternalServerError)
@@ -84,14 +140,61 @@ func queryHandler(log *slog.Logger, a app.ThingsApp) http.HandlerFunc {
 			return
 		}
 
-		if result.Count == 0 {
+		// clients asking for plain JSON, YAML or CSV get a bare empty list, others an empty vnd.api+json envelope
+		if result.Count == 0 && !acceptsJsonApi(r.Header.Get("Accept")) && !isGeoJSON(r.Header.Get("Accept")) {
 			w.WriteHeader(http.StatusOK)
 			w.Write([]byte("[]"))
 			return
 		}
 
+		if isNotModified(w, r, result, nil) {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+
+		if isYAML(r.Header.Get("Accept")) {
+			b := &bytes.Buffer{}
+			err := exportQueryResultAsYAML(result, b)
+			if err != nil {
+				logger.Error("could not export query response as YAML", "err", err.Error())
+				w.WriteHeader(http.StatusInternalServerError)
+				w.Write([]byte(err.Error()))
+				return
+			}
+
+			w.Header().Set("Content-Type", "application/yaml")
+			w.WriteHeader(http.StatusOK)
+			w.Write(b.Bytes())
+
+			return
+		}
+
+		if isGeoJSON(r.Header.Get("Accept")) {
+			fc, err := exportQueryResultAsGeoJSON(result)
+			if err != nil {
+				logger.Error("could not export query response as GeoJSON", "err", err.Error())
+				w.WriteHeader(http.StatusInternalServerError)
+				w.Write([]byte(err.Error()))
+				return
+			}
+
+			b, err := json.Marshal(fc)
+			if err != nil {
+				logger.Error("could not marshal feature collection", "err", err.Error())
+				w.WriteHeader(http.StatusInternalServerError)
+				return
+			}
+
+			w.Header().Set("Content-Type", contentTypeGeoJSON)
+			w.WriteHeader(http.StatusOK)
+			w.Write(b)
+
+			return
+		}
+
 		if r.Header.Get("Accept") == "text/csv" {
-			err := exportQueryResultAsCSV(result, w)
+			b := &bytes.Buffer{}
+			err := exportQueryResultAsCSV(result, csvColumns(r), b)
 			if err != nil {
 				logger.Error("could not export query response as CSV", "err", err.Error())
 				w.WriteHeader(http.StatusInternalServerError)
@@ -101,6 +204,7 @@ func queryHandler(log *slog.Logger, a app.ThingsApp) http.HandlerFunc {
 
 			w.Header().Set("Content-Type", "text/csv")
 			w.WriteHeader(http.StatusOK)
+			w.Write(b.Bytes())
 
 			return
 		}
@@ -115,7 +219,7 @@ func queryHandler(log *slog.Logger, a app.ThingsApp) http.HandlerFunc {
 				w.Write([]byte(err.Error()))
 				return
 			}
-			mapToOutModel(m)
+			mapToOutModel(m, includeDeviceState(r))
 			data = append(data, m)
 		}
 
@@ -135,13 +239,38 @@ func queryHandler(log *slog.Logger, a app.ThingsApp) http.HandlerFunc {
 	}
 }
 
-func exportQueryResultAsCSV(result app.QueryResult, w io.Writer) error {
+// thingsCSVColumns are the columns exported when no columns are requested
+var thingsCSVColumns = []string{"id", "type", "subType", "name", "decsription", "location", "tenant", "tags", "refDevices", "args"}
+
+// csvColumns parses a comma separated list of columns, falling back to thingsCSVColumns
+func csvColumns(r *http.Request) []string {
+	columns := []string{}
+
+	for _, param := range r.URL.Query()["columns"] {
+		for _, c := range strings.Split(param, ",") {
+			c = strings.TrimSpace(c)
+			if c != "" {
+				columns = append(columns, c)
+			}
+		}
+	}
+
+	if len(columns) == 0 {
+		return thingsCSVColumns
+	}
+
+	return columns
+}
+
+func exportQueryResultAsCSV(result app.QueryResult, columns []string, w io.Writer) error {
 	if result.Count == 0 {
 		return nil
 	}
 
+	cw := newCSVWriter(w)
+
 	for i, b := range result.Data {
-		t, err := things.ConvToThing(b)
+		t, err := things.ConvToThingOrPassthrough(b)
 		if err != nil {
 			return err
 		}
@@ -153,89 +282,239 @@ func exportQueryResultAsCSV(result app.QueryResult, w io.Writer) error {
 		}
 
 		if i == 0 {
-			header := strings.Join([]string{"id", "type", "subType", "name", "decsription", "location", "tenant", "tags", "refDevices", "args"}, ";")
-			_, err := w.Write([]byte(fmt.Sprintln(header)))
+			err := cw.Write(columns)
 			if err != nil {
 				return err
 			}
 		}
 
-		asString := func(v any) string {
-			if v == nil {
-				return ""
-			}
-			return fmt.Sprintf("%v", v)
+		values := make([]string, len(columns))
+		for j, c := range columns {
+			values[j] = csvValue(t, m, c)
 		}
-		asTags := func(v any) string {
-			if v == nil {
-				return ""
-			}
-			values := v.([]any)
-			tags := make([]string, len(values))
-			for i, tag := range values {
-				tags[i] = fmt.Sprintf("%v", tag)
-			}
 
-			return strings.Join(tags, ",")
+		err = cw.Write(values)
+		if err != nil {
+			return err
 		}
-		asRefDevices := func(v any) string {
-			if v == nil {
-				return ""
-			}
-			devices := v.([]any)
-			refDevices := make([]string, len(devices))
-			for i, device := range devices {
-				d := device.(map[string]any)
-				refDevices[i] = fmt.Sprintf("%v", d["deviceID"])
-			}
-			return strings.Join(refDevices, ",")
+	}
+
+	cw.Flush()
+	return cw.Error()
+}
+
+// newCSVWriter returns a csv.Writer using semicolon as delimiter, fields are quoted as needed
+func newCSVWriter(w io.Writer) *csv.Writer {
+	cw := csv.NewWriter(w)
+	cw.Comma = ';'
+	return cw
+}
+
+// csvValue returns the value of a column for a thing, any column not known here is read from the thing as is
+func csvValue(t things.Thing, m map[string]any, column string) string {
+	asString := func(v any) string {
+		switch s := v.(type) {
+		case nil:
+			return ""
+		case string:
+			return s
+		case map[string]any, []any:
+			b, _ := json.Marshal(s)
+			return string(b)
+		}
+		return fmt.Sprintf("%v", v)
+	}
+	asTags := func(v any) string {
+		values, _ := v.([]any)
+		tags := make([]string, len(values))
+		for i, tag := range values {
+			tags[i] = fmt.Sprintf("%v", tag)
 		}
-		asArgs := func(m map[string]any) string {
-			args := []string{}
 
-			for k, v := range m {
-				if slices.Contains([]string{"maxd", "maxl", "meanl", "offset", "angle"}, k) {
-					args = append(args, fmt.Sprintf("'%s':%f", k, v.(float64)))
+		return strings.Join(tags, ",")
+	}
+	asRefDevices := func(v any) string {
+		devices, _ := v.([]any)
+		refDevices := make([]string, len(devices))
+		for i, device := range devices {
+			d, _ := device.(map[string]any)
+			refDevices[i] = fmt.Sprintf("%v", d["deviceID"])
+		}
+		return strings.Join(refDevices, ",")
+	}
+	asArgs := func(m map[string]any) string {
+		args := []string{}
+
+		for _, k := range things.ConfigArgs {
+			switch v := m[k].(type) {
+			case float64:
+				if k == "passagesRetention" {
+					args = append(args, fmt.Sprintf("'%s':%d", k, int(v)))
+				} else {
+					args = append(args, fmt.Sprintf("'%s':%f", k, v))
 				}
-				if slices.Contains([]string{"alternativeName"}, k) {
-					s := v.(string)
-					if s != "" {
-						args = append(args, fmt.Sprintf("'%s':'%s'", k, s))
-					}
+			case string:
+				if v != "" {
+					args = append(args, fmt.Sprintf("'%s':'%s'", k, v))
 				}
 			}
+		}
 
-			if len(args) > 0 {
-				j := "{" + strings.Join(args, ",") + "}"
-				return j
-			}
-
-			return ""
+		if len(args) > 0 {
+			return "{" + strings.Join(args, ",") + "}"
 		}
 
+		return ""
+	}
+
+	switch column {
+	case "id":
+		return t.ID()
+	case "type":
+		return t.Type()
+	case "tenant":
+		return t.Tenant()
+	case "description", "decsription":
+		return asString(m["description"])
+	case "location":
 		lat, lon := t.LatLon()
-		values := []string{
-			t.ID(),
-			t.Type(),
-			asString(m["subType"]),
-			asString(m["name"]),
-			asString(m["description"]),
-			fmt.Sprintf("%f,%f", lat, lon),
-			t.Tenant(),
-			asTags(m["tags"]),
-			asRefDevices(m["refDevices"]),
-			asArgs(m),
+		return fmt.Sprintf("%f,%f", lat, lon)
+	case "tags":
+		return asTags(m["tags"])
+	case "refDevices":
+		return asRefDevices(m["refDevices"])
+	case "args":
+		return asArgs(m)
+	}
+
+	return asString(m[column])
+}
+
+const contentTypeGeoJSON string = "application/geo+json"
+
+func isGeoJSON(accept string) bool {
+	return strings.Contains(accept, contentTypeGeoJSON)
+}
+
+// exportQueryResultAsGeoJSON returns the things as features, a polygon of the area of things that have an
+// area and otherwise a point at their location. Things at the default location 0,0 without an area have
+// not been placed on a map and are left out.
+func exportQueryResultAsGeoJSON(result app.QueryResult) (FeatureCollection, error) {
+	fc := FeatureCollection{
+		Type:     "FeatureCollection",
+		Features: make([]Feature, 0, len(result.Data)),
+	}
+
+	for _, b := range result.Data {
+		t := struct {
+			ID               string               `json:"id"`
+			Type             string               `json:"type"`
+			SubType          string               `json:"subType"`
+			Name             string               `json:"name"`
+			Location         things.Location      `json:"location"`
+			ObservedLocation *things.Location     `json:"_observedLocation"`
+			Area             *things.LineSegments `json:"area"`
+		}{}
+		err := json.Unmarshal(b, &t)
+		if err != nil {
+			return FeatureCollection{}, err
+		}
+
+		// a location reported by the devices of the thing takes precedence, as it does for queries by area
+		l := t.Location
+		if t.ObservedLocation != nil {
+			l = *t.ObservedLocation
+		}
+
+		geometry := Geometry{
+			Type:        "Point",
+			Coordinates: []float64{l.Longitude, l.Latitude},
 		}
 
-		row := strings.Join(values, ";")
+		if t.Area != nil {
+			if polygon, ok := t.Area.Polygon(); ok {
+				geometry = Geometry{
+					Type:        "Polygon",
+					Coordinates: polygon,
+				}
+			}
+		}
+
+		if geometry.Type == "Point" && l.Latitude == 0 && l.Longitude == 0 {
+			continue
+		}
+
+		fc.Features = append(fc.Features, Feature{
+			ID:       t.ID,
+			Type:     "Feature",
+			Geometry: geometry,
+			Properties: map[string]any{
+				"type":    t.Type,
+				"subType": t.SubType,
+				"name":    t.Name,
+			},
+		})
+	}
+
+	return fc, nil
+}
+
+func exportQueryResultAsYAML(result app.QueryResult, w io.Writer) error {
+	inventory := app.Inventory{
+		Things: make([]app.InventoryItem, 0, len(result.Data)),
+	}
+
+	for _, b := range result.Data {
+		t := struct {
+			ID          string          `json:"id"`
+			Type        string          `json:"type"`
+			SubType     string          `json:"subType"`
+			Name        string          `json:"name"`
+			Description string          `json:"description"`
+			Location    things.Location `json:"location"`
+			Tenant      string          `json:"tenant"`
+			Tags        []string        `json:"tags"`
+			RefDevices  []things.Device `json:"refDevices"`
+		}{}
+		err := json.Unmarshal(b, &t)
+		if err != nil {
+			return err
+		}
 
-		_, err = w.Write([]byte(fmt.Sprintln(row)))
+		m := make(map[string]any)
+		err = json.Unmarshal(b, &m)
 		if err != nil {
 			return err
 		}
+
+		item := app.InventoryItem{
+			ID:          t.ID,
+			Type:        t.Type,
+			SubType:     t.SubType,
+			Name:        t.Name,
+			Description: t.Description,
+			Location:    &t.Location,
+			Tenant:      t.Tenant,
+			Tags:        t.Tags,
+		}
+
+		for _, d := range t.RefDevices {
+			item.RefDevices = append(item.RefDevices, d.DeviceID)
+		}
+
+		for _, k := range things.ConfigArgs {
+			if v, ok := m[k]; ok && v != nil && v != "" {
+				if item.Args == nil {
+					item.Args = map[string]any{}
+				}
+				item.Args[k] = v
+			}
+		}
+
+		inventory.Things = append(inventory.Things, item)
 	}
 
-	return nil
+	return yaml.NewEncoder(w).Encode(inventory)
 }
 
 func getByIDHandler(log *slog.Logger, a app.ThingsApp) http.HandlerFunc {
@@ -255,7 +534,9 @@ func getByIDHandler(log *slog.Logger, a app.ThingsApp) http.HandlerFunc {
 			return
 		}
 
-		result, err := a.QueryThings(ctx, map[string][]string{"id": {thingId}})
+		tenants := auth.GetAllowedTenantsFromContext(ctx)
+
+		result, err := a.QueryThings(ctx, map[string][]string{"id": {thingId}}, tenants)
 		if err != nil {
 			logger.Debug("failed to query things", "err", err.Error())
 			w.WriteHeader(http.StatusInternalServerError)
@@ -270,7 +551,17 @@ func getByIDHandler(log *slog.Logger, a app.ThingsApp) http.HandlerFunc {
 
 		q := r.URL.Query()
 		q.Set("thingid", thingId)
-		values, err := a.QueryValues(ctx, q)
+		values, err := a.QueryValues(ctx, q, tenants)
+		if err != nil && errors.Is(err, app.ErrForbiddenTenant) {
+			w.WriteHeader(http.StatusForbidden)
+			w.Write([]byte(err.Error()))
+			return
+		}
+		if err != nil && (errors.Is(err, app.ErrTimeRangeExceeded) || errors.Is(err, app.ErrInvalidParams)) {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(err.Error()))
+			return
+		}
 		if err != nil {
 			logger.Debug("failed to query values", "err", err.Error())
 			w.WriteHeader(http.StatusInternalServerError)
@@ -278,6 +569,11 @@ func getByIDHandler(log *slog.Logger, a app.ThingsApp) http.HandlerFunc {
 			return
 		}
 
+		if isNotModified(w, r, result, values.Data) {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+
 		thing := make(map[string]any)
 		err = json.Unmarshal(result.Data[0], &thing)
 		if err != nil {
@@ -289,10 +585,24 @@ func getByIDHandler(log *slog.Logger, a app.ThingsApp) http.HandlerFunc {
 
 		thing["values"] = transformValues(r, values.Data)
 
-		mapToOutModel(thing)
+		mapToOutModel(thing, includeDeviceState(r))
 
 		response := NewApiResponse(r, thing, uint64(values.Count), uint64(values.TotalCount), uint64(values.Offset), uint64(values.Limit))
 
+		if isCBOR(r.Header.Get("Accept")) {
+			b, err := toCBOR(response.Byte())
+			if err != nil {
+				logger.Error("could not encode thing as cbor", "err", err.Error())
+				w.WriteHeader(http.StatusInternalServerError)
+				return
+			}
+
+			w.Header().Set("Content-Type", contentTypeCBOR)
+			w.WriteHeader(http.StatusOK)
+			w.Write(b)
+			return
+		}
+
 		w.WriteHeader(http.StatusOK)
 		w.Write(response.Byte())
 	}
@@ -318,7 +628,25 @@ func addHandler(log *slog.Logger, a app.ThingsApp) http.HandlerFunc {
 			}
 			defer file.Close()
 
-			err = a.Seed(ctx, file)
+			dryRun, _ := strconv.ParseBool(r.FormValue("dryRun"))
+
+			tenants := auth.GetAllowedTenantsFromContext(ctx)
+			if len(tenants) == 0 {
+				w.WriteHeader(http.StatusForbidden)
+				return
+			}
+
+			report, err := a.Seed(ctx, file, dryRun, tenants)
+			if err != nil && errors.Is(err, app.ErrForbiddenTenant) {
+				w.WriteHeader(http.StatusForbidden)
+				w.Write([]byte(err.Error()))
+				return
+			}
+			if err != nil && isInvalidSeed(err) {
+				w.WriteHeader(http.StatusBadRequest)
+				w.Write([]byte(err.Error()))
+				return
+			}
 			if err != nil {
 				logger.Error("could not seed", "err", err.Error())
 				w.WriteHeader(http.StatusInternalServerError)
@@ -326,6 +654,59 @@ func addHandler(log *slog.Logger, a app.ThingsApp) http.HandlerFunc {
 				return
 			}
 
+			// things that are not valid are skipped and listed in the report, also when the others were seeded
+			if dryRun || len(report.Errors) > 0 {
+				b, err := json.Marshal(report)
+				if err != nil {
+					logger.Error("could not marshal seed report", "err", err.Error())
+					w.WriteHeader(http.StatusInternalServerError)
+					return
+				}
+
+				status := http.StatusOK
+				if len(report.Errors) > 0 {
+					status = http.StatusUnprocessableEntity
+				}
+
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(status)
+				w.Write(b)
+				return
+			}
+
+			w.WriteHeader(http.StatusCreated)
+			return
+		}
+
+		if isYAML(r.Header.Get("Content-Type")) {
+			ctx, span := tracer.Start(r.Context(), "seed-inventory")
+			defer func() { tracing.RecordAnyErrorAndEndSpan(err, span) }()
+			_, ctx, logger := o11y.AddTraceIDToLoggerAndStoreInContext(span, log, ctx)
+
+			tenants := auth.GetAllowedTenantsFromContext(ctx)
+			if len(tenants) == 0 {
+				w.WriteHeader(http.StatusForbidden)
+				return
+			}
+
+			err = a.SeedInventory(ctx, r.Body, tenants)
+			if err != nil && errors.Is(err, app.ErrForbiddenTenant) {
+				w.WriteHeader(http.StatusForbidden)
+				w.Write([]byte(err.Error()))
+				return
+			}
+			if err != nil && isInvalidSeed(err) {
+				w.WriteHeader(http.StatusBadRequest)
+				w.Write([]byte(err.Error()))
+				return
+			}
+			if err != nil {
+				logger.Error("could not seed inventory", "err", err.Error())
+				w.WriteHeader(http.StatusInternalServerError)
+				w.Write([]byte(err.Error()))
+				return
+			}
+
 			w.WriteHeader(http.StatusCreated)
 			return
 		}
@@ -334,7 +715,7 @@ func addHandler(log *slog.Logger, a app.ThingsApp) http.HandlerFunc {
 		defer func() { tracing.RecordAnyErrorAndEndSpan(err, span) }()
 		_, ctx, logger := o11y.AddTraceIDToLoggerAndStoreInContext(span, log, ctx)
 
-		b, err := io.ReadAll(r.Body)
+		b, err := readBody(r)
 		if err != nil {
 			logger.Error("could not read body", "err", err.Error())
 			w.WriteHeader(http.StatusBadRequest)
@@ -347,6 +728,17 @@ func addHandler(log *slog.Logger, a app.ThingsApp) http.HandlerFunc {
 			w.WriteHeader(http.StatusConflict)
 			return
 		}
+		if err != nil && (errors.Is(err, app.ErrMissingArgs) || errors.Is(err, app.ErrInvalidArgs)) {
+			logger.Debug("thing has missing or invalid args", "err", err.Error())
+			w.WriteHeader(http.StatusUnprocessableEntity)
+			w.Write([]byte(err.Error()))
+			return
+		}
+		if err != nil && errors.Is(err, app.ErrInvalidStatus) {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(err.Error()))
+			return
+		}
 		if err != nil {
 			logger.Error("could not create thing", "err", err.Error())
 			w.WriteHeader(http.StatusInternalServerError)
@@ -369,16 +761,73 @@ func updateHandler(log *slog.Logger, a app.ThingsApp) http.HandlerFunc {
 
 		w.Header().Set("Content-Type", "application/vnd.api+json")
 
-		b, err := io.ReadAll(r.Body)
+		thingId := chi.URLParam(r, "id")
+		if thingId == "" {
+			logger.Error("no id parameter found in request")
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		b, err := readBody(r)
 		if err != nil {
 			logger.Error("could not read body", "err", err.Error())
 			w.WriteHeader(http.StatusBadRequest)
 			return
 		}
 
+		// the thing in the body must be the one in the path, since a PUT may create it
+		body := struct {
+			ID string `json:"id"`
+		}{}
+		if json.Unmarshal(b, &body) == nil && body.ID != thingId {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte("id in body does not match id in path"))
+			return
+		}
+
 		tenants := auth.GetAllowedTenantsFromContext(ctx)
 
-		err = a.UpdateThing(ctx, b, tenants)
+		ctx, ok := withIfMatch(ctx, r)
+		if !ok {
+			w.WriteHeader(http.StatusPreconditionFailed)
+			return
+		}
+
+		// a PUT without If-Match creates the thing if it does not exist, with If-Match it must exist
+		created := false
+		if r.Header.Get("If-Match") == "" {
+			created, err = a.CreateOrUpdateThing(ctx, b, tenants)
+		} else {
+			err = a.UpdateThing(ctx, b, tenants)
+		}
+		if err != nil && errors.Is(err, app.ErrThingNotFound) {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		if err != nil && errors.Is(err, app.ErrAlreadyExists) {
+			w.WriteHeader(http.StatusConflict)
+			return
+		}
+		if err != nil && errors.Is(err, app.ErrForbiddenTenant) {
+			w.WriteHeader(http.StatusForbidden)
+			w.Write([]byte(err.Error()))
+			return
+		}
+		if err != nil && errors.Is(err, app.ErrPreconditionFailed) {
+			w.WriteHeader(http.StatusPreconditionFailed)
+			return
+		}
+		if err != nil && (errors.Is(err, app.ErrMissingArgs) || errors.Is(err, app.ErrInvalidArgs)) {
+			logger.Debug("thing has missing or invalid args", "err", err.Error())
+			w.WriteHeader(http.StatusUnprocessableEntity)
+			w.Write([]byte(err.Error()))
+			return
+		}
+		if err != nil && isInvalidThing(err) {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(err.Error()))
+			return
+		}
 		if err != nil {
 			logger.Error("could not update thing", "err", err.Error())
 			w.WriteHeader(http.StatusInternalServerError)
@@ -386,10 +835,44 @@ func updateHandler(log *slog.Logger, a app.ThingsApp) http.HandlerFunc {
 			return
 		}
 
+		if created {
+			w.WriteHeader(http.StatusCreated)
+			return
+		}
+
 		w.WriteHeader(http.StatusOK)
 	}
 }
 
+// isInvalidThing reports whether err is caused by the thing in the request, rather than by a failure to store it
+func isInvalidThing(err error) bool {
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	var timeErr *time.ParseError
+
+	return errors.Is(err, app.ErrMissingThingID) ||
+		errors.Is(err, app.ErrMissingThingType) ||
+		errors.Is(err, app.ErrMissingThingTenant) ||
+		errors.Is(err, app.ErrInvalidStatus) ||
+		errors.Is(err, things.ErrUnknownType) ||
+		errors.As(err, &syntaxErr) ||
+		errors.As(err, &typeErr) ||
+		errors.As(err, &timeErr)
+}
+
+// isInvalidSeed reports whether a seed failed because of an invalid thing or a malformed seed file
+func isInvalidSeed(err error) bool {
+	var csvErr *csv.ParseError
+	var yamlErr *yaml.TypeError
+
+	return isInvalidThing(err) ||
+		errors.Is(err, app.ErrMissingArgs) ||
+		errors.Is(err, app.ErrInvalidArgs) ||
+		errors.Is(err, app.ErrInvalidRelation) ||
+		errors.As(err, &csvErr) ||
+		errors.As(err, &yamlErr)
+}
+
 func patchHandler(log *slog.Logger, a app.ThingsApp) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		var err error
@@ -418,69 +901,1313 @@ func patchHandler(log *slog.Logger, a app.ThingsApp) http.HandlerFunc {
 
 		tenants := auth.GetAllowedTenantsFromContext(ctx)
 
+		ctx, ok := withIfMatch(ctx, r)
+		if !ok {
+			w.WriteHeader(http.StatusPreconditionFailed)
+			return
+		}
+
 		err = a.MergeThing(ctx, thingId, b, tenants)
-		if err != nil {
-			logger.Error("could not patch thing", "err", err.Error())
+		if err != nil && errors.Is(err, app.ErrThingNotFound) {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		if err != nil && errors.Is(err, app.ErrPreconditionFailed) {
+			w.WriteHeader(http.StatusPreconditionFailed)
+			return
+		}
+		if err != nil && isInvalidThing(err) {
 			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(err.Error()))
 			return
 		}
-
+		if err != nil {
+			logger.Error("could not patch thing", "err", err.Error())
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+	}
+}
+
+func bulkPatchHandler(log *slog.Logger, a app.ThingsApp) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var err error
+		defer r.Body.Close()
+
+		ctx, span := tracer.Start(r.Context(), "bulk-patch-things")
+		defer func() { tracing.RecordAnyErrorAndEndSpan(err, span) }()
+		_, ctx, logger := o11y.AddTraceIDToLoggerAndStoreInContext(span, log, ctx)
+
+		w.Header().Set("Content-Type", "application/vnd.api+json")
+
+		b, err := io.ReadAll(r.Body)
+		if err != nil {
+			logger.Error("could not read body", "err", err.Error())
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte(err.Error()))
+			return
+		}
+
+		tenants := auth.GetAllowedTenantsFromContext(ctx)
+
+		n, err := a.MergeThings(ctx, r.URL.Query(), b, tenants)
+		if err != nil && errors.Is(err, app.ErrForbiddenTenant) {
+			w.WriteHeader(http.StatusForbidden)
+			w.Write([]byte(err.Error()))
+			return
+		}
+		if err != nil && (errors.Is(err, app.ErrInvalidParams) || errors.Is(err, app.ErrMissingConfirmation)) {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(err.Error()))
+			return
+		}
+		if err != nil {
+			logger.Error("could not patch things", "err", err.Error())
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		response := ApiResponse{
+			Data: map[string]int{
+				"count": n,
+			},
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		w.Write(response.Byte())
+	}
+}
+
+func cloneHandler(log *slog.Logger, a app.ThingsApp) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var err error
+		defer r.Body.Close()
+
+		ctx, span := tracer.Start(r.Context(), "clone-thing")
+		defer func() { tracing.RecordAnyErrorAndEndSpan(err, span) }()
+		_, ctx, logger := o11y.AddTraceIDToLoggerAndStoreInContext(span, log, ctx)
+
+		w.Header().Set("Content-Type", "application/vnd.api+json")
+
+		thingId := chi.URLParam(r, "id")
+		if thingId == "" {
+			logger.Error("no id parameter found in request")
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		b, err := io.ReadAll(r.Body)
+		if err != nil {
+			logger.Error("could not read body", "err", err.Error())
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(err.Error()))
+			return
+		}
+
+		tenants := auth.GetAllowedTenantsFromContext(ctx)
+
+		clone, err := a.CloneThing(ctx, thingId, b, tenants)
+		if err != nil && errors.Is(err, app.ErrThingNotFound) {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		if err != nil && errors.Is(err, app.ErrAlreadyExists) {
+			w.WriteHeader(http.StatusConflict)
+			return
+		}
+		if err != nil && (errors.Is(err, app.ErrMissingArgs) || errors.Is(err, app.ErrInvalidArgs)) {
+			w.WriteHeader(http.StatusUnprocessableEntity)
+			w.Write([]byte(err.Error()))
+			return
+		}
+		if err != nil {
+			logger.Error("could not clone thing", "err", err.Error())
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(err.Error()))
+			return
+		}
+
+		thing := make(map[string]any)
+		err = json.Unmarshal(clone.Byte(), &thing)
+		if err != nil {
+			logger.Error("could not unmarshal cloned thing", "err", err.Error())
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		mapToOutModel(thing, false)
+
+		response := ApiResponse{
+			Data: thing,
+		}
+
+		w.Header().Set("Location", fmt.Sprintf("/api/v0/things/%s", clone.ID()))
+		w.WriteHeader(http.StatusCreated)
+		w.Write(response.Byte())
+	}
+}
+
+// addRelationHandler adds the thing in the body as a child of the thing in the path
+func addRelationHandler(log *slog.Logger, a app.ThingsApp) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var err error
+		defer r.Body.Close()
+
+		ctx, span := tracer.Start(r.Context(), "add-thing-relation")
+		defer func() { tracing.RecordAnyErrorAndEndSpan(err, span) }()
+		_, ctx, logger := o11y.AddTraceIDToLoggerAndStoreInContext(span, log, ctx)
+
+		thingId := chi.URLParam(r, "id")
+		if thingId == "" {
+			logger.Error("no id parameter found in request")
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		b, err := readBody(r)
+		if err != nil {
+			logger.Error("could not read body", "err", err.Error())
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		tenants := auth.GetAllowedTenantsFromContext(ctx)
+
+		err = a.AddRelatedThing(ctx, thingId, b, tenants)
+		if err != nil && errors.Is(err, app.ErrThingNotFound) {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		if err != nil && (errors.Is(err, app.ErrInvalidRelation) || errors.Is(err, app.ErrMissingThingID) || isInvalidThing(err)) {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(err.Error()))
+			return
+		}
+		if err != nil {
+			logger.Error("could not add relation", "err", err.Error())
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte(err.Error()))
+			return
+		}
+
+		w.WriteHeader(http.StatusCreated)
+	}
+}
+
+func getRelationsHandler(log *slog.Logger, a app.ThingsApp) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var err error
+
+		ctx, span := tracer.Start(r.Context(), "get-thing-relations")
+		defer func() { tracing.RecordAnyErrorAndEndSpan(err, span) }()
+		_, ctx, logger := o11y.AddTraceIDToLoggerAndStoreInContext(span, log, ctx)
+
+		thingId := chi.URLParam(r, "id")
+		if thingId == "" {
+			logger.Error("no id parameter found in request")
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		tenants := auth.GetAllowedTenantsFromContext(ctx)
+
+		relations, err := a.GetRelatedThings(ctx, thingId, tenants)
+		if err != nil && errors.Is(err, app.ErrThingNotFound) {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			logger.Error("could not get relations", "err", err.Error())
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte(err.Error()))
+			return
+		}
+
+		response := ApiResponse{
+			Data: relations,
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		w.Write(response.Byte())
+	}
+}
+
+func deleteHandler(log *slog.Logger, a app.ThingsApp) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var err error
+		defer r.Body.Close()
+
+		ctx, span := tracer.Start(r.Context(), "delete-thing")
+		defer func() { tracing.RecordAnyErrorAndEndSpan(err, span) }()
+		_, ctx, logger := o11y.AddTraceIDToLoggerAndStoreInContext(span, log, ctx)
+
+		w.Header().Set("Content-Type", "application/vnd.api+json")
+
+		thingId := chi.URLParam(r, "id")
+		if thingId == "" {
+			logger.Error("no id parameter found in request")
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		tenants := auth.GetAllowedTenantsFromContext(ctx)
+
+		err = a.DeleteThing(ctx, thingId, tenants)
+		if err != nil && errors.Is(err, app.ErrThingNotFound) {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		if err != nil && errors.Is(err, app.ErrMissingThingTenant) {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		if err != nil {
+			logger.Error("could not delete thing", "err", err.Error())
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+	}
+}
+
+// restoreHandler undoes the deletion of a thing
+func restoreHandler(log *slog.Logger, a app.ThingsApp) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var err error
+		defer r.Body.Close()
+
+		ctx, span := tracer.Start(r.Context(), "restore-thing")
+		defer func() { tracing.RecordAnyErrorAndEndSpan(err, span) }()
+		_, ctx, logger := o11y.AddTraceIDToLoggerAndStoreInContext(span, log, ctx)
+
+		w.Header().Set("Content-Type", "application/vnd.api+json")
+
+		thingId := chi.URLParam(r, "id")
+		if thingId == "" {
+			logger.Error("no id parameter found in request")
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		tenants := auth.GetAllowedTenantsFromContext(ctx)
+
+		err = a.RestoreThing(ctx, thingId, tenants)
+		if err != nil && errors.Is(err, app.ErrThingNotFound) {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		if err != nil && errors.Is(err, app.ErrMissingThingTenant) {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		if err != nil {
+			logger.Error("could not restore thing", "err", err.Error())
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+	}
+}
+
+const (
+	maxValuesBodySize int64 = 10 << 20
+	maxValuesCount    int   = 10000
+)
+
+// addValuesHandler adds a JSON array of historical values to a thing, e.g. to backfill values from another system.
+// Bodies larger than maxValuesBodySize, or with more than maxValuesCount values, are rejected with 413.
+func addValuesHandler(log *slog.Logger, a app.ThingsApp) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var err error
+		defer r.Body.Close()
+
+		ctx, span := tracer.Start(r.Context(), "add-thing-values")
+		defer func() { tracing.RecordAnyErrorAndEndSpan(err, span) }()
+		_, ctx, logger := o11y.AddTraceIDToLoggerAndStoreInContext(span, log, ctx)
+
+		thingId := chi.URLParam(r, "id")
+		if thingId == "" {
+			logger.Error("no id parameter found in request")
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		r.Body = http.MaxBytesReader(w, r.Body, maxValuesBodySize)
+
+		b, err := readBody(r)
+		var maxBytesErr *http.MaxBytesError
+		if err != nil && errors.As(err, &maxBytesErr) {
+			w.WriteHeader(http.StatusRequestEntityTooLarge)
+			w.Write([]byte(fmt.Sprintf("body may be at most %d bytes", maxValuesBodySize)))
+			return
+		}
+		if err != nil {
+			logger.Error("could not read body", "err", err.Error())
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		values := []things.Value{}
+		err = json.Unmarshal(b, &values)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(err.Error()))
+			return
+		}
+
+		if len(values) > maxValuesCount {
+			w.WriteHeader(http.StatusRequestEntityTooLarge)
+			w.Write([]byte(fmt.Sprintf("at most %d values may be added at once", maxValuesCount)))
+			return
+		}
+
+		tenants := auth.GetAllowedTenantsFromContext(ctx)
+
+		n, err := a.AddValues(ctx, thingId, values, tenants)
+		if err != nil && errors.Is(err, app.ErrThingNotFound) {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		if err != nil && errors.Is(err, app.ErrInvalidValue) {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(err.Error()))
+			return
+		}
+		if err != nil {
+			logger.Error("could not add values", "err", err.Error())
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte(err.Error()))
+			return
+		}
+
+		response := ApiResponse{
+			Data: map[string]int64{
+				"count": n,
+			},
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		w.Write(response.Byte())
+	}
+}
+
+func deleteValuesHandler(log *slog.Logger, a app.ThingsApp) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var err error
+		defer r.Body.Close()
+
+		ctx, span := tracer.Start(r.Context(), "delete-thing-values")
+		defer func() { tracing.RecordAnyErrorAndEndSpan(err, span) }()
+		_, ctx, logger := o11y.AddTraceIDToLoggerAndStoreInContext(span, log, ctx)
+
+		w.Header().Set("Content-Type", "application/vnd.api+json")
+
+		thingId := chi.URLParam(r, "id")
+		if thingId == "" {
+			logger.Error("no id parameter found in request")
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		tenants := auth.GetAllowedTenantsFromContext(ctx)
+
+		n, err := a.DeleteValues(ctx, thingId, r.URL.Query(), tenants)
+		if err != nil && errors.Is(err, app.ErrThingNotFound) {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		if err != nil && errors.Is(err, app.ErrInvalidParams) {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(err.Error()))
+			return
+		}
+		if err != nil {
+			logger.Error("could not delete values", "err", err.Error())
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte(err.Error()))
+			return
+		}
+
+		response := ApiResponse{
+			Data: map[string]int64{
+				"count": n,
+			},
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		w.Write(response.Byte())
+	}
+}
+
+func getUrnsHandler(log *slog.Logger, a app.ThingsApp) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var err error
+
+		ctx, span := tracer.Start(r.Context(), "get-thing-urns")
+		defer func() { tracing.RecordAnyErrorAndEndSpan(err, span) }()
+		_, ctx, logger := o11y.AddTraceIDToLoggerAndStoreInContext(span, log, ctx)
+
+		thingId := chi.URLParam(r, "id")
+		if thingId == "" {
+			logger.Error("no id parameter found in request")
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		tenants := auth.GetAllowedTenantsFromContext(ctx)
+
+		present, expected, err := a.GetUrns(ctx, thingId, tenants)
+		if err != nil && errors.Is(err, app.ErrThingNotFound) {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			logger.Error("could not get urns", "err", err.Error())
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte(err.Error()))
+			return
+		}
+
+		if expected == nil {
+			expected = []string{}
+		}
+
+		response := ApiResponse{
+			Data: map[string][]string{
+				"present":  present,
+				"expected": expected,
+			},
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		w.Write(response.Byte())
+	}
+}
+
+func getValueRangeHandler(log *slog.Logger, a app.ThingsApp) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var err error
+
+		ctx, span := tracer.Start(r.Context(), "get-value-range")
+		defer func() { tracing.RecordAnyErrorAndEndSpan(err, span) }()
+		_, ctx, logger := o11y.AddTraceIDToLoggerAndStoreInContext(span, log, ctx)
+
+		thingId := chi.URLParam(r, "id")
+		if thingId == "" {
+			logger.Error("no id parameter found in request")
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		tenants := auth.GetAllowedTenantsFromContext(ctx)
+
+		valueRange, err := a.GetValueRange(ctx, thingId, tenants)
+		if err != nil && errors.Is(err, app.ErrThingNotFound) {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			logger.Error("could not get value range", "err", err.Error())
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte(err.Error()))
+			return
+		}
+
+		response := ApiResponse{
+			Data: valueRange,
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		w.Write(response.Byte())
+	}
+}
+
+func getTagsHandler(log *slog.Logger, a app.ThingsApp) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var err error
+
+		ctx, span := tracer.Start(r.Context(), "get-tags")
+		defer func() { tracing.RecordAnyErrorAndEndSpan(err, span) }()
+		_, ctx, logger := o11y.AddTraceIDToLoggerAndStoreInContext(span, log, ctx)
+
+		w.Header().Set("Content-Type", "application/vnd.api+json")
+
+		tenants := auth.GetAllowedTenantsFromContext(ctx)
+
+		tags, err := a.GetTags(ctx, tenants)
+		if err != nil {
+			logger.Error("could not get tags", "err", err.Error())
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte(err.Error()))
+			return
+		}
+
+		response := NewApiResponse(r, tags, uint64(len(tags)), uint64(len(tags)), 0, uint64(len(tags)))
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		w.Write(response.Byte())
+	}
+}
+
+func getTypesHandler(log *slog.Logger, a app.ThingsApp) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var err error
+
+		ctx, span := tracer.Start(r.Context(), "get-types")
+		defer func() { tracing.RecordAnyErrorAndEndSpan(err, span) }()
+		_, ctx, logger := o11y.AddTraceIDToLoggerAndStoreInContext(span, log, ctx)
+
+		tenants := auth.GetAllowedTenantsFromContext(ctx)
+
+		types, err := a.GetTypes(ctx, tenants)
+		if err != nil {
+			logger.Error("could not get tags", "err", err.Error())
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte(err.Error()))
+			return
+		}
+
+		response := NewApiResponse(r, types, uint64(len(types)), uint64(len(types)), 0, uint64(len(types)))
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		w.Write(response.Byte())
+	}
+}
+
+func getAttentionHandler(log *slog.Logger, a app.ThingsApp) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var err error
+
+		ctx, span := tracer.Start(r.Context(), "get-things-needing-attention")
+		defer func() { tracing.RecordAnyErrorAndEndSpan(err, span) }()
+		_, ctx, logger := o11y.AddTraceIDToLoggerAndStoreInContext(span, log, ctx)
+
+		tenants := auth.GetAllowedTenantsFromContext(ctx)
+
+		attention, err := a.GetThingsNeedingAttention(ctx, tenants)
+		if err != nil {
+			logger.Error("could not get things needing attention", "err", err.Error())
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte(err.Error()))
+			return
+		}
+
+		response := NewApiResponse(r, attention, uint64(len(attention)), uint64(len(attention)), 0, uint64(len(attention)))
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		w.Write(response.Byte())
+	}
+}
+
+func getUnitsHandler(log *slog.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var err error
+
+		_, span := tracer.Start(r.Context(), "get-units")
+		defer func() { tracing.RecordAnyErrorAndEndSpan(err, span) }()
+
+		units := things.Units()
+
+		response := NewApiResponse(r, units, uint64(len(units)), uint64(len(units)), 0, uint64(len(units)))
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		w.Write(response.Byte())
+	}
+}
+
+func getValuesHandler(log *slog.Logger, a app.ThingsApp) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var err error
+
+		ctx, span := tracer.Start(r.Context(), "query-things-values")
+		defer func() { tracing.RecordAnyErrorAndEndSpan(err, span) }()
+		_, ctx, logger := o11y.AddTraceIDToLoggerAndStoreInContext(span, log, ctx)
+
+		w.Header().Set("Content-Type", "application/vnd.api+json")
+
+		tenants := auth.GetAllowedTenantsFromContext(ctx)
+
+		result, err := a.ListValues(ctx, r.URL.Query(), tenants)
+		if err != nil && errors.Is(err, app.ErrThingNotFound) {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		if err != nil && errors.Is(err, app.ErrForbiddenTenant) {
+			w.WriteHeader(http.StatusForbidden)
+			w.Write([]byte(err.Error()))
+			return
+		}
+		if err != nil && (errors.Is(err, app.ErrTimeRangeExceeded) || errors.Is(err, app.ErrInvalidParams)) {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(err.Error()))
+			return
+		}
+		if err != nil {
+			logger.Error("could not query for values", "err", err.Error())
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte(err.Error()))
+			return
+		}
+
+		if r.Header.Get("Accept") == "text/csv" {
+			err := exportValuesAsCSV(result, w)
+			if err != nil {
+				logger.Error("could not export values as CSV", "err", err.Error())
+				w.WriteHeader(http.StatusInternalServerError)
+				w.Write([]byte(err.Error()))
+				return
+			}
+
+			w.Header().Set("Content-Type", "text/csv")
+			w.WriteHeader(http.StatusOK)
+
+			return
+		}
+
+		if result.Count == 0 {
+			w.WriteHeader(http.StatusOK)
+			w.Write([]byte("[]"))
+			return
+		}
+
+		data := transformValues(r, result.Data)
+
+		u := *r.URL
+		response := NewApiResponse(r, data, uint64(result.Count), uint64(result.TotalCount), uint64(result.Offset), uint64(result.Limit))
+		if result.NextCursor != "" || u.Query().Has("cursor") {
+			response.Links = withCursor(u, response.Links, result.NextCursor)
+		}
+
+		b, err := json.Marshal(response)
+		if err != nil {
+			logger.Error("could not marshal query response", "err", err.Error())
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte(err.Error()))
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/vnd.api+json")
+		w.WriteHeader(http.StatusOK)
+		w.Write(b)
+	}
+}
+
+func getRecentValuesHandler(log *slog.Logger, a app.ThingsApp) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var err error
+
+		ctx, span := tracer.Start(r.Context(), "get-recent-values")
+		defer func() { tracing.RecordAnyErrorAndEndSpan(err, span) }()
+		_, ctx, logger := o11y.AddTraceIDToLoggerAndStoreInContext(span, log, ctx)
+
+		thingId := chi.URLParam(r, "id")
+		if thingId == "" {
+			logger.Error("no id parameter found in request")
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		n := 0
+		if s := r.URL.Query().Get("n"); s != "" {
+			n, err = strconv.Atoi(s)
+			if err != nil || n <= 0 {
+				w.WriteHeader(http.StatusBadRequest)
+				w.Write([]byte("n must be a positive integer"))
+				return
+			}
+		}
+
+		tenants := auth.GetAllowedTenantsFromContext(ctx)
+
+		result, err := a.GetRecentValues(ctx, thingId, n, r.URL.Query()["urn"], tenants)
+		if err != nil && errors.Is(err, app.ErrThingNotFound) {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			logger.Error("could not get recent values", "err", err.Error())
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte(err.Error()))
+			return
+		}
+
+		data := transformValues(r, result.Data)
+
+		response := NewApiResponse(r, data, uint64(result.Count), uint64(result.TotalCount), uint64(result.Offset), uint64(result.Limit))
+
+		w.Header().Set("Content-Type", "application/vnd.api+json")
+		w.WriteHeader(http.StatusOK)
+		w.Write(response.Byte())
+	}
+}
+
+// getLatestValuesHandler returns the latest value of each measurement of a thing, e.g. for a snapshot of its sensors
+func getLatestValuesHandler(log *slog.Logger, a app.ThingsApp) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var err error
+
+		ctx, span := tracer.Start(r.Context(), "get-latest-values")
+		defer func() { tracing.RecordAnyErrorAndEndSpan(err, span) }()
+		_, ctx, logger := o11y.AddTraceIDToLoggerAndStoreInContext(span, log, ctx)
+
+		thingId := chi.URLParam(r, "id")
+		if thingId == "" {
+			logger.Error("no id parameter found in request")
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		tenants := auth.GetAllowedTenantsFromContext(ctx)
+
+		result, err := a.GetLatestValues(ctx, thingId, tenants)
+		if err != nil && errors.Is(err, app.ErrThingNotFound) {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			logger.Error("could not get latest values", "err", err.Error())
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte(err.Error()))
+			return
+		}
+
+		if r.Header.Get("Accept") == "text/csv" {
+			w.Header().Set("Content-Type", "text/csv")
+			w.WriteHeader(http.StatusOK)
+
+			err = exportValuesAsCSV(result, w)
+			if err != nil {
+				logger.Error("could not export values as CSV", "err", err.Error())
+			}
+			return
+		}
+
+		data := transformValues(r, result.Data)
+
+		response := NewApiResponse(r, data, uint64(result.Count), uint64(result.TotalCount), 0, uint64(result.Count))
+
+		w.Header().Set("Content-Type", "application/vnd.api+json")
+		w.WriteHeader(http.StatusOK)
+		w.Write(response.Byte())
+	}
+}
+
+func getUtilizationHandler(log *slog.Logger, a app.ThingsApp) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var err error
+
+		ctx, span := tracer.Start(r.Context(), "get-utilization")
+		defer func() { tracing.RecordAnyErrorAndEndSpan(err, span) }()
+		_, ctx, logger := o11y.AddTraceIDToLoggerAndStoreInContext(span, log, ctx)
+
+		thingId := chi.URLParam(r, "id")
+		if thingId == "" {
+			logger.Error("no id parameter found in request")
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		to := time.Now().UTC()
+		if s := r.URL.Query().Get("to"); s != "" {
+			to, err = time.Parse(time.RFC3339, s)
+			if err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				w.Write([]byte("to must be a RFC3339 timestamp"))
+				return
+			}
+		}
+
+		from := to.Add(-24 * time.Hour)
+		if s := r.URL.Query().Get("from"); s != "" {
+			from, err = time.Parse(time.RFC3339, s)
+			if err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				w.Write([]byte("from must be a RFC3339 timestamp"))
+				return
+			}
+		}
+
+		if !from.Before(to) {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte("from must be before to"))
+			return
+		}
+
+		tenants := auth.GetAllowedTenantsFromContext(ctx)
+
+		u, err := a.GetUtilization(ctx, thingId, from, to, tenants)
+		if err != nil && errors.Is(err, app.ErrThingNotFound) {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		if err != nil && errors.Is(err, app.ErrTimeRangeExceeded) {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(err.Error()))
+			return
+		}
+		if err != nil {
+			logger.Error("could not get utilization", "err", err.Error())
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte(err.Error()))
+			return
+		}
+
+		response := ApiResponse{
+			Data: u,
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		w.Write(response.Byte())
+	}
+}
+
+func getCompletenessHandler(log *slog.Logger, a app.ThingsApp) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var err error
+
+		ctx, span := tracer.Start(r.Context(), "get-completeness")
+		defer func() { tracing.RecordAnyErrorAndEndSpan(err, span) }()
+		_, ctx, logger := o11y.AddTraceIDToLoggerAndStoreInContext(span, log, ctx)
+
+		thingId := chi.URLParam(r, "id")
+		if thingId == "" {
+			logger.Error("no id parameter found in request")
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		interval, err := time.ParseDuration(r.URL.Query().Get("expectedInterval"))
+		if err != nil || interval < time.Millisecond {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte("expectedInterval must be a duration of at least 1ms, e.g. 15m"))
+			return
+		}
+
+		to := time.Now().UTC()
+		if s := r.URL.Query().Get("to"); s != "" {
+			to, err = time.Parse(time.RFC3339, s)
+			if err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				w.Write([]byte("to must be a RFC3339 timestamp"))
+				return
+			}
+		}
+
+		from := to.Add(-24 * time.Hour)
+		if s := r.URL.Query().Get("from"); s != "" {
+			from, err = time.Parse(time.RFC3339, s)
+			if err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				w.Write([]byte("from must be a RFC3339 timestamp"))
+				return
+			}
+		}
+
+		if !from.Before(to) {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte("from must be before to"))
+			return
+		}
+
+		tenants := auth.GetAllowedTenantsFromContext(ctx)
+
+		c, err := a.GetCompleteness(ctx, thingId, from, to, interval, tenants)
+		if err != nil && errors.Is(err, app.ErrThingNotFound) {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		if err != nil && (errors.Is(err, app.ErrTimeRangeExceeded) || errors.Is(err, app.ErrInvalidParams)) {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(err.Error()))
+			return
+		}
+		if err != nil {
+			logger.Error("could not get completeness", "err", err.Error())
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte(err.Error()))
+			return
+		}
+
+		response := ApiResponse{
+			Data: c,
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		w.Write(response.Byte())
+	}
+}
+
+func getTimelineHandler(log *slog.Logger, a app.ThingsApp) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var err error
+
+		ctx, span := tracer.Start(r.Context(), "get-timeline")
+		defer func() { tracing.RecordAnyErrorAndEndSpan(err, span) }()
+		_, ctx, logger := o11y.AddTraceIDToLoggerAndStoreInContext(span, log, ctx)
+
+		thingId := chi.URLParam(r, "id")
+		if thingId == "" {
+			logger.Error("no id parameter found in request")
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		to := time.Now().UTC()
+		if s := r.URL.Query().Get("to"); s != "" {
+			to, err = time.Parse(time.RFC3339, s)
+			if err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				w.Write([]byte("to must be a RFC3339 timestamp"))
+				return
+			}
+		}
+
+		from := to.Add(-24 * time.Hour)
+		if s := r.URL.Query().Get("from"); s != "" {
+			from, err = time.Parse(time.RFC3339, s)
+			if err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				w.Write([]byte("from must be a RFC3339 timestamp"))
+				return
+			}
+		}
+
+		if !from.Before(to) {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte("from must be before to"))
+			return
+		}
+
+		tenants := auth.GetAllowedTenantsFromContext(ctx)
+
+		intervals, err := a.GetTimeline(ctx, thingId, from, to, tenants)
+		if err != nil && errors.Is(err, app.ErrThingNotFound) {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		if err != nil && errors.Is(err, app.ErrTimeRangeExceeded) {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(err.Error()))
+			return
+		}
+		if err != nil {
+			logger.Error("could not get timeline", "err", err.Error())
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte(err.Error()))
+			return
+		}
+
+		if r.Header.Get("Accept") == "text/csv" {
+			b := &bytes.Buffer{}
+			err = exportTimelineAsCSV(intervals, b)
+			if err != nil {
+				logger.Error("could not export timeline as CSV", "err", err.Error())
+				w.WriteHeader(http.StatusInternalServerError)
+				w.Write([]byte(err.Error()))
+				return
+			}
+
+			w.Header().Set("Content-Type", "text/csv")
+			w.WriteHeader(http.StatusOK)
+			w.Write(b.Bytes())
+			return
+		}
+
+		response := ApiResponse{
+			Data: intervals,
+		}
+
+		w.Header().Set("Content-Type", "application/json")
 		w.WriteHeader(http.StatusOK)
+		w.Write(response.Byte())
+	}
+}
+
+var timelineCSVColumns = []string{"id", "urn", "state", "start", "end", "duration"}
+
+func exportTimelineAsCSV(intervals []app.StateInterval, w io.Writer) error {
+	cw := newCSVWriter(w)
+
+	err := cw.Write(timelineCSVColumns)
+	if err != nil {
+		return err
+	}
+
+	for _, i := range intervals {
+		end := ""
+		if i.End != nil {
+			end = i.End.Format(time.RFC3339)
+		}
+
+		err = cw.Write([]string{
+			i.ID,
+			i.Urn,
+			strconv.FormatBool(i.State),
+			i.Start.Format(time.RFC3339),
+			end,
+			strconv.FormatFloat(i.Duration, 'f', -1, 64),
+		})
+		if err != nil {
+			return err
+		}
 	}
+
+	cw.Flush()
+	return cw.Error()
 }
 
-func deleteHandler(log *slog.Logger, a app.ThingsApp) http.HandlerFunc {
+func getCountHandler(log *slog.Logger, a app.ThingsApp) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		var err error
-		defer r.Body.Close()
 
-		ctx, span := tracer.Start(r.Context(), "delete-thing")
+		ctx, span := tracer.Start(r.Context(), "count-things")
 		defer func() { tracing.RecordAnyErrorAndEndSpan(err, span) }()
 		_, ctx, logger := o11y.AddTraceIDToLoggerAndStoreInContext(span, log, ctx)
 
-		w.Header().Set("Content-Type", "application/vnd.api+json")
+		tenants := auth.GetAllowedTenantsFromContext(ctx)
 
-		thingId := chi.URLParam(r, "id")
-		if thingId == "" {
-			logger.Error("no id parameter found in request")
+		counts, err := a.CountThings(ctx, tenants)
+		if err != nil && errors.Is(err, app.ErrMissingThingTenant) {
 			w.WriteHeader(http.StatusBadRequest)
 			return
 		}
+		if err != nil {
+			logger.Error("could not count things", "err", err.Error())
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte(err.Error()))
+			return
+		}
 
-		tenants := auth.GetAllowedTenantsFromContext(ctx)
+		response := ApiResponse{
+			Data: counts,
+		}
 
-		err = a.DeleteThing(ctx, thingId, tenants)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		w.Write(response.Byte())
+	}
+}
+
+func getTenantsHandler(log *slog.Logger, a app.ThingsApp) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var err error
+
+		ctx, span := tracer.Start(r.Context(), "get-tenants")
+		defer func() { tracing.RecordAnyErrorAndEndSpan(err, span) }()
+		_, ctx, logger := o11y.AddTraceIDToLoggerAndStoreInContext(span, log, ctx)
+
+		tenants, err := a.GetTenants(ctx)
 		if err != nil {
-			logger.Error("could not delete thing", "err", err.Error())
-			w.WriteHeader(http.StatusBadRequest)
+			logger.Error("could not get tenants", "err", err.Error())
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte(err.Error()))
 			return
 		}
 
+		response := ApiResponse{
+			Data: tenants,
+		}
+
+		w.Header().Set("Content-Type", "application/json")
 		w.WriteHeader(http.StatusOK)
+		w.Write(response.Byte())
 	}
 }
 
-func getTagsHandler(log *slog.Logger, a app.ThingsApp) http.HandlerFunc {
+const maxBatchSize int = 100
+
+// batchHandler fetches a number of things and their values in one round trip. Each request is
+// scoped to the tenants of the caller and failures are reported per thing.
+func batchHandler(log *slog.Logger, a app.ThingsApp) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		var err error
 
-		ctx, span := tracer.Start(r.Context(), "get-tags")
+		ctx, span := tracer.Start(r.Context(), "batch")
 		defer func() { tracing.RecordAnyErrorAndEndSpan(err, span) }()
 		_, ctx, logger := o11y.AddTraceIDToLoggerAndStoreInContext(span, log, ctx)
 
-		w.Header().Set("Content-Type", "application/vnd.api+json")
+		defer r.Body.Close()
+
+		requests := []BatchRequest{}
+		err = json.NewDecoder(r.Body).Decode(&requests)
+		if err != nil {
+			logger.Error("could not decode batch request", "err", err.Error())
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		if len(requests) > maxBatchSize {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(fmt.Sprintf("a batch may contain at most %d requests", maxBatchSize)))
+			return
+		}
 
 		tenants := auth.GetAllowedTenantsFromContext(ctx)
 
-		tags, err := a.GetTags(ctx, tenants)
+		results := make(map[string]BatchResult, len(requests))
+		for _, req := range requests {
+			if req.ThingID == "" {
+				continue
+			}
+			results[req.ThingID] = batch(ctx, a, req, tenants)
+		}
+
+		response := ApiResponse{
+			Data: results,
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		w.Write(response.Byte())
+	}
+}
+
+func batch(ctx context.Context, a app.ThingsApp, req BatchRequest, tenants []string) BatchResult {
+	result, err := a.QueryThings(ctx, map[string][]string{"id": {req.ThingID}}, tenants)
+	if err != nil {
+		return BatchResult{Error: err.Error()}
+	}
+	if result.Count != 1 {
+		return BatchResult{Error: app.ErrThingNotFound.Error()}
+	}
+
+	thing := make(map[string]any)
+	err = json.Unmarshal(result.Data[0], &thing)
+	if err != nil {
+		return BatchResult{Error: err.Error()}
+	}
+	mapToOutModel(thing, false)
+
+	values := make([]json.RawMessage, 0)
+
+	if req.Range == nil {
+		latest, err := a.QueryValues(ctx, map[string][]string{"thingid": {req.ThingID}, "latest": {"true"}}, tenants)
 		if err != nil {
-			logger.Error("could not get tags", "err", err.Error())
-			w.WriteHeader(http.StatusInternalServerError)
+			return BatchResult{Thing: thing, Error: err.Error()}
+		}
+
+		for _, b := range latest.Data {
+			if hasValueName(b, req.ValueNames) {
+				values = append(values, b)
+			}
+		}
+
+		return BatchResult{Thing: thing, Values: values}
+	}
+
+	to := time.Now().UTC()
+	if req.Range.To != nil {
+		to = *req.Range.To
+	}
+
+	params := map[string][]string{
+		"thingid":   {req.ThingID},
+		"timerel":   {"between"},
+		"timeat":    {req.Range.From.Format(time.RFC3339)},
+		"endtimeat": {to.Format(time.RFC3339)},
+	}
+
+	names := req.ValueNames
+	if len(names) == 0 {
+		names = []string{""}
+	}
+
+	for _, n := range names {
+		if n != "" {
+			params["n"] = []string{n}
+		}
+
+		result, err := a.QueryValues(ctx, params, tenants)
+		if err != nil {
+			return BatchResult{Thing: thing, Error: err.Error()}
+		}
+
+		for _, b := range result.Data {
+			values = append(values, b)
+		}
+	}
+
+	return BatchResult{Thing: thing, Values: values}
+}
+
+// hasValueName reports whether the name of the value, i.e. the last part of its id, is one of names
+func hasValueName(b []byte, names []string) bool {
+	if len(names) == 0 {
+		return true
+	}
+
+	v := struct {
+		ID string `json:"id"`
+	}{}
+	if json.Unmarshal(b, &v) != nil {
+		return false
+	}
+
+	return slices.Contains(names, v.ID[strings.LastIndex(v.ID, "/")+1:])
+}
+
+// ingestHandler handles a senml pack the same way as a measurement message and responds with
+// the outcome of each record. Records that could not be handled do not fail the request.
+func ingestHandler(log *slog.Logger, a app.ThingsApp) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var err error
+
+		ctx, span := tracer.Start(r.Context(), "ingest-measurements")
+		defer func() { tracing.RecordAnyErrorAndEndSpan(err, span) }()
+		_, ctx, logger := o11y.AddTraceIDToLoggerAndStoreInContext(span, log, ctx)
+
+		defer r.Body.Close()
+
+		pack := senml.Pack{}
+		err = json.NewDecoder(r.Body).Decode(&pack)
+		if err != nil {
+			logger.Error("could not decode pack", "err", err.Error())
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		err = pack.Validate()
+		if err != nil {
+			logger.Error("invalid pack", "err", err.Error())
+			w.WriteHeader(http.StatusBadRequest)
 			w.Write([]byte(err.Error()))
 			return
 		}
 
-		response := NewApiResponse(r, tags, uint64(len(tags)), uint64(len(tags)), 0, uint64(len(tags)))
+		correlationID := r.Header.Get("X-Correlation-ID")
+		if correlationID == "" {
+			correlationID = uuid.NewString()
+		}
+		ctx = app.WithCorrelationID(ctx, correlationID)
+		w.Header().Set("X-Correlation-ID", correlationID)
+
+		result, err := app.IngestPack(ctx, a, pack)
+		if err != nil {
+			logger.Error("could not ingest pack", "err", err.Error())
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(err.Error()))
+			return
+		}
+
+		response := ApiResponse{
+			Data: result,
+		}
 
 		w.Header().Set("Content-Type", "application/json")
 		w.WriteHeader(http.StatusOK)
@@ -488,25 +2215,28 @@ func getTagsHandler(log *slog.Logger, a app.ThingsApp) http.HandlerFunc {
 	}
 }
 
-func getTypesHandler(log *slog.Logger, a app.ThingsApp) http.HandlerFunc {
+func compactHandler(log *slog.Logger, a app.ThingsApp) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		var err error
 
-		ctx, span := tracer.Start(r.Context(), "get-types")
+		ctx, span := tracer.Start(r.Context(), "compact")
 		defer func() { tracing.RecordAnyErrorAndEndSpan(err, span) }()
 		_, ctx, logger := o11y.AddTraceIDToLoggerAndStoreInContext(span, log, ctx)
 
-		tenants := auth.GetAllowedTenantsFromContext(ctx)
-
-		types, err := a.GetTypes(ctx, tenants)
+		nThings, nValues, err := a.Compact(ctx)
 		if err != nil {
-			logger.Error("could not get tags", "err", err.Error())
+			logger.Error("could not compact things", "err", err.Error())
 			w.WriteHeader(http.StatusInternalServerError)
 			w.Write([]byte(err.Error()))
 			return
 		}
 
-		response := NewApiResponse(r, types, uint64(len(types)), uint64(len(types)), 0, uint64(len(types)))
+		response := ApiResponse{
+			Data: map[string]int64{
+				"things": nThings,
+				"values": nValues,
+			},
+		}
 
 		w.Header().Set("Content-Type", "application/json")
 		w.WriteHeader(http.StatusOK)
@@ -514,85 +2244,128 @@ func getTypesHandler(log *slog.Logger, a app.ThingsApp) http.HandlerFunc {
 	}
 }
 
-func getValuesHandler(log *slog.Logger, a app.ThingsApp) http.HandlerFunc {
+func getDuplicatesHandler(log *slog.Logger, a app.ThingsApp) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		var err error
 
-		ctx, span := tracer.Start(r.Context(), "query-things-values")
+		ctx, span := tracer.Start(r.Context(), "get-duplicates")
 		defer func() { tracing.RecordAnyErrorAndEndSpan(err, span) }()
 		_, ctx, logger := o11y.AddTraceIDToLoggerAndStoreInContext(span, log, ctx)
 
-		w.Header().Set("Content-Type", "application/vnd.api+json")
+		tenants := auth.GetAllowedTenantsFromContext(ctx)
 
-		result, err := a.QueryValues(ctx, r.URL.Query())
+		duplicates, err := a.FindDuplicates(ctx, tenants)
 		if err != nil {
-			logger.Error("could not query for values", "err", err.Error())
+			logger.Error("could not find duplicates", "err", err.Error())
 			w.WriteHeader(http.StatusInternalServerError)
 			w.Write([]byte(err.Error()))
 			return
 		}
 
-		if r.Header.Get("Accept") == "text/csv" {
-			err := exportValuesAsCSV(result, w)
-			if err != nil {
-				logger.Error("could not export values as CSV", "err", err.Error())
-				w.WriteHeader(http.StatusInternalServerError)
-				w.Write([]byte(err.Error()))
-				return
-			}
+		response := NewApiResponse(r, duplicates, uint64(len(duplicates)), uint64(len(duplicates)), 0, uint64(len(duplicates)))
 
-			w.Header().Set("Content-Type", "text/csv")
-			w.WriteHeader(http.StatusOK)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		w.Write(response.Byte())
+	}
+}
 
-			return
-		}
+// MergeRequest asks for the source thing to be merged into the target thing
+type MergeRequest struct {
+	Target string `json:"target"`
+	Source string `json:"source"`
+}
 
-		if result.Count == 0 {
-			w.WriteHeader(http.StatusOK)
-			w.Write([]byte("[]"))
+func mergeHandler(log *slog.Logger, a app.ThingsApp) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var err error
+		defer r.Body.Close()
+
+		ctx, span := tracer.Start(r.Context(), "merge-things")
+		defer func() { tracing.RecordAnyErrorAndEndSpan(err, span) }()
+		_, ctx, logger := o11y.AddTraceIDToLoggerAndStoreInContext(span, log, ctx)
+
+		req := MergeRequest{}
+		err = json.NewDecoder(r.Body).Decode(&req)
+		if err != nil {
+			logger.Error("could not decode merge request", "err", err.Error())
+			w.WriteHeader(http.StatusBadRequest)
 			return
 		}
 
-		data := transformValues(r, result.Data)
-
-		response := NewApiResponse(r, data, uint64(result.Count), uint64(result.TotalCount), uint64(result.Offset), uint64(result.Limit))
+		tenants := auth.GetAllowedTenantsFromContext(ctx)
 
-		b, err := json.Marshal(response)
+		merged, err := a.MergeDuplicate(ctx, req.Target, req.Source, tenants)
+		if err != nil && errors.Is(err, app.ErrThingNotFound) {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		if err != nil && errors.Is(err, app.ErrCannotMerge) {
+			w.WriteHeader(http.StatusConflict)
+			w.Write([]byte(err.Error()))
+			return
+		}
+		if err != nil && errors.Is(err, app.ErrMissingThingID) {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(err.Error()))
+			return
+		}
 		if err != nil {
-			logger.Error("could not marshal query response", "err", err.Error())
+			logger.Error("could not merge things", "err", err.Error())
 			w.WriteHeader(http.StatusInternalServerError)
 			w.Write([]byte(err.Error()))
 			return
 		}
 
-		w.Header().Set("Content-Type", "application/vnd.api+json")
+		thing := make(map[string]any)
+		err = json.Unmarshal(merged.Byte(), &thing)
+		if err != nil {
+			logger.Error("could not unmarshal merged thing", "err", err.Error())
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		mapToOutModel(thing, false)
+
+		response := ApiResponse{
+			Data: thing,
+		}
+
+		w.Header().Set("Content-Type", "application/json")
 		w.WriteHeader(http.StatusOK)
-		w.Write(b)
+		w.Write(response.Byte())
 	}
 }
 
-func exportValuesAsCSV(result app.QueryResult, w io.Writer) error {
-	header := strings.Join([]string{"time", "id", "urn", "v", "vb", "vs", "unit", "ref"}, ";")
+var valuesCSVColumns = []string{"time", "id", "urn", "v", "vb", "vs", "unit", "ref"}
 
-	if result.Count == 0 {
-		w.Write([]byte(header))
-		return nil
+func exportValuesAsCSV(result app.QueryResult, w io.Writer) error {
+	err := writeCSVHeader(valuesCSVColumns, w)
+	if err != nil {
+		return err
 	}
 
-	for i, b := range result.Data {
+	return writeValuesAsCSV(result.Data, w)
+}
+
+func writeCSVHeader(columns []string, w io.Writer) error {
+	cw := newCSVWriter(w)
+	cw.Write(columns)
+	cw.Flush()
+	return cw.Error()
+}
+
+// writeValuesAsCSV writes one row per value, without a header
+func writeValuesAsCSV(data [][]byte, w io.Writer) error {
+	cw := newCSVWriter(w)
+
+	for _, b := range data {
 		m := make(map[string]any)
 		err := json.Unmarshal(b, &m)
 		if err != nil {
 			return err
 		}
 
-		if i == 0 {
-			_, err := w.Write([]byte(fmt.Sprintln(header)))
-			if err != nil {
-				return err
-			}
-		}
-
 		str := func(v any) string {
 			if v == nil {
 				return ""
@@ -611,15 +2384,93 @@ func exportValuesAsCSV(result app.QueryResult, w io.Writer) error {
 			str(m["ref"]),
 		}
 
-		row := strings.Join(values, ";")
-
-		_, err = w.Write([]byte(fmt.Sprintln(row)))
+		err = cw.Write(values)
 		if err != nil {
 			return err
 		}
 	}
 
-	return nil
+	cw.Flush()
+	return cw.Error()
+}
+
+// isNotModified sets the ETag and Last-Modified headers from the query result and reports whether
+// the conditional headers in the request match, i.e. the result has not changed since the client last saw it.
+// The ETag also covers the query parameters, the Accept header and any values included in the response. Values
+// are stored without touching the modification time of their thing, so Last-Modified is only set without values.
+func isNotModified(w http.ResponseWriter, r *http.Request, result app.QueryResult, values [][]byte) bool {
+	if result.LastModified.IsZero() {
+		return false
+	}
+
+	h := fnv.New64a()
+	h.Write([]byte(r.URL.Query().Encode()))
+	h.Write([]byte(r.Header.Get("Accept")))
+	for _, v := range values {
+		h.Write(v)
+	}
+
+	lastModified := result.LastModified.UTC()
+	etag := fmt.Sprintf(`W/"%x-%x-%x"`, lastModified.UnixNano(), result.TotalCount, h.Sum64())
+
+	w.Header().Set("ETag", etag)
+	if len(values) == 0 {
+		w.Header().Set("Last-Modified", lastModified.Format(http.TimeFormat))
+	}
+
+	if ifNoneMatch := r.Header.Get("If-None-Match"); ifNoneMatch != "" {
+		for _, tag := range strings.Split(ifNoneMatch, ",") {
+			tag = strings.TrimSpace(tag)
+			if tag == etag || tag == "*" {
+				return true
+			}
+		}
+		return false
+	}
+
+	if ifModifiedSince := r.Header.Get("If-Modified-Since"); ifModifiedSince != "" && len(values) == 0 {
+		ts, err := http.ParseTime(ifModifiedSince)
+		if err != nil {
+			return false
+		}
+		return !lastModified.Truncate(time.Second).After(ts)
+	}
+
+	return false
+}
+
+// withIfMatch adds the modification time in the ETag of the If-Match header, if any, to ctx so that an
+// update fails if the thing has been modified since. It reports false if the ETag is not one given by this api.
+func withIfMatch(ctx context.Context, r *http.Request) (context.Context, bool) {
+	ifMatch := strings.TrimSpace(r.Header.Get("If-Match"))
+	if ifMatch == "" || ifMatch == "*" {
+		return ctx, true
+	}
+
+	tag := strings.Trim(strings.TrimPrefix(ifMatch, "W/"), `"`)
+	nanos, _, ok := strings.Cut(tag, "-")
+	if !ok {
+		return ctx, false
+	}
+
+	n, err := strconv.ParseInt(nanos, 16, 64)
+	if err != nil {
+		return ctx, false
+	}
+
+	return app.WithModifiedOn(ctx, time.Unix(0, n).UTC()), true
+}
+
+// acceptsJsonApi is true unless the client explicitly accepts another format, e.g. plain application/json
+func acceptsJsonApi(accept string) bool {
+	if accept == "" || strings.Contains(accept, "application/vnd.api+json") {
+		return true
+	}
+	return !(strings.Contains(accept, "application/json") || isYAML(accept) || strings.Contains(accept, "text/csv"))
+}
+
+func isYAML(contentType string) bool {
+	return strings.Contains(contentType, "application/yaml") || strings.Contains(contentType, "application/x-yaml")
 }
 
 func isMultipartFormData(r *http.Request) bool {
@@ -627,8 +2478,14 @@ func isMultipartFormData(r *http.Request) bool {
 	return strings.Contains(contentType, "multipart/form-data")
 }
 
-func mapToOutModel(m map[string]any) {
-	if refDevices, ok := m["refDevices"]; ok {
+// includeDeviceState reports whether the measurements of the ref devices should be kept in the output
+func includeDeviceState(r *http.Request) bool {
+	include, _ := strconv.ParseBool(r.URL.Query().Get("includeDeviceState"))
+	return include
+}
+
+func mapToOutModel(m map[string]any, includeDeviceState bool) {
+	if refDevices, ok := m["refDevices"]; ok && !includeDeviceState {
 		if ref, ok := refDevices.([]any); ok {
 			for _, device := range ref {
 				x := device.(map[string]any)
@@ -0,0 +1,1596 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	app "github.com/diwise/iot-things/internal/app/iot-things"
+	"github.com/diwise/iot-things/internal/app/iot-things/things"
+	"github.com/diwise/iot-things/internal/pkg/auth"
+	"github.com/diwise/messaging-golang/pkg/messaging"
+	"github.com/fxamacker/cbor/v2"
+	"github.com/go-chi/chi/v5"
+	"github.com/matryer/is"
+)
+
+func TestGetByIDNotModified(t *testing.T) {
+	is := is.New(t)
+
+	modifiedOn := time.Date(2024, 11, 1, 12, 0, 0, 0, time.UTC)
+	room := things.NewRoom("room-001", things.DefaultLocation, "default")
+	values := [][]byte{}
+
+	a := &app.ThingsAppMock{
+		QueryThingsFunc: func(ctx context.Context, params map[string][]string, tenants []string) (app.QueryResult, error) {
+			return app.QueryResult{
+				Data:         [][]byte{room.Byte()},
+				Count:        1,
+				TotalCount:   1,
+				LastModified: modifiedOn,
+			}, nil
+		},
+		QueryValuesFunc: func(ctx context.Context, params map[string][]string, tenants []string) (app.QueryResult, error) {
+			return app.QueryResult{Data: values}, nil
+		},
+	}
+
+	server := newTestServer(is, a)
+	defer server.Close()
+
+	resp := get(is, server, "/api/v0/things/room-001", nil)
+	is.Equal(resp.StatusCode, http.StatusOK)
+
+	etag := resp.Header.Get("ETag")
+	is.True(etag != "")
+
+	resp = get(is, server, "/api/v0/things/room-001", map[string]string{"If-None-Match": etag})
+	is.Equal(resp.StatusCode, http.StatusNotModified)
+
+	resp = get(is, server, "/api/v0/things/room-001", map[string]string{"If-Modified-Since": modifiedOn.Format(http.TimeFormat)})
+	is.Equal(resp.StatusCode, http.StatusNotModified)
+
+	// other query parameters give another response
+	resp = get(is, server, "/api/v0/things/room-001?limit=1", map[string]string{"If-None-Match": etag})
+	is.Equal(resp.StatusCode, http.StatusOK)
+
+	// new values do not change the thing, but the response
+	values = append(values, []byte(`{"id":"room-001/3303/5700","urn":"urn:oma:lwm2m:ext:3303","v":21,"timestamp":"2024-11-01T12:05:00Z"}`))
+
+	resp = get(is, server, "/api/v0/things/room-001", map[string]string{"If-None-Match": etag})
+	is.Equal(resp.StatusCode, http.StatusOK)
+	is.Equal(resp.Header.Get("Last-Modified"), "")
+
+	resp = get(is, server, "/api/v0/things/room-001", map[string]string{"If-Modified-Since": modifiedOn.Format(http.TimeFormat)})
+	is.Equal(resp.StatusCode, http.StatusOK)
+
+	etag = resp.Header.Get("ETag")
+	modifiedOn = modifiedOn.Add(1 * time.Minute)
+
+	resp = get(is, server, "/api/v0/things/room-001", map[string]string{"If-None-Match": etag})
+	is.Equal(resp.StatusCode, http.StatusOK)
+}
+
+func TestQueryThingsIncludeDeviceState(t *testing.T) {
+	is := is.New(t)
+
+	room := `{"id":"room-001","type":"Room","tenant":"default","refDevices":[{"deviceID":"dev-001","measurements":{"dev-001/3303/5700":{"id":"dev-001/3303/5700","urn":"urn:oma:lwm2m:ext:3303","v":21}}}],"_internal":true}`
+
+	a := &app.ThingsAppMock{
+		QueryThingsFunc: func(ctx context.Context, params map[string][]string, tenants []string) (app.QueryResult, error) {
+			return app.QueryResult{Data: [][]byte{[]byte(room)}, Count: 1, TotalCount: 1}, nil
+		},
+	}
+
+	server := newTestServer(is, a)
+	defer server.Close()
+
+	query := func(path string) map[string]any {
+		req, err := http.NewRequest(http.MethodGet, server.URL+path, nil)
+		is.NoErr(err)
+		req.Header.Set("Authorization", "Bearer token")
+
+		resp, err := http.DefaultClient.Do(req)
+		is.NoErr(err)
+		defer resp.Body.Close()
+		is.Equal(resp.StatusCode, http.StatusOK)
+
+		response := struct {
+			Data []map[string]any `json:"data"`
+		}{}
+		is.NoErr(json.NewDecoder(resp.Body).Decode(&response))
+		is.Equal(len(response.Data), 1)
+
+		return response.Data[0]
+	}
+
+	device := func(thing map[string]any) map[string]any {
+		return thing["refDevices"].([]any)[0].(map[string]any)
+	}
+
+	thing := query("/api/v0/things")
+	_, ok := device(thing)["measurements"]
+	is.True(!ok) // measurements are stripped by default
+	_, ok = thing["_internal"]
+	is.True(!ok)
+
+	thing = query("/api/v0/things?includeDeviceState=true")
+	_, ok = device(thing)["measurements"]
+	is.True(ok)
+	_, ok = thing["_internal"]
+	is.True(!ok) // internal fields are always stripped
+}
+
+func TestGetRecentValues(t *testing.T) {
+	is := is.New(t)
+
+	a := &app.ThingsAppMock{
+		GetRecentValuesFunc: func(ctx context.Context, thingID string, n int, urns []string, tenants []string) (app.QueryResult, error) {
+			if thingID != "room-001" {
+				return app.QueryResult{}, app.ErrThingNotFound
+			}
+			return app.QueryResult{}, nil
+		},
+	}
+
+	server := newTestServer(is, a)
+	defer server.Close()
+
+	resp := get(is, server, "/api/v0/things/room-001/values/recent?n=5&urn=urn:oma:lwm2m:ext:3303", nil)
+	is.Equal(resp.StatusCode, http.StatusOK)
+
+	call := a.GetRecentValuesCalls()[0]
+	is.Equal(call.N, 5)
+	is.Equal(call.Urns, []string{"urn:oma:lwm2m:ext:3303"})
+
+	resp = get(is, server, "/api/v0/things/room-001/values/recent?n=zero", nil)
+	is.Equal(resp.StatusCode, http.StatusBadRequest)
+
+	resp = get(is, server, "/api/v0/things/room-002/values/recent", nil)
+	is.Equal(resp.StatusCode, http.StatusNotFound)
+}
+
+func TestGetLatestValues(t *testing.T) {
+	is := is.New(t)
+
+	a := &app.ThingsAppMock{
+		GetLatestValuesFunc: func(ctx context.Context, thingID string, tenants []string) (app.QueryResult, error) {
+			if thingID != "room-001" {
+				return app.QueryResult{}, app.ErrThingNotFound
+			}
+			return app.QueryResult{
+				Data: [][]byte{
+					[]byte(`{"id":"room-001/3303/5700","urn":"urn:oma:lwm2m:ext:3303","v":21,"unit":"Cel","ref":"device-001","timestamp":"2024-11-01T10:00:00Z"}`),
+					[]byte(`{"id":"room-001/3304/5700","urn":"urn:oma:lwm2m:ext:3304","v":45,"unit":"%RH","ref":"device-001","timestamp":"2024-11-01T09:00:00Z"}`),
+				},
+				Count:      2,
+				TotalCount: 2,
+			}, nil
+		},
+	}
+
+	server := newTestServer(is, a)
+	defer server.Close()
+
+	request := func(path, accept string) (int, string) {
+		req, err := http.NewRequest(http.MethodGet, server.URL+path, nil)
+		is.NoErr(err)
+		req.Header.Set("Authorization", "Bearer token")
+		req.Header.Set("Accept", accept)
+
+		resp, err := http.DefaultClient.Do(req)
+		is.NoErr(err)
+		defer resp.Body.Close()
+
+		b, err := io.ReadAll(resp.Body)
+		is.NoErr(err)
+
+		return resp.StatusCode, string(b)
+	}
+
+	status, body := request("/api/v0/things/room-001/values/latest", "application/json")
+	is.Equal(status, http.StatusOK)
+
+	response := struct {
+		Data []things.Value `json:"data"`
+	}{}
+	is.NoErr(json.Unmarshal([]byte(body), &response))
+	is.Equal(len(response.Data), 2)
+	is.Equal(response.Data[0].Unit, "Cel")
+	is.Equal(response.Data[0].Ref, "device-001")
+
+	status, body = request("/api/v0/things/room-001/values/latest", "text/csv")
+	is.Equal(status, http.StatusOK)
+	is.Equal(body, "time;id;urn;v;vb;vs;unit;ref\n"+
+		"2024-11-01T10:00:00Z;room-001/3303/5700;urn:oma:lwm2m:ext:3303;21;;;Cel;device-001\n"+
+		"2024-11-01T09:00:00Z;room-001/3304/5700;urn:oma:lwm2m:ext:3304;45;;;%RH;device-001\n")
+
+	status, _ = request("/api/v0/things/room-002/values/latest", "application/json")
+	is.Equal(status, http.StatusNotFound)
+}
+
+func TestExportInventoryRoundTrip(t *testing.T) {
+	is := is.New(t)
+	ctx := context.Background()
+
+	container := things.NewWasteContainer("container-001", things.Location{Latitude: 62.39, Longitude: 17.31}, "default")
+	container.AddDevice("device-001")
+	container.AddTag("north")
+	maxd, maxl := 0.94, 0.79
+	container.(*things.Container).MaxDistance = &maxd
+	container.(*things.Container).MaxLevel = &maxl
+
+	passage := things.NewPassage("passage-001", things.Location{Latitude: 62.1, Longitude: 17.1}, "default")
+	passage.(*things.Passage).OuterBeam = "device-002"
+
+	r := &app.ThingsReaderMock{
+		QueryThingsFunc: func(ctx context.Context, conditions ...app.ConditionFunc) (app.QueryResult, error) {
+			m := map[string]any{}
+			for _, c := range conditions {
+				m = c(m)
+			}
+			if _, ok := m["id"]; ok {
+				return app.QueryResult{}, nil
+			}
+			return app.QueryResult{
+				Data:       [][]byte{container.Byte(), passage.Byte()},
+				Count:      2,
+				TotalCount: 2,
+			}, nil
+		},
+	}
+	w := &app.ThingsWriterMock{
+		AddThingFunc: func(ctx context.Context, t things.Thing) error {
+			return nil
+		},
+	}
+
+	a := app.New(ctx, r, w, &messaging.MsgContextMock{})
+
+	server := newTestServer(is, a)
+	defer server.Close()
+
+	req, err := http.NewRequest(http.MethodGet, server.URL+"/api/v0/things", nil)
+	is.NoErr(err)
+	req.Header.Set("Authorization", "Bearer token")
+	req.Header.Set("Accept", "application/yaml")
+
+	resp, err := http.DefaultClient.Do(req)
+	is.NoErr(err)
+	defer resp.Body.Close()
+	is.Equal(resp.StatusCode, http.StatusOK)
+	is.Equal(resp.Header.Get("Content-Type"), "application/yaml")
+
+	inventory, err := io.ReadAll(resp.Body)
+	is.NoErr(err)
+
+	req, err = http.NewRequest(http.MethodPost, server.URL+"/api/v0/things", bytes.NewReader(inventory))
+	is.NoErr(err)
+	req.Header.Set("Authorization", "Bearer token")
+	req.Header.Set("Content-Type", "application/yaml")
+
+	post, err := http.DefaultClient.Do(req)
+	is.NoErr(err)
+	defer post.Body.Close()
+	is.Equal(post.StatusCode, http.StatusCreated)
+
+	is.Equal(len(w.AddThingCalls()), 2)
+
+	imported := w.AddThingCalls()[0].T.(*things.Container)
+	is.Equal(imported.ID(), "container-001")
+	is.Equal(*imported.SubType, "WasteContainer")
+	is.Equal(imported.Tags, []string{"north"})
+	is.Equal(imported.Refs()[0].DeviceID, "device-001")
+	is.Equal(*imported.MaxDistance, maxd)
+	is.Equal(*imported.MaxLevel, maxl)
+	lat, lon := imported.LatLon()
+	is.Equal(lat, 62.39)
+	is.Equal(lon, 17.31)
+
+	is.Equal(w.AddThingCalls()[1].T.(*things.Passage).OuterBeam, "device-002")
+}
+
+func TestGetTenantsRequiresAdmin(t *testing.T) {
+	is := is.New(t)
+
+	a := &app.ThingsAppMock{
+		GetTenantsFunc: func(ctx context.Context) ([]app.TenantCount, error) {
+			return []app.TenantCount{
+				{Tenant: "default", Things: 2, Values: 10},
+				{Tenant: "other", Things: 1, Values: 0},
+			}, nil
+		},
+	}
+
+	r, err := Register(context.Background(), a, strings.NewReader(adminPolicy))
+	is.NoErr(err)
+
+	server := httptest.NewServer(r)
+	defer server.Close()
+
+	resp := get(is, server, "/api/v0/admin/tenants", nil)
+	is.Equal(resp.StatusCode, http.StatusUnauthorized)
+	is.Equal(len(a.GetTenantsCalls()), 0)
+
+	req, err := http.NewRequest(http.MethodGet, server.URL+"/api/v0/admin/tenants", nil)
+	is.NoErr(err)
+	req.Header.Set("Authorization", "Bearer admin")
+
+	resp, err = http.DefaultClient.Do(req)
+	is.NoErr(err)
+	defer resp.Body.Close()
+	is.Equal(resp.StatusCode, http.StatusOK)
+
+	response := struct {
+		Data []app.TenantCount `json:"data"`
+	}{}
+	is.NoErr(json.NewDecoder(resp.Body).Decode(&response))
+	is.Equal(len(response.Data), 2)
+	is.Equal(response.Data[1].Tenant, "other")
+}
+
+func TestAdminDuplicatesAndMerge(t *testing.T) {
+	is := is.New(t)
+
+	a := &app.ThingsAppMock{
+		FindDuplicatesFunc: func(ctx context.Context, tenants []string) ([]app.Duplicate, error) {
+			return []app.Duplicate{{Reason: app.DuplicateByRefDevice, Key: "device-001", Tenant: "default", ThingIDs: []string{"room-001", "room-002"}}}, nil
+		},
+		MergeDuplicateFunc: func(ctx context.Context, targetID, sourceID string, tenants []string) (things.Thing, error) {
+			if sourceID != "room-002" {
+				return nil, app.ErrThingNotFound
+			}
+			return things.NewRoom(targetID, things.DefaultLocation, "default"), nil
+		},
+	}
+
+	r, err := Register(context.Background(), a, strings.NewReader(adminPolicy))
+	is.NoErr(err)
+
+	server := httptest.NewServer(r)
+	defer server.Close()
+
+	do := func(method, path, body string) *http.Response {
+		req, err := http.NewRequest(method, server.URL+path, strings.NewReader(body))
+		is.NoErr(err)
+		req.Header.Set("Authorization", "Bearer admin")
+
+		resp, err := http.DefaultClient.Do(req)
+		is.NoErr(err)
+		return resp
+	}
+
+	resp := do(http.MethodGet, "/api/v0/admin/duplicates", "")
+	defer resp.Body.Close()
+	is.Equal(resp.StatusCode, http.StatusOK)
+
+	response := struct {
+		Data []app.Duplicate `json:"data"`
+	}{}
+	is.NoErr(json.NewDecoder(resp.Body).Decode(&response))
+	is.Equal(len(response.Data), 1)
+	is.Equal(response.Data[0].ThingIDs, []string{"room-001", "room-002"})
+
+	resp = do(http.MethodPost, "/api/v0/admin/merge", `{"target":"room-001","source":"room-002"}`)
+	defer resp.Body.Close()
+	is.Equal(resp.StatusCode, http.StatusOK)
+	is.Equal(a.MergeDuplicateCalls()[0].TargetID, "room-001")
+
+	resp = do(http.MethodPost, "/api/v0/admin/merge", `{"target":"room-001","source":"room-003"}`)
+	defer resp.Body.Close()
+	is.Equal(resp.StatusCode, http.StatusNotFound)
+}
+
+func TestIngestRespondsWithRecordResults(t *testing.T) {
+	is := is.New(t)
+
+	a := &app.ThingsAppMock{
+		HandleMeasurementsFunc: func(ctx context.Context, measurements []things.Measurement) app.IngestResult {
+			return app.IngestResult{
+				Stored:  1,
+				Records: []app.RecordResult{{Name: measurements[0].ID, Status: app.RecordStored}},
+			}
+		},
+	}
+
+	r, err := Register(context.Background(), a, strings.NewReader(adminPolicy))
+	is.NoErr(err)
+
+	server := httptest.NewServer(r)
+	defer server.Close()
+
+	body := `[{"bn":"c5a2ae17c239/3303/","bt":1730124834,"n":"0","vs":"urn:oma:lwm2m:ext:3303"},{"n":"5700","u":"Cel","v":21},{"n":"5701","vs":"Cel"}]`
+
+	req, err := http.NewRequest(http.MethodPost, server.URL+"/api/v0/admin/measurements", strings.NewReader(body))
+	is.NoErr(err)
+	req.Header.Set("Authorization", "Bearer admin")
+	req.Header.Set("Content-Type", "application/senml+json")
+
+	resp, err := http.DefaultClient.Do(req)
+	is.NoErr(err)
+	defer resp.Body.Close()
+	is.Equal(resp.StatusCode, http.StatusOK)
+
+	response := struct {
+		Data app.IngestResult `json:"data"`
+	}{}
+	is.NoErr(json.NewDecoder(resp.Body).Decode(&response))
+	is.Equal(response.Data.Stored, 1)
+	is.Equal(response.Data.Skipped, 1)
+	is.Equal(len(response.Data.Records), 2)
+}
+
+func TestBatchWithMixedRequests(t *testing.T) {
+	is := is.New(t)
+
+	ts := time.Date(2024, 11, 1, 12, 0, 0, 0, time.UTC)
+	room := things.NewRoom("room-001", things.DefaultLocation, "default")
+	other := things.NewRoom("room-002", things.DefaultLocation, "other")
+
+	value := func(id string, v float64) []byte {
+		b, _ := json.Marshal(things.NewTemperature(id, "device", v, ts).Value)
+		return b
+	}
+
+	a := &app.ThingsAppMock{
+		QueryThingsFunc: func(ctx context.Context, params map[string][]string, tenants []string) (app.QueryResult, error) {
+			for _, t := range []things.Thing{room, other} {
+				if t.ID() == params["id"][0] && slices.Contains(tenants, t.Tenant()) {
+					return app.QueryResult{Data: [][]byte{t.Byte()}, Count: 1}, nil
+				}
+			}
+			return app.QueryResult{}, nil
+		},
+		QueryValuesFunc: func(ctx context.Context, params map[string][]string, tenants []string) (app.QueryResult, error) {
+			if _, ok := params["latest"]; ok {
+				return app.QueryResult{Data: [][]byte{value("room-001", 21), []byte(`{"id":"room-001/3428/17","urn":"urn:oma:lwm2m:ext:3428","v":400}`)}, Count: 2}, nil
+			}
+			if params["timeat"][0] != ts.Add(-1*time.Hour).Format(time.RFC3339) {
+				return app.QueryResult{}, errors.New("unexpected range")
+			}
+			return app.QueryResult{Data: [][]byte{value("room-001", 20), value("room-001", 22)}, Count: 2}, nil
+		},
+	}
+
+	server := newTestServer(is, a)
+	defer server.Close()
+
+	body := fmt.Sprintf(`[
+		{"thingId":"room-001","valueNames":["5700"]},
+		{"thingId":"room-002"},
+		{"thingId":"room-003","range":{"from":"%s"}}
+	]`, ts.Add(-1*time.Hour).Format(time.RFC3339))
+
+	req, err := http.NewRequest(http.MethodPost, server.URL+"/api/v0/batch", strings.NewReader(body))
+	is.NoErr(err)
+	req.Header.Set("Authorization", "Bearer token")
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := http.DefaultClient.Do(req)
+	is.NoErr(err)
+	defer resp.Body.Close()
+	is.Equal(resp.StatusCode, http.StatusOK)
+
+	response := struct {
+		Data map[string]BatchResult `json:"data"`
+	}{}
+	is.NoErr(json.NewDecoder(resp.Body).Decode(&response))
+	is.Equal(len(response.Data), 3)
+
+	is.Equal(response.Data["room-001"].Thing["id"], "room-001")
+	is.Equal(len(response.Data["room-001"].Values), 1) // only the requested value name
+
+	is.Equal(response.Data["room-002"].Error, app.ErrThingNotFound.Error()) // belongs to another tenant
+	is.Equal(response.Data["room-003"].Error, app.ErrThingNotFound.Error())
+
+	// a range returns all values within it
+	result := batch(context.Background(), a, BatchRequest{ThingID: "room-001", Range: &BatchRange{From: ts.Add(-1 * time.Hour)}}, []string{"default"})
+	is.Equal(result.Error, "")
+	is.Equal(len(result.Values), 2)
+}
+
+func TestQueryThingsAsCSV(t *testing.T) {
+	is := is.New(t)
+
+	container := `{"id":"container-001","type":"Container","subType":"WasteContainer","name":"Bin; north","tenant":"default","tags":["a","b"],"location":{"latitude":62.1,"longitude":17.2},"currentLevel":42.5,"maxd":1.5}`
+
+	a := &app.ThingsAppMock{
+		QueryThingsFunc: func(ctx context.Context, params map[string][]string, tenants []string) (app.QueryResult, error) {
+			return app.QueryResult{Data: [][]byte{[]byte(container)}, Count: 1, TotalCount: 1}, nil
+		},
+	}
+
+	server := newTestServer(is, a)
+	defer server.Close()
+
+	query := func(path string) []string {
+		req, err := http.NewRequest(http.MethodGet, server.URL+path, nil)
+		is.NoErr(err)
+		req.Header.Set("Authorization", "Bearer token")
+		req.Header.Set("Accept", "text/csv")
+
+		resp, err := http.DefaultClient.Do(req)
+		is.NoErr(err)
+		defer resp.Body.Close()
+		is.Equal(resp.StatusCode, http.StatusOK)
+		is.Equal(resp.Header.Get("Content-Type"), "text/csv")
+
+		b, err := io.ReadAll(resp.Body)
+		is.NoErr(err)
+
+		return strings.Split(strings.TrimSpace(string(b)), "\n")
+	}
+
+	rows := query("/api/v0/things")
+	is.Equal(len(rows), 2)
+	is.Equal(rows[0], "id;type;subType;name;decsription;location;tenant;tags;refDevices;args")
+	is.Equal(rows[1], `container-001;Container;WasteContainer;"Bin; north";;62.100000,17.200000;default;a,b;;{'maxd':1.500000}`)
+
+	rows = query("/api/v0/things?columns=id,name,tags,currentLevel")
+	is.Equal(len(rows), 2)
+	is.Equal(rows[0], "id;name;tags;currentLevel")
+	is.Equal(rows[1], `container-001;"Bin; north";a,b;42.5`)
+}
+
+func TestCSVExportRoundTrip(t *testing.T) {
+	is := is.New(t)
+
+	name := "Bin; \"north\"\nby the gate"
+
+	thing, err := json.Marshal(map[string]any{"id": "container-001", "type": "Container", "name": name, "tenant": "default"})
+	is.NoErr(err)
+	value, err := json.Marshal(map[string]any{"id": "container-001/3200/5500", "urn": "urn:oma:lwm2m:ext:3200", "vs": name, "timestamp": "2024-11-01T10:00:00Z"})
+	is.NoErr(err)
+
+	read := func(b *bytes.Buffer) [][]string {
+		r := csv.NewReader(b)
+		r.Comma = ';'
+		records, err := r.ReadAll()
+		is.NoErr(err)
+		is.Equal(len(records), 2)
+		return records
+	}
+
+	b := &bytes.Buffer{}
+	is.NoErr(exportQueryResultAsCSV(app.QueryResult{Data: [][]byte{thing}, Count: 1}, []string{"id", "name"}, b))
+	records := read(b)
+	is.Equal(records[1], []string{"container-001", name})
+
+	b = &bytes.Buffer{}
+	is.NoErr(exportValuesAsCSV(app.QueryResult{Data: [][]byte{value}, Count: 1}, b))
+	records = read(b)
+	is.Equal(records[0], valuesCSVColumns)
+	is.Equal(records[1][5], name)
+}
+
+func TestGetTimeline(t *testing.T) {
+	is := is.New(t)
+
+	start := time.Date(2024, 11, 1, 8, 0, 0, 0, time.UTC)
+	end := start.Add(1 * time.Hour)
+
+	a := &app.ThingsAppMock{
+		GetTimelineFunc: func(ctx context.Context, thingID string, from, to time.Time, tenants []string) ([]app.StateInterval, error) {
+			if thingID != "sewer-001" {
+				return nil, app.ErrThingNotFound
+			}
+			return []app.StateInterval{
+				{ID: "sewer-001/3200/5500", Urn: "urn:oma:lwm2m:ext:3200", State: true, Start: start, End: &end, Duration: 3600},
+				{ID: "sewer-001/3200/5500", Urn: "urn:oma:lwm2m:ext:3200", State: false, Start: end, Duration: 1800},
+			}, nil
+		},
+	}
+
+	server := newTestServer(is, a)
+	defer server.Close()
+
+	request := func(path, accept string) (int, string) {
+		req, err := http.NewRequest(http.MethodGet, server.URL+path, nil)
+		is.NoErr(err)
+		req.Header.Set("Authorization", "Bearer token")
+		req.Header.Set("Accept", accept)
+
+		resp, err := http.DefaultClient.Do(req)
+		is.NoErr(err)
+		defer resp.Body.Close()
+
+		b, err := io.ReadAll(resp.Body)
+		is.NoErr(err)
+
+		return resp.StatusCode, string(b)
+	}
+
+	status, body := request("/api/v0/things/sewer-001/timeline", "application/json")
+	is.Equal(status, http.StatusOK)
+
+	response := struct {
+		Data []app.StateInterval `json:"data"`
+	}{}
+	is.NoErr(json.Unmarshal([]byte(body), &response))
+	is.Equal(len(response.Data), 2)
+	is.Equal(*response.Data[0].End, end)
+
+	status, body = request("/api/v0/things/sewer-001/timeline", "text/csv")
+	is.Equal(status, http.StatusOK)
+	is.Equal(body, "id;urn;state;start;end;duration\n"+
+		"sewer-001/3200/5500;urn:oma:lwm2m:ext:3200;true;2024-11-01T08:00:00Z;2024-11-01T09:00:00Z;3600\n"+
+		"sewer-001/3200/5500;urn:oma:lwm2m:ext:3200;false;2024-11-01T09:00:00Z;;1800\n")
+
+	status, _ = request("/api/v0/things/sewer-001/timeline?from=2024-11-02T00:00:00Z&to=2024-11-01T00:00:00Z", "application/json")
+	is.Equal(status, http.StatusBadRequest)
+
+	status, _ = request("/api/v0/things/sewer-002/timeline", "application/json")
+	is.Equal(status, http.StatusNotFound)
+}
+
+func TestMetricsEndpoint(t *testing.T) {
+	is := is.New(t)
+
+	server := newTestServer(is, &app.ThingsAppMock{})
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + "/metrics") // no token, metrics are collected without authentication
+	is.NoErr(err)
+	defer resp.Body.Close()
+	is.Equal(resp.StatusCode, http.StatusOK)
+
+	b, err := io.ReadAll(resp.Body)
+	is.NoErr(err)
+	is.True(strings.Contains(string(b), "go_goroutines"))
+}
+
+func TestQueryThingsEmptyResult(t *testing.T) {
+	is := is.New(t)
+
+	a := &app.ThingsAppMock{
+		QueryThingsFunc: func(ctx context.Context, params map[string][]string, tenants []string) (app.QueryResult, error) {
+			return app.QueryResult{Data: [][]byte{}}, nil
+		},
+	}
+
+	server := newTestServer(is, a)
+	defer server.Close()
+
+	query := func(accept string) (int, string) {
+		req, err := http.NewRequest(http.MethodGet, server.URL+"/api/v0/things?type=Room", nil)
+		is.NoErr(err)
+		req.Header.Set("Authorization", "Bearer token")
+		if accept != "" {
+			req.Header.Set("Accept", accept)
+		}
+
+		resp, err := http.DefaultClient.Do(req)
+		is.NoErr(err)
+		defer resp.Body.Close()
+
+		b, err := io.ReadAll(resp.Body)
+		is.NoErr(err)
+
+		return resp.StatusCode, string(b)
+	}
+
+	for _, accept := range []string{"", "application/vnd.api+json", "*/*"} {
+		status, body := query(accept)
+		is.Equal(status, http.StatusOK)
+
+		envelope := struct {
+			Data []any `json:"data"`
+			Meta struct {
+				TotalRecords *uint64 `json:"totalRecords"`
+			} `json:"meta"`
+		}{}
+		is.NoErr(json.Unmarshal([]byte(body), &envelope))
+		is.True(envelope.Data != nil) // data must be an empty list, not null
+		is.Equal(len(envelope.Data), 0)
+		is.True(envelope.Meta.TotalRecords != nil)
+		is.Equal(*envelope.Meta.TotalRecords, uint64(0))
+	}
+
+	status, body := query("application/json")
+	is.Equal(status, http.StatusOK)
+	is.Equal(body, "[]")
+}
+
+func TestQueryWithMalformedParams(t *testing.T) {
+	is := is.New(t)
+
+	r := &app.ThingsReaderMock{
+		QueryThingsFunc: func(ctx context.Context, conditions ...app.ConditionFunc) (app.QueryResult, error) {
+			return app.QueryResult{Data: [][]byte{}}, nil
+		},
+	}
+	a := app.New(context.Background(), r, &app.ThingsWriterMock{}, &messaging.MsgContextMock{})
+
+	server := newTestServer(is, a)
+	defer server.Close()
+
+	query := func(path string) (int, string) {
+		req, err := http.NewRequest(http.MethodGet, server.URL+path, nil)
+		is.NoErr(err)
+		req.Header.Set("Authorization", "Bearer token")
+
+		resp, err := http.DefaultClient.Do(req)
+		is.NoErr(err)
+		defer resp.Body.Close()
+
+		b, err := io.ReadAll(resp.Body)
+		is.NoErr(err)
+
+		return resp.StatusCode, string(b)
+	}
+
+	// malformed params are ignored unless the app is configured to be strict
+	status, _ := query("/api/v0/things?limit=ten&offset=-5")
+	is.Equal(status, http.StatusOK)
+	is.Equal(len(r.QueryThingsCalls()), 1)
+
+	is.NoErr(a.LoadConfig(context.Background(), strings.NewReader("strictParams: true\n")))
+
+	status, body := query("/api/v0/things?limit=ten&offset=-5")
+	is.Equal(status, http.StatusBadRequest)
+	is.True(strings.Contains(body, "limit must be a positive integer"))
+	is.True(strings.Contains(body, "offset must be a non-negative integer"))
+
+	status, body = query("/api/v0/things/values?timerel=after&timeat=yesterday")
+	is.Equal(status, http.StatusBadRequest)
+	is.True(strings.Contains(body, "timeat must be a RFC3339 timestamp"))
+
+	is.Equal(len(r.QueryThingsCalls()), 1)
+
+	status, _ = query("/api/v0/things?limit=10")
+	is.Equal(status, http.StatusOK)
+}
+
+func TestBulkPatchThings(t *testing.T) {
+	is := is.New(t)
+
+	a := &app.ThingsAppMock{
+		MergeThingsFunc: func(ctx context.Context, params map[string][]string, b []byte, tenants []string) (int, error) {
+			if len(params["confirm"]) == 0 {
+				return 0, app.ErrMissingConfirmation
+			}
+			return 3, nil
+		},
+	}
+
+	server := newTestServer(is, a)
+	defer server.Close()
+
+	patch := func(path string) (int, string) {
+		req, err := http.NewRequest(http.MethodPatch, server.URL+path, strings.NewReader(`{"tags":["recycling"]}`))
+		is.NoErr(err)
+		req.Header.Set("Authorization", "Bearer token")
+		req.Header.Set("Content-Type", "application/json")
+
+		resp, err := http.DefaultClient.Do(req)
+		is.NoErr(err)
+		defer resp.Body.Close()
+
+		b, err := io.ReadAll(resp.Body)
+		is.NoErr(err)
+
+		return resp.StatusCode, string(b)
+	}
+
+	status, body := patch("/api/v0/things?type=Container")
+	is.Equal(status, http.StatusBadRequest)
+	is.Equal(body, app.ErrMissingConfirmation.Error())
+
+	status, body = patch("/api/v0/things?type=Container&confirm=true")
+	is.Equal(status, http.StatusOK)
+	is.Equal(body, `{"data":{"count":3}}`)
+
+	is.Equal(a.MergeThingsCalls()[1].Params["type"], []string{"Container"})
+	is.Equal(string(a.MergeThingsCalls()[1].B), `{"tags":["recycling"]}`)
+}
+
+func TestDeleteValues(t *testing.T) {
+	is := is.New(t)
+
+	room := things.NewRoom("room-001", things.DefaultLocation, "default")
+	other := things.NewRoom("room-002", things.DefaultLocation, "other")
+
+	r := &app.ThingsReaderMock{
+		QueryThingsFunc: func(ctx context.Context, conditions ...app.ConditionFunc) (app.QueryResult, error) {
+			for _, c := range conditions {
+				m := c(map[string]any{})
+				if id, ok := m["id"]; ok && id == other.ID() {
+					// other belongs to a tenant the caller is not allowed
+					return app.QueryResult{Data: [][]byte{}}, nil
+				}
+			}
+			return app.QueryResult{Data: [][]byte{room.Byte()}}, nil
+		},
+	}
+	w := &app.ThingsWriterMock{
+		DeleteValuesFunc: func(ctx context.Context, thingID string, conditions ...app.ConditionFunc) (int64, error) {
+			return 5, nil
+		},
+	}
+	a := app.New(context.Background(), r, w, &messaging.MsgContextMock{})
+
+	server := newTestServer(is, a)
+	defer server.Close()
+
+	del := func(path string) (int, string) {
+		req, err := http.NewRequest(http.MethodDelete, server.URL+path, nil)
+		is.NoErr(err)
+		req.Header.Set("Authorization", "Bearer token")
+
+		resp, err := http.DefaultClient.Do(req)
+		is.NoErr(err)
+		defer resp.Body.Close()
+
+		b, err := io.ReadAll(resp.Body)
+		is.NoErr(err)
+
+		return resp.StatusCode, string(b)
+	}
+
+	window := "timerel=between&timeat=2024-01-01T00:00:00Z&endtimeat=2024-01-02T00:00:00Z"
+
+	status, _ := del("/api/v0/things/room-001/values")
+	is.Equal(status, http.StatusBadRequest)
+
+	status, _ = del("/api/v0/things/room-002/values?" + window)
+	is.Equal(status, http.StatusNotFound)
+
+	status, body := del("/api/v0/things/room-001/values?" + window)
+	is.Equal(status, http.StatusOK)
+	is.Equal(body, `{"data":{"count":5}}`)
+
+	is.Equal(len(w.DeleteValuesCalls()), 1)
+	is.Equal(w.DeleteValuesCalls()[0].ThingID, "room-001")
+}
+
+func TestAddValuesIsLimited(t *testing.T) {
+	is := is.New(t)
+
+	a := &app.ThingsAppMock{
+		AddValuesFunc: func(ctx context.Context, thingID string, values []things.Value, tenants []string) (int64, error) {
+			return int64(len(values)), nil
+		},
+	}
+
+	server := newTestServer(is, a)
+	defer server.Close()
+
+	post := func(body string) int {
+		req, err := http.NewRequest(http.MethodPost, server.URL+"/api/v0/things/room-001/values", strings.NewReader(body))
+		is.NoErr(err)
+		req.Header.Set("Authorization", "Bearer token")
+
+		resp, err := http.DefaultClient.Do(req)
+		is.NoErr(err)
+		defer resp.Body.Close()
+
+		return resp.StatusCode
+	}
+
+	value := `{"id":"room-001/3303/5700","urn":"urn:oma:lwm2m:ext:3303","v":21,"timestamp":"2024-01-01T00:00:00Z"}`
+
+	is.Equal(post("["+value+"]"), http.StatusCreated)
+
+	tooMany := strings.Repeat(value+",", maxValuesCount) + value
+	is.Equal(post("["+tooMany+"]"), http.StatusRequestEntityTooLarge)
+
+	tooLarge := `[{"id":"room-001/3303/5700","vs":"` + strings.Repeat("x", int(maxValuesBodySize)) + `"}]`
+	is.Equal(post(tooLarge), http.StatusRequestEntityTooLarge)
+
+	is.Equal(len(a.AddValuesCalls()), 1)
+}
+
+func TestGetValuesWithCursor(t *testing.T) {
+	is := is.New(t)
+
+	a := &app.ThingsAppMock{
+		ListValuesFunc: func(ctx context.Context, params map[string][]string, tenants []string) (app.QueryResult, error) {
+			return app.QueryResult{
+				Data:       [][]byte{[]byte(`{"id":"room-001/3303/5700","v":20}`), []byte(`{"id":"room-001/3303/5700","v":21}`)},
+				Count:      2,
+				Limit:      2,
+				TotalCount: 5,
+				NextCursor: "next-page",
+			}, nil
+		},
+	}
+
+	server := newTestServer(is, a)
+	defer server.Close()
+
+	getLinks := func(path string) map[string]string {
+		req, err := http.NewRequest(http.MethodGet, server.URL+path, nil)
+		is.NoErr(err)
+		req.Header.Set("Authorization", "Bearer token")
+
+		resp, err := http.DefaultClient.Do(req)
+		is.NoErr(err)
+		defer resp.Body.Close()
+		is.Equal(resp.StatusCode, http.StatusOK)
+
+		response := struct {
+			Links map[string]string `json:"links"`
+		}{}
+		is.NoErr(json.NewDecoder(resp.Body).Decode(&response))
+		return response.Links
+	}
+
+	// offset based pages link to the next page by cursor
+	links := getLinks("/api/v0/things/values?thingid=room-001&limit=2&offset=2")
+	is.Equal(links["next"], "/api/v0/things/values?cursor=next-page&limit=2&thingid=room-001")
+	is.True(links["last"] != "")
+
+	links = getLinks("/api/v0/things/values?thingid=room-001&limit=2&cursor=this-page")
+	is.Equal(links["self"], "/api/v0/things/values?thingid=room-001&limit=2&cursor=this-page")
+	is.Equal(links["first"], "/api/v0/things/values?limit=2&thingid=room-001")
+	is.Equal(links["next"], "/api/v0/things/values?cursor=next-page&limit=2&thingid=room-001")
+	_, ok := links["last"]
+	is.True(!ok)
+}
+
+func TestQueryWithTenantFilter(t *testing.T) {
+	is := is.New(t)
+
+	r := &app.ThingsReaderMock{
+		QueryThingsFunc: func(ctx context.Context, conditions ...app.ConditionFunc) (app.QueryResult, error) {
+			return app.QueryResult{Data: [][]byte{}}, nil
+		},
+	}
+	a := app.New(context.Background(), r, &app.ThingsWriterMock{}, &messaging.MsgContextMock{})
+
+	server := newTestServer(is, a)
+	defer server.Close()
+
+	resp := get(is, server, "/api/v0/things?tenant=default", nil)
+	is.Equal(resp.StatusCode, http.StatusOK)
+	is.Equal(len(r.QueryThingsCalls()), 1)
+
+	resp = get(is, server, "/api/v0/things?tenant=default,other", nil)
+	is.Equal(resp.StatusCode, http.StatusForbidden)
+
+	resp = get(is, server, "/api/v0/things/values?tenant=other", nil)
+	is.Equal(resp.StatusCode, http.StatusForbidden)
+
+	is.Equal(len(r.QueryThingsCalls()), 1)
+}
+
+func TestQueryAsGeoJSON(t *testing.T) {
+	is := is.New(t)
+
+	room := things.NewRoom("room-001", things.Location{Latitude: 62.39, Longitude: 17.30}, "default")
+	unplaced := things.NewRoom("room-002", things.DefaultLocation, "default")
+	parking := []byte(`{"id":"parking-001","type":"PointOfInterest","tenant":"default","location":{"latitude":0,"longitude":0},"area":[[[17.30,62.39],[17.31,62.39]],[[17.31,62.39],[17.31,62.40]],[[17.31,62.40],[17.30,62.39]]]}`)
+
+	a := &app.ThingsAppMock{
+		QueryThingsFunc: func(ctx context.Context, params map[string][]string, tenants []string) (app.QueryResult, error) {
+			return app.QueryResult{Data: [][]byte{room.Byte(), unplaced.Byte(), parking}, Count: 3, TotalCount: 3}, nil
+		},
+	}
+
+	server := newTestServer(is, a)
+	defer server.Close()
+
+	req, err := http.NewRequest(http.MethodGet, server.URL+"/api/v0/things", nil)
+	is.NoErr(err)
+	req.Header.Set("Authorization", "Bearer token")
+	req.Header.Set("Accept", "application/geo+json")
+
+	resp, err := http.DefaultClient.Do(req)
+	is.NoErr(err)
+	defer resp.Body.Close()
+
+	is.Equal(resp.StatusCode, http.StatusOK)
+	is.Equal(resp.Header.Get("Content-Type"), "application/geo+json")
+
+	fc := FeatureCollection{}
+	is.NoErr(json.NewDecoder(resp.Body).Decode(&fc))
+
+	is.Equal(fc.Type, "FeatureCollection")
+	is.Equal(len(fc.Features), 2) // things at 0,0 without an area are left out
+	is.Equal(fc.Features[0].ID, "room-001")
+	is.Equal(fc.Features[0].Geometry.Type, "Point")
+	is.Equal(fc.Features[0].Geometry.Coordinates, []any{17.30, 62.39})
+	is.Equal(fc.Features[0].Properties["type"], "Room")
+
+	is.Equal(fc.Features[1].ID, "parking-001")
+	is.Equal(fc.Features[1].Geometry.Type, "Polygon")
+	rings := fc.Features[1].Geometry.Coordinates.([]any)
+	is.Equal(len(rings[0].([]any)), 4) // a closed ring of three segments
+}
+
+func TestGetUnits(t *testing.T) {
+	is := is.New(t)
+
+	server := newTestServer(is, &app.ThingsAppMock{})
+	defer server.Close()
+
+	req, err := http.NewRequest(http.MethodGet, server.URL+"/api/v0/units", nil)
+	is.NoErr(err)
+	req.Header.Set("Authorization", "Bearer token")
+
+	resp, err := http.DefaultClient.Do(req)
+	is.NoErr(err)
+	defer resp.Body.Close()
+	is.Equal(resp.StatusCode, http.StatusOK)
+
+	response := struct {
+		Data []things.UnitDescriptor `json:"data"`
+	}{}
+	is.NoErr(json.NewDecoder(resp.Body).Decode(&response))
+
+	units := map[string]things.UnitDescriptor{}
+	for _, u := range response.Data {
+		units[u.Resource] = u
+	}
+
+	is.Equal(units["3303/5700"].Unit, "Cel")
+	is.Equal(units["3303/5700"].Urn, things.TemperatureURN)
+	is.Equal(units["3304/5700"].Unit, "%")
+	is.Equal(units["3304/5700"].Aliases, []string{"%RH"})
+	is.Equal(units["3428/17"].Unit, "ppm")
+	is.Equal(units["3424/1"].Unit, "m3")
+	is.Equal(units["3424/1"].Alternatives, []string{"l"})
+	is.True(units["3302/5500"].Boolean)
+	is.Equal(units["3302/5500"].Unit, "")
+}
+
+func TestRequestTimeout(t *testing.T) {
+	is := is.New(t)
+
+	t.Setenv("MAX_REQUEST_TIMEOUT", "100ms")
+
+	a := &app.ThingsAppMock{
+		QueryThingsFunc: func(ctx context.Context, params map[string][]string, tenants []string) (app.QueryResult, error) {
+			<-ctx.Done() // a query that does not finish before the deadline
+			return app.QueryResult{}, ctx.Err()
+		},
+	}
+
+	server := newTestServer(is, a)
+	defer server.Close()
+
+	start := time.Now()
+	resp := get(is, server, "/api/v0/things?timeout=50ms", nil)
+	is.Equal(resp.StatusCode, http.StatusGatewayTimeout)
+	is.True(time.Since(start) < 5*time.Second)
+
+	// a timeout longer than the max is capped
+	start = time.Now()
+	resp = get(is, server, "/api/v0/things?timeout=1h", nil)
+	is.Equal(resp.StatusCode, http.StatusGatewayTimeout)
+	is.True(time.Since(start) < 5*time.Second)
+
+	resp = get(is, server, "/api/v0/things?timeout=soon", nil)
+	is.Equal(resp.StatusCode, http.StatusBadRequest)
+
+	is.Equal(len(a.QueryThingsCalls()), 2)
+}
+
+func TestExportJobLifecycle(t *testing.T) {
+	is := is.New(t)
+
+	ts := time.Date(2024, 11, 1, 12, 0, 0, 0, time.UTC)
+
+	values := [][]byte{}
+	for i := range 5 {
+		b, _ := json.Marshal(things.NewTemperature("room-001", "device", float64(20+i), ts.Add(time.Duration(i)*time.Minute)).Value)
+		values = append(values, b)
+	}
+
+	a := &app.ThingsAppMock{
+		QueryValuesFunc: func(ctx context.Context, params map[string][]string, tenants []string) (app.QueryResult, error) {
+			offset, _ := strconv.Atoi(params["offset"][0])
+			limit, _ := strconv.Atoi(params["limit"][0])
+			end := min(offset+limit, len(values))
+			return app.QueryResult{Data: values[offset:end], Count: end - offset, TotalCount: int64(len(values))}, nil
+		},
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	e := newExports(ctx, 2)
+
+	r := chi.NewRouter()
+	r.Use(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			next.ServeHTTP(w, r.WithContext(auth.WithAllowedTenants(r.Context(), strings.Split(r.Header.Get("X-Tenants"), ","))))
+		})
+	})
+	r.Post("/api/v0/exports", e.createHandler(slog.Default(), a))
+	r.Get("/api/v0/exports/{id}", e.statusHandler(slog.Default()))
+	r.Get("/api/v0/exports/{id}/file", e.fileHandler(slog.Default()))
+
+	server := httptest.NewServer(r)
+	defer server.Close()
+
+	do := func(method, path, tenants string) (*http.Response, []byte) {
+		req, err := http.NewRequest(method, server.URL+path, nil)
+		is.NoErr(err)
+		req.Header.Set("X-Tenants", tenants)
+
+		resp, err := http.DefaultClient.Do(req)
+		is.NoErr(err)
+		defer resp.Body.Close()
+
+		b, err := io.ReadAll(resp.Body)
+		is.NoErr(err)
+
+		return resp, b
+	}
+
+	job := func(b []byte) exportJob {
+		response := struct {
+			Data exportJob `json:"data"`
+		}{}
+		is.NoErr(json.Unmarshal(b, &response))
+		return response.Data
+	}
+
+	resp, b := do(http.MethodPost, "/api/v0/exports?thingid=room-001&format=csv", "default")
+	is.Equal(resp.StatusCode, http.StatusAccepted)
+
+	created := job(b)
+	is.True(created.ID != "")
+	is.Equal(resp.Header.Get("Location"), "/api/v0/exports/"+created.ID)
+
+	var status exportJob
+	for range 100 {
+		resp, b = do(http.MethodGet, "/api/v0/exports/"+created.ID, "default")
+		is.Equal(resp.StatusCode, http.StatusOK)
+		status = job(b)
+		if status.Status == ExportDone || status.Status == ExportFailed {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	is.Equal(status.Status, ExportDone)
+	is.Equal(status.Count, 5)
+	is.Equal(len(a.QueryValuesCalls()), 3) // paged two values at a time
+
+	// jobs are only visible to the tenants they were created for
+	resp, _ = do(http.MethodGet, "/api/v0/exports/"+created.ID, "other")
+	is.Equal(resp.StatusCode, http.StatusNotFound)
+
+	resp, b = do(http.MethodGet, status.Link, "default")
+	is.Equal(resp.StatusCode, http.StatusOK)
+	is.Equal(resp.Header.Get("Content-Type"), "text/csv")
+
+	cr := csv.NewReader(bytes.NewReader(b))
+	cr.Comma = ';'
+	rows, err := cr.ReadAll()
+	is.NoErr(err)
+	is.Equal(len(rows), 6) // header and five values
+	is.Equal(rows[0], valuesCSVColumns)
+
+	resp, _ = do(http.MethodPost, "/api/v0/exports?format=xml", "default")
+	is.Equal(resp.StatusCode, http.StatusBadRequest)
+}
+
+func TestExportJobsAreLimited(t *testing.T) {
+	is := is.New(t)
+
+	release := make(chan struct{})
+	a := &app.ThingsAppMock{
+		QueryValuesFunc: func(ctx context.Context, params map[string][]string, tenants []string) (app.QueryResult, error) {
+			<-release
+			return app.QueryResult{}, nil
+		},
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	e := newExports(ctx, 2)
+
+	queued := 0
+	for range exportWorkers + maxQueuedExports + 1 {
+		if _, err := e.start(ctx, a, map[string][]string{}, ExportFormatNDJSON, []string{"default"}); err != nil {
+			is.True(errors.Is(err, errTooManyExports))
+			break
+		}
+		queued++
+	}
+
+	is.True(queued >= maxQueuedExports)
+	is.True(queued <= exportWorkers+maxQueuedExports)
+
+	close(release)
+}
+
+func newTestServer(is *is.I, a app.ThingsApp) *httptest.Server {
+	r, err := Register(context.Background(), a, strings.NewReader(allowAllPolicy))
+	is.NoErr(err)
+
+	return httptest.NewServer(r)
+}
+
+func get(is *is.I, server *httptest.Server, path string, headers map[string]string) *http.Response {
+	req, err := http.NewRequest(http.MethodGet, server.URL+path, nil)
+	is.NoErr(err)
+
+	req.Header.Set("Authorization", "Bearer token")
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	is.NoErr(err)
+	defer resp.Body.Close()
+
+	return resp
+}
+
+const allowAllPolicy string = `
+package example.authz
+
+default allow := false
+
+allow = response {
+    pathstart := array.slice(input.path, 0, 2)
+    pathstart == ["api", "v0"]
+
+    response := {
+        "tenants": ["default"]
+    }
+}
+`
+
+const adminPolicy string = `
+package example.authz
+
+default allow := false
+
+allow = response {
+    pathstart := array.slice(input.path, 0, 2)
+    pathstart == ["api", "v0"]
+    not is_admin_request
+
+    response := {
+        "tenants": ["default"]
+    }
+}
+
+allow = response {
+    is_admin_request
+    input.token == "admin"
+
+    response := {
+        "tenants": ["default"]
+    }
+}
+
+is_admin_request {
+    pathstart := array.slice(input.path, 0, 3)
+    pathstart == ["api", "v0", "admin"]
+}
+`
+
+func TestUpdatePatchAndDeleteStatusCodes(t *testing.T) {
+	is := is.New(t)
+
+	var appErr error
+	a := &app.ThingsAppMock{
+		UpdateThingFunc: func(ctx context.Context, b []byte, tenants []string) error {
+			return appErr
+		},
+		CreateOrUpdateThingFunc: func(ctx context.Context, b []byte, tenants []string) (bool, error) {
+			return false, appErr
+		},
+		MergeThingFunc: func(ctx context.Context, thingID string, b []byte, tenants []string) error {
+			return appErr
+		},
+		DeleteThingFunc: func(ctx context.Context, thingID string, tenants []string) error {
+			return appErr
+		},
+	}
+
+	server := newTestServer(is, a)
+	defer server.Close()
+
+	do := func(method, path string) int {
+		req, err := http.NewRequest(method, server.URL+path, strings.NewReader(`{"id":"room-001"}`))
+		is.NoErr(err)
+		req.Header.Set("Authorization", "Bearer token")
+
+		resp, err := http.DefaultClient.Do(req)
+		is.NoErr(err)
+		defer resp.Body.Close()
+
+		return resp.StatusCode
+	}
+
+	for _, method := range []string{http.MethodPut, http.MethodPatch, http.MethodDelete} {
+		appErr = nil
+		is.Equal(do(method, "/api/v0/things/room-001"), http.StatusOK)
+
+		appErr = app.ErrThingNotFound
+		is.Equal(do(method, "/api/v0/things/room-001"), http.StatusNotFound)
+
+		appErr = app.ErrMissingThingTenant
+		is.Equal(do(method, "/api/v0/things/room-001"), http.StatusBadRequest)
+
+		appErr = errors.New("database is down")
+		is.Equal(do(method, "/api/v0/things/room-001"), http.StatusInternalServerError)
+	}
+
+	appErr = fmt.Errorf("invalid thing: %w", &json.SyntaxError{})
+	is.Equal(do(http.MethodPatch, "/api/v0/things/room-001"), http.StatusBadRequest)
+}
+
+func TestPutCreatesOrUpdates(t *testing.T) {
+	is := is.New(t)
+
+	room := things.NewRoom("room-001", things.DefaultLocation, "default")
+	existing := map[string]bool{}
+
+	r := &app.ThingsReaderMock{
+		QueryThingsFunc: func(ctx context.Context, conditions ...app.ConditionFunc) (app.QueryResult, error) {
+			if !existing[room.ID()] {
+				return app.QueryResult{}, nil
+			}
+			return app.QueryResult{Data: [][]byte{room.Byte()}, Count: 1, TotalCount: 1}, nil
+		},
+	}
+	w := &app.ThingsWriterMock{
+		UpsertThingFunc: func(ctx context.Context, t things.Thing) (bool, error) {
+			existing[t.ID()] = true
+			return true, nil
+		},
+		UpdateThingFunc: func(ctx context.Context, t things.Thing) error {
+			return nil
+		},
+	}
+	a := app.New(context.Background(), r, w, &messaging.MsgContextMock{})
+
+	server := newTestServer(is, a)
+	defer server.Close()
+
+	do := func(body string) int {
+		req, err := http.NewRequest(http.MethodPut, server.URL+"/api/v0/things/room-001", strings.NewReader(body))
+		is.NoErr(err)
+		req.Header.Set("Authorization", "Bearer token")
+
+		resp, err := http.DefaultClient.Do(req)
+		is.NoErr(err)
+		defer resp.Body.Close()
+
+		return resp.StatusCode
+	}
+
+	is.Equal(do(string(room.Byte())), http.StatusCreated)
+	is.Equal(do(string(room.Byte())), http.StatusOK)
+	is.Equal(len(w.UpsertThingCalls()), 1)
+	is.Equal(len(w.UpdateThingCalls()), 1)
+
+	is.Equal(do(`{"id":"room-001","type":"Room","tenant":"other"}`), http.StatusForbidden)
+	is.Equal(do(`{"id":"room-001","type":"Room"}`), http.StatusBadRequest)
+	is.Equal(do(`{"id":"room-001","type":"Spaceship","tenant":"default"}`), http.StatusBadRequest)
+	is.Equal(do(`{"id":"room-002","type":"Room","tenant":"default"}`), http.StatusBadRequest)
+	is.Equal(len(w.UpsertThingCalls()), 1)
+	is.Equal(len(w.UpdateThingCalls()), 1)
+}
+
+func TestUpdateWithIfMatch(t *testing.T) {
+	is := is.New(t)
+
+	modifiedOn := time.Date(2024, 11, 1, 12, 0, 0, 0, time.UTC)
+	room := things.NewRoom("room-001", things.DefaultLocation, "default")
+
+	r := &app.ThingsReaderMock{
+		QueryThingsFunc: func(ctx context.Context, conditions ...app.ConditionFunc) (app.QueryResult, error) {
+			return app.QueryResult{Data: [][]byte{room.Byte()}, Count: 1, TotalCount: 1, LastModified: modifiedOn}, nil
+		},
+		QueryValuesFunc: func(ctx context.Context, conditions ...app.ConditionFunc) (app.QueryResult, error) {
+			return app.QueryResult{}, nil
+		},
+	}
+	w := &app.ThingsWriterMock{
+		UpdateThingIfUnchangedFunc: func(ctx context.Context, t things.Thing, m time.Time) error {
+			if !m.Equal(modifiedOn) {
+				return app.ErrPreconditionFailed
+			}
+			return nil
+		},
+	}
+	a := app.New(context.Background(), r, w, &messaging.MsgContextMock{})
+
+	server := newTestServer(is, a)
+	defer server.Close()
+
+	resp := get(is, server, "/api/v0/things/room-001", nil)
+	is.Equal(resp.StatusCode, http.StatusOK)
+	etag := resp.Header.Get("ETag")
+
+	do := func(method, ifMatch, body string) int {
+		req, err := http.NewRequest(method, server.URL+"/api/v0/things/room-001", strings.NewReader(body))
+		is.NoErr(err)
+		req.Header.Set("Authorization", "Bearer token")
+		req.Header.Set("If-Match", ifMatch)
+
+		resp, err := http.DefaultClient.Do(req)
+		is.NoErr(err)
+		defer resp.Body.Close()
+
+		return resp.StatusCode
+	}
+
+	is.Equal(do(http.MethodPut, etag, string(room.Byte())), http.StatusOK)
+	is.Equal(do(http.MethodPatch, etag, `{"name":"Room 1"}`), http.StatusOK)
+	is.Equal(len(w.UpdateThingIfUnchangedCalls()), 2)
+
+	is.Equal(do(http.MethodPatch, `"not-an-etag"`, `{"name":"Room 1"}`), http.StatusPreconditionFailed)
+
+	modifiedOn = modifiedOn.Add(1 * time.Minute) // someone else updated the thing
+
+	is.Equal(do(http.MethodPut, etag, string(room.Byte())), http.StatusPreconditionFailed)
+	is.Equal(do(http.MethodPatch, etag, `{"name":"Room 1"}`), http.StatusPreconditionFailed)
+}
+
+func TestCountThings(t *testing.T) {
+	is := is.New(t)
+
+	a := &app.ThingsAppMock{
+		CountThingsFunc: func(ctx context.Context, tenants []string) ([]app.ThingCount, error) {
+			return []app.ThingCount{
+				{Type: "Room", Tenant: "default", Count: 3},
+				{Type: "Sewer", Tenant: "default", Count: 1},
+			}, nil
+		},
+	}
+
+	server := newTestServer(is, a)
+	defer server.Close()
+
+	req, err := http.NewRequest(http.MethodGet, server.URL+"/api/v0/things/count", nil)
+	is.NoErr(err)
+	req.Header.Set("Authorization", "Bearer token")
+
+	resp, err := http.DefaultClient.Do(req)
+	is.NoErr(err)
+	defer resp.Body.Close()
+
+	is.Equal(resp.StatusCode, http.StatusOK)
+
+	response := struct {
+		Data []app.ThingCount `json:"data"`
+	}{}
+	is.NoErr(json.NewDecoder(resp.Body).Decode(&response))
+
+	is.Equal(len(response.Data), 2)
+	is.Equal(response.Data[0], app.ThingCount{Type: "Room", Tenant: "default", Count: 3})
+	is.Equal(a.CountThingsCalls()[0].Tenants, []string{"default"})
+
+	a.CountThingsFunc = func(ctx context.Context, tenants []string) ([]app.ThingCount, error) {
+		return nil, app.ErrMissingThingTenant
+	}
+
+	resp, err = http.DefaultClient.Do(req)
+	is.NoErr(err)
+	defer resp.Body.Close()
+
+	is.Equal(resp.StatusCode, http.StatusBadRequest)
+}
+
+func TestCBOR(t *testing.T) {
+	is := is.New(t)
+
+	room := things.NewRoom("room-001", things.DefaultLocation, "default")
+
+	var added []byte
+	a := &app.ThingsAppMock{
+		AddThingFunc: func(ctx context.Context, b []byte) error {
+			added = b
+			return nil
+		},
+		QueryThingsFunc: func(ctx context.Context, params map[string][]string, tenants []string) (app.QueryResult, error) {
+			return app.QueryResult{Data: [][]byte{room.Byte()}, Count: 1, TotalCount: 1}, nil
+		},
+		QueryValuesFunc: func(ctx context.Context, params map[string][]string, tenants []string) (app.QueryResult, error) {
+			return app.QueryResult{}, nil
+		},
+	}
+
+	server := newTestServer(is, a)
+	defer server.Close()
+
+	body, err := cbor.Marshal(map[string]any{"id": "room-001", "type": "Room", "tenant": "default"})
+	is.NoErr(err)
+
+	req, err := http.NewRequest(http.MethodPost, server.URL+"/api/v0/things", bytes.NewReader(body))
+	is.NoErr(err)
+	req.Header.Set("Authorization", "Bearer token")
+	req.Header.Set("Content-Type", contentTypeCBOR)
+
+	resp, err := http.DefaultClient.Do(req)
+	is.NoErr(err)
+	resp.Body.Close()
+
+	is.Equal(resp.StatusCode, http.StatusCreated)
+
+	thing := map[string]any{}
+	is.NoErr(json.Unmarshal(added, &thing)) // the app is given the thing as json
+	is.Equal(thing["id"], "room-001")
+
+	req, err = http.NewRequest(http.MethodGet, server.URL+"/api/v0/things/room-001", nil)
+	is.NoErr(err)
+	req.Header.Set("Authorization", "Bearer token")
+	req.Header.Set("Accept", contentTypeCBOR)
+
+	resp, err = http.DefaultClient.Do(req)
+	is.NoErr(err)
+	defer resp.Body.Close()
+
+	is.Equal(resp.StatusCode, http.StatusOK)
+	is.Equal(resp.Header.Get("Content-Type"), contentTypeCBOR)
+
+	b, err := io.ReadAll(resp.Body)
+	is.NoErr(err)
+
+	response := struct {
+		Data map[string]any `cbor:"data"`
+	}{}
+	is.NoErr(cbor.Unmarshal(b, &response))
+	is.Equal(response.Data["id"], "room-001")
+	is.Equal(response.Data["type"], "Room")
+}
+
+func TestSeedMapsInvalidThingsAndTenants(t *testing.T) {
+	is := is.New(t)
+
+	var seedErr error
+	a := &app.ThingsAppMock{
+		SeedFunc: func(ctx context.Context, r io.Reader, dryRun bool, tenants []string) (app.SeedReport, error) {
+			is.Equal(tenants, []string{"default"})
+			return app.SeedReport{}, seedErr
+		},
+	}
+
+	server := newTestServer(is, a)
+	defer server.Close()
+
+	seed := func() int {
+		body := &bytes.Buffer{}
+		mw := multipart.NewWriter(body)
+		fw, err := mw.CreateFormFile("fileupload", "things.csv")
+		is.NoErr(err)
+		fw.Write([]byte("id;type;subType;name;decsription;location;tenant;tags;refDevices;args\n"))
+		is.NoErr(mw.Close())
+
+		req, err := http.NewRequest(http.MethodPost, server.URL+"/api/v0/things", body)
+		is.NoErr(err)
+		req.Header.Set("Authorization", "Bearer token")
+		req.Header.Set("Content-Type", mw.FormDataContentType())
+
+		resp, err := http.DefaultClient.Do(req)
+		is.NoErr(err)
+		defer resp.Body.Close()
+
+		return resp.StatusCode
+	}
+
+	is.Equal(seed(), http.StatusCreated)
+
+	seedErr = fmt.Errorf("room-001: %w", app.ErrMissingThingType)
+	is.Equal(seed(), http.StatusBadRequest)
+
+	seedErr = fmt.Errorf("room-001: %w: other", app.ErrForbiddenTenant)
+	is.Equal(seed(), http.StatusForbidden)
+
+	seedErr = errors.New("connection refused")
+	is.Equal(seed(), http.StatusInternalServerError)
+}
@@ -0,0 +1,54 @@
+package api
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"reflect"
+	"strings"
+
+	"github.com/fxamacker/cbor/v2"
+)
+
+const contentTypeCBOR string = "application/cbor"
+
+// decode CBOR maps with string keys, the only keys JSON objects can have
+var cborDecMode, _ = cbor.DecOptions{
+	DefaultMapType: reflect.TypeOf(map[string]any(nil)),
+}.DecMode()
+
+// isCBOR reports whether a Content-Type or Accept header is, or accepts, CBOR
+func isCBOR(mediaType string) bool {
+	return strings.Contains(mediaType, contentTypeCBOR)
+}
+
+// readBody reads the request body as JSON, converting it from CBOR if that is its content type
+func readBody(r *http.Request) ([]byte, error) {
+	b, err := io.ReadAll(r.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	if !isCBOR(r.Header.Get("Content-Type")) {
+		return b, nil
+	}
+
+	var v any
+	err = cborDecMode.Unmarshal(b, &v)
+	if err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(v)
+}
+
+// toCBOR converts a JSON document to CBOR
+func toCBOR(b []byte) ([]byte, error) {
+	var v any
+	err := json.Unmarshal(b, &v)
+	if err != nil {
+		return nil, err
+	}
+
+	return cbor.Marshal(v)
+}
@@ -0,0 +1,394 @@
+package api
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"maps"
+	"net/http"
+	"os"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+
+	app "github.com/diwise/iot-things/internal/app/iot-things"
+	"github.com/diwise/iot-things/internal/pkg/auth"
+	"github.com/diwise/service-chassis/pkg/infrastructure/o11y"
+	"github.com/diwise/service-chassis/pkg/infrastructure/o11y/tracing"
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+)
+
+const (
+	ExportPending string = "pending"
+	ExportRunning string = "running"
+	ExportDone    string = "done"
+	ExportFailed  string = "failed"
+
+	ExportFormatCSV    string = "csv"
+	ExportFormatNDJSON string = "ndjson"
+)
+
+// exportJob is a value export running in the background, so that large exports are not
+// cut short by the request timeout. The result is written to a temporary file.
+//
+// Jobs and their files are kept in memory and on disk of the replica that created them, so with
+// several replicas the status and file of a job must be requested from the same replica, e.g. by
+// routing /api/v0/exports with session affinity.
+type exportJob struct {
+	ID         string     `json:"id"`
+	Status     string     `json:"status"`
+	Format     string     `json:"format"`
+	Count      int        `json:"count"`
+	Error      string     `json:"error,omitempty"`
+	CreatedAt  time.Time  `json:"createdAt"`
+	FinishedAt *time.Time `json:"finishedAt,omitempty"`
+	Link       string     `json:"link,omitempty"`
+
+	params  map[string][]string
+	tenants []string
+	path    string
+}
+
+type exports struct {
+	mu        sync.Mutex
+	jobs      map[string]*exportJob
+	queue     chan queuedExport
+	pageSize  int
+	retention time.Duration
+}
+
+type queuedExport struct {
+	ctx context.Context
+	a   app.ThingsApp
+	id  string
+}
+
+const (
+	defaultExportPageSize  int           = 1000
+	defaultExportRetention time.Duration = 24 * time.Hour
+
+	exportWorkers       int           = 2  // exports running at the same time
+	maxQueuedExports    int           = 20 // exports waiting for a worker, more are rejected
+	exportPruneInterval time.Duration = 1 * time.Hour
+)
+
+var errTooManyExports = errors.New("too many exports, try again later")
+
+// newExports starts the workers running exports and the pruning of old jobs, which stop when ctx is done
+func newExports(ctx context.Context, pageSize int) *exports {
+	e := &exports{
+		jobs:      map[string]*exportJob{},
+		queue:     make(chan queuedExport, maxQueuedExports),
+		pageSize:  pageSize,
+		retention: defaultExportRetention,
+	}
+
+	for range exportWorkers {
+		go e.worker(ctx)
+	}
+
+	go func() {
+		ticker := time.NewTicker(exportPruneInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case now := <-ticker.C:
+				e.prune(now)
+			}
+		}
+	}()
+
+	return e
+}
+
+func (e *exports) worker(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case q := <-e.queue:
+			e.run(q.ctx, q.a, q.id)
+		}
+	}
+}
+
+// get returns a copy of the job if it exists and all of its tenants are allowed
+func (e *exports) get(id string, tenants []string) (exportJob, bool) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	job, ok := e.jobs[id]
+	if !ok {
+		return exportJob{}, false
+	}
+
+	for _, t := range job.tenants {
+		if !slices.Contains(tenants, t) {
+			return exportJob{}, false
+		}
+	}
+
+	return *job, true
+}
+
+func (e *exports) update(id string, fn func(job *exportJob)) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if job, ok := e.jobs[id]; ok {
+		fn(job)
+	}
+}
+
+// prune removes finished jobs, and their files, older than the retention period
+func (e *exports) prune(now time.Time) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	for id, job := range e.jobs {
+		if job.FinishedAt != nil && now.Sub(*job.FinishedAt) > e.retention {
+			if job.path != "" {
+				os.Remove(job.path)
+			}
+			delete(e.jobs, id)
+		}
+	}
+}
+
+// start queues an export, or returns errTooManyExports if too many exports are already waiting
+func (e *exports) start(ctx context.Context, a app.ThingsApp, params map[string][]string, format string, tenants []string) (exportJob, error) {
+	job := &exportJob{
+		ID:        uuid.NewString(),
+		Status:    ExportPending,
+		Format:    format,
+		CreatedAt: time.Now().UTC(),
+		params:    params,
+		tenants:   tenants,
+	}
+
+	e.mu.Lock()
+	e.jobs[job.ID] = job
+	snapshot := *job
+	e.mu.Unlock()
+
+	select {
+	case e.queue <- queuedExport{ctx: context.WithoutCancel(ctx), a: a, id: job.ID}:
+	default:
+		e.mu.Lock()
+		delete(e.jobs, job.ID)
+		e.mu.Unlock()
+		return exportJob{}, errTooManyExports
+	}
+
+	return snapshot, nil
+}
+
+func (e *exports) run(ctx context.Context, a app.ThingsApp, id string) {
+	var job exportJob
+	e.update(id, func(j *exportJob) {
+		j.Status = ExportRunning
+		job = *j
+	})
+
+	count, path, err := e.export(ctx, a, job)
+
+	e.update(id, func(j *exportJob) {
+		now := time.Now().UTC()
+		j.FinishedAt = &now
+		j.Count = count
+		j.path = path
+
+		if err != nil {
+			j.Status = ExportFailed
+			j.Error = err.Error()
+			return
+		}
+
+		j.Status = ExportDone
+		j.Link = fmt.Sprintf("/api/v0/exports/%s/file", j.ID)
+	})
+}
+
+// export pages through the values matching the job and writes them to a temporary file
+func (e *exports) export(ctx context.Context, a app.ThingsApp, job exportJob) (int, string, error) {
+	f, err := os.CreateTemp("", "iot-things-export-*")
+	if err != nil {
+		return 0, "", err
+	}
+	defer f.Close()
+
+	w := bufio.NewWriter(f)
+
+	fail := func(err error) (int, string, error) {
+		os.Remove(f.Name())
+		return 0, "", err
+	}
+
+	if job.Format == ExportFormatCSV {
+		err = writeCSVHeader(valuesCSVColumns, w)
+		if err != nil {
+			return fail(err)
+		}
+	}
+
+	params := maps.Clone(job.params)
+	params["limit"] = []string{strconv.Itoa(e.pageSize)}
+
+	count := 0
+
+	for {
+		params["offset"] = []string{strconv.Itoa(count)}
+
+		result, err := a.QueryValues(ctx, params, job.tenants)
+		if err != nil {
+			return fail(err)
+		}
+
+		if job.Format == ExportFormatCSV {
+			err = writeValuesAsCSV(result.Data, w)
+		} else {
+			for _, b := range result.Data {
+				_, err = fmt.Fprintln(w, string(b))
+				if err != nil {
+					break
+				}
+			}
+		}
+		if err != nil {
+			return fail(err)
+		}
+
+		count += len(result.Data)
+
+		if len(result.Data) < e.pageSize || int64(count) >= result.TotalCount {
+			break
+		}
+	}
+
+	err = w.Flush()
+	if err != nil {
+		return fail(err)
+	}
+
+	return count, f.Name(), nil
+}
+
+func (e *exports) createHandler(log *slog.Logger, a app.ThingsApp) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var err error
+
+		ctx, span := tracer.Start(r.Context(), "create-export")
+		defer func() { tracing.RecordAnyErrorAndEndSpan(err, span) }()
+		_, ctx, logger := o11y.AddTraceIDToLoggerAndStoreInContext(span, log, ctx)
+
+		params := r.URL.Query()
+
+		format := params.Get("format")
+		if format == "" {
+			format = ExportFormatCSV
+		}
+		if format != ExportFormatCSV && format != ExportFormatNDJSON {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte("format must be csv or ndjson"))
+			return
+		}
+		params.Del("format")
+		params.Del("limit")
+		params.Del("offset")
+
+		tenants := auth.GetAllowedTenantsFromContext(ctx)
+
+		job, err := e.start(ctx, a, params, format, tenants)
+		if err != nil {
+			logger.Warn("export job rejected", "err", err.Error())
+			w.Header().Set("Retry-After", "60")
+			w.WriteHeader(http.StatusTooManyRequests)
+			w.Write([]byte(err.Error()))
+			return
+		}
+
+		logger.Debug("export job created", "id", job.ID)
+
+		response := ApiResponse{
+			Data: job,
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Location", fmt.Sprintf("/api/v0/exports/%s", job.ID))
+		w.WriteHeader(http.StatusAccepted)
+		w.Write(response.Byte())
+	}
+}
+
+func (e *exports) statusHandler(log *slog.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var err error
+
+		ctx, span := tracer.Start(r.Context(), "get-export")
+		defer func() { tracing.RecordAnyErrorAndEndSpan(err, span) }()
+
+		job, ok := e.get(chi.URLParam(r, "id"), auth.GetAllowedTenantsFromContext(ctx))
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		response := ApiResponse{
+			Data: job,
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		w.Write(response.Byte())
+	}
+}
+
+func (e *exports) fileHandler(log *slog.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var err error
+
+		ctx, span := tracer.Start(r.Context(), "get-export-file")
+		defer func() { tracing.RecordAnyErrorAndEndSpan(err, span) }()
+		_, ctx, logger := o11y.AddTraceIDToLoggerAndStoreInContext(span, log, ctx)
+
+		job, ok := e.get(chi.URLParam(r, "id"), auth.GetAllowedTenantsFromContext(ctx))
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		if job.Status != ExportDone {
+			w.WriteHeader(http.StatusConflict)
+			w.Write([]byte("export is " + job.Status))
+			return
+		}
+
+		f, err := os.Open(job.path)
+		if err != nil {
+			logger.Error("could not open export file", "id", job.ID, "err", err.Error())
+			if errors.Is(err, os.ErrNotExist) {
+				w.WriteHeader(http.StatusGone)
+				return
+			}
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		defer f.Close()
+
+		contentType := "text/csv"
+		if job.Format == ExportFormatNDJSON {
+			contentType = "application/x-ndjson"
+		}
+
+		w.Header().Set("Content-Type", contentType)
+		w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="export-%s.%s"`, job.ID, job.Format))
+		http.ServeContent(w, r, "", *job.FinishedAt, f)
+	}
+}
@@ -0,0 +1,90 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/diwise/service-chassis/pkg/infrastructure/env"
+)
+
+const (
+	defaultRequestTimeout time.Duration = 60 * time.Second
+	defaultMaxTimeout     time.Duration = 5 * time.Minute
+)
+
+// requestTimeouts returns the timeout applied to requests and the longest timeout a request may ask for.
+// The max timeout caps the default timeout as well.
+func requestTimeouts(ctx context.Context) (time.Duration, time.Duration) {
+	parse := func(name string, d time.Duration) time.Duration {
+		if v, err := time.ParseDuration(env.GetVariableOrDefault(ctx, name, "")); err == nil && v > 0 {
+			return v
+		}
+		return d
+	}
+
+	maxTimeout := parse("MAX_REQUEST_TIMEOUT", defaultMaxTimeout)
+	return min(parse("REQUEST_TIMEOUT", defaultRequestTimeout), maxTimeout), maxTimeout
+}
+
+// timeout cancels the context of a request after the timeout given by the timeout query parameter,
+// or the default timeout if none is given. Requested timeouts are capped at maxTimeout. A request that
+// fails because the deadline was exceeded is responded to with 504 Gateway Timeout.
+func timeout(defaultTimeout, maxTimeout time.Duration) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			d := defaultTimeout
+
+			if t := r.URL.Query().Get("timeout"); t != "" {
+				requested, err := time.ParseDuration(t)
+				if err != nil || requested <= 0 {
+					w.WriteHeader(http.StatusBadRequest)
+					w.Write([]byte("timeout must be a positive duration, e.g. 90s"))
+					return
+				}
+				d = min(requested, maxTimeout)
+			}
+
+			ctx, cancel := context.WithTimeout(r.Context(), d)
+			defer cancel()
+
+			dw := &deadlineWriter{ResponseWriter: w, ctx: ctx}
+
+			defer func() {
+				if !dw.written && errors.Is(ctx.Err(), context.DeadlineExceeded) {
+					w.WriteHeader(http.StatusGatewayTimeout)
+				}
+			}()
+
+			next.ServeHTTP(dw, r.WithContext(ctx))
+		})
+	}
+}
+
+// deadlineWriter replaces server errors caused by an exceeded deadline with 504 Gateway Timeout
+type deadlineWriter struct {
+	http.ResponseWriter
+	ctx     context.Context
+	written bool
+}
+
+func (w *deadlineWriter) WriteHeader(code int) {
+	if w.written {
+		return
+	}
+	w.written = true
+
+	if code >= http.StatusInternalServerError && errors.Is(w.ctx.Err(), context.DeadlineExceeded) {
+		code = http.StatusGatewayTimeout
+	}
+
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *deadlineWriter) Write(b []byte) (int, error) {
+	if !w.written {
+		w.WriteHeader(http.StatusOK)
+	}
+	return w.ResponseWriter.Write(b)
+}
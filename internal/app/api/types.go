@@ -5,6 +5,7 @@ import (
 	"net/http"
 	"net/url"
 	"strconv"
+	"time"
 )
 
 type FeatureCollection struct {
@@ -12,14 +13,32 @@ type FeatureCollection struct {
 	Features []Feature `json:"features"`
 }
 type Feature struct {
-	ID         string   `json:"id"`
-	Type       string   `json:"type"`
-	Geometry   Geometry `json:"geometry"`
-	Properties map[string]any
+	ID         string         `json:"id"`
+	Type       string         `json:"type"`
+	Geometry   Geometry       `json:"geometry"`
+	Properties map[string]any `json:"properties"`
 }
 type Geometry struct {
-	Type        string    `json:"type"`
-	Coordinates []float64 `json:"coordinates"`
+	Type        string `json:"type"`
+	Coordinates any    `json:"coordinates"` // [lon, lat] for a Point, rings of [lon, lat] for a Polygon
+}
+
+// BatchRequest asks for a thing and its values. Without a range the latest value of each value name is returned.
+type BatchRequest struct {
+	ThingID    string      `json:"thingId"`
+	ValueNames []string    `json:"valueNames,omitempty"`
+	Range      *BatchRange `json:"range,omitempty"`
+}
+
+type BatchRange struct {
+	From time.Time  `json:"from"`
+	To   *time.Time `json:"to,omitempty"`
+}
+
+type BatchResult struct {
+	Thing  map[string]any    `json:"thing,omitempty"`
+	Values []json.RawMessage `json:"values,omitempty"`
+	Error  string            `json:"error,omitempty"`
 }
 
 type Resource struct {
@@ -79,6 +98,33 @@ func (r ApiResponse) Byte() []byte {
 	return b
 }
 
+// withCursor links to the next page of values using cursor. Offset based links do not apply when
+// paging by cursor, since the total is then not counted.
+func withCursor(u url.URL, l *links, cursor string) *links {
+	query := u.Query()
+
+	if query.Has("cursor") || l == nil {
+		self := u.String()
+
+		query.Del("cursor")
+		query.Del("offset")
+		u.RawQuery = query.Encode()
+		first := u.String()
+
+		l = &links{Self: &self, First: &first}
+	}
+
+	if cursor != "" {
+		query.Del("offset")
+		query.Set("cursor", cursor)
+		u.RawQuery = query.Encode()
+		next := u.String()
+		l.Next = &next
+	}
+
+	return l
+}
+
 func createLinks(u *url.URL, m *meta) *links {
 	if m == nil || m.TotalRecords == 0 || m.Count == nil || (*m.Count == m.TotalRecords) {
 		return nil
@@ -1,6 +1,8 @@
 package iotthings
 
 import (
+	"bufio"
+	"bytes"
 	"context"
 	"encoding/csv"
 	"encoding/json"
@@ -8,12 +10,15 @@ import (
 	"fmt"
 	"io"
 	"log/slog"
+	"maps"
+	"reflect"
 	"slices"
 	"strconv"
 	"strings"
 	"sync"
 	"time"
 
+	"github.com/diwise/iot-things/internal/app/iot-things/functions"
 	"github.com/diwise/iot-things/internal/app/iot-things/things"
 	"github.com/diwise/iot-things/pkg/types"
 	"github.com/diwise/messaging-golang/pkg/messaging"
@@ -23,22 +28,46 @@ import (
 
 //go:generate moq -rm -out app_mock.go . ThingsApp
 type ThingsApp interface {
-	HandleMeasurements(ctx context.Context, measurements []things.Measurement)
+	HandleMeasurements(ctx context.Context, measurements []things.Measurement) IngestResult
 
 	AddThing(ctx context.Context, b []byte) error
+	CreateOrUpdateThing(ctx context.Context, b []byte, tenants []string) (bool, error)
 	DeleteThing(ctx context.Context, thingID string, tenants []string) error
+	RestoreThing(ctx context.Context, thingID string, tenants []string) error
 	MergeThing(ctx context.Context, thingID string, b []byte, tenants []string) error
-	QueryThings(ctx context.Context, params map[string][]string) (QueryResult, error)
+	MergeThings(ctx context.Context, params map[string][]string, b []byte, tenants []string) (int, error)
+	CloneThing(ctx context.Context, thingID string, b []byte, tenants []string) (things.Thing, error)
+	FindDuplicates(ctx context.Context, tenants []string) ([]Duplicate, error)
+	MergeDuplicate(ctx context.Context, targetID, sourceID string, tenants []string) (things.Thing, error)
+	AddRelatedThing(ctx context.Context, thingID string, b []byte, tenants []string) error
+	GetRelatedThings(ctx context.Context, thingID string, tenants []string) ([]things.Relation, error)
+	QueryThings(ctx context.Context, params map[string][]string, tenants []string) (QueryResult, error)
 	UpdateThing(ctx context.Context, b []byte, tenants []string) error
 
 	AddValue(ctx context.Context, t things.Thing, m things.Value) error
-	QueryValues(ctx context.Context, params map[string][]string) (QueryResult, error)
+	AddValues(ctx context.Context, thingID string, values []things.Value, tenants []string) (int64, error)
+	QueryValues(ctx context.Context, params map[string][]string, tenants []string) (QueryResult, error)
+	ListValues(ctx context.Context, params map[string][]string, tenants []string) (QueryResult, error)
+	DeleteValues(ctx context.Context, thingID string, params map[string][]string, tenants []string) (int64, error)
+	GetRecentValues(ctx context.Context, thingID string, n int, urns []string, tenants []string) (QueryResult, error)
+	GetLatestValues(ctx context.Context, thingID string, tenants []string) (QueryResult, error)
+	GetUtilization(ctx context.Context, thingID string, from, to time.Time, tenants []string) (Utilization, error)
+	GetCompleteness(ctx context.Context, thingID string, from, to time.Time, interval time.Duration, tenants []string) (Completeness, error)
+	GetTimeline(ctx context.Context, thingID string, from, to time.Time, tenants []string) ([]StateInterval, error)
 
 	GetTags(ctx context.Context, tenants []string) ([]string, error)
 	GetTypes(ctx context.Context, tenants []string) ([]things.ThingType, error)
+	GetUrns(ctx context.Context, thingID string, tenants []string) ([]string, []string, error)
+	GetValueRange(ctx context.Context, thingID string, tenants []string) (ValueRange, error)
+	GetThingsNeedingAttention(ctx context.Context, tenants []string) ([]Attention, error)
+	CountThings(ctx context.Context, tenants []string) ([]ThingCount, error)
 
 	LoadConfig(ctx context.Context, r io.Reader) error
-	Seed(ctx context.Context, r io.Reader) error
+	Seed(ctx context.Context, r io.Reader, dryRun bool, tenants []string) (SeedReport, error)
+	SeedInventory(ctx context.Context, r io.Reader, tenants []string) error
+
+	Compact(ctx context.Context) (int64, int64, error)
+	GetTenants(ctx context.Context) ([]TenantCount, error)
 }
 
 //go:generate moq -rm -out reader_mock.go . ThingsReader
@@ -46,50 +75,265 @@ type ThingsReader interface {
 	QueryThings(ctx context.Context, conditions ...ConditionFunc) (QueryResult, error)
 	QueryValues(ctx context.Context, conditions ...ConditionFunc) (QueryResult, error)
 	GetTags(ctx context.Context, tenants []string) ([]string, error)
+	GetUrns(ctx context.Context, thingID string) ([]string, error)
+	CountByTenant(ctx context.Context) ([]TenantCount, error)
+	CountThings(ctx context.Context, tenants []string) ([]ThingCount, error)
+	GetValueBuckets(ctx context.Context, thingID string, from, to time.Time, interval time.Duration) ([]time.Time, error)
+	GetValueRange(ctx context.Context, thingID string) (ValueRange, error)
+}
+
+// TenantCount is the number of things, and values belonging to them, of a tenant
+type TenantCount struct {
+	Tenant string `json:"tenant"`
+	Things int64  `json:"things"`
+	Values int64  `json:"values"`
+}
+
+// ThingCount is the number of things of a type and tenant
+type ThingCount struct {
+	Type   string `json:"type"`
+	Tenant string `json:"tenant"`
+	Count  int64  `json:"count"`
+}
+
+// ValueRange is the time of the earliest and latest stored value of a thing, nil if it has no values
+type ValueRange struct {
+	Earliest *time.Time `json:"earliest"`
+	Latest   *time.Time `json:"latest"`
+	Count    int64      `json:"count"`
 }
 
 //go:generate moq -rm -out writer_mock.go . ThingsWriter
 type ThingsWriter interface {
 	AddThing(ctx context.Context, t things.Thing) error
+	UpsertThing(ctx context.Context, t things.Thing) (bool, error)
 	UpdateThing(ctx context.Context, t things.Thing) error
+	UpdateThingIfUnchanged(ctx context.Context, t things.Thing, modifiedOn time.Time) error
+	UpdateThings(ctx context.Context, t []things.Thing) error
+	AddRelations(ctx context.Context, relations map[string]things.Relation) error
 	DeleteThing(ctx context.Context, thingID string) error
+	MergeDuplicate(ctx context.Context, target things.Thing, sourceID string) error
+	UndeleteThing(ctx context.Context, thingID string) error
 	AddValue(ctx context.Context, t things.Thing, m things.Value) error
+	AddValueWithAggregate(ctx context.Context, t things.Thing, m things.Value) error
+	AddValues(ctx context.Context, t things.Thing, values []things.Value, aggregate bool) (int64, error)
+	DeleteValues(ctx context.Context, thingID string, conditions ...ConditionFunc) (int64, error)
+	RedactValues(ctx context.Context, thingID string, conditions ...ConditionFunc) (int64, error)
+	PurgeDeletedThings(ctx context.Context, deletedBefore time.Time) (int64, int64, error)
 }
 
 var (
-	ErrThingNotFound      = errors.New("thing not found")
-	ErrAlreadyExists      = errors.New("thing already exists")
-	ErrMissingThingID     = errors.New("thing ID must be provided")
-	ErrMissingThingTenant = errors.New("tenant must be provided")
-	ErrMissingThingType   = errors.New("thing type must be provided")
+	ErrThingNotFound       = errors.New("thing not found")
+	ErrAlreadyExists       = errors.New("thing already exists")
+	ErrMissingThingID      = errors.New("thing ID must be provided")
+	ErrMissingThingTenant  = errors.New("tenant must be provided")
+	ErrMissingThingType    = errors.New("thing type must be provided")
+	ErrMissingArgs         = errors.New("required args must be provided")
+	ErrInvalidArgs         = errors.New("invalid args")
+	ErrTimeRangeExceeded   = errors.New("time range exceeds maximum allowed")
+	ErrInvalidStatus       = errors.New("invalid thing status")
+	ErrInvalidParams       = errors.New("invalid query parameters")
+	ErrMissingConfirmation = errors.New("confirm=true must be provided")
+	ErrForbiddenTenant     = errors.New("tenant not allowed")
+	ErrCannotMerge         = errors.New("things cannot be merged")
+	ErrPreconditionFailed  = errors.New("thing has been modified")
+	ErrInvalidValue        = errors.New("invalid value")
+	ErrInvalidRelation     = errors.New("invalid relation")
 )
 
 type app struct {
 	reader ThingsReader
 	writer ThingsWriter
 	cfg    *config
+	cfgMu  sync.RWMutex
+
+	pub     chan changedThing
+	msgCtx  messaging.MsgContext
+	sampler *sampler
 
-	pub chan string
+	workers     []chan ingestJob
+	workersOnce sync.Once
+	done        <-chan struct{} // closed when the app is stopped
 }
 
 type config struct {
-	Types []typeConfig `json:"types" yaml:"types"`
+	Types              []typeConfig       `json:"types" yaml:"types"`
+	Compaction         compactionConfig   `json:"compaction" yaml:"compaction"`
+	LocationPrecedence string             `json:"locationPrecedence" yaml:"locationPrecedence"`
+	OutOfOrder         string             `json:"outOfOrder" yaml:"outOfOrder"`
+	FieldNames         map[string]string  `json:"fieldNames,omitempty" yaml:"fieldNames,omitempty"`
+	Publisher          publisherConfig    `json:"publisher" yaml:"publisher"`
+	Ingest             ingestConfig       `json:"ingest" yaml:"ingest"`
+	Values             valuesConfig       `json:"values" yaml:"values"`
+	Tags               tagsConfig         `json:"tags" yaml:"tags"`
+	Attention          attentionConfig    `json:"attention" yaml:"attention"`
+	ChangeThresholds   map[string]float64 `json:"changeThresholds,omitempty" yaml:"changeThresholds,omitempty"` // urn -> minimum difference for a value to be stored
+	TagRules           []tagRule          `json:"tagRules,omitempty" yaml:"tagRules,omitempty"`
+
+	// StrictParams rejects queries with malformed query parameters instead of ignoring them
+	StrictParams bool `json:"strictParams,omitempty" yaml:"strictParams,omitempty"`
+
+	// UnknownTypeFallback loads stored things of unknown types as passthrough things, storing values as reported.
+	// Things of unknown types are still rejected when created, updated or seeded.
+	UnknownTypeFallback bool `json:"unknownTypeFallback,omitempty" yaml:"unknownTypeFallback,omitempty"`
+
+	Tenants map[string]tenantConfig `json:"tenants,omitempty" yaml:"tenants,omitempty"` // per tenant overrides
+}
+
+// tenantConfig overrides the global configuration for things belonging to a tenant
+type tenantConfig struct {
+	Types            []typeConfig       `json:"types,omitempty" yaml:"types,omitempty"`                       // replaces the global types if set
+	ChangeThresholds map[string]float64 `json:"changeThresholds,omitempty" yaml:"changeThresholds,omitempty"` // merged with the global thresholds
+}
+
+// typesFor returns the types configured for the tenant, or the global types if there is no override
+func (c *config) typesFor(tenant string) []typeConfig {
+	if tc, ok := c.Tenants[tenant]; ok && tc.Types != nil {
+		return tc.Types
+	}
+	return c.Types
+}
+
+type tagsConfig struct {
+	Lowercase bool `json:"lowercase" yaml:"lowercase"`
+}
+
+type valuesConfig struct {
+	DefaultLookback time.Duration  `json:"defaultLookback" yaml:"defaultLookback"`             // applied when a value query has no time filter
+	MaxLookback     time.Duration  `json:"maxLookback" yaml:"maxLookback"`                     // the longest time range a value query may span
+	IntegerURNs     []string       `json:"integerUrns,omitempty" yaml:"integerUrns,omitempty"` // urns with whole number values, stored as integers
+	Sampling        []samplingRule `json:"sampling,omitempty" yaml:"sampling,omitempty"`       // values of matching things and urns are thinned before being stored
+}
+
+const (
+	defaultValuesLookback time.Duration = 24 * time.Hour
+	defaultValuesMaxRange time.Duration = 365 * 24 * time.Hour
+)
+
+type publisherConfig struct {
+	Mode   string        `json:"mode" yaml:"mode"`     // "thing" (default) publishes one message per thing, "digest" one message per tenant
+	Window time.Duration `json:"window" yaml:"window"` // how long updates are collected before being published
+
+	// OnChangeOnly skips publishing things where a measurement changed nothing but timestamps
+	OnChangeOnly bool `json:"onChangeOnly,omitempty" yaml:"onChangeOnly,omitempty"`
+
+	// Topic and ContentType override the topic and content type of thing.updated messages. Other messages,
+	// e.g. thing.deleted, keep the topic and content type given by their type so that consumers can tell them apart.
+	Topic       string `json:"topic,omitempty" yaml:"topic,omitempty"`
+	ContentType string `json:"contentType,omitempty" yaml:"contentType,omitempty"`
+
+	// Envelope is "raw" (default) to publish messages as is, or "cloudevents" to wrap them as CloudEvents from Source
+	Envelope string `json:"envelope,omitempty" yaml:"envelope,omitempty"`
+	Source   string `json:"source,omitempty" yaml:"source,omitempty"`
+
+	// Seed is what is published when things are seeded, "none" (default), "things" or "summary"
+	Seed string `json:"seed,omitempty" yaml:"seed,omitempty"`
+}
+
+type outboundMessage struct {
+	messaging.TopicMessage
+	topic       string
+	contentType string
+}
+
+func (m outboundMessage) TopicName() string {
+	if m.topic != "" {
+		return m.topic
+	}
+	return m.TopicMessage.TopicName()
+}
+
+func (m outboundMessage) ContentType() string {
+	if m.contentType != "" {
+		return m.contentType
+	}
+	return m.TopicMessage.ContentType()
+}
+
+// target wraps msg in the configured envelope, and publishes updated things to the configured topic and content type, if any
+func (c publisherConfig) target(msg messaging.TopicMessage) messaging.TopicMessage {
+	_, updated := msg.(*types.ThingUpdated)
+
+	if c.Envelope == EnvelopeCloudEvents {
+		msg = types.NewCloudEvent(msg, c.source())
+	}
+
+	if !updated || (c.Topic == "" && c.ContentType == "") {
+		return msg
+	}
+	return outboundMessage{
+		TopicMessage: msg,
+		topic:        c.Topic,
+		contentType:  c.ContentType,
+	}
+}
+
+const (
+	PublishModeThing  string = "thing"
+	PublishModeDigest string = "digest"
+
+	defaultPublishWindow time.Duration = 2 * time.Second
+
+	SeedPublishNone    string = "none"    // seeded things are not published
+	SeedPublishThings  string = "things"  // each seeded thing is published like an updated thing
+	SeedPublishSummary string = "summary" // one seed.completed message is published when the seed is done
+
+	EnvelopeRaw         string = "raw"
+	EnvelopeCloudEvents string = "cloudevents"
+
+	defaultEventSource string = "github.com/diwise/iot-things"
+)
+
+func (c publisherConfig) source() string {
+	if c.Source != "" {
+		return c.Source
+	}
+	return defaultEventSource
+}
+
+func (c publisherConfig) window() time.Duration {
+	if c.Window > 0 {
+		return c.Window
+	}
+	return defaultPublishWindow
 }
 
+type compactionConfig struct {
+	GracePeriod time.Duration `json:"gracePeriod" yaml:"gracePeriod"`
+}
+
+const defaultCompactionGracePeriod time.Duration = 30 * 24 * time.Hour
+
 type typeConfig struct {
-	Type     string   `json:"type" yaml:"type"`
-	SubTypes []string `json:"subTypes" yaml:"subTypes"`
+	Type         string   `json:"type" yaml:"type"`
+	SubTypes     []string `json:"subTypes" yaml:"subTypes"`
+	RequiredArgs []string `json:"requiredArgs,omitempty" yaml:"requiredArgs,omitempty"`
+
+	// used when seeding things with a blank location or tenant
+	DefaultLocation *things.Location `json:"defaultLocation,omitempty" yaml:"defaultLocation,omitempty"`
+	Inherit         []string         `json:"inherit,omitempty" yaml:"inherit,omitempty"` // fields inherited from the parent thing, "location" and/or "tenant"
+
+	// Aggregate keeps a daily sum/avg per value id up to date when values are added, trading write cost for fast reads
+	Aggregate bool `json:"aggregate,omitempty" yaml:"aggregate,omitempty"`
 }
 
+const (
+	InheritLocation string = "location"
+	InheritTenant   string = "tenant"
+)
+
 func New(ctx context.Context, r ThingsReader, w ThingsWriter, msgCtx messaging.MsgContext) ThingsApp {
 	a := &app{
 		reader: r,
 		writer: w,
 
-		pub: make(chan string),
+		pub:     make(chan changedThing, pubBufferSize),
+		msgCtx:  msgCtx,
+		sampler: newSampler(),
+		done:    ctx.Done(),
 	}
 
-	go publisher(ctx, a.reader, msgCtx, a.pub)
+	go publisher(ctx, a.reader, msgCtx, a.pub, a.loadThing, a.publisherConfig)
 
 	return a
 }
@@ -101,100 +345,281 @@ func (a *app) LoadConfig(ctx context.Context, r io.Reader) error {
 		return err
 	}
 
+	a.cfgMu.Lock()
 	a.cfg = &c
+	a.cfgMu.Unlock()
 
 	return nil
 }
 
-var mu = sync.Mutex{}
+// changeThresholds returns the change thresholds per urn of a tenant, the global thresholds overridden by those of the tenant
+func (a *app) changeThresholds(tenant string) map[string]float64 {
+	a.cfgMu.RLock()
+	defer a.cfgMu.RUnlock()
+
+	if a.cfg == nil {
+		return nil
+	}
 
-func (a *app) HandleMeasurements(ctx context.Context, measurements []things.Measurement) {
-	mu.Lock()
-	defer mu.Unlock()
+	tc, ok := a.cfg.Tenants[tenant]
+	if !ok || len(tc.ChangeThresholds) == 0 {
+		return a.cfg.ChangeThresholds
+	}
 
-	changedThings := []string{}
+	thresholds := maps.Clone(a.cfg.ChangeThresholds)
+	if thresholds == nil {
+		thresholds = map[string]float64{}
+	}
+	maps.Copy(thresholds, tc.ChangeThresholds)
+
+	return thresholds
+}
+
+// isIntegerURN reports whether the numeric values of urn are whole numbers, e.g. counters, to be stored as integers
+func (a *app) isIntegerURN(urn string) bool {
+	a.cfgMu.RLock()
+	defer a.cfgMu.RUnlock()
+
+	return a.cfg != nil && slices.Contains(a.cfg.Values.IntegerURNs, urn)
+}
+
+func (a *app) publisherConfig() publisherConfig {
+	a.cfgMu.RLock()
+	defer a.cfgMu.RUnlock()
+
+	if a.cfg == nil {
+		return publisherConfig{}
+	}
+	return a.cfg.Publisher
+}
 
+func (a *app) HandleMeasurements(ctx context.Context, measurements []things.Measurement) IngestResult {
+	result := IngestResult{Records: []RecordResult{}}
+
+	// all measurements are dispatched before waiting for any of them, so that different things are handled concurrently
+	pending := make([]func() ([]string, RecordResult), 0, len(measurements))
 	for _, m := range measurements {
-		changedThings = append(changedThings, a.handle(ctx, m)...)
+		pending = append(pending, a.dispatch(ctx, m))
+	}
+
+	changedThings := []string{}
+	correlationIDs := map[string]string{}
+
+	for i, wait := range pending {
+		changed, rr := wait()
+		meters().measurementsHandled.Add(ctx, 1, withStatus(rr.Status))
+		changedThings = append(changedThings, changed...)
+		for _, thingID := range changed {
+			correlationIDs[thingID] = measurements[i].CorrelationID
+		}
+		result.add(rr)
 	}
 
 	if len(changedThings) > 0 {
 		for _, thingID := range unique(changedThings) {
-			a.pub <- thingID
+			a.pub <- changedThing{thingID: thingID, correlationID: correlationIDs[thingID]}
 		}
 	}
+
+	return result
 }
 
-func (a *app) handle(ctx context.Context, m things.Measurement) []string {
+// handle handles a measurement for all things connected to its device and returns the things that changed
+func (a *app) handle(ctx context.Context, m things.Measurement) ([]string, RecordResult) {
+	return a.dispatch(ctx, m)()
+}
+
+// dispatch queues a measurement for each of the things connected to its device and returns a func
+// waiting for them to be handled
+func (a *app) dispatch(ctx context.Context, m things.Measurement) func() ([]string, RecordResult) {
+	result := RecordResult{Name: m.ID, Status: RecordStored}
+
 	connectedThings, err := a.getConnectedThings(ctx, m.DeviceID())
 	if err != nil {
-		return []string{}
+		result.Status, result.Reason = RecordError, err.Error()
+		return func() ([]string, RecordResult) { return []string{}, result }
 	}
 
-	changedThings := []string{}
+	if len(connectedThings) == 0 {
+		result.Status, result.Reason = RecordSkipped, "no connected things"
+		return func() ([]string, RecordResult) { return []string{}, result }
+	}
 
+	done := make([]<-chan ingestResult, 0, len(connectedThings))
 	for _, t := range connectedThings {
-		measurements := []things.Measurement{m}
-		err := t.Handle(measurements, func(m things.ValueProvider) error {
-			var errs []error
+		done = append(done, a.enqueue(ctx, t.ID(), m))
+	}
 
-			for _, v := range m.Values() {
-				errs = append(errs, a.AddValue(ctx, t, v)) // add value to storage. A value is a measurement with the thingID instead of the deviceID
+	return func() ([]string, RecordResult) {
+		changedThings := []string{}
+		var errs []error
+
+		// the remaining things are handled even if some of them fail
+		for _, d := range done {
+			r := a.wait(d)
+			if r.err != nil {
+				errs = append(errs, r.err)
+				continue
 			}
+			if r.changed {
+				changedThings = append(changedThings, r.thingID)
+			}
+		}
 
-			return errors.Join(errs...)
-		})
-		if err != nil {
-			continue
+		if err := errors.Join(errs...); err != nil {
+			meters().thingErrors.Add(ctx, int64(len(errs)))
+			result.Status, result.Reason = RecordError, err.Error()
 		}
 
-		t.SetLastObserved(measurements) // adds the current measurement to its (ref)device and ObservedAt if the timestamp is newer
+		return changedThings, result
+	}
+}
 
-		err = a.saveThing(ctx, t)
-		if err != nil {
-			continue
+// handleThing applies a measurement to a thing and reports whether the thing changed. The thing is loaded
+// here, rather than when the measurement is dispatched, so that it includes the changes of any earlier
+// measurement for the same thing.
+func (a *app) handleThing(ctx context.Context, thingID string, m things.Measurement) (bool, error) {
+	result, err := a.reader.QueryThings(ctx, WithID(thingID))
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", thingID, err)
+	}
+	if len(result.Data) != 1 {
+		return false, nil // deleted since the measurement was dispatched
+	}
+
+	t, err := a.loadThing(result.Data[0])
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", thingID, err)
+	}
+
+	if !slices.ContainsFunc(t.Refs(), func(d things.Device) bool { return d.DeviceID == m.DeviceID() }) {
+		return false, nil // disconnected since the measurement was dispatched
+	}
+
+	log := logging.GetFromContext(ctx)
+
+	if m.Location != nil {
+		t.SetObservedLocation(*m.Location, m.Timestamp, a.locationPrecedence())
+	}
+
+	t.SetOutOfOrderPolicy(a.outOfOrderPolicy())
+	t.SetChangeThresholds(a.changeThresholds(t.Tenant()))
+
+	before := visibleState(t)
+
+	calibrated := things.Calibrate(t, m)
+
+	// alerts are published once the thing is saved, so that a measurement redelivered after a failed save
+	// does not publish the same alert twice
+	var alerts []things.WaterMeterAlert
+
+	measurements := []things.Measurement{calibrated}
+	err = t.Handle(measurements, func(vp things.ValueProvider) error {
+		if alert, ok := vp.(things.WaterMeterAlert); ok {
+			alerts = append(alerts, alert)
+		}
+
+		var errs []error
+
+		for _, v := range vp.Values() {
+			v.Quality = m.Quality // values derived from a flagged measurement carry the same flag
+			v.Calibrated = calibrated.Calibrated
+			v.CorrelationID = m.CorrelationID
+			if !a.sample(t, v) {
+				continue
+			}
+			errs = append(errs, a.AddValue(ctx, t, v)) // add value to storage. A value is a measurement with the thingID instead of the deviceID
 		}
 
-		changedThings = append(changedThings, t.ID())
+		return errors.Join(errs...)
+	})
+	if err != nil {
+		log.Error("could not handle measurement", "thingID", t.ID(), "measurementID", m.ID, "err", err.Error())
+		return false, fmt.Errorf("%s: %w", t.ID(), err)
+	}
+
+	t.SetLastObserved(measurements) // adds the current measurement to its (ref)device and ObservedAt if the timestamp is newer
+
+	a.applyTagRules(t)
+
+	err = a.saveThing(ctx, t)
+	if err != nil {
+		log.Error("could not save thing", "thingID", t.ID(), "measurementID", m.ID, "err", err.Error())
+		return false, fmt.Errorf("%s: %w", t.ID(), err)
+	}
+
+	for _, alert := range alerts {
+		a.publishWaterMeterAlert(ctx, t, alert)
 	}
 
-	return changedThings
+	if a.publisherConfig().OnChangeOnly && reflect.DeepEqual(before, visibleState(t)) {
+		return false, nil
+	}
+
+	return true, nil
+}
+
+func (a *app) locationPrecedence() string {
+	a.cfgMu.RLock()
+	defer a.cfgMu.RUnlock()
+
+	if a.cfg == nil || a.cfg.LocationPrecedence == "" {
+		return things.LocationPrecedenceThing
+	}
+	return a.cfg.LocationPrecedence
+}
+
+func (a *app) outOfOrderPolicy() string {
+	a.cfgMu.RLock()
+	defer a.cfgMu.RUnlock()
+
+	if a.cfg == nil || a.cfg.OutOfOrder == "" {
+		return things.OutOfOrderSkip
+	}
+	return a.cfg.OutOfOrder
+}
+
+// changedThing is a thing to publish, with the correlation id of the measurement that changed it
+type changedThing struct {
+	thingID       string
+	correlationID string
+	changedAt     time.Time // when the thing was first changed since it was last published
 }
 
-func publisher(ctx context.Context, r ThingsReader, msgCtx messaging.MsgContext, in chan string) {
+// pendingThing is a changed thing waiting for its publish window to pass
+type pendingThing struct {
+	pubAfter      time.Time
+	correlationID string
+	changedAt     time.Time
+}
+
+func publisher(ctx context.Context, r ThingsReader, msgCtx messaging.MsgContext, in chan changedThing, load func([]byte) (things.Thing, error), settings func() publisherConfig) {
 	log := logging.GetFromContext(ctx)
 
 	thingsToPub := new(sync.Map)
-	pub := make(chan string)
+	pub := make(chan changedThing)
+	digest := make(chan []changedThing)
 
 	go func() {
-		for thingID := range pub {
-			result, err := r.QueryThings(ctx, WithID(thingID))
-			if err != nil {
-				log.Error("could not query thing", "err", err.Error())
-				continue
-			}
-
-			if len(result.Data) != 1 {
-				log.Debug("thing not found", "thingID", thingID, slog.Int("count", len(result.Data)))
-				continue
-			}
+		for changed := range pub {
+			thingID := changed.thingID
 
-			t, err := things.ConvToThing(result.Data[0])
+			t, err := getThingToPublish(ctx, r, thingID, load)
 			if err != nil {
-				log.Error("could not convert thing", "err", err.Error())
 				continue
 			}
 
 			msg := &types.ThingUpdated{ // for each updated connected thing, publish thing.updated
-				ID:        t.ID(),
-				Type:      t.Type(),
-				Thing:     stripFields(t),
-				Tenant:    t.Tenant(),
-				Timestamp: time.Now().UTC(),
+				ID:            t.ID(),
+				Type:          t.Type(),
+				Thing:         stripFields(t),
+				Tenant:        t.Tenant(),
+				Timestamp:     time.Now().UTC(),
+				CorrelationID: changed.correlationID,
 			}
 
-			err = msgCtx.PublishOnTopic(ctx, msg)
+			err = msgCtx.PublishOnTopic(ctx, settings().target(msg))
+			observePublished(ctx, changed.changedAt, err)
 			if err != nil {
 				log.Error("could not publish message", "err", err.Error())
 				continue
@@ -204,46 +629,121 @@ func publisher(ctx context.Context, r ThingsReader, msgCtx messaging.MsgContext,
 		}
 	}()
 
+	go func() {
+		for changedThings := range digest {
+			byTenant := map[string][]changedThing{}
+
+			for _, changed := range changedThings {
+				t, err := getThingToPublish(ctx, r, changed.thingID, load)
+				if err != nil {
+					continue
+				}
+				byTenant[t.Tenant()] = append(byTenant[t.Tenant()], changed)
+			}
+
+			for tenant, changed := range byTenant {
+				ids := make([]string, 0, len(changed))
+				for _, c := range changed {
+					ids = append(ids, c.thingID)
+				}
+
+				msg := &types.ThingsUpdated{ // one digest of updated things per tenant
+					IDs:       ids,
+					Tenant:    tenant,
+					Timestamp: time.Now().UTC(),
+				}
+
+				err := msgCtx.PublishOnTopic(ctx, settings().target(msg))
+				for _, c := range changed {
+					observePublished(ctx, c.changedAt, err)
+				}
+				if err != nil {
+					log.Error("could not publish message", "err", err.Error())
+				}
+			}
+		}
+	}()
+
+	ticker := time.NewTicker(settings().window())
+	defer ticker.Stop()
+
 	for {
 		select {
 		case <-ctx.Done():
 			return
 
-		case thingID := <-in:
-			pubAfter := time.Now().Add(2 * time.Second)
-			thingsToPub.Store(thingID, pubAfter)
+		case changed := <-in:
+			// within the window, the last measurement changing the thing is the one correlated with the update
+			now := time.Now()
+			p := pendingThing{pubAfter: now.Add(settings().window()), correlationID: changed.correlationID, changedAt: now}
+			if pending, ok := thingsToPub.Load(changed.thingID); ok {
+				p.changedAt = pending.(pendingThing).changedAt
+			}
+			thingsToPub.Store(changed.thingID, p)
+
+		case ts := <-ticker.C:
+			cfg := settings()
+			ticker.Reset(cfg.window())
+
+			ready := []changedThing{}
 
-		case ts := <-time.Tick(2 * time.Second):
 			thingsToPub.Range(func(key, value any) bool {
-				t, ok := value.(time.Time)
+				p, ok := value.(pendingThing)
 				if ok {
-					if t.Before(ts) {
+					if p.pubAfter.Before(ts) {
 						thingID, ok := key.(string)
 						if ok {
-							pub <- thingID
+							ready = append(ready, changedThing{thingID: thingID, correlationID: p.correlationID, changedAt: p.changedAt})
 						}
 					}
 				}
 				return true
 			})
+
+			if cfg.Mode == PublishModeDigest {
+				if len(ready) > 0 {
+					for _, changed := range ready {
+						thingsToPub.Delete(changed.thingID)
+					}
+					digest <- ready
+				}
+				continue
+			}
+
+			for _, changed := range ready {
+				pub <- changed
+			}
 		}
 	}
 }
 
-func (a *app) AddThing(ctx context.Context, b []byte) error {
-	t, err := things.ConvToThing(b)
+func getThingToPublish(ctx context.Context, r ThingsReader, thingID string, load func([]byte) (things.Thing, error)) (things.Thing, error) {
+	log := logging.GetFromContext(ctx)
+
+	result, err := r.QueryThings(ctx, WithID(thingID))
 	if err != nil {
-		return err
+		log.Error("could not query thing", "err", err.Error())
+		return nil, err
 	}
 
-	if t.ID() == "" {
-		return ErrMissingThingID
+	if len(result.Data) != 1 {
+		log.Debug("thing not found", "thingID", thingID, slog.Int("count", len(result.Data)))
+		return nil, ErrThingNotFound
 	}
-	if t.Tenant() == "" {
-		return ErrMissingThingTenant
+
+	t, err := load(result.Data[0])
+	if err != nil {
+		log.Error("could not convert thing", "err", err.Error())
+		return nil, err
 	}
-	if t.Type() == "" {
-		return ErrMissingThingType
+
+	return t, nil
+}
+
+func (a *app) AddThing(ctx context.Context, b []byte) error {
+	t, err := a.validateNewThing(b)
+	if err != nil {
+		return err
 	}
 
 	err = a.writer.AddThing(ctx, t)
@@ -254,104 +754,290 @@ func (a *app) AddThing(ctx context.Context, b []byte) error {
 	return nil
 }
 
-func (a *app) UpdateThing(ctx context.Context, b []byte, tenants []string) error {
+// CreateOrUpdateThing adds the thing in b, or replaces it if it already exists, and reports whether it was
+// added. An existing thing is replaced the same way as by UpdateThing, required args are only checked on insert.
+func (a *app) CreateOrUpdateThing(ctx context.Context, b []byte, tenants []string) (bool, error) {
 	if len(tenants) == 0 {
-		return errors.New("tenants must be provided")
+		return false, errors.New("tenants must be provided")
 	}
 
-	t, err := things.ConvToThing(b)
+	b, err := withoutRelations(b)
 	if err != nil {
-		return err
+		return false, err
 	}
 
-	if t.ID() == "" {
-		return ErrMissingThingID
-	}
-	if t.Tenant() == "" {
-		return ErrMissingThingTenant
+	t, err := a.validateThing(b)
+	if err != nil {
+		return false, err
 	}
-	if t.Type() == "" {
-		return ErrMissingThingType
+
+	if !slices.Contains(tenants, t.Tenant()) {
+		return false, fmt.Errorf("%w: %s", ErrForbiddenTenant, t.Tenant())
 	}
 
-	result, err := a.reader.QueryThings(ctx, WithID(t.ID()), WithTenants(tenants))
-	if err != nil {
-		return err
+	result, err := a.queryThing(ctx, t.ID(), tenants)
+	if err == nil {
+		return false, a.updateThing(ctx, t, result)
 	}
-	if len(result.Data) != 1 {
-		return ErrThingNotFound
+	if !errors.Is(err, ErrThingNotFound) {
+		return false, err
 	}
 
-	err = a.writer.UpdateThing(ctx, t)
+	err = a.validateRequiredArgs(t)
 	if err != nil {
-		return err
+		return false, err
 	}
 
-	return nil
+	return a.writer.UpsertThing(ctx, t)
 }
 
-func (a *app) saveThing(ctx context.Context, t things.Thing) error {
+// validateThing converts b to a thing and checks that it has an id, tenant and type
+func (a *app) validateThing(b []byte) (things.Thing, error) {
+	t, err := a.convToThing(b)
+	if err != nil {
+		return nil, err
+	}
+
 	if t.ID() == "" {
-		return ErrMissingThingID
+		return nil, ErrMissingThingID
 	}
 	if t.Tenant() == "" {
-		return ErrMissingThingTenant
+		return nil, ErrMissingThingTenant
 	}
 	if t.Type() == "" {
-		return ErrMissingThingType
+		return nil, ErrMissingThingType
 	}
 
-	err := a.writer.UpdateThing(ctx, t)
+	return t, nil
+}
+
+// validateNewThing validates a thing to be added, which must also have the args required by its type
+func (a *app) validateNewThing(b []byte) (things.Thing, error) {
+	t, err := a.validateThing(b)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	return nil
+	err = a.validateRequiredArgs(t)
+	if err != nil {
+		return nil, err
+	}
+
+	return t, nil
 }
 
-func (a *app) MergeThing(ctx context.Context, thingID string, b []byte, tenants []string) error {
-	if len(tenants) == 0 {
-		return ErrMissingThingTenant
-	}
+func (a *app) mapFieldNames(b []byte) ([]byte, error) {
+	a.cfgMu.RLock()
+	defer a.cfgMu.RUnlock()
 
-	patch := make(map[string]any)
-	err := json.Unmarshal(b, &patch)
-	if err != nil {
-		return err
+	if a.cfg == nil {
+		return b, nil
 	}
+	return things.MapFieldNames(b, a.cfg.FieldNames)
+}
 
-	result, err := a.reader.QueryThings(ctx, WithID(thingID), WithTenants(tenants))
+func (a *app) convToThing(b []byte) (things.Thing, error) {
+	b, err := a.mapFieldNames(b)
 	if err != nil {
-		return err
-	}
-	if len(result.Data) != 1 {
-		return ErrThingNotFound
+		return nil, err
 	}
 
-	current := make(map[string]any)
-	err = json.Unmarshal(result.Data[0], &current)
+	m := make(map[string]any)
+	err = json.Unmarshal(b, &m)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	for k, v := range patch {
-		if slices.Contains([]string{"id", "type"}, k) {
-			continue
+	if tags, ok := m["tags"]; ok {
+		m["tags"] = a.normalizeTags(tags)
+		b, err = json.Marshal(m)
+		if err != nil {
+			return nil, err
 		}
-		current[k] = v
 	}
 
-	v, err := json.Marshal(current)
-	if err != nil {
+	return convToThing(b)
+}
+
+func (a *app) lowercaseTags() bool {
+	a.cfgMu.RLock()
+	defer a.cfgMu.RUnlock()
+
+	return a.cfg != nil && a.cfg.Tags.Lowercase
+}
+
+// normalizeTags normalizes tags from a decoded JSON payload. Anything but a list of strings is left as is.
+func (a *app) normalizeTags(v any) any {
+	values, ok := v.([]any)
+	if !ok {
+		return v
+	}
+
+	tags := make([]string, 0, len(values))
+	for _, value := range values {
+		tag, ok := value.(string)
+		if !ok {
+			return v
+		}
+		tags = append(tags, tag)
+	}
+
+	return things.NormalizeTags(tags, a.lowercaseTags())
+}
+
+func (a *app) unknownTypeFallback() bool {
+	a.cfgMu.RLock()
+	defer a.cfgMu.RUnlock()
+
+	return a.cfg != nil && a.cfg.UnknownTypeFallback
+}
+
+// loadThing converts a stored thing. Unlike things given by clients, stored things of unknown types
+// are loaded as passthrough things if the app is configured to fall back on them.
+func (a *app) loadThing(b []byte) (things.Thing, error) {
+	if a.unknownTypeFallback() {
+		return things.ConvToThingOrPassthrough(b)
+	}
+	return things.ConvToThing(b)
+}
+
+// queryThing returns the thing with thingID, if it belongs to one of tenants, or ErrThingNotFound
+func (a *app) queryThing(ctx context.Context, thingID string, tenants []string) (QueryResult, error) {
+	result, err := a.reader.QueryThings(ctx, WithID(thingID), WithTenants(tenants))
+	if err != nil {
+		return QueryResult{}, err
+	}
+	if len(result.Data) != 1 {
+		return QueryResult{}, ErrThingNotFound
+	}
+	return result, nil
+}
+
+// getThing loads the thing with thingID, if it belongs to one of tenants, or returns ErrThingNotFound
+func (a *app) getThing(ctx context.Context, thingID string, tenants []string) (things.Thing, error) {
+	result, err := a.queryThing(ctx, thingID, tenants)
+	if err != nil {
+		return nil, err
+	}
+	return a.loadThing(result.Data[0])
+}
+
+func convToThing(b []byte) (things.Thing, error) {
+	t, err := things.ConvToThing(b)
+	if err != nil {
+		return nil, err
+	}
+
+	status := struct {
+		Status string `json:"status"`
+	}{}
+	json.Unmarshal(b, &status)
+
+	if !things.IsValidStatus(status.Status) {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidStatus, status.Status)
+	}
+
+	return t, nil
+}
+
+func (a *app) validateRequiredArgs(t things.Thing) error {
+	m := make(map[string]any)
+	err := json.Unmarshal(t.Byte(), &m)
+	if err != nil {
+		return err
+	}
+
+	if clamp, ok := m["clamp"].(string); ok {
+		err = functions.ValidateClamp(clamp)
+		if err != nil {
+			return fmt.Errorf("%w: %w", ErrInvalidArgs, err)
+		}
+	}
+
+	a.cfgMu.RLock()
+	defer a.cfgMu.RUnlock()
+
+	if a.cfg == nil {
+		return nil
+	}
+
+	missing := []string{}
+
+	for _, tc := range a.cfg.typesFor(t.Tenant()) {
+		if !strings.EqualFold(tc.Type, t.Type()) {
+			continue
+		}
+		for _, arg := range tc.RequiredArgs {
+			if v, ok := m[arg]; !ok || v == nil {
+				missing = append(missing, arg)
+			}
+		}
+	}
+
+	if len(missing) > 0 {
+		return fmt.Errorf("%w: %s", ErrMissingArgs, strings.Join(missing, ","))
+	}
+
+	return nil
+}
+
+func (a *app) UpdateThing(ctx context.Context, b []byte, tenants []string) error {
+	if len(tenants) == 0 {
+		return errors.New("tenants must be provided")
+	}
+
+	b, err := withoutRelations(b)
+	if err != nil {
 		return err
 	}
 
-	patchedThing, err := things.ConvToThing(v)
+	t, err := a.validateThing(b)
 	if err != nil {
 		return err
 	}
 
-	err = a.writer.UpdateThing(ctx, patchedThing)
+	result, err := a.queryThing(ctx, t.ID(), tenants)
+	if err != nil {
+		return err
+	}
+
+	return a.updateThing(ctx, t, result)
+}
+
+type modifiedOnKey struct{}
+
+// WithModifiedOn returns a context that makes updates of a thing fail with ErrPreconditionFailed
+// unless the thing is still as last modified on modifiedOn
+func WithModifiedOn(ctx context.Context, modifiedOn time.Time) context.Context {
+	return context.WithValue(ctx, modifiedOnKey{}, modifiedOn)
+}
+
+// updateThing saves t, read as current, unless ctx requires that it is unchanged and it has been modified since
+func (a *app) updateThing(ctx context.Context, t things.Thing, current QueryResult) error {
+	modifiedOn, ok := ctx.Value(modifiedOnKey{}).(time.Time)
+	if !ok {
+		return a.writer.UpdateThing(ctx, t)
+	}
+
+	if !current.LastModified.IsZero() && !current.LastModified.Equal(modifiedOn) {
+		return ErrPreconditionFailed
+	}
+
+	return a.writer.UpdateThingIfUnchanged(ctx, t, modifiedOn)
+}
+
+func (a *app) saveThing(ctx context.Context, t things.Thing) error {
+	if t.ID() == "" {
+		return ErrMissingThingID
+	}
+	if t.Tenant() == "" {
+		return ErrMissingThingTenant
+	}
+	if t.Type() == "" {
+		return ErrMissingThingType
+	}
+
+	err := a.writer.UpdateThing(ctx, t)
 	if err != nil {
 		return err
 	}
@@ -359,12 +1045,267 @@ func (a *app) MergeThing(ctx context.Context, thingID string, b []byte, tenants
 	return nil
 }
 
+func (a *app) MergeThing(ctx context.Context, thingID string, b []byte, tenants []string) error {
+	if len(tenants) == 0 {
+		return ErrMissingThingTenant
+	}
+
+	patch, err := a.readPatch(b)
+	if err != nil {
+		return err
+	}
+
+	result, err := a.queryThing(ctx, thingID, tenants)
+	if err != nil {
+		return err
+	}
+
+	patchedThing, err := a.mergePatch(result.Data[0], patch)
+	if err != nil {
+		return err
+	}
+
+	return a.updateThing(ctx, patchedThing, result)
+}
+
+const queryAllPageSize int = 100
+
+// queryAllThings pages through all things matching the conditions, ignoring any limit and offset
+func (a *app) queryAllThings(ctx context.Context, conditions ...ConditionFunc) ([][]byte, error) {
+	all := [][]byte{}
+
+	for offset := 0; ; offset += queryAllPageSize {
+		result, err := a.reader.QueryThings(ctx, append(conditions, WithOffset(offset), WithLimit(queryAllPageSize))...)
+		if err != nil {
+			return nil, err
+		}
+
+		all = append(all, result.Data...)
+
+		if len(result.Data) < queryAllPageSize {
+			return all, nil
+		}
+	}
+}
+
+// MergeThings applies a merge patch to all things matching the query params and returns the number
+// of things patched. As a guard against accidental mass edits the params must contain confirm=true.
+// All things are updated within one transaction.
+func (a *app) MergeThings(ctx context.Context, params map[string][]string, b []byte, tenants []string) (int, error) {
+	if len(tenants) == 0 {
+		return 0, ErrMissingThingTenant
+	}
+
+	if err := a.validateParams(params); err != nil {
+		return 0, err
+	}
+
+	confirm, ok := normalizeParams(params)["confirm"]
+	if !ok || len(confirm) == 0 {
+		return 0, ErrMissingConfirmation
+	}
+	if confirmed, err := strconv.ParseBool(confirm[0]); err != nil || !confirmed {
+		return 0, ErrMissingConfirmation
+	}
+
+	patch, err := a.readPatch(b)
+	if err != nil {
+		return 0, err
+	}
+
+	conditions, err := a.queryThingsConditions(params, tenants)
+	if err != nil {
+		return 0, err
+	}
+
+	matching, err := a.queryAllThings(ctx, conditions...)
+	if err != nil {
+		return 0, err
+	}
+
+	patched := []things.Thing{}
+	for _, data := range matching {
+		t, err := a.mergePatch(data, patch)
+		if err != nil {
+			return 0, err
+		}
+		patched = append(patched, t)
+	}
+
+	if len(patched) == 0 {
+		return 0, nil
+	}
+
+	err = a.writer.UpdateThings(ctx, patched)
+	if err != nil {
+		return 0, err
+	}
+
+	return len(patched), nil
+}
+
+func (a *app) readPatch(b []byte) (map[string]any, error) {
+	b, err := a.mapFieldNames(b)
+	if err != nil {
+		return nil, err
+	}
+
+	patch := make(map[string]any)
+	err = json.Unmarshal(b, &patch)
+	if err != nil {
+		return nil, err
+	}
+
+	if tags, ok := patch["tags"]; ok {
+		patch["tags"] = a.normalizeTags(tags)
+	}
+
+	return patch, nil
+}
+
+// mergePatch applies the patch to the thing as a JSON merge patch (RFC 7386), nested objects are merged
+// and fields set to null are removed. The id, type, tenant and relations of the thing can not be patched.
+func (a *app) mergePatch(data []byte, patch map[string]any) (things.Thing, error) {
+	current := make(map[string]any)
+	err := json.Unmarshal(data, &current)
+	if err != nil {
+		return nil, err
+	}
+
+	for k, v := range patch {
+		if slices.Contains([]string{"id", "type", "tenant", "relations"}, k) {
+			continue
+		}
+		mergeField(current, k, v)
+	}
+
+	v, err := json.Marshal(current)
+	if err != nil {
+		return nil, err
+	}
+
+	return convToThing(v)
+}
+
+// mergeField merges the patched value v of field k into target
+func mergeField(target map[string]any, k string, v any) {
+	if v == nil {
+		delete(target, k)
+		return
+	}
+
+	patch, ok := v.(map[string]any)
+	if !ok {
+		target[k] = v // arrays and scalars replace the current value
+		return
+	}
+
+	current, ok := target[k].(map[string]any)
+	if !ok {
+		current = make(map[string]any)
+	}
+	for pk, pv := range patch {
+		mergeField(current, pk, pv)
+	}
+	target[k] = current
+}
+
+// CloneRequest holds the overrides applied to a thing cloned from another
+type CloneRequest struct {
+	NewID     string           `json:"newId"`
+	Location  *things.Location `json:"location,omitempty"`
+	DeviceIDs []string         `json:"deviceIds,omitempty"`
+}
+
+// CloneThing adds a new thing with the type, tags and config args of an existing one. Devices,
+// observed state and values are not copied.
+func (a *app) CloneThing(ctx context.Context, thingID string, b []byte, tenants []string) (things.Thing, error) {
+	if len(tenants) == 0 {
+		return nil, ErrMissingThingTenant
+	}
+
+	req := CloneRequest{}
+	err := json.Unmarshal(b, &req)
+	if err != nil {
+		return nil, err
+	}
+	if req.NewID == "" {
+		return nil, ErrMissingThingID
+	}
+
+	result, err := a.queryThing(ctx, thingID, tenants)
+	if err != nil {
+		return nil, err
+	}
+
+	source := make(map[string]any)
+	err = json.Unmarshal(result.Data[0], &source)
+	if err != nil {
+		return nil, err
+	}
+
+	clone := map[string]any{
+		"id": req.NewID,
+	}
+
+	for _, k := range append([]string{"type", "subType", "tenant", "location", "tags", "validURN"}, things.ConfigArgs...) {
+		if v, ok := source[k]; ok && v != nil {
+			clone[k] = v
+		}
+	}
+
+	if req.Location != nil {
+		clone["location"] = *req.Location
+	}
+
+	if len(req.DeviceIDs) > 0 {
+		refDevices := make([]things.Device, 0, len(req.DeviceIDs))
+		for _, deviceID := range req.DeviceIDs {
+			refDevices = append(refDevices, things.Device{DeviceID: deviceID})
+		}
+		clone["refDevices"] = refDevices
+	}
+
+	b, err = json.Marshal(clone)
+	if err != nil {
+		return nil, err
+	}
+
+	err = a.AddThing(ctx, b)
+	if err != nil {
+		return nil, err
+	}
+
+	return a.convToThing(b)
+}
+
 func (a *app) DeleteThing(ctx context.Context, thingID string, tenants []string) error {
 	if len(tenants) == 0 {
 		return ErrMissingThingTenant
 	}
 
-	result, err := a.reader.QueryThings(ctx, WithID(thingID), WithTenants(tenants))
+	t, err := a.getThing(ctx, thingID, tenants)
+	if err != nil {
+		return err
+	}
+
+	err = a.writer.DeleteThing(ctx, thingID)
+	if err != nil {
+		return err
+	}
+
+	a.publishDeleted(ctx, t)
+
+	return nil
+}
+
+// RestoreThing undoes a soft delete of a thing that has not yet been compacted
+func (a *app) RestoreThing(ctx context.Context, thingID string, tenants []string) error {
+	if len(tenants) == 0 {
+		return ErrMissingThingTenant
+	}
+
+	result, err := a.reader.QueryThings(ctx, WithID(thingID), WithTenants(tenants), WithIncludeDeleted(true))
 	if err != nil {
 		return err
 	}
@@ -372,30 +1313,365 @@ func (a *app) DeleteThing(ctx context.Context, thingID string, tenants []string)
 		return ErrThingNotFound
 	}
 
-	err = a.writer.DeleteThing(ctx, thingID)
+	err = a.writer.UndeleteThing(ctx, thingID)
 	if err != nil {
 		return err
 	}
 
+	// consumers were told the thing was deleted, so it is published as updated when it is back
+	a.pub <- changedThing{thingID: thingID}
+
 	return nil
 }
 
-func (a *app) QueryThings(ctx context.Context, params map[string][]string) (QueryResult, error) {
-	result, err := a.reader.QueryThings(ctx, WithParams(params)...)
+// publishWaterMeterAlert notifies consumers right away that a leak, backflow or fraud was detected by a water meter
+func (a *app) publishWaterMeterAlert(ctx context.Context, t things.Thing, alert things.WaterMeterAlert) {
+	msg := &types.WaterMeterAlert{
+		ID:        t.ID(),
+		Kind:      alert.Kind,
+		Tenant:    t.Tenant(),
+		Timestamp: alert.Timestamp,
+	}
+
+	err := a.msgCtx.PublishOnTopic(ctx, a.publisherConfig().target(msg))
+	if err != nil {
+		logging.GetFromContext(ctx).Error("could not publish message", "err", err.Error())
+	}
+}
+
+// publishDeleted tells consumers of thing updates that t has been removed
+func (a *app) publishDeleted(ctx context.Context, t things.Thing) {
+	msg := &types.ThingDeleted{
+		ID:        t.ID(),
+		Type:      t.Type(),
+		Tenant:    t.Tenant(),
+		Timestamp: time.Now().UTC(),
+	}
+
+	err := a.msgCtx.PublishOnTopic(ctx, a.publisherConfig().target(msg))
+	if err != nil {
+		logging.GetFromContext(ctx).Error("could not publish message", "err", err.Error())
+	}
+}
+
+func (a *app) validateParams(params map[string][]string) error {
+	a.cfgMu.RLock()
+	strict := a.cfg != nil && a.cfg.StrictParams
+	a.cfgMu.RUnlock()
+
+	if !strict {
+		return nil
+	}
+
+	return ValidateParams(params)
+}
+
+func (a *app) QueryThings(ctx context.Context, params map[string][]string, tenants []string) (QueryResult, error) {
+	if err := a.validateParams(params); err != nil {
+		return QueryResult{}, err
+	}
+
+	conditions, err := a.queryThingsConditions(params, tenants)
+	if err != nil {
+		return QueryResult{}, err
+	}
+
+	// deleted things are only ever listed, e.g. to be restored, and not included by other uses of query params
+	if deleted, ok := normalizeParams(params)["deleted"]; ok {
+		if include, err := strconv.ParseBool(deleted[0]); err == nil {
+			conditions = append(conditions, WithIncludeDeleted(include))
+		}
+	}
+
+	result, err := a.reader.QueryThings(ctx, conditions...)
 	if err != nil {
 		return QueryResult{}, err
 	}
 	return result, nil
 }
 
-func (a *app) QueryValues(ctx context.Context, params map[string][]string) (QueryResult, error) {
-	result, err := a.reader.QueryValues(ctx, WithParams(params)...)
+func (a *app) queryThingsConditions(params map[string][]string, tenants []string) ([]ConditionFunc, error) {
+	allowed, err := allowedTenants(params, tenants)
+	if err != nil {
+		return nil, err
+	}
+
+	conditions := append(WithParams(params), WithTenants(allowed))
+
+	// inactive things are hidden from listings unless explicitly asked for
+	p := normalizeParams(params)
+	_, hasID := p["id"]
+	_, hasStatus := p["status"]
+	if !hasID && !hasStatus {
+		conditions = append(conditions, WithStatus(things.StatusActive))
+	}
+
+	return conditions, nil
+}
+
+func (a *app) QueryValues(ctx context.Context, params map[string][]string, tenants []string) (QueryResult, error) {
+	return a.queryValues(ctx, params, tenants, false)
+}
+
+// ListValues queries values like QueryValues, but a query without a time filter gets the default lookback
+// applied, so that listing values never scans all values by accident
+func (a *app) ListValues(ctx context.Context, params map[string][]string, tenants []string) (QueryResult, error) {
+	return a.queryValues(ctx, params, tenants, true)
+}
+
+func (a *app) queryValues(ctx context.Context, params map[string][]string, tenants []string, defaultLookback bool) (QueryResult, error) {
+	if err := a.validateParams(params); err != nil {
+		return QueryResult{}, err
+	}
+
+	allowed, err := allowedTenants(params, tenants)
+	if err != nil {
+		return QueryResult{}, err
+	}
+
+	p := normalizeParams(params)
+
+	// values are not stored with a tenant, so make sure the thing they belong to is visible to the caller
+	if thingID, ok := p["thingid"]; ok && len(thingID) > 0 {
+		_, err := a.queryThing(ctx, thingID[0], allowed)
+		if err != nil {
+			return QueryResult{}, err
+		}
+	}
+
+	p, err = a.withTimeRange(p, time.Now().UTC(), defaultLookback)
+	if err != nil {
+		return QueryResult{}, err
+	}
+
+	conditions := append(WithParams(p), WithTenants(allowed))
+
+	result, err := a.reader.QueryValues(ctx, conditions...)
 	if err != nil {
 		return QueryResult{}, err
 	}
 	return result, nil
 }
 
+const (
+	defaultRecentValues int = 20
+	maxRecentValues     int = 1000
+)
+
+// GetRecentValues returns the n most recent values of a thing, newest first, optionally limited to urns
+func (a *app) GetRecentValues(ctx context.Context, thingID string, n int, urns []string, tenants []string) (QueryResult, error) {
+	_, err := a.queryThing(ctx, thingID, tenants)
+	if err != nil {
+		return QueryResult{}, err
+	}
+
+	if n <= 0 {
+		n = defaultRecentValues
+	}
+	if n > maxRecentValues {
+		n = maxRecentValues
+	}
+
+	conditions := []ConditionFunc{WithThingID(thingID), WithLimit(n), WithNewestFirst(), WithTenants(tenants)}
+	if len(urns) > 0 {
+		conditions = append(conditions, WithUrn(urns))
+	}
+
+	return a.reader.QueryValues(ctx, conditions...)
+}
+
+// GetLatestValues returns the latest value of each measurement of a thing
+func (a *app) GetLatestValues(ctx context.Context, thingID string, tenants []string) (QueryResult, error) {
+	_, err := a.queryThing(ctx, thingID, tenants)
+	if err != nil {
+		return QueryResult{}, err
+	}
+
+	return a.reader.QueryValues(ctx, WithThingID(thingID), WithShowLatest(true))
+}
+
+// withTimeRange limits value queries in time. A query without a time filter gets the default lookback applied
+// if defaultLookback is set, a query spanning more than the maximum lookback is rejected with ErrTimeRangeExceeded
+// and a query before a point in time is limited to the maximum lookback.
+func (a *app) withTimeRange(p map[string][]string, now time.Time, defaultLookback bool) (map[string][]string, error) {
+	if latest, ok := p["latest"]; ok && latest[0] == "true" {
+		return p, nil
+	}
+
+	lookback, maxRange := defaultValuesLookback, defaultValuesMaxRange
+
+	a.cfgMu.RLock()
+	if a.cfg != nil && a.cfg.Values.DefaultLookback > 0 {
+		lookback = a.cfg.Values.DefaultLookback
+	}
+	if a.cfg != nil && a.cfg.Values.MaxLookback > 0 {
+		maxRange = a.cfg.Values.MaxLookback
+	}
+	a.cfgMu.RUnlock()
+
+	timeRel, ok := p["timerel"]
+	if !ok || len(timeRel) == 0 {
+		if defaultLookback {
+			p["timerel"] = []string{"after"}
+			p["timeat"] = []string{now.Add(-lookback).Format(time.RFC3339)}
+		}
+		return p, nil
+	}
+
+	parse := func(key string) (time.Time, error) {
+		v, ok := p[key]
+		if !ok || len(v) == 0 {
+			return time.Time{}, fmt.Errorf("%w: timerel %s requires %s", ErrInvalidParams, timeRel[0], key)
+		}
+		ts, err := time.Parse(time.RFC3339, v[0])
+		if err != nil {
+			return time.Time{}, fmt.Errorf("%w: %s must be a RFC3339 timestamp", ErrInvalidParams, key)
+		}
+		return ts, nil
+	}
+
+	var from, to time.Time
+	var err error
+
+	switch strings.ToLower(timeRel[0]) {
+	case "after":
+		if from, err = parse("timeat"); err != nil {
+			return nil, err
+		}
+		to = now
+	case "between":
+		if from, err = parse("timeat"); err != nil {
+			return nil, err
+		}
+		if to, err = parse("endtimeat"); err != nil {
+			return nil, err
+		}
+	case "before":
+		if to, err = parse("timeat"); err != nil {
+			return nil, err
+		}
+		// values before a point in time are limited to the maximum range before it, rather than scanning all values
+		p["timerel"] = []string{"between"}
+		p["timeat"] = []string{to.Add(-maxRange).Format(time.RFC3339)}
+		p["endtimeat"] = []string{to.Format(time.RFC3339)}
+		return p, nil
+	default:
+		return nil, fmt.Errorf("%w: timerel must be one of before, after or between", ErrInvalidParams)
+	}
+
+	if to.Sub(from) > maxRange {
+		return nil, fmt.Errorf("%w: max %s", ErrTimeRangeExceeded, maxRange)
+	}
+
+	return p, nil
+}
+
+// DeleteValues deletes the values of a thing within the time window given by params. If params contains
+// redact=true the values are redacted instead, i.e. the rows are kept for audit but the measured values are removed.
+func (a *app) DeleteValues(ctx context.Context, thingID string, params map[string][]string, tenants []string) (int64, error) {
+	log := logging.GetFromContext(ctx)
+
+	if len(tenants) == 0 {
+		return 0, ErrMissingThingTenant
+	}
+
+	// params are always validated strictly here, since a malformed param that is ignored widens what is removed
+	if err := ValidateParams(params); err != nil {
+		return 0, err
+	}
+
+	p := normalizeParams(params)
+	delete(p, "thingid")
+
+	if err := requireTimeWindow(p); err != nil {
+		return 0, err
+	}
+
+	_, err := a.queryThing(ctx, thingID, tenants)
+	if err != nil {
+		return 0, err
+	}
+
+	conditions := WithParams(p)
+
+	if redact, ok := p["redact"]; ok && redact[0] == "true" {
+		n, err := a.writer.RedactValues(ctx, thingID, conditions...)
+		if err != nil {
+			return 0, err
+		}
+		log.Info("values redacted", "thingID", thingID, slog.Int64("count", n))
+		return n, nil
+	}
+
+	n, err := a.writer.DeleteValues(ctx, thingID, conditions...)
+	if err != nil {
+		return 0, err
+	}
+	log.Info("values deleted", "thingID", thingID, slog.Int64("count", n))
+
+	return n, nil
+}
+
+// requireTimeWindow checks that params give a time window, so that a whole series is never removed by accident
+func requireTimeWindow(params map[string][]string) error {
+	first := func(key string) string {
+		if v, ok := params[key]; ok && len(v) > 0 {
+			return v[0]
+		}
+		return ""
+	}
+
+	timerel := strings.ToLower(first("timerel"))
+	if !slices.Contains([]string{"before", "after", "between"}, timerel) {
+		return fmt.Errorf("%w: timerel must be one of before, after or between", ErrInvalidParams)
+	}
+
+	timeat, err := time.Parse(time.RFC3339, first("timeat"))
+	if err != nil {
+		return fmt.Errorf("%w: timerel requires a valid timeat", ErrInvalidParams)
+	}
+
+	if timerel == "between" {
+		endtimeat, err := time.Parse(time.RFC3339, first("endtimeat"))
+		if err != nil || !endtimeat.After(timeat) {
+			return fmt.Errorf("%w: timerel between requires a valid endtimeat after timeat", ErrInvalidParams)
+		}
+	}
+
+	return nil
+}
+
+// allowedTenants returns the requested tenants, or all allowed tenants if no tenant was requested.
+// Tenants may be requested as repeated or comma separated tenant params, and requesting a tenant
+// the caller is not allowed to access is rejected with ErrForbiddenTenant.
+func allowedTenants(params map[string][]string, tenants []string) ([]string, error) {
+	values, ok := normalizeParams(params)["tenant"]
+	if !ok {
+		return tenants, nil
+	}
+
+	requested := []string{}
+	for _, v := range values {
+		for _, t := range strings.Split(v, ",") {
+			if t = strings.TrimSpace(t); t != "" && !slices.Contains(requested, t) {
+				requested = append(requested, t)
+			}
+		}
+	}
+
+	if len(requested) == 0 {
+		return tenants, nil
+	}
+
+	for _, t := range requested {
+		if !slices.Contains(tenants, t) {
+			return nil, fmt.Errorf("%w: %s", ErrForbiddenTenant, t)
+		}
+	}
+
+	return requested, nil
+}
+
 func (a *app) getThingByID(ctx context.Context, thingID string) things.Thing {
 	result, err := a.reader.QueryThings(ctx, WithID(thingID))
 	if err != nil {
@@ -405,7 +1681,7 @@ func (a *app) getThingByID(ctx context.Context, thingID string) things.Thing {
 		return nil
 	}
 
-	t, err := things.ConvToThing(result.Data[0])
+	t, err := a.loadThing(result.Data[0])
 	if err != nil {
 		return nil
 	}
@@ -419,44 +1695,190 @@ func (a *app) getConnectedThings(ctx context.Context, deviceID string) ([]things
 		return nil, err
 	}
 
-	tt := make([]things.Thing, 0)
-
-	for _, b := range result.Data {
-		t, err := things.ConvToThing(b)
+	tt := make([]things.Thing, 0)
+
+	for _, b := range result.Data {
+		t, err := a.loadThing(b)
+		if err != nil {
+			return nil, err
+		}
+
+		tt = append(tt, t)
+	}
+
+	return tt, nil
+}
+
+func (a *app) GetTags(ctx context.Context, tenants []string) ([]string, error) {
+	tags, err := a.reader.GetTags(ctx, tenants)
+	if err != nil {
+		return nil, err
+	}
+
+	// things stored before tags were normalized may still carry messy tags
+	return things.NormalizeTags(tags, a.lowercaseTags()), nil
+}
+
+// GetUrns returns the distinct urns that a thing has values for and the urns it is configured to handle
+func (a *app) GetUrns(ctx context.Context, thingID string, tenants []string) ([]string, []string, error) {
+	t, err := a.getThing(ctx, thingID, tenants)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	present, err := a.reader.GetUrns(ctx, thingID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return present, t.ValidURNs(), nil
+}
+
+func (a *app) GetValueRange(ctx context.Context, thingID string, tenants []string) (ValueRange, error) {
+	_, err := a.queryThing(ctx, thingID, tenants)
+	if err != nil {
+		return ValueRange{}, err
+	}
+
+	return a.reader.GetValueRange(ctx, thingID)
+}
+
+func (a *app) AddValue(ctx context.Context, t things.Thing, m things.Value) error {
+	m, err := validateValue(m)
+	if err != nil {
+		return err
+	}
+	m.Integer = a.isIntegerURN(m.Urn)
+
+	if a.aggregated(t.Tenant(), t.Type()) {
+		err = a.writer.AddValueWithAggregate(ctx, t, m)
+	} else {
+		err = a.writer.AddValue(ctx, t, m)
+	}
+
+	meters().valuesWritten.Add(ctx, 1, statusOf(err))
+
+	return err
+}
+
+// validateValue checks that m can be stored and returns it with its unit normalized
+func validateValue(m things.Value) (things.Value, error) {
+	if m.ID == "" {
+		return m, errors.New("measurement ID must be provided")
+	}
+	if m.Timestamp.IsZero() {
+		return m, errors.New("timestamp must be provided")
+	}
+	if m.Value == nil && m.StringValue == nil && m.BoolValue == nil {
+		return m, errors.New("value must be provided")
+	}
+	if m.Urn == "" {
+		return m, errors.New("URN must be provided")
+	}
+
+	unit, err := things.NormalizeUnit(m.Urn, m.ID, m.Unit)
+	if err != nil {
+		return m, err
+	}
+	m.Unit = unit
+
+	return m, nil
+}
+
+// AddValues stores historical values of a thing in one batch, e.g. when backfilling from another system.
+// The id of a value may be left out if its urn has a single resource, or be given as object/resource. All
+// values are validated before any is stored, and values already stored are skipped. It returns the number
+// of values added.
+func (a *app) AddValues(ctx context.Context, thingID string, values []things.Value, tenants []string) (int64, error) {
+	if len(tenants) == 0 {
+		return 0, ErrMissingThingTenant
+	}
+
+	t, err := a.getThing(ctx, thingID, tenants)
+	if err != nil {
+		return 0, err
+	}
+
+	valid := make([]things.Value, 0, len(values))
+	for i, m := range values {
+		m.ID, err = valueID(thingID, m)
+		if err == nil {
+			m, err = validateValue(m)
+		}
 		if err != nil {
-			return nil, err
+			return 0, fmt.Errorf("%w: value %d: %s", ErrInvalidValue, i, err.Error())
 		}
+		m.Integer = a.isIntegerURN(m.Urn)
+		valid = append(valid, m)
+	}
 
-		tt = append(tt, t)
+	n, err := a.writer.AddValues(ctx, t, valid, a.aggregated(t.Tenant(), t.Type()))
+	if err != nil {
+		meters().valuesWritten.Add(ctx, int64(len(valid)), statusOf(err))
+		return 0, err
 	}
 
-	return tt, nil
-}
+	meters().valuesWritten.Add(ctx, n, statusOf(nil))
 
-func (a *app) GetTags(ctx context.Context, tenants []string) ([]string, error) {
-	return a.reader.GetTags(ctx, tenants)
+	return n, nil
 }
 
-func (a *app) AddValue(ctx context.Context, t things.Thing, m things.Value) error {
-	if m.ID == "" {
-		return errors.New("measurement ID must be provided")
+// valueID returns the id to store a value of thing thingID with, i.e. thingID/object/resource
+func valueID(thingID string, m things.Value) (string, error) {
+	if strings.HasPrefix(m.ID, thingID+"/") {
+		return m.ID, nil
 	}
-	if m.Timestamp.IsZero() {
-		return errors.New("timestamp must be provided")
+	if m.ID != "" {
+		return thingID + "/" + m.ID, nil
 	}
-	if m.Value == nil && m.StringValue == nil && m.BoolValue == nil {
-		return errors.New("value must be provided")
+
+	resources := []string{}
+	for _, u := range things.Units() {
+		if u.Urn == m.Urn {
+			resources = append(resources, u.Resource)
+		}
 	}
-	if m.Urn == "" {
-		return errors.New("URN must be provided")
+	if len(resources) != 1 {
+		return "", fmt.Errorf("id must be provided for urn %s", m.Urn)
+	}
+
+	return thingID + "/" + resources[0], nil
+}
+
+// aggregated reports whether daily aggregates should be maintained for values of things of the given type
+func (a *app) aggregated(tenant, thingType string) bool {
+	a.cfgMu.RLock()
+	defer a.cfgMu.RUnlock()
+
+	if a.cfg == nil {
+		return false
+	}
+
+	for _, tc := range a.cfg.typesFor(tenant) {
+		if strings.EqualFold(tc.Type, thingType) {
+			return tc.Aggregate
+		}
 	}
 
-	return a.writer.AddValue(ctx, t, m)
+	return false
 }
 
-func (a *app) Seed(ctx context.Context, r io.Reader) error {
-	f := csv.NewReader(r)
+// Seed creates or updates things from either semicolon separated CSV rows, or JSON with a thing
+// object or an array of thing objects. Invalid things are skipped and listed in the report, so that one
+// bad row does not leave the rest of the file unseeded. A dry run writes nothing, but validates every thing. Things can only be seeded
+// for the given tenants, or for any tenant if tenants is nil, as for the seed file read at startup.
+func (a *app) Seed(ctx context.Context, r io.Reader, dryRun bool, tenants []string) (SeedReport, error) {
+	run := newSeedRun(tenants)
+	run.dryRun = dryRun
+
+	br := bufio.NewReader(r)
+	if isJSON(br) {
+		return a.seedJSON(ctx, br, run)
+	}
+
+	f := csv.NewReader(br)
 	f.Comma = ';'
+	f.FieldsPerRecord = -1 // the parent column is optional
 	rowNum := 0
 
 	location := func(s string) things.Location {
@@ -490,18 +1912,11 @@ func (a *app) Seed(ctx context.Context, r io.Reader) error {
 		return tags
 	}
 
-	refDevices := func(t string) []things.Device {
+	refDevices := func(t string) []string {
 		if t == "" {
 			return nil
 		}
-		if !strings.Contains(t, ",") {
-			return []things.Device{{DeviceID: t}}
-		}
-		devices := []things.Device{}
-		for _, s := range strings.Split(t, ",") {
-			devices = append(devices, things.Device{DeviceID: s})
-		}
-		return devices
+		return strings.Split(t, ",")
 	}
 
 	args := func(t string) map[string]any {
@@ -517,21 +1932,36 @@ func (a *app) Seed(ctx context.Context, r io.Reader) error {
 		return m
 	}
 
-	tenants := []string{"default"}
+	seeded := map[string]things.Thing{}
 
 	for {
 		record, err := f.Read()
 		if err == io.EOF {
 			break
 		}
+		if err != nil {
+			pe := &csv.ParseError{}
+			if errors.As(err, &pe) {
+				run.fail(pe.Line, "", err)
+				continue
+			}
+			return run.report(), err
+		}
+
+		line, _ := f.FieldPos(0)
 
 		if rowNum == 0 {
 			rowNum++
 			continue
 		}
 
-		//  0	 1      2      3         4           5       6      7       8         9
-		// id, type, subType, name, decsription, location, tenant, tags, refDevices, args
+		if len(record) < 10 {
+			run.fail(line, record[0], fmt.Errorf("row %d has %d fields, expected at least 10", line, len(record)))
+			continue
+		}
+
+		//  0	 1      2      3         4           5       6      7       8         9      10 (optional)
+		// id, type, subType, name, decsription, location, tenant, tags, refDevices, args, parent
 
 		id_ := record[0]
 		type_ := record[1]
@@ -543,88 +1973,564 @@ func (a *app) Seed(ctx context.Context, r io.Reader) error {
 		tags_ := tags(record[7])
 		refDevices_ := refDevices(record[8])
 
-		m := make(map[string]any)
+		parent_ := ""
+		if len(record) > 10 {
+			parent_ = strings.TrimSpace(record[10])
+		}
 
-		current := a.getThingByID(ctx, id_)
-		if current != nil {
-			err := json.Unmarshal(current.Byte(), &m)
-			if err != nil {
-				return err
-			}
-		} else {
-			m["id"] = id_
-			m["type"] = type_
+		item := InventoryItem{
+			ID:          id_,
+			Type:        type_,
+			SubType:     subType_,
+			Name:        name_,
+			Description: description_,
+			Tenant:      tenant_,
+			Tags:        tags_,
+			RefDevices:  refDevices_,
+			Args:        args(record[9]),
+			Parent:      parent_,
+		}
+		if strings.TrimSpace(record[5]) != "" {
+			item.Location = &location_
+		}
+
+		parent, err := a.seedParent(ctx, parent_, seeded, run)
+		if err != nil {
+			run.fail(line, item.ID, err)
+			continue
+		}
+
+		t, err := a.seedItem(ctx, item, parent, run)
+		if err != nil {
+			run.fail(line, item.ID, err)
+			continue
+		}
+
+		seeded[t.ID()] = t
+	}
+
+	a.publishSeed(ctx, run)
+
+	return run.report(), nil
+}
+
+// isJSON reports whether the buffered data starts as a JSON object or array rather than as CSV
+func isJSON(br *bufio.Reader) bool {
+	b, _ := br.Peek(512)
+	b = bytes.TrimLeft(b, " \t\r\n\ufeff")
+	return len(b) > 0 && (b[0] == '[' || b[0] == '{')
+}
+
+func (a *app) seedJSON(ctx context.Context, r io.Reader, run *seedRun) (SeedReport, error) {
+	b, err := io.ReadAll(r)
+	if err != nil {
+		return run.report(), err
+	}
+
+	b = bytes.TrimLeft(b, " \t\r\n\ufeff")
+
+	items := []json.RawMessage{}
+	if b[0] == '{' {
+		items = append(items, json.RawMessage(b))
+	} else {
+		err = json.Unmarshal(b, &items)
+		if err != nil {
+			return run.report(), err
 		}
+	}
 
-		if subType_ != "" {
-			m["subType"] = subType_
-		} else {
-			delete(m, "subType")
+	for i, item := range items {
+		err := a.seedThing(ctx, item, run)
+		if err != nil {
+			run.fail(i+1, "", err)
+			continue
 		}
+	}
+
+	a.publishSeed(ctx, run)
+
+	return run.report(), nil
+}
+
+// seedThing adds a thing given as a full thing object, or updates it if it already exists. Updates keep
+// the internal state (fields starting with "_") of the existing thing.
+func (a *app) seedThing(ctx context.Context, b []byte, run *seedRun) error {
+	b, err := a.mapFieldNames(b)
+	if err != nil {
+		return err
+	}
 
-		m["name"] = name_
-		m["description"] = description_
-		m["location"] = location_
-		m["tenant"] = tenant_
+	t, err := things.ConvToThing(b)
+	if err != nil {
+		return err
+	}
+	if t.ID() == "" {
+		return ErrMissingThingID
+	}
+	if !run.allows(t.Tenant()) {
+		return fmt.Errorf("%s: %w: %s", t.ID(), ErrForbiddenTenant, t.Tenant())
+	}
 
-		if len(tags_) > 0 {
-			m["tags"] = tags_
-		} else {
-			delete(m, "tags")
+	current := a.getThingByID(ctx, t.ID())
+	if current != nil && !run.allows(current.Tenant()) {
+		return fmt.Errorf("%s: %w: %s", t.ID(), ErrForbiddenTenant, current.Tenant())
+	}
+	if current != nil {
+		m := make(map[string]any)
+		err = json.Unmarshal(current.Byte(), &m)
+		if err != nil {
+			return err
 		}
 
-		if len(refDevices_) > 0 {
-			m["refDevices"] = refDevices_
-		} else {
-			delete(m, "refDevices")
+		incoming := make(map[string]any)
+		err = json.Unmarshal(b, &incoming)
+		if err != nil {
+			return err
 		}
 
-		for k, v := range args(record[9]) {
+		for k, v := range incoming {
+			if strings.HasPrefix(k, "_") {
+				continue
+			}
 			m[k] = v
 		}
 
-		b, err := json.Marshal(m)
+		b, err = json.Marshal(m)
 		if err != nil {
 			return err
 		}
+	}
+
+	err = a.saveSeeded(ctx, b, current, run)
+	if err != nil {
+		return fmt.Errorf("%s: %w", t.ID(), err)
+	}
+
+	if current == nil {
+		run.created = append(run.created, t.ID())
+	} else {
+		run.updated = append(run.updated, t.ID())
+	}
+	if !slices.Contains(run.seeded, t.Tenant()) {
+		run.seeded = append(run.seeded, t.Tenant())
+	}
+
+	return nil
+}
+
+// InventoryItem describes a thing in a declarative inventory. It holds the same information as a row in a seed file.
+type InventoryItem struct {
+	ID          string           `json:"id" yaml:"id"`
+	Type        string           `json:"type" yaml:"type"`
+	SubType     string           `json:"subType,omitempty" yaml:"subType,omitempty"`
+	Name        string           `json:"name,omitempty" yaml:"name,omitempty"`
+	Description string           `json:"description,omitempty" yaml:"description,omitempty"`
+	Location    *things.Location `json:"location,omitempty" yaml:"location,omitempty"`
+	Tenant      string           `json:"tenant,omitempty" yaml:"tenant,omitempty"`
+	Tags        []string         `json:"tags,omitempty" yaml:"tags,omitempty"`
+	RefDevices  []string         `json:"refDevices,omitempty" yaml:"refDevices,omitempty"`
+	Args        map[string]any   `json:"args,omitempty" yaml:"args,omitempty"`
+	Parent      string           `json:"parent,omitempty" yaml:"parent,omitempty"`
+}
+
+type Inventory struct {
+	Things []InventoryItem `json:"things" yaml:"things"`
+}
+
+// SeedInventory creates or updates the things in a YAML inventory, e.g. one exported from the things endpoint.
+// Things can only be seeded for the given tenants, or for any tenant if tenants is nil. Invalid things are
+// skipped, and the errors of all of them are returned.
+func (a *app) SeedInventory(ctx context.Context, r io.Reader, tenants []string) error {
+	inventory := Inventory{}
+	err := yaml.NewDecoder(r).Decode(&inventory)
+	if err != nil {
+		return err
+	}
+
+	run := newSeedRun(tenants)
+	seeded := map[string]things.Thing{}
+	errs := []error{}
+
+	for _, item := range inventory.Things {
+		parent, err := a.seedParent(ctx, item.Parent, seeded, run)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", item.ID, err))
+			continue
+		}
 
-		if !slices.Contains(tenants, tenant_) {
-			tenants = append(tenants, tenant_)
+		t, err := a.seedItem(ctx, item, parent, run)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", item.ID, err))
+			continue
 		}
 
-		if current == nil {
-			err = a.AddThing(ctx, b)
-			if err != nil {
-				return err
+		seeded[t.ID()] = t
+	}
+
+	a.publishSeed(ctx, run)
+
+	return errors.Join(errs...)
+}
+
+// seedParent returns the parent of a seeded thing, either one seeded earlier in the same run or a stored
+// thing of one of the tenants the run may seed for. A parent that cannot be found is an invalid relation.
+func (a *app) seedParent(ctx context.Context, id string, seeded map[string]things.Thing, run *seedRun) (things.Thing, error) {
+	if id == "" {
+		return nil, nil
+	}
+	if p, ok := seeded[id]; ok {
+		return p, nil
+	}
+
+	if run.tenants == nil {
+		if p := a.getThingByID(ctx, id); p != nil {
+			return p, nil
+		}
+		return nil, fmt.Errorf("%w: parent %s not found", ErrInvalidRelation, id)
+	}
+
+	p, err := a.getThing(ctx, id, run.tenants)
+	if errors.Is(err, ErrThingNotFound) {
+		return nil, fmt.Errorf("%w: parent %s not found", ErrInvalidRelation, id)
+	}
+
+	return p, err
+}
+
+// seedRun keeps track of the things created and updated by a seed
+type seedRun struct {
+	tenants []string // tenants things may be seeded for, any tenant if nil
+	created []string
+	updated []string
+	seeded  []string // tenants of the seeded things
+
+	dryRun bool // validate things without creating or updating them
+	errors []SeedError
+}
+
+func newSeedRun(tenants []string) *seedRun {
+	return &seedRun{
+		tenants: tenants,
+	}
+}
+
+// allows reports whether things may be seeded for tenant
+func (run *seedRun) allows(tenant string) bool {
+	return run.tenants == nil || slices.Contains(run.tenants, tenant)
+}
+
+// SeedReport lists the things created and updated by a seed, or that would be by a dry run, and the
+// rows that are not valid
+type SeedReport struct {
+	DryRun  bool        `json:"dryRun"`
+	Created []string    `json:"created"`
+	Updated []string    `json:"updated"`
+	Errors  []SeedError `json:"errors"`
+}
+
+// SeedError is an invalid row of a seed file. Row is the line number in a CSV file, or the position of
+// the thing in a JSON list.
+type SeedError struct {
+	Row   int    `json:"row"`
+	ID    string `json:"id,omitempty"`
+	Error string `json:"error"`
+}
+
+func (run *seedRun) fail(row int, id string, err error) {
+	run.errors = append(run.errors, SeedError{Row: row, ID: id, Error: err.Error()})
+}
+
+func (run *seedRun) report() SeedReport {
+	return SeedReport{
+		DryRun:  run.dryRun,
+		Created: append([]string{}, run.created...),
+		Updated: append([]string{}, run.updated...),
+		Errors:  append([]SeedError{}, run.errors...),
+	}
+}
+
+// saveSeeded adds a seeded thing, or updates current if it exists. A dry run only validates it.
+func (a *app) saveSeeded(ctx context.Context, b []byte, current things.Thing, run *seedRun) error {
+	var err error
+
+	switch {
+	case run.dryRun && current != nil:
+		_, err = a.validateThing(b)
+	case run.dryRun:
+		_, err = a.validateNewThing(b)
+	case current != nil:
+		err = a.UpdateThing(ctx, b, run.updateTenants(b, current))
+	default:
+		err = a.AddThing(ctx, b)
+	}
+
+	return err
+}
+
+// updateTenants returns the tenants a seeded thing is updated with. These are the tenants of the caller, or
+// for a seed not limited to tenants, the tenant of the existing thing and the tenant it is seeded with.
+func (run *seedRun) updateTenants(b []byte, current things.Thing) []string {
+	if run.tenants != nil {
+		return run.tenants
+	}
+
+	seeded := struct {
+		Tenant string `json:"tenant"`
+	}{}
+	json.Unmarshal(b, &seeded)
+
+	return []string{current.Tenant(), seeded.Tenant}
+}
+
+// publishSeed publishes the things seeded by run according to the configured seed publish mode
+func (a *app) publishSeed(ctx context.Context, run *seedRun) {
+	if run.dryRun {
+		return
+	}
+
+	cfg := a.publisherConfig()
+
+	switch cfg.Seed {
+	case SeedPublishThings:
+		for _, thingID := range append(run.created, run.updated...) {
+			a.pub <- changedThing{thingID: thingID}
+		}
+	case SeedPublishSummary:
+		msg := &types.SeedCompleted{
+			Created:   len(run.created),
+			Updated:   len(run.updated),
+			Tenants:   run.seeded,
+			Timestamp: time.Now().UTC(),
+		}
+
+		err := a.msgCtx.PublishOnTopic(ctx, cfg.target(msg))
+		if err != nil {
+			logging.GetFromContext(ctx).Error("could not publish message", "err", err.Error())
+		}
+	}
+}
+
+// reservedSeedArgs are the fields of a seeded thing that can not be given in its args
+var reservedSeedArgs = []string{"id", "type", "tenant"}
+
+// seedItem adds the thing described by item, or updates it if it already exists
+func (a *app) seedItem(ctx context.Context, item InventoryItem, parent things.Thing, run *seedRun) (things.Thing, error) {
+	location_ := things.Location{}
+	if item.Location != nil {
+		location_ = *item.Location
+	}
+
+	location_, tenant_ := a.inherit(item.Type, item.Location == nil, location_, item.Tenant, parent)
+
+	m := make(map[string]any)
+
+	current := a.getThingByID(ctx, item.ID)
+	if current != nil && !run.allows(current.Tenant()) {
+		return nil, fmt.Errorf("%s: %w: %s", item.ID, ErrForbiddenTenant, current.Tenant())
+	}
+	if current != nil {
+		err := json.Unmarshal(current.Byte(), &m)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		m["id"] = item.ID
+		m["type"] = item.Type
+	}
+
+	if item.SubType != "" {
+		m["subType"] = item.SubType
+	} else {
+		delete(m, "subType")
+	}
+
+	m["name"] = item.Name
+	m["description"] = item.Description
+	m["location"] = location_
+	m["tenant"] = tenant_
+
+	if len(item.Tags) > 0 {
+		m["tags"] = item.Tags
+	} else {
+		delete(m, "tags")
+	}
+
+	if len(item.RefDevices) > 0 {
+		refDevices := make([]things.Device, 0, len(item.RefDevices))
+		for _, deviceID := range item.RefDevices {
+			refDevices = append(refDevices, things.Device{DeviceID: deviceID})
+		}
+		m["refDevices"] = refDevices
+	} else {
+		delete(m, "refDevices")
+	}
+
+	// args configure the thing, they can not make the seed write another thing than the one checked above
+	for k, v := range item.Args {
+		if slices.Contains(reservedSeedArgs, k) {
+			continue
+		}
+		m[k] = v
+	}
+
+	b, err := json.Marshal(m)
+	if err != nil {
+		return nil, err
+	}
+
+	// args may carry the tenant using a foreign field name
+	b, err = a.mapFieldNames(b)
+	if err != nil {
+		return nil, err
+	}
+
+	mapped := struct {
+		ID     string `json:"id"`
+		Tenant string `json:"tenant"`
+	}{}
+	err = json.Unmarshal(b, &mapped)
+	if err != nil {
+		return nil, err
+	}
+
+	if mapped.ID != item.ID {
+		return nil, fmt.Errorf("%s: %w: id %s given in args", item.ID, ErrInvalidArgs, mapped.ID)
+	}
+	if !run.allows(mapped.Tenant) {
+		return nil, fmt.Errorf("%s: %w: %s", item.ID, ErrForbiddenTenant, mapped.Tenant)
+	}
+
+	err = a.saveSeeded(ctx, b, current, run)
+	if err != nil {
+		return nil, err
+	}
+
+	if current == nil {
+		run.created = append(run.created, item.ID)
+	} else {
+		run.updated = append(run.updated, item.ID)
+	}
+	if !slices.Contains(run.seeded, mapped.Tenant) {
+		run.seeded = append(run.seeded, mapped.Tenant)
+	}
+
+	return things.ConvToThing(b)
+}
+
+// inherit fills in a blank location or tenant of a seeded thing. Values are inherited from the parent
+// thing according to the inherit rules of the type, falling back to the default location of the type.
+// Without configured rules only the tenant is inherited.
+func (a *app) inherit(thingType string, blankLocation bool, l things.Location, tenant string, parent things.Thing) (things.Location, string) {
+	rules := []string{InheritTenant}
+	var defaultLocation *things.Location
+
+	a.cfgMu.RLock()
+	if a.cfg != nil {
+		for _, tc := range a.cfg.typesFor(tenant) {
+			if !strings.EqualFold(tc.Type, thingType) {
+				continue
 			}
-		} else {
-			err = a.UpdateThing(ctx, b, tenants)
-			if err != nil {
-				return err
+			if tc.Inherit != nil {
+				rules = tc.Inherit
 			}
+			defaultLocation = tc.DefaultLocation
 		}
 	}
+	a.cfgMu.RUnlock()
 
-	return nil
+	if parent != nil {
+		if blankLocation && slices.Contains(rules, InheritLocation) {
+			lat, lon := parent.LatLon()
+			l = things.Location{Latitude: lat, Longitude: lon}
+			blankLocation = false
+		}
+		if tenant == "" && slices.Contains(rules, InheritTenant) {
+			tenant = parent.Tenant()
+		}
+	}
+
+	if blankLocation && defaultLocation != nil {
+		l = *defaultLocation
+	}
+
+	return l, tenant
 }
 
+// GetTypes returns the types configured for any of the tenants, using the global types for tenants without overrides
 func (a *app) GetTypes(ctx context.Context, tenants []string) ([]things.ThingType, error) {
 	types := make([]things.ThingType, 0)
 
-	for _, t := range a.cfg.Types {
-		types = append(types, things.ThingType{
-			Type: t.Type,
-			Name: t.Type,
-		})
-
-		for _, s := range t.SubTypes {
-			types = append(types, things.ThingType{
-				Type:    t.Type,
-				SubType: s,
-				Name:    fmt.Sprintf("%s-%s", t.Type, s),
+	a.cfgMu.RLock()
+	defer a.cfgMu.RUnlock()
+
+	if a.cfg == nil {
+		return types, nil
+	}
+
+	if len(tenants) == 0 {
+		tenants = []string{""}
+	}
+
+	add := func(tt things.ThingType) {
+		if !slices.Contains(types, tt) {
+			types = append(types, tt)
+		}
+	}
+
+	for _, tenant := range tenants {
+		for _, t := range a.cfg.typesFor(tenant) {
+			add(things.ThingType{
+				Type: t.Type,
+				Name: t.Type,
 			})
+
+			for _, s := range t.SubTypes {
+				add(things.ThingType{
+					Type:    t.Type,
+					SubType: s,
+					Name:    fmt.Sprintf("%s-%s", t.Type, s),
+				})
+			}
 		}
 	}
 
 	return types, nil
 }
+
+// Compact hard-deletes things, and their values, that have been soft-deleted for longer than the configured grace period
+func (a *app) Compact(ctx context.Context) (int64, int64, error) {
+	log := logging.GetFromContext(ctx)
+
+	gracePeriod := defaultCompactionGracePeriod
+	a.cfgMu.RLock()
+	if a.cfg != nil && a.cfg.Compaction.GracePeriod > 0 {
+		gracePeriod = a.cfg.Compaction.GracePeriod
+	}
+	a.cfgMu.RUnlock()
+
+	deletedBefore := time.Now().UTC().Add(-gracePeriod)
+
+	nThings, nValues, err := a.writer.PurgeDeletedThings(ctx, deletedBefore)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	log.Info("compacted soft-deleted things", slog.Int64("things", nThings), slog.Int64("values", nValues), slog.Time("deletedBefore", deletedBefore))
+
+	return nThings, nValues, nil
+}
+
+// CountThings returns the number of things of each type and tenant, for the given tenants
+func (a *app) CountThings(ctx context.Context, tenants []string) ([]ThingCount, error) {
+	if len(tenants) == 0 {
+		return nil, ErrMissingThingTenant
+	}
+
+	return a.reader.CountThings(ctx, tenants)
+}
+
+// GetTenants returns thing and value counts for every tenant. It is not scoped to any tenants and
+// must only be exposed to administrators.
+func (a *app) GetTenants(ctx context.Context) ([]TenantCount, error) {
+	return a.reader.CountByTenant(ctx)
+}
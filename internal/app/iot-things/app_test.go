@@ -2,11 +2,21 @@ package iotthings
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
+	"slices"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/diwise/iot-things/internal/app/iot-things/things"
+	"github.com/diwise/iot-things/pkg/types"
+	"github.com/diwise/messaging-golang/pkg/messaging"
 	"github.com/matryer/is"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
 )
 
 func TestSeed(t *testing.T) {
@@ -26,7 +36,7 @@ func TestSeed(t *testing.T) {
 	}
 
 	app := New(ctx, r, w, msgCtxMock())
-	app.Seed(ctx, strings.NewReader(csvData))
+	app.Seed(ctx, strings.NewReader(csvData), false, nil)
 }
 
 func TestSeedUpdate(t *testing.T) {
@@ -64,8 +74,8 @@ func TestSeedUpdate(t *testing.T) {
 		},
 	}
 
-	app := New(ctx,r, w, msgCtxMock())
-	app.Seed(ctx, strings.NewReader(csvData))
+	app := New(ctx, r, w, msgCtxMock())
+	app.Seed(ctx, strings.NewReader(csvData), false, nil)
 }
 
 func TestLoadConfig(t *testing.T) {
@@ -98,30 +108,2422 @@ types:
       - "subType2C"
 `
 
-	app := New(ctx,r, w, msgCtxMock())
+	app := New(ctx, r, w, msgCtxMock())
 	err := app.LoadConfig(ctx, strings.NewReader(yamlConfig))
 	is.NoErr(err)
 }
 
-func newConditions(conditions ...ConditionFunc) map[string]any {
-	m := make(map[string]any)
+func TestQueryValuesFromOtherTenantIsNotAllowed(t *testing.T) {
+	ctx := context.Background()
+	is := is.New(t)
 
-	for _, f := range conditions {
-		m = f(m)
+	r := &ThingsReaderMock{
+		QueryThingsFunc: func(ctx context.Context, conditions ...ConditionFunc) (QueryResult, error) {
+			cond := newConditions(conditions...)
+			tenants := cond["tenants"].([]string)
+			if cond["id"] == "room-001" && slices.Contains(tenants, "tenant-a") {
+				room := things.NewRoom("room-001", things.DefaultLocation, "tenant-a")
+				return QueryResult{
+					Data: [][]byte{room.Byte()},
+				}, nil
+			}
+			return QueryResult{
+				Data: [][]byte{},
+			}, nil
+		},
+		QueryValuesFunc: func(ctx context.Context, conditions ...ConditionFunc) (QueryResult, error) {
+			return QueryResult{
+				Data: [][]byte{[]byte(`{"id":"room-001/3303/5700"}`)},
+			}, nil
+		},
 	}
+	w := &ThingsWriterMock{}
 
-	if _, ok := m["limit"]; !ok {
-		m["limit"] = 100
+	app := New(ctx, r, w, msgCtxMock())
+
+	_, err := app.QueryValues(ctx, map[string][]string{"thingid": {"room-001"}}, []string{"tenant-b"})
+	is.True(errors.Is(err, ErrThingNotFound))
+	is.Equal(len(r.QueryValuesCalls()), 0)
+
+	_, err = app.QueryValues(ctx, map[string][]string{"thingid": {"room-001"}, "tenant": {"tenant-a"}}, []string{"tenant-b"})
+	is.True(errors.Is(err, ErrForbiddenTenant))
+	is.Equal(len(r.QueryValuesCalls()), 0)
+
+	result, err := app.QueryValues(ctx, map[string][]string{"thingid": {"room-001"}}, []string{"tenant-a"})
+	is.NoErr(err)
+	is.Equal(len(result.Data), 1)
+
+	cond := newConditions(r.QueryValuesCalls()[0].Conditions...)
+	is.Equal(cond["tenants"], []string{"tenant-a"})
+}
+
+func TestQueryThingsIsScopedToAllowedTenants(t *testing.T) {
+	ctx := context.Background()
+	is := is.New(t)
+
+	r := &ThingsReaderMock{
+		QueryThingsFunc: func(ctx context.Context, conditions ...ConditionFunc) (QueryResult, error) {
+			return QueryResult{}, nil
+		},
 	}
+	w := &ThingsWriterMock{}
 
-	if _, ok := m["offset"]; !ok {
-		m["offset"] = 0
+	app := New(ctx, r, w, msgCtxMock())
+
+	allowed := []string{"tenant-a", "tenant-b", "tenant-c"}
+
+	_, err := app.QueryThings(ctx, map[string][]string{}, allowed)
+	is.NoErr(err)
+	cond := newConditions(r.QueryThingsCalls()[0].Conditions...)
+	is.Equal(cond["tenants"], allowed)
+
+	// a subset of the allowed tenants scopes the query to those tenants
+	_, err = app.QueryThings(ctx, map[string][]string{"tenant": {"tenant-b"}}, allowed)
+	is.NoErr(err)
+	cond = newConditions(r.QueryThingsCalls()[1].Conditions...)
+	is.Equal(cond["tenants"], []string{"tenant-b"})
+
+	_, err = app.QueryThings(ctx, map[string][]string{"tenant": {"tenant-a,tenant-c"}}, allowed)
+	is.NoErr(err)
+	cond = newConditions(r.QueryThingsCalls()[2].Conditions...)
+	is.Equal(cond["tenants"], []string{"tenant-a", "tenant-c"})
+
+	// requesting any tenant that is not allowed is rejected
+	_, err = app.QueryThings(ctx, map[string][]string{"tenant": {"tenant-b", "tenant-d"}}, allowed)
+	is.True(errors.Is(err, ErrForbiddenTenant))
+	is.Equal(len(r.QueryThingsCalls()), 3)
+}
+
+func TestAddThingWithMissingRequiredArgs(t *testing.T) {
+	ctx := context.Background()
+	is := is.New(t)
+
+	r := &ThingsReaderMock{}
+	w := &ThingsWriterMock{
+		AddThingFunc: func(ctx context.Context, t things.Thing) error {
+			return nil
+		},
 	}
 
-	return m
+	yamlConfig := `
+types:
+  - type: "Container"
+    requiredArgs:
+      - "maxd"
+      - "maxl"
+`
+
+	app := New(ctx, r, w, msgCtxMock())
+	err := app.LoadConfig(ctx, strings.NewReader(yamlConfig))
+	is.NoErr(err)
+
+	err = app.AddThing(ctx, []byte(`{"id":"container-001","type":"Container","tenant":"default","maxl":0.79}`))
+	is.True(errors.Is(err, ErrMissingArgs))
+	is.True(strings.HasSuffix(err.Error(), ": maxd"))
+	is.Equal(len(w.AddThingCalls()), 0)
+
+	err = app.AddThing(ctx, []byte(`{"id":"container-001","type":"Container","tenant":"default","maxd":0.94,"maxl":0.79}`))
+	is.NoErr(err)
+	is.Equal(len(w.AddThingCalls()), 1)
 }
 
-const csvData string = `id;type;subType;name;decsription;location;tenant;tags;refDevices;args
-forradet-bpn;Sewer;CombinedSewageOverflow;Förrådet BPN;Förrådet BPN;62.4008,17.4135;msva;braddmatare;d4f3e2f1-d430-467b-85ec-7cd977b0335f;
-5;Container;WasteContainer;namn;beskrivning;62.39095613,17.31727909;default;soptunna,linje 1;d4f3e2f1-d430-467b-85ec-7cd977b0335f,527090f3-7f85-49f8-889b-99a50530dede;{'max_distance':0.94,'max_level':0.79}
+func TestAddThingWithInvalidClamp(t *testing.T) {
+	ctx := context.Background()
+	is := is.New(t)
+
+	r := &ThingsReaderMock{}
+	w := &ThingsWriterMock{
+		AddThingFunc: func(ctx context.Context, t things.Thing) error {
+			return nil
+		},
+	}
+
+	app := New(ctx, r, w, msgCtxMock())
+
+	err := app.AddThing(ctx, []byte(`{"id":"container-001","type":"Container","tenant":"default","maxd":0.94,"clamp":"zero"}`))
+	is.True(errors.Is(err, ErrInvalidArgs))
+	is.Equal(len(w.AddThingCalls()), 0)
+
+	err = app.AddThing(ctx, []byte(`{"id":"container-001","type":"Container","tenant":"default","maxd":0.94,"clamp":"clampZero"}`))
+	is.NoErr(err)
+	is.Equal(len(w.AddThingCalls()), 1)
+}
+
+func TestCreateOrUpdateThing(t *testing.T) {
+	ctx := context.Background()
+	is := is.New(t)
+
+	existing := things.NewContainer("container-001", things.DefaultLocation, "default")
+
+	r := &ThingsReaderMock{
+		QueryThingsFunc: func(ctx context.Context, conditions ...ConditionFunc) (QueryResult, error) {
+			cond := newConditions(conditions...)
+			if cond["id"] != existing.ID() {
+				return QueryResult{}, nil
+			}
+			return QueryResult{Data: [][]byte{existing.Byte()}, Count: 1}, nil
+		},
+	}
+	w := &ThingsWriterMock{
+		UpdateThingFunc: func(ctx context.Context, t things.Thing) error {
+			return nil
+		},
+		UpsertThingFunc: func(ctx context.Context, t things.Thing) (bool, error) {
+			return true, nil
+		},
+	}
+
+	yamlConfig := `
+types:
+  - type: "Container"
+    requiredArgs:
+      - "maxd"
+`
+
+	app := New(ctx, r, w, msgCtxMock())
+	is.NoErr(app.LoadConfig(ctx, strings.NewReader(yamlConfig)))
+
+	// an existing thing is replaced through the update path, without checking required args
+	created, err := app.CreateOrUpdateThing(ctx, []byte(`{"id":"container-001","type":"Container","tenant":"default"}`), []string{"default"})
+	is.NoErr(err)
+	is.True(!created)
+	is.Equal(len(w.UpdateThingCalls()), 1)
+	is.Equal(len(w.UpsertThingCalls()), 0)
+
+	// a new thing must have its required args
+	_, err = app.CreateOrUpdateThing(ctx, []byte(`{"id":"container-002","type":"Container","tenant":"default"}`), []string{"default"})
+	is.True(errors.Is(err, ErrMissingArgs))
+	is.Equal(len(w.UpsertThingCalls()), 0)
+
+	created, err = app.CreateOrUpdateThing(ctx, []byte(`{"id":"container-002","type":"Container","tenant":"default","maxd":0.94}`), []string{"default"})
+	is.NoErr(err)
+	is.True(created)
+	is.Equal(len(w.UpsertThingCalls()), 1)
+}
+
+func TestCloneThing(t *testing.T) {
+	ctx := context.Background()
+	is := is.New(t)
+
+	maxd, maxl, angle := 0.94, 0.79, 15.0
+
+	source := things.NewContainer("container-001", things.Location{Latitude: 62.4, Longitude: 17.3}, "default")
+	c := source.(*things.Container)
+	c.MaxDistance = &maxd
+	c.MaxLevel = &maxl
+	c.Angle = &angle
+	c.CurrentLevel = 0.5
+	c.Percent = 63.3
+	c.AddTag("glass")
+	c.AddDevice("a81758fffe0524f3")
+	c.SetLastObserved([]things.Measurement{{ID: "a81758fffe0524f3/3330/5700", Urn: things.DistanceURN, Timestamp: time.Now()}})
+
+	r := &ThingsReaderMock{
+		QueryThingsFunc: func(ctx context.Context, conditions ...ConditionFunc) (QueryResult, error) {
+			cond := newConditions(conditions...)
+			if !slices.Contains(cond["tenants"].([]string), source.Tenant()) {
+				return QueryResult{}, nil
+			}
+			return QueryResult{Data: [][]byte{source.Byte()}, Count: 1}, nil
+		},
+	}
+	w := &ThingsWriterMock{
+		AddThingFunc: func(ctx context.Context, t things.Thing) error {
+			return nil
+		},
+	}
+
+	app := New(ctx, r, w, msgCtxMock())
+
+	clone, err := app.CloneThing(ctx, "container-001", []byte(`{"newId":"container-002","location":{"latitude":62.5,"longitude":17.4},"deviceIds":["a81758fffe0524f4"]}`), []string{"default"})
+	is.NoErr(err)
+	is.Equal(len(w.AddThingCalls()), 1)
+
+	added := w.AddThingCalls()[0].T.(*things.Container)
+	is.Equal(added.ID(), "container-002")
+	is.Equal(clone.ID(), "container-002")
+	is.Equal(added.Tenant(), "default")
+
+	// config args and tags are copied
+	is.Equal(*added.MaxDistance, maxd)
+	is.Equal(*added.MaxLevel, maxl)
+	is.Equal(*added.Angle, angle)
+	is.Equal(added.Tags, []string{"glass"})
+
+	// state, location and devices are not
+	is.Equal(added.CurrentLevel, 0.0)
+	is.Equal(added.Percent, 0.0)
+	lat, lon := added.LatLon()
+	is.Equal(lat, 62.5)
+	is.Equal(lon, 17.4)
+	is.Equal(len(added.Refs()), 1)
+	is.Equal(added.Refs()[0].DeviceID, "a81758fffe0524f4")
+	is.Equal(len(added.Refs()[0].Measurements), 0)
+
+	_, err = app.CloneThing(ctx, "container-001", []byte(`{"newId":"container-003"}`), []string{"other"})
+	is.True(errors.Is(err, ErrThingNotFound))
+
+	_, err = app.CloneThing(ctx, "container-001", []byte(`{}`), []string{"default"})
+	is.True(errors.Is(err, ErrMissingThingID))
+	is.Equal(len(w.AddThingCalls()), 1)
+}
+
+func TestCompactUsesConfiguredGracePeriod(t *testing.T) {
+	ctx := context.Background()
+	is := is.New(t)
+
+	r := &ThingsReaderMock{}
+	w := &ThingsWriterMock{
+		PurgeDeletedThingsFunc: func(ctx context.Context, deletedBefore time.Time) (int64, int64, error) {
+			return 1, 10, nil
+		},
+	}
+
+	yamlConfig := `
+compaction:
+  gracePeriod: 48h
+`
+
+	app := New(ctx, r, w, msgCtxMock())
+	err := app.LoadConfig(ctx, strings.NewReader(yamlConfig))
+	is.NoErr(err)
+
+	nThings, nValues, err := app.Compact(ctx)
+	is.NoErr(err)
+	is.Equal(nThings, int64(1))
+	is.Equal(nValues, int64(10))
+
+	deletedBefore := w.PurgeDeletedThingsCalls()[0].DeletedBefore
+	is.True(time.Since(deletedBefore) >= 48*time.Hour)
+	is.True(time.Since(deletedBefore) < 49*time.Hour)
+}
+
+func TestDeleteValuesWithRedact(t *testing.T) {
+	ctx := context.Background()
+	is := is.New(t)
+
+	r := &ThingsReaderMock{
+		QueryThingsFunc: func(ctx context.Context, conditions ...ConditionFunc) (QueryResult, error) {
+			room := things.NewRoom("room-001", things.DefaultLocation, "default")
+			return QueryResult{
+				Data: [][]byte{room.Byte()},
+			}, nil
+		},
+	}
+	w := &ThingsWriterMock{
+		DeleteValuesFunc: func(ctx context.Context, thingID string, conditions ...ConditionFunc) (int64, error) {
+			return 2, nil
+		},
+		RedactValuesFunc: func(ctx context.Context, thingID string, conditions ...ConditionFunc) (int64, error) {
+			return 3, nil
+		},
+	}
+
+	app := New(ctx, r, w, msgCtxMock())
+
+	params := map[string][]string{
+		"timerel":   {"between"},
+		"timeat":    {"2024-01-01T00:00:00Z"},
+		"endtimeat": {"2024-02-01T00:00:00Z"},
+		"redact":    {"true"},
+	}
+
+	n, err := app.DeleteValues(ctx, "room-001", params, []string{"default"})
+	is.NoErr(err)
+	is.Equal(n, int64(3))
+	is.Equal(len(w.RedactValuesCalls()), 1)
+	is.Equal(len(w.DeleteValuesCalls()), 0)
+
+	cond := newConditions(w.RedactValuesCalls()[0].Conditions...)
+	is.Equal(cond["timerel"], "between")
+
+	// timerel is case insensitive, as when querying values
+	delete(params, "redact")
+	params["timerel"] = []string{"Between"}
+
+	n, err = app.DeleteValues(ctx, "room-001", params, []string{"default"})
+	is.NoErr(err)
+	is.Equal(n, int64(2))
+	is.Equal(len(w.DeleteValuesCalls()), 1)
+
+	cond = newConditions(w.DeleteValuesCalls()[0].Conditions...)
+	is.Equal(cond["timerel"], "between")
+}
+
+func TestDeleteValuesRequiresTimeWindow(t *testing.T) {
+	ctx := context.Background()
+	is := is.New(t)
+
+	r := &ThingsReaderMock{}
+	w := &ThingsWriterMock{}
+
+	app := New(ctx, r, w, msgCtxMock())
+
+	_, err := app.DeleteValues(ctx, "room-001", map[string][]string{"redact": {"true"}}, []string{"default"})
+	is.True(errors.Is(err, ErrInvalidParams))
+
+	_, err = app.DeleteValues(ctx, "room-001", map[string][]string{}, []string{"default"})
+	is.True(errors.Is(err, ErrInvalidParams))
+
+	// malformed params are rejected even though they are ignored in queries
+
+	for _, params := range []map[string][]string{
+		{"timerel": {"foo"}, "timeat": {"2024-01-01T00:00:00Z"}},
+		{"timerel": {"after"}, "timeat": {"yesterday"}},
+		{"timerel": {"between"}, "timeat": {"2024-01-01T00:00:00Z"}},
+		{"timerel": {"after"}, "timeat": {"2024-01-01T00:00:00Z"}, "op": {"gt", "lt"}, "value": {"1"}},
+	} {
+		_, err = app.DeleteValues(ctx, "room-001", params, []string{"default"})
+		is.True(errors.Is(err, ErrInvalidParams))
+	}
+
+	is.Equal(len(w.DeleteValuesCalls()), 0)
+	is.Equal(len(w.RedactValuesCalls()), 0)
+}
+
+func TestSeedWithAlternateFieldNames(t *testing.T) {
+	ctx := context.Background()
+	is := is.New(t)
+
+	r := &ThingsReaderMock{
+		QueryThingsFunc: func(ctx context.Context, conditions ...ConditionFunc) (QueryResult, error) {
+			return QueryResult{
+				Data: [][]byte{},
+			}, nil
+		},
+	}
+	w := &ThingsWriterMock{
+		AddThingFunc: func(ctx context.Context, t things.Thing) error {
+			return nil
+		},
+	}
+
+	yamlConfig := `
+fieldNames:
+  organisation: tenant
+  category: type
 `
+
+	app := New(ctx, r, w, msgCtxMock())
+	err := app.LoadConfig(ctx, strings.NewReader(yamlConfig))
+	is.NoErr(err)
+
+	csv := `id;type;subType;name;decsription;location;tenant;tags;refDevices;args
+room-001;;;Rum 1;;62.4008,17.4135;;;;{'organisation':'msva','category':'Room'}
+`
+	_, err = app.Seed(ctx, strings.NewReader(csv), false, nil)
+	is.NoErr(err)
+
+	is.Equal(len(w.AddThingCalls()), 1)
+	is.Equal(w.AddThingCalls()[0].T.Type(), "Room")
+	is.Equal(w.AddThingCalls()[0].T.Tenant(), "msva")
+
+	err = app.AddThing(ctx, []byte(`{"id":"room-002","category":"Room","organisation":"msva"}`))
+	is.NoErr(err)
+	is.Equal(w.AddThingCalls()[1].T.Type(), "Room")
+	is.Equal(w.AddThingCalls()[1].T.Tenant(), "msva")
+}
+
+func TestPublisherCollapsesUpdatesWithinWindow(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	is := is.New(t)
+
+	room := things.NewRoom("room-001", things.DefaultLocation, "default")
+
+	r := &ThingsReaderMock{
+		QueryThingsFunc: func(ctx context.Context, conditions ...ConditionFunc) (QueryResult, error) {
+			return QueryResult{Data: [][]byte{room.Byte()}, Count: 1}, nil
+		},
+	}
+
+	published := make(chan messaging.TopicMessage, 10)
+	m := &messaging.MsgContextMock{
+		PublishOnTopicFunc: func(ctx context.Context, message messaging.TopicMessage) error {
+			published <- message
+			return nil
+		},
+	}
+
+	const window = 100 * time.Millisecond
+
+	in := make(chan changedThing)
+	go publisher(ctx, r, m, in, things.ConvToThing, func() publisherConfig {
+		return publisherConfig{Window: window}
+	})
+
+	in <- changedThing{thingID: "room-001"}
+	in <- changedThing{thingID: "room-001"}
+
+	select {
+	case msg := <-published:
+		is.Equal(msg.TopicName(), "thing.updated")
+	case <-ctx.Done():
+		t.Fatal("timed out waiting for thing.updated")
+	}
+
+	select {
+	case <-published:
+		t.Fatal("expected updates within the window to be published once")
+	case <-time.After(3 * window):
+	}
+}
+
+func TestPublisherDigestMode(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	is := is.New(t)
+
+	store := map[string]things.Thing{
+		"room-001": things.NewRoom("room-001", things.DefaultLocation, "tenant-a"),
+		"room-002": things.NewRoom("room-002", things.DefaultLocation, "tenant-a"),
+		"room-003": things.NewRoom("room-003", things.DefaultLocation, "tenant-b"),
+	}
+
+	r := &ThingsReaderMock{
+		QueryThingsFunc: func(ctx context.Context, conditions ...ConditionFunc) (QueryResult, error) {
+			cond := newConditions(conditions...)
+			return QueryResult{
+				Data: [][]byte{store[cond["id"].(string)].Byte()},
+			}, nil
+		},
+	}
+
+	published := make(chan messaging.TopicMessage, 10)
+	m := &messaging.MsgContextMock{
+		PublishOnTopicFunc: func(ctx context.Context, message messaging.TopicMessage) error {
+			published <- message
+			return nil
+		},
+	}
+
+	in := make(chan changedThing)
+	go publisher(ctx, r, m, in, things.ConvToThing, func() publisherConfig {
+		return publisherConfig{Mode: PublishModeDigest, Window: 50 * time.Millisecond}
+	})
+
+	for _, id := range []string{"room-001", "room-002", "room-003", "room-001"} {
+		in <- changedThing{thingID: id}
+	}
+
+	digests := map[string][]string{}
+	for len(digests["tenant-a"])+len(digests["tenant-b"]) < 3 {
+		select {
+		case msg := <-published:
+			d, ok := msg.(*types.ThingsUpdated)
+			is.True(ok)
+			is.Equal(msg.TopicName(), "things.updated")
+			digests[d.Tenant] = append(digests[d.Tenant], d.IDs...)
+		case <-ctx.Done():
+			t.Fatal("timed out waiting for digests")
+		}
+	}
+
+	slices.Sort(digests["tenant-a"])
+	is.Equal(digests["tenant-a"], []string{"room-001", "room-002"})
+	is.Equal(digests["tenant-b"], []string{"room-003"})
+}
+
+func TestPublisherCloudEventsEnvelope(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	is := is.New(t)
+
+	room := things.NewRoom("room-001", things.DefaultLocation, "default")
+
+	r := &ThingsReaderMock{
+		QueryThingsFunc: func(ctx context.Context, conditions ...ConditionFunc) (QueryResult, error) {
+			return QueryResult{Data: [][]byte{room.Byte()}}, nil
+		},
+	}
+
+	published := make(chan messaging.TopicMessage, 10)
+	m := &messaging.MsgContextMock{
+		PublishOnTopicFunc: func(ctx context.Context, message messaging.TopicMessage) error {
+			published <- message
+			return nil
+		},
+	}
+
+	in := make(chan changedThing)
+	go publisher(ctx, r, m, in, things.ConvToThing, func() publisherConfig {
+		return publisherConfig{Window: 50 * time.Millisecond, Envelope: EnvelopeCloudEvents, Source: "urn:diwise:test"}
+	})
+
+	in <- changedThing{thingID: "room-001"}
+
+	var msg messaging.TopicMessage
+	select {
+	case msg = <-published:
+	case <-ctx.Done():
+		t.Fatal("timed out waiting for message")
+	}
+
+	is.Equal(msg.TopicName(), "thing.updated")
+	is.Equal(msg.ContentType(), "application/cloudevents+json")
+
+	event := map[string]json.RawMessage{}
+	is.NoErr(json.Unmarshal(msg.Body(), &event))
+
+	str := func(key string) string {
+		var s string
+		is.NoErr(json.Unmarshal(event[key], &s))
+		return s
+	}
+
+	is.Equal(str("specversion"), "1.0")
+	is.Equal(str("type"), "diwise.thing.updated")
+	is.Equal(str("source"), "urn:diwise:test")
+	is.Equal(str("subject"), "room-001")
+	is.Equal(str("datacontenttype"), "application/vnd.diwise.room+json")
+	is.True(str("id") != "")
+	_, err := time.Parse(time.RFC3339, str("time"))
+	is.NoErr(err)
+
+	data := types.ThingUpdated{}
+	is.NoErr(json.Unmarshal(event["data"], &data))
+	is.Equal(data.ID, "room-001")
+	is.Equal(data.Tenant, "default")
+
+	// the raw envelope publishes the message as is
+	raw := publisherConfig{}.target(&types.ThingUpdated{ID: "room-001", Type: "Room"})
+	_, ok := raw.(*types.ThingUpdated)
+	is.True(ok)
+}
+
+func TestPublishOnlyOnChange(t *testing.T) {
+	ctx := context.Background()
+	is := is.New(t)
+
+	room := things.NewRoom("room-001", things.DefaultLocation, "default")
+	room.AddDevice("c5a2ae17c239")
+
+	r := &ThingsReaderMock{
+		QueryThingsFunc: func(ctx context.Context, conditions ...ConditionFunc) (QueryResult, error) {
+			return QueryResult{Data: [][]byte{room.Byte()}}, nil
+		},
+	}
+	w := &ThingsWriterMock{
+		AddValueFunc: func(ctx context.Context, t things.Thing, m things.Value) error {
+			return nil
+		},
+		UpdateThingFunc: func(ctx context.Context, t things.Thing) error {
+			room = t
+			return nil
+		},
+	}
+
+	a := New(ctx, r, w, msgCtxMock()).(*app)
+
+	temperature := func(v float64, ts time.Time) things.Measurement {
+		return things.Measurement{ID: "c5a2ae17c239/3303/5700", Urn: things.TemperatureURN, Value: &v, Unit: "Cel", Timestamp: ts}
+	}
+
+	ts := time.Now().Add(-1 * time.Hour)
+
+	// without configuration every saved thing is published
+	changed, _ := a.handle(ctx, temperature(21, ts))
+	is.Equal(changed, []string{"room-001"})
+	changed, _ = a.handle(ctx, temperature(21, ts.Add(1*time.Minute)))
+	is.Equal(changed, []string{"room-001"})
+
+	err := a.LoadConfig(ctx, strings.NewReader("publisher:\n  onChangeOnly: true\n"))
+	is.NoErr(err)
+
+	// the same temperature only moves observedAt, the thing is saved but not published
+	changed, rr := a.handle(ctx, temperature(21, ts.Add(2*time.Minute)))
+	is.Equal(len(changed), 0)
+	is.Equal(rr.Status, RecordStored)
+	is.Equal(len(w.UpdateThingCalls()), 3)
+
+	changed, _ = a.handle(ctx, temperature(22, ts.Add(3*time.Minute)))
+	is.Equal(changed, []string{"room-001"})
+}
+
+func TestHandleContinuesWhenOneThingFails(t *testing.T) {
+	ctx := context.Background()
+	is := is.New(t)
+
+	failedBefore := metricSum(ctx, is, "things.handle.errors", "")
+
+	failing := things.NewRoom("room-001", things.DefaultLocation, "default")
+	failing.AddDevice("c5a2ae17c239")
+	working := things.NewRoom("room-002", things.DefaultLocation, "default")
+	working.AddDevice("c5a2ae17c239")
+
+	r := &ThingsReaderMock{
+		QueryThingsFunc: func(ctx context.Context, conditions ...ConditionFunc) (QueryResult, error) {
+			switch newConditions(conditions...)["id"] {
+			case "room-001":
+				return QueryResult{Data: [][]byte{failing.Byte()}, Count: 1}, nil
+			case "room-002":
+				return QueryResult{Data: [][]byte{working.Byte()}, Count: 1}, nil
+			}
+			return QueryResult{Data: [][]byte{failing.Byte(), working.Byte()}, Count: 2}, nil
+		},
+	}
+	w := &ThingsWriterMock{
+		AddValueFunc: func(ctx context.Context, t things.Thing, m things.Value) error {
+			if t.ID() == "room-001" {
+				return errors.New("storage unavailable")
+			}
+			return nil
+		},
+		UpdateThingFunc: func(ctx context.Context, t things.Thing) error {
+			return nil
+		},
+	}
+
+	a := New(ctx, r, w, msgCtxMock()).(*app)
+
+	v := 21.0
+	changed, rr := a.handle(ctx, things.Measurement{ID: "c5a2ae17c239/3303/5700", Urn: things.TemperatureURN, Value: &v, Unit: "Cel", Timestamp: time.Now()})
+
+	is.Equal(changed, []string{"room-002"})
+	is.Equal(len(w.UpdateThingCalls()), 1)
+	is.Equal(w.UpdateThingCalls()[0].T.ID(), "room-002")
+
+	is.Equal(rr.Status, RecordError)
+	is.True(strings.Contains(rr.Reason, "room-001: storage unavailable"))
+
+	is.Equal(metricSum(ctx, is, "things.handle.errors", "")-failedBefore, int64(1))
+}
+
+func TestIngestMetrics(t *testing.T) {
+	ctx := context.Background()
+	is := is.New(t)
+
+	room := things.NewRoom("room-001", things.DefaultLocation, "default")
+	room.AddDevice("c5a2ae17c239")
+
+	r := &ThingsReaderMock{
+		QueryThingsFunc: func(ctx context.Context, conditions ...ConditionFunc) (QueryResult, error) {
+			return QueryResult{Data: [][]byte{room.Byte()}, Count: 1}, nil
+		},
+	}
+	w := &ThingsWriterMock{
+		AddValueFunc: func(ctx context.Context, t things.Thing, m things.Value) error {
+			return nil
+		},
+		UpdateThingFunc: func(ctx context.Context, t things.Thing) error {
+			return nil
+		},
+	}
+
+	handledBefore := metricSum(ctx, is, "things.measurements.handled", RecordStored)
+	writtenBefore := metricSum(ctx, is, "things.values.written", "ok")
+
+	a := New(ctx, r, w, msgCtxMock())
+
+	v := 21.0
+	a.HandleMeasurements(ctx, []things.Measurement{{ID: "c5a2ae17c239/3303/5700", Urn: things.TemperatureURN, Value: &v, Unit: "Cel", Timestamp: time.Now()}})
+
+	is.Equal(metricSum(ctx, is, "things.measurements.handled", RecordStored)-handledBefore, int64(1))
+	is.Equal(metricSum(ctx, is, "things.values.written", "ok")-writtenBefore, int64(1))
+}
+
+// metricReader collects the metrics of all tests
+var metricReader = sync.OnceValue(func() *sdkmetric.ManualReader {
+	reader := sdkmetric.NewManualReader()
+	InitMetrics(sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader)))
+	return reader
+})
+
+// metricSum sums a counter, only counting data points with the given status unless status is empty
+func metricSum(ctx context.Context, is *is.I, name, status string) int64 {
+	rm := metricdata.ResourceMetrics{}
+	is.NoErr(metricReader().Collect(ctx, &rm))
+
+	var sum int64
+	for _, sm := range rm.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			if m.Name != name {
+				continue
+			}
+			for _, dp := range m.Data.(metricdata.Sum[int64]).DataPoints {
+				if s, _ := dp.Attributes.Value("status"); status == "" || s.AsString() == status {
+					sum += dp.Value
+				}
+			}
+		}
+	}
+	return sum
+}
+
+func TestHandleMeasurementsConcurrentlyPerThing(t *testing.T) {
+	ctx := context.Background()
+	is := is.New(t)
+
+	is.True(workerIndex("room-001", 4) != workerIndex("room-002", 4)) // the things must be handled by different workers
+
+	mu := sync.Mutex{}
+	store := map[string]things.Thing{
+		"dev-a": things.NewRoom("room-001", things.DefaultLocation, "default"),
+		"dev-b": things.NewRoom("room-002", things.DefaultLocation, "default"),
+	}
+	store["dev-a"].AddDevice("dev-a")
+	store["dev-b"].AddDevice("dev-b")
+
+	r := &ThingsReaderMock{
+		QueryThingsFunc: func(ctx context.Context, conditions ...ConditionFunc) (QueryResult, error) {
+			mu.Lock()
+			defer mu.Unlock()
+			c := newConditions(conditions...)
+			if id, ok := c["id"]; ok {
+				for _, t := range store {
+					if t.ID() == id {
+						return QueryResult{Data: [][]byte{t.Byte()}, Count: 1}, nil
+					}
+				}
+				return QueryResult{}, nil
+			}
+			t := store[c["refdevice"].(string)]
+			return QueryResult{Data: [][]byte{t.Byte()}, Count: 1}, nil
+		},
+	}
+
+	released := make(chan struct{})
+	values := map[string][]float64{}
+
+	w := &ThingsWriterMock{
+		AddValueFunc: func(ctx context.Context, t things.Thing, v things.Value) error {
+			if t.ID() == "room-001" {
+				// blocks until room-002 is handled, which it can only be if the things are handled concurrently
+				select {
+				case <-released:
+				case <-time.After(1 * time.Second):
+					return errors.New("room-002 was not handled while room-001 was busy")
+				}
+			} else {
+				close(released)
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			values[t.ID()] = append(values[t.ID()], *v.Value)
+			return nil
+		},
+		UpdateThingFunc: func(ctx context.Context, t things.Thing) error {
+			mu.Lock()
+			defer mu.Unlock()
+			store[t.Refs()[0].DeviceID] = t
+			return nil
+		},
+	}
+
+	a := New(ctx, r, w, msgCtxMock())
+	is.NoErr(a.LoadConfig(ctx, strings.NewReader("ingest:\n  workers: 4\n")))
+
+	temperature := func(deviceID string, v float64, ts time.Time) things.Measurement {
+		return things.Measurement{ID: deviceID + "/3303/5700", Urn: things.TemperatureURN, Value: &v, Unit: "Cel", Timestamp: ts}
+	}
+
+	ts := time.Now().Add(-1 * time.Hour)
+	result := a.HandleMeasurements(ctx, []things.Measurement{
+		temperature("dev-a", 20, ts),
+		temperature("dev-a", 21, ts.Add(1*time.Minute)),
+		temperature("dev-b", 22, ts),
+	})
+
+	for _, rr := range result.Records {
+		is.Equal(rr.Status, RecordStored)
+	}
+
+	is.Equal(values["room-001"], []float64{20, 21}) // measurements for the same thing are handled in order
+	is.Equal(values["room-002"], []float64{22})
+}
+
+func TestUnknownTypeFallback(t *testing.T) {
+	ctx := context.Background()
+	is := is.New(t)
+
+	bench := []byte(`{"id":"bench-001","type":"Bench","tenant":"default","location":{"latitude":62.39,"longitude":17.30},"refDevices":[{"deviceID":"c5a2ae17c239"}]}`)
+
+	var stored things.Thing
+	values := []things.Value{}
+
+	r := &ThingsReaderMock{
+		QueryThingsFunc: func(ctx context.Context, conditions ...ConditionFunc) (QueryResult, error) {
+			if stored != nil {
+				return QueryResult{Data: [][]byte{stored.Byte()}}, nil
+			}
+			return QueryResult{Data: [][]byte{bench}}, nil
+		},
+	}
+	w := &ThingsWriterMock{
+		AddValueFunc: func(ctx context.Context, t things.Thing, m things.Value) error {
+			values = append(values, m)
+			return nil
+		},
+		UpdateThingFunc: func(ctx context.Context, t things.Thing) error {
+			stored = t
+			return nil
+		},
+	}
+
+	a := New(ctx, r, w, msgCtxMock()).(*app)
+
+	v := 21.0
+	m := things.Measurement{ID: "c5a2ae17c239/3303/5700", Urn: things.TemperatureURN, Value: &v, Unit: "Cel", Timestamp: time.Now()}
+
+	// unknown types fail by default
+	_, rr := a.handle(ctx, m)
+	is.Equal(rr.Status, RecordError)
+	is.True(strings.Contains(rr.Reason, "unknown thing type [Bench]"))
+	is.Equal(len(values), 0)
+
+	err := a.LoadConfig(ctx, strings.NewReader("unknownTypeFallback: true\n"))
+	is.NoErr(err)
+
+	changed, rr := a.handle(ctx, m)
+	is.Equal(rr.Status, RecordStored)
+	is.Equal(changed, []string{"bench-001"})
+
+	// the value is stored as reported, with the id of the thing
+	is.Equal(len(values), 1)
+	is.Equal(values[0].ID, "bench-001/3303/5700")
+	is.Equal(values[0].Urn, things.TemperatureURN)
+	is.Equal(*values[0].Value, 21.0)
+	is.Equal(values[0].Ref, "c5a2ae17c239/3303/5700")
+
+	// the type is kept and the urn is recorded
+	is.Equal(stored.Type(), "Bench")
+	is.Equal(stored.ValidURNs(), []string{things.TemperatureURN})
+	is.Equal(len(stored.Refs()[0].Measurements), 1)
+
+	// things of unknown types are only loaded, never created or updated
+	err = a.AddThing(ctx, bench)
+	is.True(err != nil)
+	err = a.UpdateThing(ctx, bench, []string{"default"})
+	is.True(err != nil)
+}
+
+func TestSeedInheritsFromParent(t *testing.T) {
+	ctx := context.Background()
+	is := is.New(t)
+
+	r := &ThingsReaderMock{
+		QueryThingsFunc: func(ctx context.Context, conditions ...ConditionFunc) (QueryResult, error) {
+			return QueryResult{}, nil
+		},
+	}
+	w := &ThingsWriterMock{
+		AddThingFunc: func(ctx context.Context, t things.Thing) error {
+			return nil
+		},
+	}
+
+	yamlConfig := `
+types:
+  - type: "Room"
+  - type: "Desk"
+    inherit: ["location", "tenant"]
+  - type: "WaterMeter"
+    defaultLocation:
+      latitude: 62.1
+      longitude: 17.1
+`
+
+	app := New(ctx, r, w, msgCtxMock())
+	err := app.LoadConfig(ctx, strings.NewReader(yamlConfig))
+	is.NoErr(err)
+
+	csv := `id;type;subType;name;decsription;location;tenant;tags;refDevices;args;parent
+room-001;Room;;Room 1;;62.39,17.31;tenant-a;;;;
+desk-001;Desk;;Desk 1;;;;;;;room-001
+wm-001;WaterMeter;;Meter 1;;;;;;;room-001
+`
+	_, err = app.Seed(ctx, strings.NewReader(csv), false, nil)
+	is.NoErr(err)
+	is.Equal(len(w.AddThingCalls()), 3)
+
+	desk := w.AddThingCalls()[1].T
+	lat, lon := desk.LatLon()
+	is.Equal(lat, 62.39)
+	is.Equal(lon, 17.31)
+	is.Equal(desk.Tenant(), "tenant-a")
+
+	wm := w.AddThingCalls()[2].T
+	lat, lon = wm.LatLon()
+	is.Equal(lat, 62.1) // default location of the type, location is not inherited
+	is.Equal(lon, 17.1)
+	is.Equal(wm.Tenant(), "tenant-a") // tenant is inherited by default
+}
+
+func TestGetRecentValues(t *testing.T) {
+	ctx := context.Background()
+	is := is.New(t)
+
+	r := &ThingsReaderMock{
+		QueryThingsFunc: func(ctx context.Context, conditions ...ConditionFunc) (QueryResult, error) {
+			room := things.NewRoom("room-001", things.DefaultLocation, "default")
+			return QueryResult{Data: [][]byte{room.Byte()}}, nil
+		},
+		QueryValuesFunc: func(ctx context.Context, conditions ...ConditionFunc) (QueryResult, error) {
+			return QueryResult{}, nil
+		},
+	}
+	w := &ThingsWriterMock{}
+
+	app := New(ctx, r, w, msgCtxMock())
+
+	_, err := app.GetRecentValues(ctx, "room-001", 5, []string{things.TemperatureURN}, []string{"default"})
+	is.NoErr(err)
+
+	cond := newConditions(r.QueryValuesCalls()[0].Conditions...)
+	is.Equal(cond["limit"], 5)
+	is.Equal(cond["newestfirst"], true)
+	is.Equal(cond["urn"], []string{things.TemperatureURN})
+	_, hasTimeRel := cond["timerel"]
+	is.True(!hasTimeRel)
+
+	_, err = app.GetRecentValues(ctx, "room-001", 0, nil, []string{"default"})
+	is.NoErr(err)
+
+	cond = newConditions(r.QueryValuesCalls()[1].Conditions...)
+	is.Equal(cond["limit"], defaultRecentValues)
+	_, hasUrn := cond["urn"]
+	is.True(!hasUrn)
+}
+
+func TestQueryThingsByStatus(t *testing.T) {
+	ctx := context.Background()
+	is := is.New(t)
+
+	r := &ThingsReaderMock{
+		QueryThingsFunc: func(ctx context.Context, conditions ...ConditionFunc) (QueryResult, error) {
+			room := things.NewRoom("room-001", things.DefaultLocation, "default")
+			return QueryResult{Data: [][]byte{room.Byte()}}, nil
+		},
+	}
+	w := &ThingsWriterMock{
+		UpdateThingFunc: func(ctx context.Context, t things.Thing) error {
+			return nil
+		},
+	}
+
+	app := New(ctx, r, w, msgCtxMock())
+
+	query := func(params map[string][]string) map[string]any {
+		_, err := app.QueryThings(ctx, params, []string{"default"})
+		is.NoErr(err)
+		calls := r.QueryThingsCalls()
+		return newConditions(calls[len(calls)-1].Conditions...)
+	}
+
+	is.Equal(query(map[string][]string{})["status"], "active")
+	is.Equal(query(map[string][]string{"status": {"inactive"}})["status"], "inactive")
+
+	_, ok := query(map[string][]string{"status": {"all"}})["status"]
+	is.True(!ok)
+	_, ok = query(map[string][]string{"id": {"room-001"}})["status"]
+	is.True(!ok)
+
+	err := app.MergeThing(ctx, "room-001", []byte(`{"status":"inactive"}`), []string{"default"})
+	is.NoErr(err)
+	is.Equal(w.UpdateThingCalls()[0].T.Status(), "inactive")
+
+	err = app.MergeThing(ctx, "room-001", []byte(`{"status":"retired"}`), []string{"default"})
+	is.True(errors.Is(err, ErrInvalidStatus))
+	is.Equal(len(w.UpdateThingCalls()), 1)
+}
+
+func TestTagsAreNormalized(t *testing.T) {
+	ctx := context.Background()
+	is := is.New(t)
+
+	r := &ThingsReaderMock{
+		QueryThingsFunc: func(ctx context.Context, conditions ...ConditionFunc) (QueryResult, error) {
+			room := things.NewRoom("room-001", things.DefaultLocation, "default")
+			return QueryResult{Data: [][]byte{room.Byte()}}, nil
+		},
+		GetTagsFunc: func(ctx context.Context, tenants []string) ([]string, error) {
+			return []string{" North", "north", "", "South "}, nil
+		},
+	}
+	w := &ThingsWriterMock{
+		AddThingFunc: func(ctx context.Context, t things.Thing) error {
+			return nil
+		},
+		UpdateThingFunc: func(ctx context.Context, t things.Thing) error {
+			return nil
+		},
+	}
+
+	app := New(ctx, r, w, msgCtxMock())
+	err := app.LoadConfig(ctx, strings.NewReader("tags:\n  lowercase: true\n"))
+	is.NoErr(err)
+
+	tagsOf := func(t things.Thing) []string {
+		m := struct {
+			Tags []string `json:"tags"`
+		}{}
+		json.Unmarshal(t.Byte(), &m)
+		return m.Tags
+	}
+
+	err = app.AddThing(ctx, []byte(`{"id":"room-002","type":"Room","tenant":"default","tags":[" North ","north","","Floor 1"]}`))
+	is.NoErr(err)
+	is.Equal(tagsOf(w.AddThingCalls()[0].T), []string{"north", "floor 1"})
+
+	err = app.MergeThing(ctx, "room-001", []byte(`{"tags":["  ","South","SOUTH "]}`), []string{"default"})
+	is.NoErr(err)
+	is.Equal(tagsOf(w.UpdateThingCalls()[0].T), []string{"south"})
+
+	tags, err := app.GetTags(ctx, []string{"default"})
+	is.NoErr(err)
+	is.Equal(tags, []string{"north", "south"})
+}
+
+func TestValidateParams(t *testing.T) {
+	is := is.New(t)
+
+	is.NoErr(ValidateParams(map[string][]string{
+		"limit": {"10"}, "offset": {"0"}, "timerel": {"between"}, "timeAt": {"2024-11-01T00:00:00Z"}, "endTimeAt": {"2024-11-02T00:00:00Z"},
+		"op": {"GT"}, "v": {"1.5"}, "timeunit": {"day"}, "aggr": {"avg"}, "status": {"all"}, "fields": {"anything"},
+	}))
+
+	malformed := map[string]string{
+		"limit":              "limit must be a positive integer",
+		"offset":             "offset must be a non-negative integer",
+		"timeat":             "timeat must be a RFC3339 timestamp",
+		"op":                 "op must be one of eq, ne, gt or lt",
+		"value":              "value must be a number",
+		"commissionedBefore": "commissionedbefore must be a RFC3339 timestamp",
+		"hasRecentValues":    "hasrecentvalues must be true or false",
+		"within":             "within must be a positive duration, e.g. 24h",
+		"cursor":             "cursor is not valid",
+	}
+
+	for param, expected := range malformed {
+		err := ValidateParams(map[string][]string{param: {"x"}})
+		is.True(errors.Is(err, ErrInvalidParams))
+		is.True(strings.Contains(err.Error(), expected)) // each malformed parameter is described
+	}
+
+	err := ValidateParams(map[string][]string{"limit": {"0"}, "offset": {"-1"}, "timerel": {"between"}, "timeat": {"2024-11-01T00:00:00Z"}})
+	is.Equal(err.Error(), "invalid query parameters: limit must be a positive integer; offset must be a non-negative integer; timerel between requires endtimeat")
+}
+
+func TestValuePredicateParams(t *testing.T) {
+	is := is.New(t)
+
+	cond := newConditions(WithParams(map[string][]string{"op": {"gt", "LT"}, "value": {"10", "20"}})...)
+	is.Equal(cond["valuepredicates"], []ValuePredicate{{Operator: "gt", Value: 10}, {Operator: "lt", Value: 20}})
+	_, ok := cond["value"]
+	is.True(!ok)
+
+	cond = newConditions(WithParams(map[string][]string{"op": {"gt"}, "value": {"10"}})...)
+	is.Equal(cond["value"], 10.0)
+	is.Equal(cond["operator"], "gt")
+
+	is.NoErr(ValidateParams(map[string][]string{"op": {"gt", "lt"}, "value": {"10", "20"}}))
+
+	err := ValidateParams(map[string][]string{"op": {"gt", "lt"}, "value": {"10"}})
+	is.Equal(err.Error(), "invalid query parameters: op and value must be given in pairs")
+}
+
+func TestWithAggregate(t *testing.T) {
+	is := is.New(t)
+
+	cond := newConditions(WithParams(map[string][]string{"timeunit": {"day"}, "aggr": {"avg,MAX", "median", "avg"}})...)
+	is.Equal(cond["aggr"], []string{"avg", "max"})
+	is.Equal(cond["timeunit"], "day")
+
+	is.NoErr(ValidateParams(map[string][]string{"timeunit": {"day"}, "aggr": {"avg,min"}}))
+	is.NoErr(ValidateParams(map[string][]string{"timeunit": {"day"}, "aggr": {"avg", "min"}}))
+
+	err := ValidateParams(map[string][]string{"timeunit": {"day"}, "aggr": {"avg,median"}})
+	is.Equal(err.Error(), "invalid query parameters: aggr must be one or more of sum, avg, min and max")
+}
+
+func TestWithName(t *testing.T) {
+	is := is.New(t)
+
+	cond := newConditions(WithParams(map[string][]string{"name": {" Förrådet "}, "type": {"Sewer"}, "tags": {"north"}})...)
+	is.Equal(cond["name"], "Förrådet")
+	is.Equal(cond["types"], []string{"Sewer"})
+	is.Equal(cond["tags"], []string{"north"})
+
+	cond = newConditions(WithName(" "))
+	_, ok := cond["name"]
+	is.True(!ok)
+}
+
+func TestWithTagMode(t *testing.T) {
+	is := is.New(t)
+
+	cond := newConditions(WithParams(map[string][]string{"tags": {"north", "south"}, "tagMode": {"ANY"}})...)
+	is.Equal(cond["tags"], []string{"north", "south"})
+	is.Equal(cond["tagmode"], TagModeAny)
+
+	cond = newConditions(WithParams(map[string][]string{"tags": {"north"}, "tagmode": {"some"}})...)
+	_, ok := cond["tagmode"]
+	is.True(!ok)
+
+	is.NoErr(ValidateParams(map[string][]string{"tagmode": {"all"}}))
+
+	err := ValidateParams(map[string][]string{"tagmode": {"some"}})
+	is.Equal(err.Error(), "invalid query parameters: tagmode must be all or any")
+}
+
+func TestWithStaleSince(t *testing.T) {
+	is := is.New(t)
+
+	cond := newConditions(WithParams(map[string][]string{"staleSince": {"2024-01-01T00:00:00Z"}, "observedAfter": {"2023-01-01T00:00:00Z"}})...)
+	is.Equal(cond["observedbefore"], time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	is.Equal(cond["observedafter"], time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC))
+
+	err := ValidateParams(map[string][]string{"staleSince": {"yesterday"}})
+	is.Equal(err.Error(), "invalid query parameters: stalesince must be a RFC3339 timestamp")
+}
+
+func TestWithBoundsAndNear(t *testing.T) {
+	is := is.New(t)
+
+	cond := newConditions(WithParams(map[string][]string{"bbox": {"17.2,62.3,17.4,62.5"}})...)
+	is.Equal(cond["bounds"], []float64{62.3, 17.2, 62.5, 17.4})
+
+	cond = newConditions(WithParams(map[string][]string{"near": {"62.39,17.31"}, "radius": {"500"}})...)
+	is.Equal(cond["near"], []float64{62.39, 17.31, 500})
+
+	is.NoErr(ValidateParams(map[string][]string{"bbox": {"17.2,62.3,17.4,62.5"}, "near": {"62.39,17.31"}, "radius": {"500"}}))
+
+	err := ValidateParams(map[string][]string{"bbox": {"17.4,62.3,17.2,62.5"}})
+	is.Equal(err.Error(), "invalid query parameters: bbox must be minLon,minLat,maxLon,maxLat")
+
+	err = ValidateParams(map[string][]string{"near": {"62.39,17.31"}})
+	is.Equal(err.Error(), "invalid query parameters: near requires radius")
+
+	err = ValidateParams(map[string][]string{"near": {"62.39"}, "radius": {"-1"}})
+	is.Equal(err.Error(), "invalid query parameters: near must be lat,lon; radius must be a positive number of meters")
+}
+
+func TestCursor(t *testing.T) {
+	is := is.New(t)
+
+	ts := time.Date(2024, 11, 1, 12, 0, 0, 123456000, time.UTC)
+
+	cursor := EncodeCursor(ts, "room-001/3303/5700")
+	decodedTs, id, err := DecodeCursor(cursor)
+	is.NoErr(err)
+	is.True(decodedTs.Equal(ts))
+	is.Equal(id, "room-001/3303/5700")
+
+	cond := newConditions(WithParams(map[string][]string{"cursor": {cursor}})...)
+	is.True(cond["cursorts"].(time.Time).Equal(ts))
+	is.Equal(cond["cursorid"], "room-001/3303/5700")
+
+	_, _, err = DecodeCursor(EncodeCursor(ts, ""))
+	is.True(err != nil)
+}
+
+func TestQueryValuesAggregatedByType(t *testing.T) {
+	ctx := context.Background()
+	is := is.New(t)
+
+	r := &ThingsReaderMock{
+		QueryValuesFunc: func(ctx context.Context, conditions ...ConditionFunc) (QueryResult, error) {
+			return QueryResult{}, nil
+		},
+	}
+	w := &ThingsWriterMock{}
+
+	app := New(ctx, r, w, msgCtxMock())
+
+	params := map[string][]string{"type": {"Passage"}, "aggr": {"sum"}, "timeunit": {"day"}}
+	_, err := app.QueryValues(ctx, params, []string{"default"})
+	is.NoErr(err)
+
+	cond := newConditions(r.QueryValuesCalls()[0].Conditions...)
+	is.Equal(cond["types"], []string{"Passage"})
+	is.Equal(cond["aggr"], []string{"sum"})
+	is.Equal(cond["timeunit"], "day")
+	is.Equal(cond["tenants"], []string{"default"})
+
+	// unknown aggregates are ignored by default
+	params["aggr"] = []string{"median"}
+	_, err = app.QueryValues(ctx, params, []string{"default"})
+	is.NoErr(err)
+
+	_, ok := newConditions(r.QueryValuesCalls()[1].Conditions...)["aggr"]
+	is.True(!ok)
+
+	is.NoErr(app.LoadConfig(ctx, strings.NewReader("strictParams: true\n")))
+	_, err = app.QueryValues(ctx, params, []string{"default"})
+	is.True(errors.Is(err, ErrInvalidParams))
+	is.Equal(len(r.QueryValuesCalls()), 2)
+}
+
+func TestPublisherUsesConfiguredTopic(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	is := is.New(t)
+
+	room := things.NewRoom("room-001", things.DefaultLocation, "default")
+
+	r := &ThingsReaderMock{
+		QueryThingsFunc: func(ctx context.Context, conditions ...ConditionFunc) (QueryResult, error) {
+			return QueryResult{Data: [][]byte{room.Byte()}}, nil
+		},
+	}
+
+	published := make(chan messaging.TopicMessage, 10)
+	m := &messaging.MsgContextMock{
+		PublishOnTopicFunc: func(ctx context.Context, message messaging.TopicMessage) error {
+			published <- message
+			return nil
+		},
+	}
+
+	in := make(chan changedThing)
+	go publisher(ctx, r, m, in, things.ConvToThing, func() publisherConfig {
+		return publisherConfig{Window: 50 * time.Millisecond, Topic: "city.things", ContentType: "application/json"}
+	})
+
+	in <- changedThing{thingID: "room-001"}
+
+	select {
+	case msg := <-published:
+		is.Equal(msg.TopicName(), "city.things")
+		is.Equal(msg.ContentType(), "application/json")
+		is.True(strings.Contains(string(msg.Body()), `"id":"room-001"`))
+	case <-ctx.Done():
+		t.Fatal("timed out waiting for message")
+	}
+}
+
+func TestAddValueWithAggregateForConfiguredTypes(t *testing.T) {
+	ctx := context.Background()
+	is := is.New(t)
+
+	r := &ThingsReaderMock{}
+	w := &ThingsWriterMock{
+		AddValueFunc: func(ctx context.Context, t things.Thing, m things.Value) error {
+			return nil
+		},
+		AddValueWithAggregateFunc: func(ctx context.Context, t things.Thing, m things.Value) error {
+			return nil
+		},
+	}
+
+	app := New(ctx, r, w, msgCtxMock())
+	err := app.LoadConfig(ctx, strings.NewReader("types:\n  - type: Building\n    aggregate: true\n  - type: Room\n"))
+	is.NoErr(err)
+
+	ts := time.Now()
+
+	err = app.AddValue(ctx, things.NewBuilding("building-001", things.DefaultLocation, "default"), things.NewTemperature("building-001", "device", 1, ts).Value)
+	is.NoErr(err)
+	err = app.AddValue(ctx, things.NewRoom("room-001", things.DefaultLocation, "default"), things.NewTemperature("room-001", "device", 21, ts).Value)
+	is.NoErr(err)
+
+	is.Equal(len(w.AddValueWithAggregateCalls()), 1)
+	is.Equal(len(w.AddValueCalls()), 1)
+}
+
+func newConditions(conditions ...ConditionFunc) map[string]any {
+	m := make(map[string]any)
+
+	for _, f := range conditions {
+		m = f(m)
+	}
+
+	if _, ok := m["limit"]; !ok {
+		m["limit"] = 100
+	}
+
+	if _, ok := m["offset"]; !ok {
+		m["offset"] = 0
+	}
+
+	return m
+}
+
+// Measurements are related to things through the refDevices of the thing, there are no separate
+// device things. Seeding a thing with refDevices is what relates it to its devices.
+func TestSeedRelatesDevices(t *testing.T) {
+	ctx := context.Background()
+	is := is.New(t)
+
+	r := &ThingsReaderMock{
+		QueryThingsFunc: func(ctx context.Context, conditions ...ConditionFunc) (QueryResult, error) {
+			return QueryResult{Data: [][]byte{}}, nil
+		},
+	}
+	w := &ThingsWriterMock{
+		AddThingFunc: func(ctx context.Context, t things.Thing) error {
+			return nil
+		},
+	}
+
+	app := New(ctx, r, w, msgCtxMock())
+	_, err := app.Seed(ctx, strings.NewReader(csvData), false, nil)
+	is.NoErr(err)
+
+	is.Equal(len(w.AddThingCalls()), 2)
+
+	container := w.AddThingCalls()[1].T
+	is.Equal(container.ID(), "5")
+
+	devices := []string{}
+	for _, d := range container.Refs() {
+		devices = append(devices, d.DeviceID)
+	}
+	is.Equal(devices, []string{"d4f3e2f1-d430-467b-85ec-7cd977b0335f", "527090f3-7f85-49f8-889b-99a50530dede"})
+}
+
+func TestCommissionedAt(t *testing.T) {
+	ctx := context.Background()
+	is := is.New(t)
+
+	room := things.NewRoom("room-001", things.DefaultLocation, "default")
+
+	r := &ThingsReaderMock{
+		QueryThingsFunc: func(ctx context.Context, conditions ...ConditionFunc) (QueryResult, error) {
+			if newConditions(conditions...)["id"] == "room-001" {
+				return QueryResult{Data: [][]byte{room.Byte()}, Count: 1}, nil
+			}
+			return QueryResult{Data: [][]byte{}}, nil
+		},
+	}
+	w := &ThingsWriterMock{
+		AddThingFunc: func(ctx context.Context, t things.Thing) error {
+			return nil
+		},
+		UpdateThingFunc: func(ctx context.Context, t things.Thing) error {
+			return nil
+		},
+	}
+
+	commissionedAt := func(t things.Thing) *time.Time {
+		m := struct {
+			CommissionedAt *time.Time `json:"commissionedAt"`
+		}{}
+		json.Unmarshal(t.Byte(), &m)
+		return m.CommissionedAt
+	}
+
+	app := New(ctx, r, w, msgCtxMock())
+
+	csv := `id;type;subType;name;decsription;location;tenant;tags;refDevices;args
+room-002;Room;;Rum 2;;62.4008,17.4135;default;;;{'commissionedAt':'2023-05-01T00:00:00Z'}
+`
+	_, err := app.Seed(ctx, strings.NewReader(csv), false, nil)
+	is.NoErr(err)
+	is.Equal(*commissionedAt(w.AddThingCalls()[0].T), time.Date(2023, 5, 1, 0, 0, 0, 0, time.UTC))
+
+	err = app.MergeThing(ctx, "room-001", []byte(`{"commissionedAt":"2024-02-01T10:00:00Z"}`), []string{"default"})
+	is.NoErr(err)
+	is.Equal(*commissionedAt(w.UpdateThingCalls()[0].T), time.Date(2024, 2, 1, 10, 0, 0, 0, time.UTC))
+
+	conditions := newConditions(WithParams(map[string][]string{"commissionedBefore": {"2024-01-01T00:00:00Z"}})...)
+	is.Equal(conditions["commissionedbefore"], time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+}
+
+func TestSeedJSON(t *testing.T) {
+	ctx := context.Background()
+	is := is.New(t)
+
+	existing := things.NewPumpingStation("ps-001", things.DefaultLocation, "default")
+	on := true
+	is.NoErr(existing.Handle([]things.Measurement{{ID: "device/3200/5500", Urn: things.DigitalInputURN, BoolValue: &on, Timestamp: time.Now()}}, func(m things.ValueProvider) error {
+		return nil
+	}))
+
+	r := &ThingsReaderMock{
+		QueryThingsFunc: func(ctx context.Context, conditions ...ConditionFunc) (QueryResult, error) {
+			if newConditions(conditions...)["id"] == existing.ID() {
+				return QueryResult{Data: [][]byte{existing.Byte()}}, nil
+			}
+			return QueryResult{}, nil
+		},
+	}
+	w := &ThingsWriterMock{
+		AddThingFunc: func(ctx context.Context, t things.Thing) error {
+			return nil
+		},
+		UpdateThingFunc: func(ctx context.Context, t things.Thing) error {
+			return nil
+		},
+	}
+
+	app := New(ctx, r, w, msgCtxMock())
+
+	seed := `
+	[
+		{"id":"room-001","type":"Room","name":"Rum 1","tenant":"default","location":{"latitude":62.4,"longitude":17.4}},
+		{"id":"room-002","type":"Room","name":"Rum 2","tenant":"other","tags":["school"]},
+		{"id":"ps-001","type":"PumpingStation","name":"Pumpstation","tenant":"default","_stopwatch":null}
+	]`
+
+	_, err := app.Seed(ctx, strings.NewReader(seed), false, nil)
+	is.NoErr(err)
+
+	is.Equal(len(w.AddThingCalls()), 2)
+	is.Equal(w.AddThingCalls()[0].T.Tenant(), "default")
+	is.Equal(w.AddThingCalls()[1].T.Tenant(), "other")
+
+	is.Equal(len(w.UpdateThingCalls()), 1)
+	updated := w.UpdateThingCalls()[0].T.(*things.PumpingStation)
+	is.Equal(updated.Name, "Pumpstation")
+	is.True(updated.Sw != nil && updated.Sw.State) // internal state is kept
+
+	// a single thing object is seeded as well
+	_, err = app.Seed(ctx, strings.NewReader(`{"id":"room-003","type":"Room","tenant":"default"}`), false, nil)
+	is.NoErr(err)
+	is.Equal(w.AddThingCalls()[2].T.ID(), "room-003")
+}
+
+func TestSeedIsLimitedToAllowedTenants(t *testing.T) {
+	ctx := context.Background()
+	is := is.New(t)
+
+	r := &ThingsReaderMock{
+		QueryThingsFunc: func(ctx context.Context, conditions ...ConditionFunc) (QueryResult, error) {
+			if newConditions(conditions...)["id"] == "room-002" {
+				return QueryResult{Data: [][]byte{things.NewRoom("room-002", things.DefaultLocation, "other").Byte()}}, nil
+			}
+			return QueryResult{}, nil
+		},
+	}
+	w := &ThingsWriterMock{
+		AddThingFunc: func(ctx context.Context, t things.Thing) error {
+			return nil
+		},
+	}
+
+	app := New(ctx, r, w, msgCtxMock())
+
+	report, err := app.Seed(ctx, strings.NewReader(`[{"id":"room-001","type":"Room","tenant":"other"},{"id":"room-002","type":"Room","tenant":"default"}]`), false, []string{"default"})
+	is.NoErr(err)
+
+	// an existing thing can not be moved into an allowed tenant either
+	is.Equal(len(report.Errors), 2)
+	is.True(strings.Contains(report.Errors[0].Error, ErrForbiddenTenant.Error()))
+	is.True(strings.Contains(report.Errors[1].Error, ErrForbiddenTenant.Error()))
+
+	err = app.SeedInventory(ctx, strings.NewReader("things:\n  - id: room-003\n    type: Room\n    tenant: other\n"), []string{"default"})
+	is.True(errors.Is(err, ErrForbiddenTenant))
+	is.Equal(len(w.AddThingCalls()), 0)
+
+	report, err = app.Seed(ctx, strings.NewReader(`{"id":"room-001","type":"Room","tenant":"default"}`), false, []string{"default"})
+	is.NoErr(err)
+	is.Equal(len(report.Errors), 0)
+	is.Equal(len(w.AddThingCalls()), 1)
+}
+
+func TestSeedArgsCanNotReplaceIdentity(t *testing.T) {
+	ctx := context.Background()
+	is := is.New(t)
+
+	r := &ThingsReaderMock{
+		QueryThingsFunc: func(ctx context.Context, conditions ...ConditionFunc) (QueryResult, error) {
+			return QueryResult{}, nil
+		},
+	}
+	w := &ThingsWriterMock{
+		AddThingFunc: func(ctx context.Context, t things.Thing) error {
+			return nil
+		},
+	}
+
+	app := New(ctx, r, w, msgCtxMock())
+
+	csv := `id;type;subType;name;decsription;location;tenant;tags;refDevices;args
+room-001;Room;;Rum 1;;62.4008,17.4135;default;;;{'id':'room-002','type':'Sewer','tenant':'other','alternativeName':'Rum ett'}
+`
+	report, err := app.Seed(ctx, strings.NewReader(csv), false, []string{"default"})
+	is.NoErr(err)
+	is.Equal(len(report.Errors), 0)
+	is.Equal(report.Created, []string{"room-001"})
+
+	is.Equal(len(w.AddThingCalls()), 1)
+	room := w.AddThingCalls()[0].T
+	is.Equal(room.ID(), "room-001")
+	is.Equal(room.Type(), "Room")
+	is.Equal(room.Tenant(), "default")
+	is.True(strings.Contains(string(room.Byte()), `"alternativeName":"Rum ett"`))
+}
+
+func TestSeedParentIsLimitedToAllowedTenants(t *testing.T) {
+	ctx := context.Background()
+	is := is.New(t)
+
+	r := &ThingsReaderMock{
+		QueryThingsFunc: func(ctx context.Context, conditions ...ConditionFunc) (QueryResult, error) {
+			cond := newConditions(conditions...)
+			tenants, limited := cond["tenants"].([]string)
+			if cond["id"] == "room-001" && (!limited || slices.Contains(tenants, "other")) {
+				return QueryResult{Data: [][]byte{things.NewRoom("room-001", things.Location{Latitude: 62.39, Longitude: 17.31}, "other").Byte()}}, nil
+			}
+			return QueryResult{}, nil
+		},
+	}
+	w := &ThingsWriterMock{
+		AddThingFunc: func(ctx context.Context, t things.Thing) error {
+			return nil
+		},
+	}
+
+	yamlConfig := `
+types:
+  - type: "Desk"
+    inherit: ["location", "tenant"]
+`
+
+	app := New(ctx, r, w, msgCtxMock())
+	err := app.LoadConfig(ctx, strings.NewReader(yamlConfig))
+	is.NoErr(err)
+
+	csv := `id;type;subType;name;decsription;location;tenant;tags;refDevices;args;parent
+desk-001;Desk;;Desk 1;;;default;;;;room-001
+desk-002;Desk;;Desk 2;;;default;;;;room-404
+`
+	report, err := app.Seed(ctx, strings.NewReader(csv), false, []string{"default"})
+	is.NoErr(err)
+
+	// the parent of another tenant is not found, rather than lending its location to the desk
+	is.Equal(len(report.Errors), 2)
+	is.True(strings.Contains(report.Errors[0].Error, "parent room-001 not found"))
+	is.True(strings.Contains(report.Errors[1].Error, "parent room-404 not found"))
+	is.Equal(len(w.AddThingCalls()), 0)
+
+	err = app.SeedInventory(ctx, strings.NewReader("things:\n  - id: desk-001\n    type: Desk\n    tenant: default\n    parent: room-001\n"), []string{"default"})
+	is.True(errors.Is(err, ErrInvalidRelation))
+	is.Equal(len(w.AddThingCalls()), 0)
+
+	// an unrestricted seed finds the parent of any tenant
+	report, err = app.Seed(ctx, strings.NewReader(csv), false, nil)
+	is.NoErr(err)
+	is.Equal(len(report.Errors), 1)
+	is.Equal(len(w.AddThingCalls()), 1)
+	lat, _ := w.AddThingCalls()[0].T.LatLon()
+	is.Equal(lat, 62.39)
+}
+
+func TestSeedDryRun(t *testing.T) {
+	ctx := context.Background()
+	is := is.New(t)
+
+	r := &ThingsReaderMock{
+		QueryThingsFunc: func(ctx context.Context, conditions ...ConditionFunc) (QueryResult, error) {
+			if newConditions(conditions...)["id"] == "room-001" {
+				return QueryResult{Data: [][]byte{things.NewRoom("room-001", things.DefaultLocation, "default").Byte()}}, nil
+			}
+			return QueryResult{}, nil
+		},
+	}
+	w := &ThingsWriterMock{}
+
+	app := New(ctx, r, w, msgCtxMock())
+
+	csv := `id;type;subType;name;decsription;location;tenant;tags;refDevices;args
+room-001;Room;;Rum 1;;62.4008,17.4135;default;;;
+room-002;Room;;Rum 2;;62.4008,17.4135;default;;;
+room-003;Room;;Rum 3;;62.4008,17.4135;;;;
+room-004
+`
+	report, err := app.Seed(ctx, strings.NewReader(csv), true, nil)
+	is.NoErr(err)
+
+	is.True(report.DryRun)
+	is.Equal(report.Created, []string{"room-002"})
+	is.Equal(report.Updated, []string{"room-001"})
+	is.Equal(len(report.Errors), 2)
+	is.Equal(report.Errors[0].Row, 4)
+	is.Equal(report.Errors[0].ID, "room-003")
+	is.Equal(report.Errors[1].Row, 5)
+
+	is.Equal(len(w.AddThingCalls()), 0)
+	is.Equal(len(w.UpdateThingCalls()), 0)
+}
+
+func TestSeedSkipsInvalidThings(t *testing.T) {
+	ctx := context.Background()
+	is := is.New(t)
+
+	r := &ThingsReaderMock{
+		QueryThingsFunc: func(ctx context.Context, conditions ...ConditionFunc) (QueryResult, error) {
+			if newConditions(conditions...)["id"] == "room-001" {
+				return QueryResult{Data: [][]byte{things.NewRoom("room-001", things.DefaultLocation, "default").Byte()}}, nil
+			}
+			return QueryResult{}, nil
+		},
+	}
+	w := &ThingsWriterMock{
+		AddThingFunc: func(ctx context.Context, t things.Thing) error {
+			return nil
+		},
+		UpdateThingFunc: func(ctx context.Context, t things.Thing) error {
+			return nil
+		},
+	}
+
+	app := New(ctx, r, w, msgCtxMock())
+
+	csv := `id;type;subType;name;decsription;location;tenant;tags;refDevices;args
+room-003;Room;;Rum 3;;62.4008,17.4135;;;;
+room-001;Room;;Rum 1;;62.4008,17.4135;default;;;
+room-004
+room-002;Room;;Rum 2;;62.4008,17.4135;default;;;
+`
+	report, err := app.Seed(ctx, strings.NewReader(csv), false, []string{"default"})
+	is.NoErr(err)
+
+	is.Equal(report.Created, []string{"room-002"})
+	is.Equal(report.Updated, []string{"room-001"})
+	is.Equal(len(report.Errors), 2)
+	is.Equal(report.Errors[0].ID, "room-003")
+	is.Equal(report.Errors[1].Row, 4)
+
+	is.Equal(len(w.AddThingCalls()), 1)
+	is.Equal(len(w.UpdateThingCalls()), 1)
+}
+
+func TestGetLatestValues(t *testing.T) {
+	ctx := context.Background()
+	is := is.New(t)
+
+	r := &ThingsReaderMock{
+		QueryThingsFunc: func(ctx context.Context, conditions ...ConditionFunc) (QueryResult, error) {
+			if slices.Contains(newConditions(conditions...)["tenants"].([]string), "default") {
+				return QueryResult{Data: [][]byte{things.NewRoom("room-001", things.DefaultLocation, "default").Byte()}}, nil
+			}
+			return QueryResult{}, nil
+		},
+		QueryValuesFunc: func(ctx context.Context, conditions ...ConditionFunc) (QueryResult, error) {
+			return QueryResult{}, nil
+		},
+	}
+
+	app := New(ctx, r, &ThingsWriterMock{}, msgCtxMock())
+
+	_, err := app.GetLatestValues(ctx, "room-001", []string{"default"})
+	is.NoErr(err)
+
+	cond := newConditions(r.QueryValuesCalls()[0].Conditions...)
+	is.Equal(cond["thingid"], "room-001")
+	is.Equal(cond["showlatest"], true)
+
+	_, err = app.GetLatestValues(ctx, "room-001", []string{"other"})
+	is.True(errors.Is(err, ErrThingNotFound))
+}
+
+func TestAddValues(t *testing.T) {
+	ctx := context.Background()
+	is := is.New(t)
+
+	r := &ThingsReaderMock{
+		QueryThingsFunc: func(ctx context.Context, conditions ...ConditionFunc) (QueryResult, error) {
+			if slices.Contains(newConditions(conditions...)["tenants"].([]string), "default") {
+				return QueryResult{Data: [][]byte{things.NewRoom("room-001", things.DefaultLocation, "default").Byte()}}, nil
+			}
+			return QueryResult{}, nil
+		},
+	}
+	w := &ThingsWriterMock{
+		AddValuesFunc: func(ctx context.Context, t things.Thing, values []things.Value, aggregate bool) (int64, error) {
+			return int64(len(values)), nil
+		},
+	}
+
+	app := New(ctx, r, w, msgCtxMock())
+
+	v := 21.0
+	ts := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	values := []things.Value{
+		{Measurement: things.Measurement{Urn: things.TemperatureURN, Value: &v, Unit: "celsius", Timestamp: ts}},
+		{Measurement: things.Measurement{ID: "3303/5700", Urn: things.TemperatureURN, Value: &v, Timestamp: ts.Add(time.Hour)}},
+		{Measurement: things.Measurement{ID: "room-001/3304/5700", Urn: things.HumidityURN, Value: &v, Timestamp: ts}},
+	}
+
+	n, err := app.AddValues(ctx, "room-001", values, []string{"default"})
+	is.NoErr(err)
+	is.Equal(n, int64(3))
+
+	added := w.AddValuesCalls()[0].Values
+	is.Equal(added[0].ID, "room-001/3303/5700")
+	is.Equal(added[0].Unit, "Cel")
+	is.Equal(added[1].ID, "room-001/3303/5700")
+	is.Equal(added[2].ID, "room-001/3304/5700")
+
+	// the urn of a stopwatch has more than one resource, so the id must be given
+	values = append(values, things.Value{Measurement: things.Measurement{Urn: things.StopwatchURN, Value: &v, Timestamp: ts}})
+	_, err = app.AddValues(ctx, "room-001", values, []string{"default"})
+	is.True(errors.Is(err, ErrInvalidValue))
+	is.Equal(len(w.AddValuesCalls()), 1) // nothing is added if any value is invalid
+
+	_, err = app.AddValues(ctx, "room-001", values[:1], []string{"other"})
+	is.True(errors.Is(err, ErrThingNotFound))
+}
+
+func TestRecentValuesParams(t *testing.T) {
+	is := is.New(t)
+
+	before := time.Now().UTC()
+
+	conditions := newConditions(WithParams(map[string][]string{"hasRecentValues": {"true"}, "within": {"2h"}})...)
+	is.Equal(conditions["hasrecentvalues"], true)
+	since := conditions["recentsince"].(time.Time)
+	is.True(!since.Before(before.Add(-2 * time.Hour)))
+	is.True(since.Before(before.Add(-2*time.Hour + time.Minute)))
+
+	conditions = newConditions(WithParams(map[string][]string{"hasRecentValues": {"false"}})...)
+	is.Equal(conditions["hasrecentvalues"], false)
+	is.True(conditions["recentsince"].(time.Time).Before(before.Add(-23 * time.Hour)))
+}
+
+func TestTenantConfigOverrides(t *testing.T) {
+	ctx := context.Background()
+	is := is.New(t)
+
+	r := &ThingsReaderMock{}
+	w := &ThingsWriterMock{
+		AddThingFunc: func(ctx context.Context, t things.Thing) error {
+			return nil
+		},
+	}
+
+	yamlConfig := `
+types:
+  - type: Room
+  - type: Container
+    subTypes:
+      - WasteContainer
+tenants:
+  water:
+    types:
+      - type: Sewer
+        requiredArgs:
+          - maxDistance
+      - type: Room
+`
+	app := New(ctx, r, w, msgCtxMock())
+	err := app.LoadConfig(ctx, strings.NewReader(yamlConfig))
+	is.NoErr(err)
+
+	names := func(tenants ...string) []string {
+		types, err := app.GetTypes(ctx, tenants)
+		is.NoErr(err)
+		n := []string{}
+		for _, t := range types {
+			n = append(n, t.Name)
+		}
+		return n
+	}
+
+	is.Equal(names("default"), []string{"Room", "Container", "Container-WasteContainer"})
+	is.Equal(names("water"), []string{"Sewer", "Room"})
+	is.Equal(names("water", "default"), []string{"Sewer", "Room", "Container", "Container-WasteContainer"})
+
+	// required args are validated against the types of the tenant of the thing
+	err = app.AddThing(ctx, []byte(`{"id":"sewer-001","type":"Sewer","tenant":"water"}`))
+	is.True(errors.Is(err, ErrMissingArgs))
+	err = app.AddThing(ctx, []byte(`{"id":"sewer-002","type":"Sewer","tenant":"default"}`))
+	is.NoErr(err)
+}
+
+const csvData string = `id;type;subType;name;decsription;location;tenant;tags;refDevices;args
+forradet-bpn;Sewer;CombinedSewageOverflow;Förrådet BPN;Förrådet BPN;62.4008,17.4135;msva;braddmatare;d4f3e2f1-d430-467b-85ec-7cd977b0335f;
+5;Container;WasteContainer;namn;beskrivning;62.39095613,17.31727909;default;soptunna,linje 1;d4f3e2f1-d430-467b-85ec-7cd977b0335f,527090f3-7f85-49f8-889b-99a50530dede;{'max_distance':0.94,'max_level':0.79}
+`
+
+func TestQueryValuesTimeRange(t *testing.T) {
+	ctx := context.Background()
+	is := is.New(t)
+
+	r := &ThingsReaderMock{
+		QueryValuesFunc: func(ctx context.Context, conditions ...ConditionFunc) (QueryResult, error) {
+			return QueryResult{}, nil
+		},
+	}
+	w := &ThingsWriterMock{}
+
+	yamlConfig := `
+values:
+  defaultLookback: 12h
+  maxLookback: 720h
+`
+
+	app := New(ctx, r, w, msgCtxMock())
+	err := app.LoadConfig(ctx, strings.NewReader(yamlConfig))
+	is.NoErr(err)
+
+	_, err = app.ListValues(ctx, map[string][]string{}, []string{"default"})
+	is.NoErr(err)
+
+	cond := newConditions(r.QueryValuesCalls()[0].Conditions...)
+	is.Equal(cond["timerel"], "after")
+	timeAt := cond["timeat"].(time.Time)
+	is.True(time.Since(timeAt) >= 12*time.Hour)
+	is.True(time.Since(timeAt) < 13*time.Hour)
+
+	// the default lookback only applies when listing values, e.g. not to exports
+	_, err = app.QueryValues(ctx, map[string][]string{}, []string{"default"})
+	is.NoErr(err)
+
+	cond = newConditions(r.QueryValuesCalls()[1].Conditions...)
+	_, ok := cond["timerel"]
+	is.True(!ok)
+
+	_, err = app.QueryValues(ctx, map[string][]string{"timerel": {"before"}, "timeat": {"2024-01-01T00:00:00Z"}}, []string{"default"})
+	is.NoErr(err)
+
+	// a timeat older than the max lookback still gives a window ending at timeat
+	cond = newConditions(r.QueryValuesCalls()[2].Conditions...)
+	is.Equal(cond["timerel"], "between")
+	is.Equal(cond["timeat"].(time.Time), time.Date(2023, 12, 2, 0, 0, 0, 0, time.UTC))
+	is.Equal(cond["endtimeat"].(time.Time), time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+
+	between := map[string][]string{
+		"timerel":   {"between"},
+		"timeat":    {"2024-01-01T00:00:00Z"},
+		"endtimeat": {"2024-01-20T00:00:00Z"},
+	}
+	_, err = app.QueryValues(ctx, between, []string{"default"})
+	is.NoErr(err)
+
+	between["endtimeat"] = []string{"2024-03-01T00:00:00Z"}
+	_, err = app.QueryValues(ctx, between, []string{"default"})
+	is.True(errors.Is(err, ErrTimeRangeExceeded))
+
+	_, err = app.QueryValues(ctx, map[string][]string{"timerel": {"after"}, "timeat": {"2020-01-01T00:00:00Z"}}, []string{"default"})
+	is.True(errors.Is(err, ErrTimeRangeExceeded))
+
+	// malformed time filters are rejected even though other malformed params are ignored
+
+	for _, params := range []map[string][]string{
+		{"timerel": {"sometime"}, "timeat": {"2024-01-01T00:00:00Z"}},
+		{"timerel": {"after"}, "timeat": {"yesterday"}},
+		{"timerel": {"between"}, "timeat": {"2024-01-01T00:00:00Z"}},
+	} {
+		_, err = app.QueryValues(ctx, params, []string{"default"})
+		is.True(errors.Is(err, ErrInvalidParams))
+	}
+
+	is.Equal(len(r.QueryValuesCalls()), 4)
+}
+
+func TestLifebuoyInspection(t *testing.T) {
+	ctx := context.Background()
+	is := is.New(t)
+
+	lifebuoy := things.NewLifebuoy("lifebuoy-001", things.DefaultLocation, "default")
+
+	r := &ThingsReaderMock{
+		QueryThingsFunc: func(ctx context.Context, conditions ...ConditionFunc) (QueryResult, error) {
+			return QueryResult{Data: [][]byte{lifebuoy.Byte()}, Count: 1}, nil
+		},
+	}
+	w := &ThingsWriterMock{
+		UpdateThingFunc: func(ctx context.Context, t things.Thing) error {
+			return nil
+		},
+	}
+
+	app := New(ctx, r, w, msgCtxMock())
+
+	err := app.MergeThing(ctx, "lifebuoy-001", []byte(`{"lastInspectedAt":"2024-05-01T08:00:00Z","nextInspectionDue":"2024-06-01T00:00:00Z"}`), []string{"default"})
+	is.NoErr(err)
+
+	updated := w.UpdateThingCalls()[0].T.(*things.Lifebuoy)
+	is.Equal(*updated.LastInspectedAt, time.Date(2024, 5, 1, 8, 0, 0, 0, time.UTC))
+	is.True(updated.InspectionDue(time.Date(2024, 6, 2, 0, 0, 0, 0, time.UTC)))
+	is.True(!updated.InspectionDue(time.Date(2024, 5, 2, 0, 0, 0, 0, time.UTC)))
+
+	err = app.MergeThing(ctx, "lifebuoy-001", []byte(`{"nextInspectionDue":"next week"}`), []string{"default"})
+	is.True(err != nil)
+
+	before := time.Now().UTC()
+	conditions := newConditions(WithParams(map[string][]string{"inspectionDue": {"true"}})...)
+	is.Equal(conditions["inspectiondue"], true)
+	is.True(!conditions["inspectionat"].(time.Time).Before(before))
+
+	is.True(errors.Is(ValidateParams(map[string][]string{"inspectionDue": {"yes please"}}), ErrInvalidParams))
+}
+
+func TestMergeThingsPatchesAllContainers(t *testing.T) {
+	ctx := context.Background()
+	is := is.New(t)
+
+	containers := [][]byte{
+		things.NewContainer("container-001", things.DefaultLocation, "default").Byte(),
+		things.NewContainer("container-002", things.DefaultLocation, "default").Byte(),
+	}
+
+	r := &ThingsReaderMock{
+		QueryThingsFunc: func(ctx context.Context, conditions ...ConditionFunc) (QueryResult, error) {
+			c := newConditions(conditions...)
+			if slices.Equal(c["types"].([]string), []string{"Container"}) && c["offset"] == 0 {
+				return QueryResult{Data: containers, Count: len(containers)}, nil
+			}
+			return QueryResult{Data: [][]byte{}}, nil
+		},
+	}
+	w := &ThingsWriterMock{
+		UpdateThingsFunc: func(ctx context.Context, t []things.Thing) error {
+			return nil
+		},
+	}
+
+	app := New(ctx, r, w, msgCtxMock())
+
+	patch := []byte(`{"tags":["Recycling"],"maxd":1.2}`)
+
+	_, err := app.MergeThings(ctx, map[string][]string{"type": {"Container"}}, patch, []string{"default"})
+	is.True(errors.Is(err, ErrMissingConfirmation))
+	is.Equal(len(r.QueryThingsCalls()), 0)
+
+	_, err = app.MergeThings(ctx, map[string][]string{"type": {"Container"}, "confirm": {"false"}}, patch, []string{"default"})
+	is.True(errors.Is(err, ErrMissingConfirmation))
+	is.Equal(len(r.QueryThingsCalls()), 0)
+
+	n, err := app.MergeThings(ctx, map[string][]string{"type": {"Container"}, "confirm": {"True"}}, patch, []string{"default"})
+	is.NoErr(err)
+	is.Equal(n, 2)
+
+	is.Equal(len(w.UpdateThingsCalls()), 1)
+	patched := w.UpdateThingsCalls()[0].T
+	is.Equal(len(patched), 2)
+	for _, p := range patched {
+		is.Equal(p.Type(), "Container")
+		is.True(strings.Contains(string(p.Byte()), `"tags":["Recycling"]`))
+		is.Equal(*p.(*things.Container).MaxDistance, 1.2)
+	}
+
+	n, err = app.MergeThings(ctx, map[string][]string{"type": {"Sewer"}, "confirm": {"true"}}, patch, []string{"default"})
+	is.NoErr(err)
+	is.Equal(n, 0)
+	is.Equal(len(w.UpdateThingsCalls()), 1)
+}
+
+func TestSeedPublishesSummaryInsteadOfThings(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	is := is.New(t)
+
+	seeded := map[string][]byte{}
+
+	r := &ThingsReaderMock{
+		QueryThingsFunc: func(ctx context.Context, conditions ...ConditionFunc) (QueryResult, error) {
+			if b, ok := seeded[newConditions(conditions...)["id"].(string)]; ok {
+				return QueryResult{Data: [][]byte{b}, Count: 1}, nil
+			}
+			return QueryResult{Data: [][]byte{}}, nil
+		},
+	}
+	w := &ThingsWriterMock{
+		AddThingFunc: func(ctx context.Context, t things.Thing) error {
+			seeded[t.ID()] = t.Byte()
+			return nil
+		},
+		UpdateThingFunc: func(ctx context.Context, t things.Thing) error {
+			return nil
+		},
+	}
+
+	published := make(chan messaging.TopicMessage, 10)
+	m := &messaging.MsgContextMock{
+		PublishOnTopicFunc: func(ctx context.Context, message messaging.TopicMessage) error {
+			published <- message
+			return nil
+		},
+	}
+
+	app := New(ctx, r, w, m)
+
+	csv := `id;type;subType;name;decsription;location;tenant;tags;refDevices;args
+room-001;Room;;Rum 1;;62.4008,17.4135;default;;;
+room-002;Room;;Rum 2;;62.4008,17.4135;default;;;
+room-003;Room;;Rum 3;;62.4008,17.4135;other;;;
+`
+
+	seed := func(mode string) []messaging.TopicMessage {
+		is.NoErr(app.LoadConfig(ctx, strings.NewReader("publisher:\n  window: 20ms\n  seed: "+mode+"\n")))
+		_, err := app.Seed(ctx, strings.NewReader(csv), false, nil)
+		is.NoErr(err)
+
+		msgs := []messaging.TopicMessage{}
+		for {
+			select {
+			case msg := <-published:
+				msgs = append(msgs, msg)
+			case <-time.After(200 * time.Millisecond):
+				return msgs
+			}
+		}
+	}
+
+	msgs := seed(SeedPublishSummary)
+	is.Equal(len(msgs), 1) // no thing.updated per seeded thing
+
+	summary, ok := msgs[0].(*types.SeedCompleted)
+	is.True(ok)
+	is.Equal(summary.TopicName(), "seed.completed")
+	is.Equal(summary.Created, 3)
+	is.Equal(summary.Updated, 0)
+	is.Equal(summary.Tenants, []string{"default", "other"})
+
+	// seeding again updates the things, and each of them is published
+	msgs = seed(SeedPublishThings)
+	is.Equal(len(msgs), 3)
+	for _, msg := range msgs {
+		is.Equal(msg.TopicName(), "thing.updated")
+	}
+
+	is.Equal(len(seed(SeedPublishNone)), 0)
+}
+
+func TestDeleteThingPublishesThingDeleted(t *testing.T) {
+	ctx := context.Background()
+	is := is.New(t)
+
+	room := things.NewRoom("room-001", things.DefaultLocation, "default")
+
+	r := &ThingsReaderMock{
+		QueryThingsFunc: func(ctx context.Context, conditions ...ConditionFunc) (QueryResult, error) {
+			return QueryResult{Data: [][]byte{room.Byte()}, Count: 1}, nil
+		},
+	}
+	w := &ThingsWriterMock{
+		DeleteThingFunc: func(ctx context.Context, thingID string) error {
+			return nil
+		},
+	}
+
+	var published []messaging.TopicMessage
+	msgCtx := &messaging.MsgContextMock{
+		PublishOnTopicFunc: func(ctx context.Context, message messaging.TopicMessage) error {
+			published = append(published, message)
+			return nil
+		},
+	}
+
+	a := New(ctx, r, w, msgCtx)
+
+	// the configured topic is used for thing.updated only, so that deleted things can be told apart
+	is.NoErr(a.LoadConfig(ctx, strings.NewReader("publisher:\n  topic: things\n  contentType: application/json\n")))
+
+	err := a.DeleteThing(ctx, "room-001", []string{"default"})
+	is.NoErr(err)
+
+	is.Equal(len(published), 1)
+	is.Equal(published[0].TopicName(), "thing.deleted")
+	is.Equal(published[0].ContentType(), "application/vnd.diwise.thingdeleted+json")
+
+	deleted := types.ThingDeleted{}
+	is.NoErr(json.Unmarshal(published[0].Body(), &deleted))
+	is.Equal(deleted.ID, "room-001")
+	is.Equal(deleted.Type, "Room")
+	is.Equal(deleted.Tenant, "default")
+}
+
+func TestDeleteThingDoesNotPublishWhenDeleteFails(t *testing.T) {
+	ctx := context.Background()
+	is := is.New(t)
+
+	room := things.NewRoom("room-001", things.DefaultLocation, "default")
+
+	r := &ThingsReaderMock{
+		QueryThingsFunc: func(ctx context.Context, conditions ...ConditionFunc) (QueryResult, error) {
+			return QueryResult{Data: [][]byte{room.Byte()}, Count: 1}, nil
+		},
+	}
+	w := &ThingsWriterMock{
+		DeleteThingFunc: func(ctx context.Context, thingID string) error {
+			return errors.New("delete failed")
+		},
+	}
+	msgCtx := msgCtxMock()
+
+	a := New(ctx, r, w, msgCtx)
+
+	err := a.DeleteThing(ctx, "room-001", []string{"default"})
+	is.True(err != nil)
+	is.Equal(len(msgCtx.PublishOnTopicCalls()), 0)
+}
+
+func TestRestoreThing(t *testing.T) {
+	ctx := context.Background()
+	is := is.New(t)
+
+	room := things.NewRoom("room-001", things.DefaultLocation, "default")
+	deleted := atomic.Bool{}
+	deleted.Store(true)
+
+	r := &ThingsReaderMock{
+		QueryThingsFunc: func(ctx context.Context, conditions ...ConditionFunc) (QueryResult, error) {
+			c := newConditions(conditions...)
+			if _, ok := c["includedeleted"]; (deleted.Load() && !ok) || c["id"] != room.ID() {
+				return QueryResult{}, nil
+			}
+			return QueryResult{Data: [][]byte{room.Byte()}, Count: 1}, nil
+		},
+	}
+	w := &ThingsWriterMock{
+		UndeleteThingFunc: func(ctx context.Context, thingID string) error {
+			deleted.Store(false)
+			return nil
+		},
+	}
+
+	published := make(chan messaging.TopicMessage, 10)
+	msgCtx := &messaging.MsgContextMock{
+		PublishOnTopicFunc: func(ctx context.Context, message messaging.TopicMessage) error {
+			published <- message
+			return nil
+		},
+	}
+
+	a := New(ctx, r, w, msgCtx)
+	is.NoErr(a.LoadConfig(ctx, strings.NewReader("publisher:\n  window: 20ms\n")))
+
+	is.NoErr(a.RestoreThing(ctx, "room-001", []string{"default"}))
+	is.Equal(w.UndeleteThingCalls()[0].ThingID, "room-001")
+
+	select {
+	case msg := <-published:
+		is.Equal(msg.TopicName(), "thing.updated")
+	case <-time.After(1 * time.Second):
+		t.Fatal("restored thing was not published")
+	}
+
+	err := a.RestoreThing(ctx, "room-002", []string{"default"})
+	is.True(errors.Is(err, ErrThingNotFound))
+	is.Equal(len(w.UndeleteThingCalls()), 1)
+}
+
+func TestDeletedThingsAreOnlyListed(t *testing.T) {
+	ctx := context.Background()
+	is := is.New(t)
+
+	r := &ThingsReaderMock{
+		QueryThingsFunc: func(ctx context.Context, conditions ...ConditionFunc) (QueryResult, error) {
+			return QueryResult{}, nil
+		},
+	}
+	w := &ThingsWriterMock{}
+
+	a := New(ctx, r, w, msgCtxMock())
+	params := map[string][]string{"deleted": {"true"}, "confirm": {"true"}}
+
+	_, err := a.QueryThings(ctx, params, []string{"default"})
+	is.NoErr(err)
+	is.Equal(newConditions(r.QueryThingsCalls()[0].Conditions...)["includedeleted"], true)
+
+	_, err = a.MergeThings(ctx, params, []byte(`{"name":"patched"}`), []string{"default"})
+	is.NoErr(err)
+	_, ok := newConditions(r.QueryThingsCalls()[1].Conditions...)["includedeleted"]
+	is.True(!ok)
+}
+
+func TestAddValueNormalizesUnit(t *testing.T) {
+	ctx := context.Background()
+	is := is.New(t)
+
+	var stored []things.Value
+	w := &ThingsWriterMock{
+		AddValueFunc: func(ctx context.Context, t things.Thing, m things.Value) error {
+			stored = append(stored, m)
+			return nil
+		},
+	}
+
+	a := New(ctx, &ThingsReaderMock{}, w, msgCtxMock())
+	room := things.NewRoom("room-001", things.DefaultLocation, "default")
+
+	v := 21.0
+	value := things.Value{
+		Measurement: things.Measurement{
+			ID:        "room-001/3303/5700",
+			Urn:       things.TemperatureURN,
+			Value:     &v,
+			Unit:      "Celsius",
+			Timestamp: time.Now(),
+		},
+	}
+
+	is.NoErr(a.AddValue(ctx, room, value))
+	is.Equal(stored[0].Unit, "Cel")
+
+	value.Unit = "m"
+	err := a.AddValue(ctx, room, value)
+	is.True(errors.Is(err, things.ErrUnexpectedUnit))
+	is.Equal(len(stored), 1)
+}
+
+func TestMergeThingIsMergePatch(t *testing.T) {
+	ctx := context.Background()
+	is := is.New(t)
+
+	r := &ThingsReaderMock{
+		QueryThingsFunc: func(ctx context.Context, conditions ...ConditionFunc) (QueryResult, error) {
+			room := things.NewRoom("room-001", things.Location{Latitude: 62.0, Longitude: 17.0}, "default")
+			room.AddDevice("device-001")
+			room.AddTag("north")
+			return QueryResult{Data: [][]byte{room.Byte()}, Count: 1}, nil
+		},
+	}
+	w := &ThingsWriterMock{
+		UpdateThingFunc: func(ctx context.Context, t things.Thing) error {
+			return nil
+		},
+	}
+
+	app := New(ctx, r, w, msgCtxMock())
+
+	patched := func(patch string) map[string]any {
+		is.NoErr(app.MergeThing(ctx, "room-001", []byte(patch), []string{"default"}))
+		calls := w.UpdateThingCalls()
+		m := map[string]any{}
+		is.NoErr(json.Unmarshal(calls[len(calls)-1].T.Byte(), &m))
+		return m
+	}
+
+	m := patched(`{"location":{"latitude":62.5}}`)
+	is.Equal(m["location"], map[string]any{"latitude": 62.5, "longitude": 17.0})
+
+	m = patched(`{"tags":null}`)
+	_, ok := m["tags"]
+	is.True(!ok)
+
+	m = patched(`{"refDevices":[{"deviceID":"device-002"}]}`)
+	is.Equal(len(m["refDevices"].([]any)), 1)
+	is.Equal(m["refDevices"].([]any)[0].(map[string]any)["deviceID"], "device-002")
+
+	m = patched(`{"id":"room-002","type":"Desk","tenant":"other"}`)
+	is.Equal(m["id"], "room-001")
+	is.Equal(m["type"], "Room")
+	is.Equal(m["tenant"], "default")
+}
+
+func TestWaterMeterAlertIsPublished(t *testing.T) {
+	ctx := context.Background()
+	is := is.New(t)
+
+	wm := things.NewWatermeter("watermeter-001", things.DefaultLocation, "default")
+	wm.AddDevice("device-001")
+
+	r := &ThingsReaderMock{
+		QueryThingsFunc: func(ctx context.Context, conditions ...ConditionFunc) (QueryResult, error) {
+			return QueryResult{Data: [][]byte{wm.Byte()}, Count: 1}, nil
+		},
+	}
+	var saveErr error
+	w := &ThingsWriterMock{
+		AddValueFunc: func(ctx context.Context, t things.Thing, m things.Value) error {
+			return nil
+		},
+		UpdateThingFunc: func(ctx context.Context, t things.Thing) error {
+			return saveErr
+		},
+	}
+
+	var alerts []*types.WaterMeterAlert
+	msgCtx := &messaging.MsgContextMock{
+		PublishOnTopicFunc: func(ctx context.Context, message messaging.TopicMessage) error {
+			if alert, ok := message.(*types.WaterMeterAlert); ok {
+				alerts = append(alerts, alert)
+			}
+			return nil
+		},
+	}
+
+	a := New(ctx, r, w, msgCtx)
+	is.NoErr(a.LoadConfig(ctx, strings.NewReader("publisher:\n  topic: things\n")))
+
+	leak := true
+	ts := time.Date(2024, 11, 1, 6, 0, 0, 0, time.UTC)
+	measurements := []things.Measurement{{
+		ID:        "device-001/3424/10",
+		Urn:       things.WaterMeterURN,
+		BoolValue: &leak,
+		Timestamp: ts,
+	}}
+
+	// nothing is published if the thing could not be saved, as the measurement will be redelivered
+	saveErr = errors.New("storage unavailable")
+	a.HandleMeasurements(ctx, measurements)
+	is.Equal(len(alerts), 0)
+
+	saveErr = nil
+	a.HandleMeasurements(ctx, measurements)
+
+	is.Equal(len(alerts), 1)
+	is.Equal(alerts[0].TopicName(), "watermeter.alert")
+	is.Equal(alerts[0].ID, "watermeter-001")
+	is.Equal(alerts[0].Kind, "leak")
+	is.Equal(alerts[0].Tenant, "default")
+	is.Equal(alerts[0].Timestamp, ts)
+}
+
+func TestUpdateThingWithModifiedOn(t *testing.T) {
+	ctx := context.Background()
+	is := is.New(t)
+
+	modifiedOn := time.Date(2024, 11, 1, 12, 0, 0, 0, time.UTC)
+	room := things.NewRoom("room-001", things.DefaultLocation, "default")
+
+	r := &ThingsReaderMock{
+		QueryThingsFunc: func(ctx context.Context, conditions ...ConditionFunc) (QueryResult, error) {
+			return QueryResult{Data: [][]byte{room.Byte()}, Count: 1, LastModified: modifiedOn}, nil
+		},
+	}
+	w := &ThingsWriterMock{
+		UpdateThingFunc: func(ctx context.Context, t things.Thing) error {
+			return nil
+		},
+		UpdateThingIfUnchangedFunc: func(ctx context.Context, t things.Thing, modifiedOn time.Time) error {
+			return nil
+		},
+	}
+
+	a := New(ctx, r, w, msgCtxMock())
+
+	is.NoErr(a.UpdateThing(ctx, room.Byte(), []string{"default"}))
+	is.Equal(len(w.UpdateThingCalls()), 1)
+
+	is.NoErr(a.UpdateThing(WithModifiedOn(ctx, modifiedOn), room.Byte(), []string{"default"}))
+	is.Equal(len(w.UpdateThingIfUnchangedCalls()), 1)
+	is.Equal(w.UpdateThingIfUnchangedCalls()[0].ModifiedOn, modifiedOn)
+
+	err := a.MergeThing(WithModifiedOn(ctx, modifiedOn.Add(-1*time.Minute)), "room-001", []byte(`{"name":"Room 1"}`), []string{"default"})
+	is.True(errors.Is(err, ErrPreconditionFailed))
+	is.Equal(len(w.UpdateThingIfUnchangedCalls()), 1)
+}
+
+func TestValueSettingsAreConfiguredPerApp(t *testing.T) {
+	ctx := context.Background()
+	is := is.New(t)
+
+	a := New(ctx, &ThingsReaderMock{}, &ThingsWriterMock{}, msgCtxMock()).(*app)
+	err := a.LoadConfig(ctx, strings.NewReader(`
+changeThresholds:
+  "urn:oma:lwm2m:ext:3303": 0.1
+  "urn:oma:lwm2m:ext:3301": 10
+tenants:
+  strict:
+    changeThresholds:
+      "urn:oma:lwm2m:ext:3303": 1
+values:
+  integerUrns:
+    - urn:oma:lwm2m:ext:3334
+`))
+	is.NoErr(err)
+
+	is.Equal(a.changeThresholds("default"), map[string]float64{things.TemperatureURN: 0.1, things.IlluminanceURN: 10})
+	is.Equal(a.changeThresholds("strict"), map[string]float64{things.TemperatureURN: 1, things.IlluminanceURN: 10})
+	is.True(a.isIntegerURN(things.PeopleCounterURN))
+	is.True(!a.isIntegerURN(things.TemperatureURN))
+
+	// another app is not affected by the configuration
+	other := New(ctx, &ThingsReaderMock{}, &ThingsWriterMock{}, msgCtxMock()).(*app)
+	is.Equal(len(other.changeThresholds("strict")), 0)
+	is.True(!other.isIntegerURN(things.PeopleCounterURN))
+}
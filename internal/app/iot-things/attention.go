@@ -0,0 +1,189 @@
+package iotthings
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/diwise/iot-things/internal/app/iot-things/things"
+)
+
+// Attention is a thing that needs attention and the reasons why. Things are prioritized by their most
+// severe reason, alerts first, then out of range values and last things not reporting any values.
+type Attention struct {
+	ThingID  string   `json:"thingId"`
+	Type     string   `json:"type"`
+	Tenant   string   `json:"tenant"`
+	Priority int      `json:"priority"` // 1 is the highest priority
+	Reasons  []string `json:"reasons"`
+}
+
+const (
+	AttentionAlert      string = "alert"      // e.g. alert:leakage
+	AttentionOutOfRange string = "outOfRange" // e.g. outOfRange:temperature
+	AttentionStale      string = "stale"
+
+	defaultStaleAfter time.Duration = 24 * time.Hour
+)
+
+var attentionPriorities = map[string]int{
+	AttentionAlert:      1,
+	AttentionOutOfRange: 2,
+	AttentionStale:      3,
+}
+
+// attentionConfig configures when things need attention. Ranges are checked against the numeric
+// fields of things, e.g. the temperature of a Room.
+type attentionConfig struct {
+	StaleAfter time.Duration    `json:"staleAfter,omitempty" yaml:"staleAfter,omitempty"` // things without values for this long are stale, 24h if not set
+	Ranges     []attentionRange `json:"ranges,omitempty" yaml:"ranges,omitempty"`
+}
+
+type attentionRange struct {
+	Type  string   `json:"type" yaml:"type"`
+	Field string   `json:"field" yaml:"field"`
+	Min   *float64 `json:"min,omitempty" yaml:"min,omitempty"`
+	Max   *float64 `json:"max,omitempty" yaml:"max,omitempty"`
+}
+
+// alertFields are the boolean fields of things that signal an alert when true
+var alertFields = []string{"leakage", "burst", "backflow", "fraud", "overflowObserved"}
+
+func (a *app) attentionConfig() attentionConfig {
+	a.cfgMu.RLock()
+	defer a.cfgMu.RUnlock()
+
+	if a.cfg == nil {
+		return attentionConfig{}
+	}
+	return a.cfg.Attention
+}
+
+// GetThingsNeedingAttention returns the active things of the tenants that are stale, have an alert or
+// have values out of their configured range, ordered by priority.
+func (a *app) GetThingsNeedingAttention(ctx context.Context, tenants []string) ([]Attention, error) {
+	if len(tenants) == 0 {
+		return nil, ErrMissingThingTenant
+	}
+
+	cfg := a.attentionConfig()
+	now := time.Now().UTC()
+
+	staleAfter := cfg.StaleAfter
+	if staleAfter <= 0 {
+		staleAfter = defaultStaleAfter
+	}
+
+	stale, err := a.queryAllThings(ctx, WithTenants(tenants), WithStatus(things.StatusActive), WithRecentValues(false, now.Add(-staleAfter)))
+	if err != nil {
+		return nil, err
+	}
+
+	staleIDs := map[string]bool{}
+	for _, b := range stale {
+		t, err := a.loadThing(b)
+		if err != nil {
+			continue
+		}
+		staleIDs[t.ID()] = true
+	}
+
+	all, err := a.queryAllThings(ctx, WithTenants(tenants), WithStatus(things.StatusActive))
+	if err != nil {
+		return nil, err
+	}
+
+	result := []Attention{}
+
+	for _, b := range all {
+		t, err := a.loadThing(b)
+		if err != nil {
+			continue
+		}
+
+		fields := map[string]any{}
+		err = json.Unmarshal(b, &fields)
+		if err != nil {
+			continue
+		}
+
+		reasons := alerts(t, fields, now)
+		reasons = append(reasons, outOfRange(t, fields, cfg.Ranges)...)
+		if staleIDs[t.ID()] {
+			reasons = append(reasons, AttentionStale)
+		}
+
+		if len(reasons) == 0 {
+			continue
+		}
+
+		result = append(result, Attention{
+			ThingID:  t.ID(),
+			Type:     t.Type(),
+			Tenant:   t.Tenant(),
+			Priority: priority(reasons),
+			Reasons:  reasons,
+		})
+	}
+
+	slices.SortFunc(result, func(x, y Attention) int {
+		return cmp.Or(cmp.Compare(x.Priority, y.Priority), strings.Compare(x.ThingID, y.ThingID))
+	})
+
+	return result, nil
+}
+
+func alerts(t things.Thing, fields map[string]any, now time.Time) []string {
+	reasons := []string{}
+
+	for _, f := range alertFields {
+		if v, ok := fields[f].(bool); ok && v {
+			reasons = append(reasons, AttentionAlert+":"+f)
+		}
+	}
+
+	// a lifebuoy that has reported that it is not in place
+	if l, ok := t.(*things.Lifebuoy); ok {
+		if !l.Presence && !l.ObservedAt.IsZero() {
+			reasons = append(reasons, AttentionAlert+":presence")
+		}
+		if l.InspectionDue(now) {
+			reasons = append(reasons, AttentionAlert+":inspectionDue")
+		}
+	}
+
+	return reasons
+}
+
+func outOfRange(t things.Thing, fields map[string]any, ranges []attentionRange) []string {
+	reasons := []string{}
+
+	for _, r := range ranges {
+		if !strings.EqualFold(r.Type, t.Type()) {
+			continue
+		}
+
+		v, ok := fields[r.Field].(float64)
+		if !ok {
+			continue
+		}
+
+		if (r.Min != nil && v < *r.Min) || (r.Max != nil && v > *r.Max) {
+			reasons = append(reasons, AttentionOutOfRange+":"+r.Field)
+		}
+	}
+
+	return reasons
+}
+
+func priority(reasons []string) int {
+	p := len(attentionPriorities) + 1
+	for _, r := range reasons {
+		kind, _, _ := strings.Cut(r, ":")
+		p = min(p, attentionPriorities[kind])
+	}
+	return p
+}
@@ -0,0 +1,70 @@
+package iotthings
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/matryer/is"
+)
+
+func TestGetThingsNeedingAttention(t *testing.T) {
+	ctx := context.Background()
+	is := is.New(t)
+
+	overdue := time.Now().UTC().Add(-24 * time.Hour).Format(time.RFC3339)
+
+	leaking := []byte(`{"id":"watermeter-001","type":"WaterMeter","tenant":"default","leakage":true}`)
+	hot := []byte(`{"id":"room-001","type":"Room","tenant":"default","temperature":31.5}`)
+	stale := []byte(`{"id":"room-002","type":"Room","tenant":"default","temperature":21}`)
+	lifebuoy := []byte(`{"id":"lifebuoy-001","type":"Lifebuoy","tenant":"default","presence":true,"nextInspectionDue":"` + overdue + `"}`)
+	healthy := []byte(`{"id":"room-003","type":"Room","tenant":"default","temperature":21}`)
+
+	r := &ThingsReaderMock{
+		QueryThingsFunc: func(ctx context.Context, conditions ...ConditionFunc) (QueryResult, error) {
+			c := newConditions(conditions...)
+			if c["offset"] != 0 {
+				return QueryResult{Data: [][]byte{}}, nil
+			}
+			if c["hasrecentvalues"] == false {
+				return QueryResult{Data: [][]byte{stale, hot}, Count: 2}, nil
+			}
+			return QueryResult{Data: [][]byte{leaking, hot, stale, lifebuoy, healthy}, Count: 5}, nil
+		},
+	}
+
+	a := New(ctx, r, &ThingsWriterMock{}, msgCtxMock())
+
+	err := a.LoadConfig(ctx, strings.NewReader(`
+attention:
+  staleAfter: 6h
+  ranges:
+    - type: Room
+      field: temperature
+      min: 18
+      max: 28
+`))
+	is.NoErr(err)
+
+	result, err := a.GetThingsNeedingAttention(ctx, []string{"default"})
+	is.NoErr(err)
+	is.Equal(len(result), 4) // all but the healthy room
+
+	is.Equal(result[0].ThingID, "lifebuoy-001")
+	is.Equal(result[0].Reasons, []string{"alert:inspectionDue"})
+	is.Equal(result[1].ThingID, "watermeter-001")
+	is.Equal(result[1].Reasons, []string{"alert:leakage"})
+	is.Equal(result[1].Priority, 1)
+
+	is.Equal(result[2].ThingID, "room-001")
+	is.Equal(result[2].Reasons, []string{"outOfRange:temperature", "stale"})
+	is.Equal(result[2].Priority, 2)
+
+	is.Equal(result[3].ThingID, "room-002")
+	is.Equal(result[3].Reasons, []string{"stale"})
+	is.Equal(result[3].Priority, 3)
+
+	since := newConditions(r.QueryThingsCalls()[0].Conditions...)["recentsince"].(time.Time)
+	is.True(time.Since(since) > 6*time.Hour-time.Minute && time.Since(since) < 6*time.Hour+time.Minute)
+}
@@ -0,0 +1,115 @@
+package iotthings
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// Completeness is how many of the expected values of a thing that were received during a period,
+// given the interval the thing is expected to report at. The period is divided into buckets of one
+// interval each, and a bucket with at least one value counts as received. ExpectedInterval and
+// LongestGap are in seconds.
+type Completeness struct {
+	From             time.Time  `json:"from"`
+	To               time.Time  `json:"to"`
+	ExpectedInterval float64    `json:"expectedInterval"`
+	Expected         int        `json:"expected"`
+	Received         int        `json:"received"`
+	Completeness     float64    `json:"completeness"`
+	LongestGap       float64    `json:"longestGap"`
+	LongestGapFrom   *time.Time `json:"longestGapFrom,omitempty"`
+	LongestGapTo     *time.Time `json:"longestGapTo,omitempty"`
+}
+
+const maxCompletenessIntervals int = 100000
+
+func (a *app) GetCompleteness(ctx context.Context, thingID string, from, to time.Time, interval time.Duration, tenants []string) (Completeness, error) {
+	// values are bucketed by whole milliseconds
+	if interval < time.Millisecond {
+		return Completeness{}, fmt.Errorf("%w: expected interval must be at least 1ms", ErrInvalidParams)
+	}
+
+	if expectedIntervals(from, to, interval) > maxCompletenessIntervals {
+		return Completeness{}, ErrTimeRangeExceeded
+	}
+
+	_, err := a.queryThing(ctx, thingID, tenants)
+	if err != nil {
+		return Completeness{}, err
+	}
+
+	buckets, err := a.reader.GetValueBuckets(ctx, thingID, from, to, interval)
+	if err != nil {
+		return Completeness{}, err
+	}
+
+	return completeness(buckets, from, to, interval), nil
+}
+
+func expectedIntervals(from, to time.Time, interval time.Duration) int {
+	d := to.Sub(from)
+	n := int(d / interval)
+	if d%interval != 0 {
+		n++
+	}
+	return n
+}
+
+// completeness compares the buckets with values, ordered by time and aligned to from, to the
+// buckets expected in the period. Gaps are runs of buckets without values, the last one cut at to.
+func completeness(buckets []time.Time, from, to time.Time, interval time.Duration) Completeness {
+	c := Completeness{
+		From:             from,
+		To:               to,
+		ExpectedInterval: interval.Seconds(),
+		Expected:         expectedIntervals(from, to, interval),
+	}
+
+	received := map[int]bool{}
+	for _, b := range buckets {
+		if b.Before(from) || !b.Before(to) {
+			continue
+		}
+		received[int(b.Sub(from)/interval)] = true
+	}
+	c.Received = len(received)
+
+	if c.Expected > 0 {
+		c.Completeness = float64(c.Received) / float64(c.Expected)
+	}
+
+	gapStart := -1
+	endGap := func(i int) {
+		if gapStart == -1 {
+			return
+		}
+
+		start := from.Add(time.Duration(gapStart) * interval)
+		end := from.Add(time.Duration(i) * interval)
+		if end.After(to) {
+			end = to
+		}
+
+		if d := end.Sub(start).Seconds(); d > c.LongestGap {
+			c.LongestGap = d
+			c.LongestGapFrom = &start
+			c.LongestGapTo = &end
+		}
+
+		gapStart = -1
+	}
+
+	for i := range c.Expected {
+		if received[i] {
+			endGap(i)
+			continue
+		}
+		if gapStart == -1 {
+			gapStart = i
+		}
+	}
+	endGap(c.Expected)
+
+	return c
+}
@@ -0,0 +1,85 @@
+package iotthings
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/diwise/iot-things/internal/app/iot-things/things"
+	"github.com/matryer/is"
+)
+
+func TestCompleteness(t *testing.T) {
+	is := is.New(t)
+
+	from := time.Date(2024, 11, 1, 8, 0, 0, 0, time.UTC)
+	to := from.Add(10 * time.Hour)
+
+	hour := func(h int) time.Time {
+		return from.Add(time.Duration(h) * time.Hour)
+	}
+
+	// reported hourly but skipped 11:00-14:00 and 17:00
+	buckets := []time.Time{hour(0), hour(1), hour(2), hour(6), hour(7), hour(8)}
+
+	c := completeness(buckets, from, to, time.Hour)
+	is.Equal(c.Expected, 10)
+	is.Equal(c.Received, 6)
+	is.Equal(c.Completeness, 0.6)
+	is.Equal(c.LongestGap, (3 * time.Hour).Seconds())
+	is.Equal(*c.LongestGapFrom, hour(3))
+	is.Equal(*c.LongestGapTo, hour(6))
+
+	// a trailing gap is cut at the end of the period
+	c = completeness(buckets[:1], from, from.Add(150*time.Minute), time.Hour)
+	is.Equal(c.Expected, 3)
+	is.Equal(c.Received, 1)
+	is.Equal(c.LongestGap, (90 * time.Minute).Seconds())
+
+	c = completeness([]time.Time{}, from, to, time.Hour)
+	is.Equal(c.Received, 0)
+	is.Equal(c.Completeness, 0.0)
+	is.Equal(c.LongestGap, (10 * time.Hour).Seconds())
+
+	c = completeness(buckets, from, from.Add(3*time.Hour), time.Hour)
+	is.Equal(c.Completeness, 1.0)
+	is.Equal(c.LongestGap, 0.0)
+	is.True(c.LongestGapFrom == nil)
+}
+
+func TestGetCompleteness(t *testing.T) {
+	ctx := context.Background()
+	is := is.New(t)
+
+	from := time.Date(2024, 11, 1, 8, 0, 0, 0, time.UTC)
+	to := from.Add(4 * time.Hour)
+
+	r := &ThingsReaderMock{
+		QueryThingsFunc: func(ctx context.Context, conditions ...ConditionFunc) (QueryResult, error) {
+			room := things.NewRoom("room-001", things.DefaultLocation, "default")
+			return QueryResult{Data: [][]byte{room.Byte()}}, nil
+		},
+		GetValueBucketsFunc: func(ctx context.Context, thingID string, from, to time.Time, interval time.Duration) ([]time.Time, error) {
+			return []time.Time{from, from.Add(3 * interval)}, nil
+		},
+	}
+
+	a := New(ctx, r, &ThingsWriterMock{}, msgCtxMock())
+
+	c, err := a.GetCompleteness(ctx, "room-001", from, to, 30*time.Minute, []string{"default"})
+	is.NoErr(err)
+	is.Equal(c.Expected, 8)
+	is.Equal(c.Received, 2)
+	is.Equal(c.LongestGap, (120 * time.Minute).Seconds())
+
+	is.Equal(len(r.GetValueBucketsCalls()), 1)
+	is.Equal(r.GetValueBucketsCalls()[0].ThingID, "room-001")
+
+	_, err = a.GetCompleteness(ctx, "room-001", from, to, time.Millisecond, []string{"default"})
+	is.True(errors.Is(err, ErrTimeRangeExceeded))
+
+	_, err = a.GetCompleteness(ctx, "room-001", from, from.Add(time.Second), 500*time.Microsecond, []string{"default"})
+	is.True(errors.Is(err, ErrInvalidParams))
+	is.Equal(len(r.GetValueBucketsCalls()), 1)
+}
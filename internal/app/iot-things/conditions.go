@@ -1,21 +1,26 @@
 package iotthings
 
 import (
+	"encoding/base64"
 	"fmt"
 	"slices"
 	"strconv"
 	"strings"
 	"time"
+
+	"github.com/diwise/iot-things/internal/app/iot-things/things"
 )
 
 type ConditionFunc func(map[string]any) map[string]any
 
 type QueryResult struct {
-	Data       [][]byte
-	Count      int
-	Limit      int
-	Offset     int
-	TotalCount int64
+	Data         [][]byte
+	Count        int
+	Limit        int
+	Offset       int
+	TotalCount   int64
+	LastModified time.Time
+	NextCursor   string // set when there are more values after this page, see WithCursor
 }
 
 func WithID(id string) ConditionFunc {
@@ -32,6 +37,92 @@ func WithTenants(tenants []string) ConditionFunc {
 	}
 }
 
+func WithMinDevices(n int) ConditionFunc {
+	return func(m map[string]any) map[string]any {
+		m["mindevices"] = n
+		return m
+	}
+}
+
+func WithMaxDevices(n int) ConditionFunc {
+	return func(m map[string]any) map[string]any {
+		m["maxdevices"] = n
+		return m
+	}
+}
+
+// WithRecentValues filters things on whether they have (or, if has is false, do not have) values newer than since
+func WithRecentValues(has bool, since time.Time) ConditionFunc {
+	return func(m map[string]any) map[string]any {
+		m["hasrecentvalues"] = has
+		m["recentsince"] = since
+		return m
+	}
+}
+
+const defaultRecentValuesWindow time.Duration = 24 * time.Hour
+
+// WithInspectionDue filters things on whether their next inspection is due (or, if due is false, not due) at now
+func WithInspectionDue(due bool, now time.Time) ConditionFunc {
+	return func(m map[string]any) map[string]any {
+		m["inspectiondue"] = due
+		m["inspectionat"] = now
+		return m
+	}
+}
+
+func WithCommissionedBefore(t time.Time) ConditionFunc {
+	return func(m map[string]any) map[string]any {
+		m["commissionedbefore"] = t
+		return m
+	}
+}
+
+func WithCommissionedAfter(t time.Time) ConditionFunc {
+	return func(m map[string]any) map[string]any {
+		m["commissionedafter"] = t
+		return m
+	}
+}
+
+// WithObservedBefore matches things last observed before t, including things that have never been observed
+func WithObservedBefore(t time.Time) ConditionFunc {
+	return func(m map[string]any) map[string]any {
+		m["observedbefore"] = t
+		return m
+	}
+}
+
+// WithObservedAfter matches things last observed after t
+func WithObservedAfter(t time.Time) ConditionFunc {
+	return func(m map[string]any) map[string]any {
+		m["observedafter"] = t
+		return m
+	}
+}
+
+// WithStatus filters things by status, "active" or "inactive". Any other status, e.g. "all", matches all things.
+func WithStatus(status string) ConditionFunc {
+	return func(m map[string]any) map[string]any {
+		status = strings.ToLower(status)
+		if status == things.StatusActive || status == things.StatusInactive {
+			m["status"] = status
+		}
+		return m
+	}
+}
+
+// WithIncludeDeleted includes soft-deleted things, e.g. to audit or restore them. Deleted things are
+// returned with a deletedOn timestamp.
+func WithIncludeDeleted(include bool) ConditionFunc {
+	return func(m map[string]any) map[string]any {
+		if include {
+			m["includedeleted"] = true
+		}
+		return m
+	}
+}
+
 func WithTypes(types []string) ConditionFunc {
 	return func(m map[string]any) map[string]any {
 		m["types"] = types
@@ -46,6 +137,51 @@ func WithSubType(subType string) ConditionFunc {
 	}
 }
 
+// WithName matches things whose name contains name, disregarding case and accents
+func WithName(name string) ConditionFunc {
+	return func(m map[string]any) map[string]any {
+		if name = strings.TrimSpace(name); name != "" {
+			m["name"] = name
+		}
+		return m
+	}
+}
+
+// WithBounds matches things located within the bounding box
+func WithBounds(minLat, minLon, maxLat, maxLon float64) ConditionFunc {
+	return func(m map[string]any) map[string]any {
+		m["bounds"] = []float64{minLat, minLon, maxLat, maxLon}
+		return m
+	}
+}
+
+// WithNear matches things located within radius meters of lat, lon
+func WithNear(lat, lon, radius float64) ConditionFunc {
+	return func(m map[string]any) map[string]any {
+		m["near"] = []float64{lat, lon, radius}
+		return m
+	}
+}
+
+// parseFloats parses a comma separated list of exactly n numbers
+func parseFloats(s string, n int) ([]float64, bool) {
+	parts := strings.Split(s, ",")
+	if len(parts) != n {
+		return nil, false
+	}
+
+	f := make([]float64, 0, n)
+	for _, p := range parts {
+		v, err := strconv.ParseFloat(strings.TrimSpace(p), 64)
+		if err != nil {
+			return nil, false
+		}
+		f = append(f, v)
+	}
+
+	return f, true
+}
+
 func WithTags(tags []string) ConditionFunc {
 	return func(m map[string]any) map[string]any {
 		m["tags"] = tags
@@ -53,6 +189,19 @@ func WithTags(tags []string) ConditionFunc {
 	}
 }
 
+const (
+	TagModeAll string = "all" // things must have all of the tags (default)
+	TagModeAny string = "any" // things must have at least one of the tags
+)
+
+// WithTagMode sets whether things must have all or any of the tags given by WithTags
+func WithTagMode(mode string) ConditionFunc {
+	return func(m map[string]any) map[string]any {
+		m["tagmode"] = strings.ToLower(mode)
+		return m
+	}
+}
+
 func WithRefDevice(refDevice string) ConditionFunc {
 	return func(m map[string]any) map[string]any {
 		m["refdevice"] = refDevice
@@ -88,6 +237,20 @@ func WithUrn(urn []string) ConditionFunc {
 	}
 }
 
+func WithUnit(unit []string) ConditionFunc {
+	return func(m map[string]any) map[string]any {
+		m["unit"] = unit
+		return m
+	}
+}
+
+func WithUrnPrefix(prefix string) ConditionFunc {
+	return func(m map[string]any) map[string]any {
+		m["urnprefix"] = prefix
+		return m
+	}
+}
+
 func WithTimeRel(timeRel string) ConditionFunc {
 	return func(m map[string]any) map[string]any {
 		timeRel = strings.ToLower(timeRel)
@@ -150,6 +313,53 @@ func WithValue(value string) ConditionFunc {
 	}
 }
 
+// ValuePredicate compares the numeric value of a value with Value using Operator, one of eq, ne, gt or lt
+type ValuePredicate struct {
+	Operator string
+	Value    float64
+}
+
+// WithValuePredicates matches values that satisfy all of the predicates, e.g. a band of readings with gt 10
+// and lt 20. Predicates with an unknown operator are ignored.
+func WithValuePredicates(predicates []ValuePredicate) ConditionFunc {
+	valid := []ValuePredicate{}
+	for _, p := range predicates {
+		p.Operator = strings.ToLower(p.Operator)
+		if slices.Contains([]string{"eq", "ne", "gt", "lt"}, p.Operator) {
+			valid = append(valid, p)
+		}
+	}
+
+	return func(m map[string]any) map[string]any {
+		if len(valid) > 0 {
+			m["valuepredicates"] = valid
+		}
+		return m
+	}
+}
+
+// valuePredicates pairs repeated op and value parameters in the order they are given. The operator
+// defaults to eq if no op is given at all. It reports false if the parameters do not pair up.
+func valuePredicates(ops, values []string) ([]ValuePredicate, bool) {
+	if len(ops) == 0 {
+		ops = slices.Repeat([]string{"eq"}, len(values))
+	}
+	if len(ops) != len(values) {
+		return nil, false
+	}
+
+	predicates := []ValuePredicate{}
+	for i, value := range values {
+		v, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return nil, false
+		}
+		predicates = append(predicates, ValuePredicate{Operator: ops[i], Value: v})
+	}
+
+	return predicates, true
+}
+
 func WithBoolValue(vb string) ConditionFunc {
 	b, err := strconv.ParseBool(vb)
 	if err != nil {
@@ -164,6 +374,14 @@ func WithBoolValue(vb string) ConditionFunc {
 	}
 }
 
+// WithStates matches values having a boolean value, i.e. state changes such as presence or door open
+func WithStates() ConditionFunc {
+	return func(m map[string]any) map[string]any {
+		m["states"] = true
+		return m
+	}
+}
+
 func WithValueName(n string) ConditionFunc {
 	return func(m map[string]any) map[string]any {
 		m["n"] = n
@@ -180,6 +398,33 @@ func WithTimeUnit(timeUnit string) ConditionFunc {
 	}
 }
 
+// WithQuality filters values by quality flag. Values without a flag are considered good.
+func WithQuality(quality string) ConditionFunc {
+	return func(m map[string]any) map[string]any {
+		m["quality"] = strings.ToLower(quality)
+		return m
+	}
+}
+
+// WithAggregate aggregates values per timeunit using one or more of sum, avg, min and max, given as
+// separate values or separated by comma
+func WithAggregate(aggr []string) ConditionFunc {
+	return func(m map[string]any) map[string]any {
+		stats := []string{}
+		for _, a := range aggr {
+			for _, stat := range strings.Split(strings.ToLower(a), ",") {
+				if slices.Contains([]string{"sum", "avg", "min", "max"}, stat) && !slices.Contains(stats, stat) {
+					stats = append(stats, stat)
+				}
+			}
+		}
+		if len(stats) > 0 {
+			m["aggr"] = stats
+		}
+		return m
+	}
+}
+
 func WithFieldNameValue(fieldName string, value any) ConditionFunc {
 	return func(m map[string]any) map[string]any {
 		key := fmt.Sprintf("<%s>", fieldName)
@@ -188,6 +433,49 @@ func WithFieldNameValue(fieldName string, value any) ConditionFunc {
 	}
 }
 
+// WithNewestFirst orders values by time descending, i.e. the most recent value first
+func WithNewestFirst() ConditionFunc {
+	return func(m map[string]any) map[string]any {
+		m["newestfirst"] = true
+		return m
+	}
+}
+
+// WithCursor continues a query for values after the value with timestamp ts and id, i.e. keyset
+// pagination ordered by time and id. The cursor replaces offset.
+func WithCursor(ts time.Time, id string) ConditionFunc {
+	return func(m map[string]any) map[string]any {
+		m["cursorts"] = ts.UTC()
+		m["cursorid"] = id
+		return m
+	}
+}
+
+// EncodeCursor returns an opaque cursor pointing at the value with timestamp ts and id
+func EncodeCursor(ts time.Time, id string) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(ts.UTC().Format(time.RFC3339Nano) + " " + id))
+}
+
+// DecodeCursor returns the timestamp and id of a cursor created by EncodeCursor
+func DecodeCursor(cursor string) (time.Time, string, error) {
+	b, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return time.Time{}, "", err
+	}
+
+	ts, id, ok := strings.Cut(string(b), " ")
+	if !ok || id == "" {
+		return time.Time{}, "", fmt.Errorf("malformed cursor")
+	}
+
+	t, err := time.Parse(time.RFC3339Nano, ts)
+	if err != nil {
+		return time.Time{}, "", err
+	}
+
+	return t, id, nil
+}
+
 func WithShowLatest(showLatest bool) ConditionFunc {
 	return func(m map[string]any) map[string]any {
 		m["showlatest"] = showLatest
@@ -195,9 +483,7 @@ func WithShowLatest(showLatest bool) ConditionFunc {
 	}
 }
 
-func WithParams(query map[string][]string) []ConditionFunc {
-	conditions := make([]ConditionFunc, 0)
-
+func normalizeParams(query map[string][]string) map[string][]string {
 	params := map[string][]string{}
 	for k, v := range query {
 		key := strings.ReplaceAll(strings.ToLower(k), "_", "")
@@ -206,6 +492,13 @@ func WithParams(query map[string][]string) []ConditionFunc {
 		}
 		params[key] = v
 	}
+	return params
+}
+
+func WithParams(query map[string][]string) []ConditionFunc {
+	conditions := make([]ConditionFunc, 0)
+
+	params := normalizeParams(query)
 
 	for key, values := range params {
 		switch key {
@@ -217,10 +510,69 @@ func WithParams(query map[string][]string) []ConditionFunc {
 			conditions = append(conditions, WithTypes(values))
 		case "subtype":
 			conditions = append(conditions, WithSubType(values[0]))
+		case "name":
+			conditions = append(conditions, WithName(values[0]))
+		case "bbox":
+			// same order as a GeoJSON bbox, i.e. minLon,minLat,maxLon,maxLat
+			if b, ok := parseFloats(values[0], 4); ok {
+				conditions = append(conditions, WithBounds(b[1], b[0], b[3], b[2]))
+			}
+		case "near":
+			if p, ok := parseFloats(values[0], 2); ok {
+				if radius, ok := params["radius"]; ok {
+					if r, err := strconv.ParseFloat(radius[0], 64); err == nil {
+						conditions = append(conditions, WithNear(p[0], p[1], r))
+					}
+				}
+			}
+		case "status":
+			conditions = append(conditions, WithStatus(values[0]))
 		case "tags":
 			conditions = append(conditions, WithTags(values))
+		case "tagmode":
+			if mode := strings.ToLower(values[0]); mode == TagModeAll || mode == TagModeAny {
+				conditions = append(conditions, WithTagMode(mode))
+			}
 		case "refdevice":
 			conditions = append(conditions, WithRefDevice(values[0]))
+		case "mindevices":
+			if i, err := strconv.Atoi(values[0]); err == nil {
+				conditions = append(conditions, WithMinDevices(i))
+			}
+		case "maxdevices":
+			if i, err := strconv.Atoi(values[0]); err == nil {
+				conditions = append(conditions, WithMaxDevices(i))
+			}
+		case "hasrecentvalues":
+			if has, err := strconv.ParseBool(values[0]); err == nil {
+				within := defaultRecentValuesWindow
+				if w, ok := params["within"]; ok {
+					if d, err := time.ParseDuration(w[0]); err == nil && d > 0 {
+						within = d
+					}
+				}
+				conditions = append(conditions, WithRecentValues(has, time.Now().UTC().Add(-within)))
+			}
+		case "inspectiondue":
+			if due, err := strconv.ParseBool(values[0]); err == nil {
+				conditions = append(conditions, WithInspectionDue(due, time.Now().UTC()))
+			}
+		case "commissionedbefore":
+			if t, err := time.Parse(time.RFC3339, values[0]); err == nil {
+				conditions = append(conditions, WithCommissionedBefore(t))
+			}
+		case "commissionedafter":
+			if t, err := time.Parse(time.RFC3339, values[0]); err == nil {
+				conditions = append(conditions, WithCommissionedAfter(t))
+			}
+		case "stalesince":
+			if t, err := time.Parse(time.RFC3339, values[0]); err == nil {
+				conditions = append(conditions, WithObservedBefore(t))
+			}
+		case "observedafter":
+			if t, err := time.Parse(time.RFC3339, values[0]); err == nil {
+				conditions = append(conditions, WithObservedAfter(t))
+			}
 		case "offset":
 			if i, err := strconv.Atoi(values[0]); err == nil {
 				conditions = append(conditions, WithOffset(i))
@@ -229,10 +581,18 @@ func WithParams(query map[string][]string) []ConditionFunc {
 			if i, err := strconv.Atoi(values[0]); err == nil {
 				conditions = append(conditions, WithLimit(i))
 			}
+		case "cursor":
+			if ts, id, err := DecodeCursor(values[0]); err == nil {
+				conditions = append(conditions, WithCursor(ts, id))
+			}
 		case "thingid":
 			conditions = append(conditions, WithThingID(values[0]))
 		case "urn":
 			conditions = append(conditions, WithUrn(values))
+		case "urnprefix":
+			conditions = append(conditions, WithUrnPrefix(values[0]))
+		case "unit":
+			conditions = append(conditions, WithUnit(values))
 		case "timerel":
 			conditions = append(conditions, WithTimeRel(values[0]))
 			if timeAt, ok := params["timeat"]; ok {
@@ -244,6 +604,12 @@ func WithParams(query map[string][]string) []ConditionFunc {
 		case "op":
 			conditions = append(conditions, WithOperator(values[0]))
 		case "value":
+			if len(values) > 1 || len(params["op"]) > 1 {
+				if predicates, ok := valuePredicates(params["op"], values); ok {
+					conditions = append(conditions, WithValuePredicates(predicates))
+				}
+				continue
+			}
 			if _, ok := params["op"]; !ok {
 				conditions = append(conditions, WithOperator("eq"))
 			}
@@ -254,6 +620,10 @@ func WithParams(query map[string][]string) []ConditionFunc {
 			conditions = append(conditions, WithValueName(values[0]))
 		case "timeunit":
 			conditions = append(conditions, WithTimeUnit(values[0]))
+		case "aggr":
+			conditions = append(conditions, WithAggregate(values))
+		case "quality":
+			conditions = append(conditions, WithQuality(values[0]))
 		case "latest":
 			if values[0] == "true" {
 				if _, ok := params["thingid"]; ok {
@@ -273,3 +643,137 @@ func WithParams(query map[string][]string) []ConditionFunc {
 
 	return conditions
 }
+
+// ValidateParams checks the parameters understood by WithParams, which silently ignores malformed
+// values, and returns an ErrInvalidParams describing each malformed parameter.
+func ValidateParams(query map[string][]string) error {
+	params := normalizeParams(query)
+
+	keys := make([]string, 0, len(params))
+	for k := range params {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+
+	problems := []string{}
+	problem := func(format string, args ...any) {
+		problems = append(problems, fmt.Sprintf(format, args...))
+	}
+
+	isInt := func(s string, min int) bool {
+		i, err := strconv.Atoi(s)
+		return err == nil && i >= min
+	}
+	isBool := func(s string) bool {
+		_, err := strconv.ParseBool(s)
+		return err == nil
+	}
+	isTime := func(s string) bool {
+		_, err := time.Parse(time.RFC3339, s)
+		return err == nil
+	}
+	oneOf := func(s string, valid ...string) bool {
+		return slices.Contains(valid, strings.ToLower(s))
+	}
+
+	for _, key := range keys {
+		values := params[key]
+		if len(values) == 0 {
+			continue
+		}
+		v := values[0]
+
+		switch key {
+		case "offset", "mindevices", "maxdevices":
+			if !isInt(v, 0) {
+				problem("%s must be a non-negative integer", key)
+			}
+		case "limit":
+			if !isInt(v, 1) {
+				problem("limit must be a positive integer")
+			}
+		case "bbox":
+			b, ok := parseFloats(v, 4)
+			if !ok || b[0] > b[2] || b[1] > b[3] || b[1] < -90 || b[3] > 90 {
+				problem("bbox must be minLon,minLat,maxLon,maxLat")
+			}
+		case "near":
+			p, ok := parseFloats(v, 2)
+			if !ok || p[0] < -90 || p[0] > 90 {
+				problem("near must be lat,lon")
+			} else if _, ok := params["radius"]; !ok {
+				problem("near requires radius")
+			}
+		case "radius":
+			if r, err := strconv.ParseFloat(v, 64); err != nil || r <= 0 {
+				problem("radius must be a positive number of meters")
+			}
+		case "cursor":
+			if _, _, err := DecodeCursor(v); err != nil {
+				problem("cursor is not valid")
+			}
+		case "hasrecentvalues", "inspectiondue", "vb", "latest", "confirm", "deleted":
+			if !isBool(v) {
+				problem("%s must be true or false", key)
+			}
+		case "within":
+			if d, err := time.ParseDuration(v); err != nil || d <= 0 {
+				problem("within must be a positive duration, e.g. 24h")
+			}
+		case "commissionedbefore", "commissionedafter", "stalesince", "observedafter", "timeat", "endtimeat":
+			if !isTime(v) {
+				problem("%s must be a RFC3339 timestamp", key)
+			}
+		case "timerel":
+			if !oneOf(v, "before", "after", "between") {
+				problem("timerel must be one of before, after or between")
+			} else if _, ok := params["timeat"]; !ok {
+				problem("timerel requires timeat")
+			} else if _, ok := params["endtimeat"]; !ok && strings.EqualFold(v, "between") {
+				problem("timerel between requires endtimeat")
+			}
+		case "op":
+			for _, op := range values {
+				if !oneOf(op, "eq", "ne", "gt", "lt") {
+					problem("op must be one of eq, ne, gt or lt")
+					break
+				}
+			}
+		case "value":
+			for _, value := range values {
+				if _, err := strconv.ParseFloat(value, 64); err != nil {
+					problem("value must be a number")
+					break
+				}
+			}
+			if ops, ok := params["op"]; ok && len(ops) != len(values) && (len(ops) > 1 || len(values) > 1) {
+				problem("op and value must be given in pairs")
+			}
+		case "timeunit":
+			if !oneOf(v, "hour", "day") {
+				problem("timeunit must be hour or day")
+			}
+		case "aggr":
+			for _, stat := range strings.Split(strings.Join(values, ","), ",") {
+				if !oneOf(stat, "sum", "avg", "min", "max") {
+					problem("aggr must be one or more of sum, avg, min and max")
+					break
+				}
+			}
+		case "tagmode":
+			if !oneOf(v, TagModeAll, TagModeAny) {
+				problem("tagmode must be all or any")
+			}
+		case "status":
+			if !oneOf(v, things.StatusActive, things.StatusInactive, "all") {
+				problem("status must be active, inactive or all")
+			}
+		}
+	}
+
+	if len(problems) > 0 {
+		return fmt.Errorf("%w: %s", ErrInvalidParams, strings.Join(problems, "; "))
+	}
+
+	return nil
+}
@@ -0,0 +1,130 @@
+package iotthings
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+
+	"github.com/diwise/iot-things/internal/app/iot-things/things"
+)
+
+const (
+	DuplicateByRefDevice string = "refDevice" // things connected to the same device
+	DuplicateByLocation  string = "location"  // things of the same type at the exact same location
+)
+
+// Duplicate is a group of things, within a tenant, that are likely to describe the same real world thing
+type Duplicate struct {
+	Reason   string   `json:"reason"`
+	Key      string   `json:"key"` // the device id or the type and location shared by the things
+	Tenant   string   `json:"tenant"`
+	ThingIDs []string `json:"thingIds"`
+}
+
+// FindDuplicates finds things sharing a ref device or having the same type and location. Things without
+// a location are not compared by location.
+func (a *app) FindDuplicates(ctx context.Context, tenants []string) ([]Duplicate, error) {
+	if len(tenants) == 0 {
+		return nil, ErrMissingThingTenant
+	}
+
+	all, err := a.queryAllThings(ctx, WithTenants(tenants))
+	if err != nil {
+		return nil, err
+	}
+
+	type group struct {
+		reason, key, tenant string
+	}
+
+	groups := map[group][]string{}
+	order := []group{}
+
+	add := func(g group, thingID string) {
+		if _, ok := groups[g]; !ok {
+			order = append(order, g)
+		}
+		if !slices.Contains(groups[g], thingID) {
+			groups[g] = append(groups[g], thingID)
+		}
+	}
+
+	for _, b := range all {
+		t, err := a.loadThing(b)
+		if err != nil {
+			continue
+		}
+
+		for _, device := range t.Refs() {
+			add(group{DuplicateByRefDevice, device.DeviceID, t.Tenant()}, t.ID())
+		}
+
+		if lat, lon := t.LatLon(); lat != 0 || lon != 0 {
+			add(group{DuplicateByLocation, fmt.Sprintf("%s@%v,%v", t.Type(), lat, lon), t.Tenant()}, t.ID())
+		}
+	}
+
+	duplicates := []Duplicate{}
+	for _, g := range order {
+		if ids := groups[g]; len(ids) > 1 {
+			duplicates = append(duplicates, Duplicate{
+				Reason:   g.reason,
+				Key:      g.key,
+				Tenant:   g.tenant,
+				ThingIDs: ids,
+			})
+		}
+	}
+
+	return duplicates, nil
+}
+
+// MergeDuplicate merges the source thing into the target thing. The devices and tags of the source are
+// added to the target, and the source is deleted. Both things must be of the same type and tenant.
+// Values stored for the source are left as they are, they are not moved to the target.
+func (a *app) MergeDuplicate(ctx context.Context, targetID, sourceID string, tenants []string) (things.Thing, error) {
+	if targetID == "" || sourceID == "" {
+		return nil, ErrMissingThingID
+	}
+	if targetID == sourceID {
+		return nil, fmt.Errorf("%w: a thing cannot be merged into itself", ErrCannotMerge)
+	}
+
+	target, err := a.getThing(ctx, targetID, tenants)
+	if err != nil {
+		return nil, err
+	}
+	source, err := a.getThing(ctx, sourceID, tenants)
+	if err != nil {
+		return nil, err
+	}
+
+	if target.Type() != source.Type() || target.Tenant() != source.Tenant() {
+		return nil, fmt.Errorf("%w: %s and %s differ in type or tenant", ErrCannotMerge, targetID, sourceID)
+	}
+
+	for _, device := range source.Refs() {
+		target.AddDevice(device.DeviceID)
+	}
+
+	tags := struct {
+		Tags []string `json:"tags"`
+	}{}
+	err = json.Unmarshal(source.Byte(), &tags)
+	if err != nil {
+		return nil, err
+	}
+	for _, tag := range tags.Tags {
+		target.AddTag(tag)
+	}
+
+	err = a.writer.MergeDuplicate(ctx, target, sourceID)
+	if err != nil {
+		return nil, err
+	}
+
+	a.publishDeleted(ctx, source)
+
+	return target, nil
+}
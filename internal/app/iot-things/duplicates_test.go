@@ -0,0 +1,110 @@
+package iotthings
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/diwise/iot-things/internal/app/iot-things/things"
+	"github.com/matryer/is"
+)
+
+func TestFindDuplicates(t *testing.T) {
+	ctx := context.Background()
+	is := is.New(t)
+
+	location := things.Location{Latitude: 62.39, Longitude: 17.30}
+
+	// room-001 and room-002 are connected to the same device, container-001 and container-002 are at the same location
+	room1 := things.NewRoom("room-001", things.DefaultLocation, "default")
+	room1.AddDevice("device-001")
+	room2 := things.NewRoom("room-002", things.Location{Latitude: 62.40, Longitude: 17.31}, "default")
+	room2.AddDevice("device-001")
+	room3 := things.NewRoom("room-003", location, "default")
+	container1 := things.NewContainer("container-001", location, "default")
+	container2 := things.NewContainer("container-002", location, "default")
+	other := things.NewContainer("container-003", location, "other")
+
+	r := &ThingsReaderMock{
+		QueryThingsFunc: func(ctx context.Context, conditions ...ConditionFunc) (QueryResult, error) {
+			c := newConditions(conditions...)
+			data := [][]byte{}
+			for _, t := range []things.Thing{room1, room2, room3, container1, container2, other} {
+				if c["offset"] == 0 && slices.Contains(c["tenants"].([]string), t.Tenant()) {
+					data = append(data, t.Byte())
+				}
+			}
+			return QueryResult{Data: data, Count: len(data)}, nil
+		},
+	}
+
+	app := New(ctx, r, &ThingsWriterMock{}, msgCtxMock())
+
+	duplicates, err := app.FindDuplicates(ctx, []string{"default", "other"})
+	is.NoErr(err)
+	is.Equal(len(duplicates), 2)
+
+	is.Equal(duplicates[0].Reason, DuplicateByRefDevice)
+	is.Equal(duplicates[0].Key, "device-001")
+	is.Equal(duplicates[0].ThingIDs, []string{"room-001", "room-002"})
+
+	is.Equal(duplicates[1].Reason, DuplicateByLocation)
+	is.Equal(duplicates[1].Tenant, "default")
+	is.Equal(duplicates[1].ThingIDs, []string{"container-001", "container-002"}) // the room at the same location is of another type
+}
+
+func TestMergeDuplicate(t *testing.T) {
+	ctx := context.Background()
+	is := is.New(t)
+
+	target := things.NewRoom("room-001", things.DefaultLocation, "default")
+	target.AddDevice("device-001")
+	target.AddTag("floor-1")
+	source := things.NewRoom("room-002", things.DefaultLocation, "default")
+	source.AddDevice("device-001")
+	source.AddDevice("device-002")
+	source.AddTag("floor-1")
+	source.AddTag("north")
+	container := things.NewContainer("container-001", things.DefaultLocation, "default")
+
+	r := &ThingsReaderMock{
+		QueryThingsFunc: func(ctx context.Context, conditions ...ConditionFunc) (QueryResult, error) {
+			for _, t := range []things.Thing{target, source, container} {
+				if t.ID() == newConditions(conditions...)["id"] {
+					return QueryResult{Data: [][]byte{t.Byte()}, Count: 1}, nil
+				}
+			}
+			return QueryResult{Data: [][]byte{}}, nil
+		},
+	}
+	w := &ThingsWriterMock{
+		MergeDuplicateFunc: func(ctx context.Context, target things.Thing, sourceID string) error {
+			return nil
+		},
+	}
+
+	app := New(ctx, r, w, msgCtxMock())
+
+	merged, err := app.MergeDuplicate(ctx, "room-001", "room-002", []string{"default"})
+	is.NoErr(err)
+
+	is.Equal(len(merged.Refs()), 2)
+	is.Equal(merged.Refs()[1].DeviceID, "device-002")
+	is.True(strings.Contains(string(merged.Byte()), `"tags":["floor-1","north"]`))
+
+	is.Equal(w.MergeDuplicateCalls()[0].Target.ID(), "room-001")
+	is.Equal(w.MergeDuplicateCalls()[0].SourceID, "room-002")
+
+	_, err = app.MergeDuplicate(ctx, "room-001", "container-001", []string{"default"})
+	is.True(errors.Is(err, ErrCannotMerge))
+
+	_, err = app.MergeDuplicate(ctx, "room-001", "room-001", []string{"default"})
+	is.True(errors.Is(err, ErrCannotMerge))
+
+	_, err = app.MergeDuplicate(ctx, "room-001", "room-004", []string{"default"})
+	is.True(errors.Is(err, ErrThingNotFound))
+
+	is.Equal(len(w.MergeDuplicateCalls()), 1)
+}
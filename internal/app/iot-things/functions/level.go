@@ -20,6 +20,27 @@ type LevelConfig struct {
 	MeanLevel   *float64 `json:"meanl,omitempty"`
 	Offset      *float64 `json:"offset,omitempty"`
 	Angle       *float64 `json:"angle,omitempty"`
+	Clamp       *string  `json:"clamp,omitempty"` // what to do with levels below zero, see ClampReject, ClampZero and ClampKeep
+}
+
+// A distance larger than the max distance gives a level below zero. If no clamp mode is configured the
+// level is kept while the percentage is set to zero.
+const (
+	ClampReject string = "reject"    // the distance is rejected with ErrImpossibleDistance
+	ClampZero   string = "clampZero" // both level and percentage are set to zero
+	ClampKeep   string = "keep"      // both level and percentage are kept below zero
+)
+
+var ErrImpossibleDistance = errors.New("distance exceeds max distance")
+
+// ValidateClamp returns an error if clamp is not one of the supported clamp modes
+func ValidateClamp(clamp string) error {
+	switch clamp {
+	case ClampReject, ClampZero, ClampKeep:
+		return nil
+	default:
+		return fmt.Errorf("level clamp %s not one of %s, %s or %s", clamp, ClampReject, ClampZero, ClampKeep)
+	}
 }
 
 type level struct {
@@ -28,13 +49,14 @@ type level struct {
 	maxLevel    float64
 	meanLevel   float64
 	offsetLevel float64
+	clamp       string
 
 	Current_ float64  `json:"current"`
 	Percent_ *float64 `json:"percent,omitempty"`
 	Offset_  *float64 `json:"offset,omitempty"`
 }
 
-func NewLevel(angle, maxDistance, maxLevel, meanLevel, offset *float64, current float64) (Level, error) {
+func NewLevel(angle, maxDistance, maxLevel, meanLevel, offset *float64, clamp *string, current float64) (Level, error) {
 	lvl := &level{
 		cosAlpha: 1.0,
 	}
@@ -46,6 +68,14 @@ func NewLevel(angle, maxDistance, maxLevel, meanLevel, offset *float64, current
 		lvl.cosAlpha = math.Cos(*angle * math.Pi / 180.0)
 	}
 
+	if clamp != nil {
+		err := ValidateClamp(*clamp)
+		if err != nil {
+			return nil, err
+		}
+		lvl.clamp = *clamp
+	}
+
 	f := func(value *float64, v float64) float64 {
 		if value != nil {
 			return *value
@@ -61,10 +91,7 @@ func NewLevel(angle, maxDistance, maxLevel, meanLevel, offset *float64, current
 	lvl.Current_ = current
 
 	if isNotZero(lvl.maxLevel) {
-		pct := math.Min((lvl.Current_*100.0)/lvl.maxLevel, 100.0)
-		if pct < 0 {
-			pct = 0
-		}
+		pct := lvl.percent()
 		lvl.Percent_ = &pct
 	}
 
@@ -81,19 +108,27 @@ func (l *level) Calc(distance float64, ts time.Time) (bool, error) {
 
 	previousLevel := l.Current_
 
-	var errs []error
-
 	// Calculate the current level using the configured angle (if any) and round to two decimals
-	l.Current_ = math.Round((l.maxDistance-distance)*l.cosAlpha*100) / 100.0
+	current := math.Round((l.maxDistance-distance)*l.cosAlpha*100) / 100.0
+
+	if current < 0 {
+		switch l.clamp {
+		case ClampReject:
+			return false, fmt.Errorf("%w: level %.2f is below zero", ErrImpossibleDistance, current)
+		case ClampZero:
+			current = 0
+		}
+	}
+
+	l.Current_ = current
 
 	if !hasChanged(previousLevel, l.Current_) {
 		return false, nil
 	}
 
 	if isNotZero(l.maxLevel) {
-		pct := math.Min((l.Current_*100.0)/l.maxLevel, 100.0)
+		pct := l.percent()
 		l.Percent_ = &pct
-
 	}
 
 	if isNotZero(l.meanLevel) {
@@ -101,8 +136,16 @@ func (l *level) Calc(distance float64, ts time.Time) (bool, error) {
 		l.Offset_ = &offset
 	}
 
-	return true, errors.Join(errs...)
+	return true, nil
+}
 
+// percent is the current level in percent of the max level, at most 100 and, unless negative levels are kept, at least 0
+func (l *level) percent() float64 {
+	pct := math.Min((l.Current_*100.0)/l.maxLevel, 100.0)
+	if pct < 0 && l.clamp != ClampKeep {
+		pct = 0
+	}
+	return pct
 }
 
 func (l *level) Current() float64 {
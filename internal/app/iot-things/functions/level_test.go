@@ -0,0 +1,77 @@
+package functions
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/matryer/is"
+)
+
+// distances beyond the max distance of 1.0 give the levels -0.2 and -0.5
+var negativeSeries = []float64{0.5, 1.2, 1.5}
+
+func levels(is *is.I, clamp *string) ([]float64, []float64, error) {
+	maxd, maxl := 1.0, 1.0
+
+	lvl, err := NewLevel(nil, &maxd, &maxl, nil, nil, clamp, 0)
+	is.NoErr(err)
+
+	current, percent := []float64{}, []float64{}
+	for _, d := range negativeSeries {
+		_, err := lvl.Calc(d, time.Now())
+		if err != nil {
+			return current, percent, err
+		}
+		current = append(current, lvl.Current())
+		percent = append(percent, lvl.Percent())
+	}
+
+	return current, percent, nil
+}
+
+func TestLevelKeepsNegativeLevelByDefault(t *testing.T) {
+	is := is.New(t)
+
+	current, percent, err := levels(is, nil)
+	is.NoErr(err)
+	is.Equal(current, []float64{0.5, -0.2, -0.5})
+	is.Equal(percent, []float64{50, 0, 0})
+}
+
+func TestLevelClampZero(t *testing.T) {
+	is := is.New(t)
+
+	clamp := ClampZero
+	current, percent, err := levels(is, &clamp)
+	is.NoErr(err)
+	is.Equal(current, []float64{0.5, 0, 0})
+	is.Equal(percent, []float64{50, 0, 0})
+}
+
+func TestLevelKeep(t *testing.T) {
+	is := is.New(t)
+
+	clamp := ClampKeep
+	current, percent, err := levels(is, &clamp)
+	is.NoErr(err)
+	is.Equal(current, []float64{0.5, -0.2, -0.5})
+	is.Equal(percent, []float64{50, -20, -50})
+}
+
+func TestLevelReject(t *testing.T) {
+	is := is.New(t)
+
+	clamp := ClampReject
+	current, _, err := levels(is, &clamp)
+	is.True(errors.Is(err, ErrImpossibleDistance))
+	is.Equal(current, []float64{0.5})
+}
+
+func TestLevelUnknownClamp(t *testing.T) {
+	is := is.New(t)
+
+	clamp := "sometimes"
+	_, err := NewLevel(nil, nil, nil, nil, nil, &clamp, 0)
+	is.True(err != nil)
+}
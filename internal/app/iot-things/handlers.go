@@ -6,6 +6,7 @@ import (
 	"errors"
 	"fmt"
 	"log/slog"
+	"slices"
 	"strconv"
 	"strings"
 	"time"
@@ -16,6 +17,7 @@ import (
 	"github.com/diwise/service-chassis/pkg/infrastructure/o11y"
 	"github.com/diwise/service-chassis/pkg/infrastructure/o11y/logging"
 	"github.com/diwise/service-chassis/pkg/infrastructure/o11y/tracing"
+	"github.com/google/uuid"
 	"go.opentelemetry.io/otel"
 )
 
@@ -30,8 +32,9 @@ func NewMeasurementsHandler(app ThingsApp, msgCtx messaging.MsgContext) messagin
 		_, ctx, log := o11y.AddTraceIDToLoggerAndStoreInContext(span, logger, ctx)
 
 		msg := struct {
-			Pack      senml.Pack `json:"pack"`
-			Timestamp time.Time  `json:"timestamp"`
+			Pack          senml.Pack `json:"pack"`
+			Timestamp     time.Time  `json:"timestamp"`
+			CorrelationID string     `json:"correlationId,omitempty"`
 		}{}
 
 		err = json.Unmarshal(d.Body(), &msg)
@@ -51,19 +54,94 @@ func NewMeasurementsHandler(app ThingsApp, msgCtx messaging.MsgContext) messagin
 			return
 		}
 
-		measurements, err := convPack(ctx, msg.Pack)
+		if msg.CorrelationID == "" {
+			msg.CorrelationID = uuid.NewString()
+		}
+		ctx = WithCorrelationID(ctx, msg.CorrelationID)
+		log = log.With(slog.String("correlationID", msg.CorrelationID))
+
+		var result IngestResult
+		result, err = IngestPack(ctx, app, msg.Pack)
 		if err != nil {
 			log.Error("could not convert pack to measurements", "err", err.Error())
 			return
 		}
 
-		if len(measurements) == 0 {
-			log.Debug("no measurements found in pack")
-			return
+		if result.Errors > 0 {
+			log.Warn("some records in pack could not be handled", "stored", result.Stored, "skipped", result.Skipped, "errors", result.Errors)
 		}
+	}
+}
+
+type correlationIDKey struct{}
+
+// WithCorrelationID returns a context carrying the correlation id given to measurements ingested with it
+func WithCorrelationID(ctx context.Context, correlationID string) context.Context {
+	return context.WithValue(ctx, correlationIDKey{}, correlationID)
+}
+
+// CorrelationID returns the correlation id carried by ctx, or an empty string
+func CorrelationID(ctx context.Context) string {
+	id, _ := ctx.Value(correlationIDKey{}).(string)
+	return id
+}
+
+const (
+	RecordStored  string = "stored"
+	RecordSkipped string = "skipped"
+	RecordError   string = "error"
+)
+
+// RecordResult is the outcome of ingesting a single senml record
+type RecordResult struct {
+	Name   string `json:"name"`
+	Status string `json:"status"`
+	Reason string `json:"reason,omitempty"`
+}
+
+// IngestResult summarizes the outcome of ingesting a pack, record by record
+type IngestResult struct {
+	Stored  int            `json:"stored"`
+	Skipped int            `json:"skipped"`
+	Errors  int            `json:"errors"`
+	Records []RecordResult `json:"records"`
+}
+
+func (r *IngestResult) add(rr RecordResult) {
+	switch rr.Status {
+	case RecordStored:
+		r.Stored++
+	case RecordSkipped:
+		r.Skipped++
+	default:
+		r.Errors++
+	}
+	r.Records = append(r.Records, rr)
+}
+
+// IngestPack converts the pack to measurements and handles them best-effort. Records that can not be
+// converted or handled are reported in the result rather than failing the whole pack.
+func IngestPack(ctx context.Context, app ThingsApp, pack senml.Pack) (IngestResult, error) {
+	result := IngestResult{Records: []RecordResult{}}
+
+	measurements, skipped, err := convPack(ctx, pack)
+	if err != nil {
+		return result, err
+	}
+
+	for _, rr := range skipped {
+		result.add(rr)
+	}
+
+	if len(measurements) == 0 {
+		return result, nil
+	}
 
-		app.HandleMeasurements(ctx, measurements)
+	for _, rr := range app.HandleMeasurements(ctx, measurements).Records {
+		result.add(rr)
 	}
+
+	return result, nil
 }
 
 func unique(arr []string) []string {
@@ -80,6 +158,13 @@ func unique(arr []string) []string {
 	return result
 }
 
+// visibleState is the published state of a thing, without the timestamp updated by every measurement
+func visibleState(t things.Thing) map[string]any {
+	m := stripFields(t)
+	delete(m, "observedAt")
+	return m
+}
+
 func stripFields(t things.Thing) map[string]any {
 	m := make(map[string]any)
 	b, err := json.Marshal(t)
@@ -111,33 +196,58 @@ func stripFields(t things.Thing) map[string]any {
 	return m
 }
 
-func convPack(ctx context.Context, pack senml.Pack) ([]things.Measurement, error) {
+// convPack returns the measurements in the pack together with the records that were skipped
+func convPack(ctx context.Context, pack senml.Pack) ([]things.Measurement, []RecordResult, error) {
 	log := logging.GetFromContext(ctx)
 
-	header, ok := pack.GetRecord(senml.FindByName("0"))
-	if !ok {
-		return nil, fmt.Errorf("could not find header record (0)")
+	urn := ""
+	if header, ok := pack.GetRecord(findHeader); ok {
+		urn = header.StringValue
+	} else if !slices.ContainsFunc(pack, isQualified) {
+		return nil, nil, fmt.Errorf("could not find header record (0)")
 	}
 
 	measurements := make([]things.Measurement, 0)
+	skipped := make([]RecordResult, 0)
+
+	var location *things.Location
+	if lat, lon, ok := pack.GetLatLon(); ok && (lat != 0 || lon != 0) {
+		location = &things.Location{Latitude: lat, Longitude: lon}
+	}
 
-	urn := header.StringValue
+	// a device may flag all readings in the pack, e.g. as estimated or faulty
+	quality := ""
+	if q, ok := pack.GetRecord(senml.FindByName("quality")); ok {
+		quality = strings.ToLower(q.StringValue)
+	}
+
+	correlationID := CorrelationID(ctx)
 
 	var errs []error
 
 	for _, r := range pack {
-		n, err := strconv.Atoi(r.Name)
-		if err != nil || n == 0 {
-			continue
+		// records are either named by resource id only, with the urn given by the header,
+		// or fully qualified as device/object/resource and carrying their own urn
+		recordUrn := urn
+		if n, err := strconv.Atoi(r.Name); err != nil || n == 0 {
+			objectID, _, ok := splitQualifiedName(r.Name)
+			if !ok {
+				continue
+			}
+			if !strings.HasSuffix(urn, ":"+objectID) {
+				recordUrn = lwm2mURN(objectID)
+			}
 		}
 
 		rec, ok := pack.GetRecord(senml.FindByName(r.Name))
 		if !ok {
 			log.Error("could not find record", "name", r.Name)
+			skipped = append(skipped, RecordResult{Name: r.Name, Status: RecordError, Reason: "record not found"})
 			continue
 		}
 
 		if rec.Value == nil && rec.BoolValue == nil {
+			skipped = append(skipped, RecordResult{Name: rec.Name, Status: RecordSkipped, Reason: "missing value"})
 			continue
 		}
 
@@ -148,30 +258,72 @@ func convPack(ctx context.Context, pack senml.Pack) ([]things.Measurement, error
 			vs = &rec.StringValue
 		}
 
-		if id == "" || urn == "" {
+		if id == "" || recordUrn == "" {
+			skipped = append(skipped, RecordResult{Name: rec.Name, Status: RecordSkipped, Reason: "missing name or urn"})
 			continue
 		}
 
 		m := things.Measurement{
-			ID:          id,
-			Timestamp:   ts.UTC(),
-			Urn:         urn,
-			BoolValue:   rec.BoolValue,
-			Value:       rec.Value,
-			StringValue: vs,
-			Unit:        rec.Unit,
+			ID:            id,
+			Timestamp:     ts.UTC(),
+			Urn:           recordUrn,
+			BoolValue:     rec.BoolValue,
+			Value:         rec.Value,
+			StringValue:   vs,
+			Unit:          rec.Unit,
+			Location:      location,
+			Quality:       quality,
+			CorrelationID: correlationID,
 		}
 
 		measurements = append(measurements, m)
 	}
 
-	return measurements, errors.Join(errs...)
+	return measurements, skipped, errors.Join(errs...)
 }
 
 func extractDeviceID(pack senml.Pack) (string, bool) {
-	r, ok := pack.GetRecord(senml.FindByName("0"))
+	r, ok := pack.GetRecord(findHeader)
 	if !ok {
-		return "", false
+		idx := slices.IndexFunc(pack, isQualified)
+		if idx == -1 {
+			return "", false
+		}
+		r = pack[idx]
 	}
 	return strings.Split(r.Name, "/")[0], true
 }
+
+// findHeader matches the header record, named 0 or fully qualified as device/object/0
+func findHeader(r senml.Record) bool {
+	return r.Name == "0" || (strings.HasSuffix(r.Name, "/0") && strings.Count(r.Name, "/") >= 2)
+}
+
+func isQualified(r senml.Record) bool {
+	_, _, ok := splitQualifiedName(r.Name)
+	return ok
+}
+
+// splitQualifiedName returns the object and resource id of a fully qualified resource name,
+// i.e. device/object/resource or device/object/instance/resource
+func splitQualifiedName(name string) (string, string, bool) {
+	parts := strings.Split(name, "/")
+	if len(parts) < 3 || len(parts) > 4 || parts[0] == "" {
+		return "", "", false
+	}
+
+	objectID, resourceID := parts[1], parts[len(parts)-1]
+
+	if _, err := strconv.Atoi(objectID); err != nil {
+		return "", "", false
+	}
+	if n, err := strconv.Atoi(resourceID); err != nil || n == 0 {
+		return "", "", false
+	}
+
+	return objectID, resourceID, true
+}
+
+func lwm2mURN(objectID string) string {
+	return "urn:oma:lwm2m:ext:" + objectID
+}
@@ -2,13 +2,20 @@ package iotthings
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"log/slog"
+	"math"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
 	"github.com/diwise/iot-things/internal/app/iot-things/things"
+	"github.com/diwise/iot-things/pkg/types"
 	"github.com/diwise/messaging-golang/pkg/messaging"
+	"github.com/diwise/senml"
 	"github.com/matryer/is"
 )
 
@@ -29,6 +36,75 @@ func TestRoomTemperature(t *testing.T) {
 	is.Equal(s[r.ID()].(*things.Room).Temperature, 21.0)
 }
 
+func TestConvPackWithFullyQualifiedNames(t *testing.T) {
+	is := is.New(t)
+
+	pack := senml.Pack{}
+	is.NoErr(json.Unmarshal([]byte(fullyQualifiedPack), &pack))
+
+	measurements, skipped, err := convPack(context.Background(), pack)
+	is.NoErr(err)
+	is.Equal(len(skipped), 0)
+	is.Equal(len(measurements), 2)
+
+	is.Equal(measurements[0].ID, "c5a2ae17c239/3303/5700")
+	is.Equal(measurements[0].Urn, things.TemperatureURN)
+	is.Equal(measurements[0].DeviceID(), "c5a2ae17c239")
+	is.Equal(*measurements[0].Value, 21.0)
+
+	is.Equal(measurements[1].ID, "c5a2ae17c239/3304/0/5700")
+	is.Equal(measurements[1].Urn, things.HumidityURN)
+	is.Equal(*measurements[1].Value, 45.0)
+
+	deviceID, ok := extractDeviceID(pack)
+	is.True(ok)
+	is.Equal(deviceID, "c5a2ae17c239")
+}
+
+func TestRoomTemperatureWithFullyQualifiedNames(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	is := is.New(t)
+
+	r := things.NewRoom("room-001", things.DefaultLocation, "default")
+	r.AddDevice("c5a2ae17c239")
+
+	s := map[string]things.Thing{}
+	v := map[string][]things.Value{}
+
+	NewMeasurementsHandler(appMock(ctx, r, s, v), msgCtxMock())(ctx, msgMock(fullyQualifiedTemperatureMsg), slog.Default())
+
+	is.Equal(s[r.ID()].(*things.Room).Temperature, 21.0)
+}
+
+func TestRoomTemperatureWithCalibration(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	is := is.New(t)
+
+	scale := 1.1
+	r := things.NewRoom("room-001", things.DefaultLocation, "default")
+	r.AddDevice("c5a2ae17c239")
+	r.(*things.Room).RefDevices[0].Calibration = &things.Calibration{Urn: things.TemperatureURN, Scale: &scale, Offset: -1.6}
+
+	s := map[string]things.Thing{}
+	v := map[string][]things.Value{}
+
+	NewMeasurementsHandler(appMock(ctx, r, s, v), msgCtxMock())(ctx, msgMock(temperatureMsg), slog.Default())
+
+	room := s[r.ID()].(*things.Room)
+	is.Equal(math.Round(room.Temperature*100)/100, 21.5) // 1.1*21 - 1.6
+
+	is.Equal(len(v[r.ID()]), 1)
+	is.Equal(math.Round(*v[r.ID()][0].Value*100)/100, 21.5)
+	is.True(v[r.ID()][0].Calibrated)
+
+	// the calibration is kept on the ref device
+	is.Equal(room.RefDevices[0].Calibration.Offset, -1.6)
+}
+
 func TestContainerDistance(t *testing.T) {
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
@@ -135,11 +211,195 @@ func TestPumpingStationDigitalInput(t *testing.T) {
 	is.Equal(s[p.ID()].(*things.PumpingStation).PumpingObserved, false)
 }
 
+func TestMeasurementLocationPrecedence(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	is := is.New(t)
+
+	r := things.NewRoom("room-001", things.Location{Latitude: 62, Longitude: 17}, "default")
+	r.AddDevice("c5a2ae17c239")
+
+	s := map[string]things.Thing{}
+	v := map[string][]things.Value{}
+
+	a := appMock(ctx, r, s, v)
+	err := a.LoadConfig(ctx, strings.NewReader("locationPrecedence: measurement"))
+	is.NoErr(err)
+
+	NewMeasurementsHandler(a, msgCtxMock())(ctx, msgMock(temperatureWithLocationMsg), slog.Default())
+
+	lat, lon := s[r.ID()].LatLon()
+	is.Equal(lat, 62.5)
+	is.Equal(lon, 17.5)
+	is.Equal(s[r.ID()].(*things.Room).Location, things.Location{Latitude: 62, Longitude: 17})
+}
+
+func TestMeasurementQualityIsStoredWithValues(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	is := is.New(t)
+
+	r := things.NewRoom("room-001", things.Location{Latitude: 62, Longitude: 17}, "default")
+	r.AddDevice("c5a2ae17c239")
+
+	s := map[string]things.Thing{}
+	v := map[string][]things.Value{}
+
+	a := appMock(ctx, r, s, v)
+
+	NewMeasurementsHandler(a, msgCtxMock())(ctx, msgMock(estimatedTemperatureMsg), slog.Default())
+
+	is.Equal(len(v[r.ID()]), 1)
+	is.Equal(v[r.ID()][0].Quality, things.QualityEstimated)
+}
+
+func TestIngestPackWithMixedValidity(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	is := is.New(t)
+
+	room := things.NewRoom("room-001", things.DefaultLocation, "default")
+	room.AddDevice("c5a2ae17c239")
+
+	r := &ThingsReaderMock{
+		QueryThingsFunc: func(ctx context.Context, conditions ...ConditionFunc) (QueryResult, error) {
+			return QueryResult{Data: [][]byte{room.Byte()}}, nil
+		},
+	}
+
+	updates := 0
+	w := &ThingsWriterMock{
+		AddValueFunc: func(ctx context.Context, t things.Thing, m things.Value) error {
+			return nil
+		},
+		UpdateThingFunc: func(ctx context.Context, t things.Thing) error {
+			// storage fails after the first update
+			updates++
+			if updates > 1 {
+				return errors.New("connection lost")
+			}
+			return nil
+		},
+	}
+
+	a := New(ctx, r, w, msgCtxMock())
+
+	pack := senml.Pack{}
+	is.NoErr(json.Unmarshal([]byte(mixedTemperaturePack), &pack))
+
+	result, err := IngestPack(ctx, a, pack)
+	is.NoErr(err)
+
+	is.Equal(result.Stored, 1)
+	is.Equal(result.Skipped, 1)
+	is.Equal(result.Errors, 1)
+
+	status := map[string]string{}
+	for _, rr := range result.Records {
+		status[rr.Name] = rr.Status
+	}
+	is.Equal(status["c5a2ae17c239/3303/5700"], RecordStored)
+	is.Equal(status["c5a2ae17c239/3303/5701"], RecordSkipped)
+	is.Equal(status["c5a2ae17c239/3303/5601"], RecordError)
+}
+
+func TestCorrelationIDFlowsToPublishedThingUpdated(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	is := is.New(t)
+
+	room := things.NewRoom("room-001", things.DefaultLocation, "default")
+	room.AddDevice("c5a2ae17c239")
+
+	r := &ThingsReaderMock{
+		QueryThingsFunc: func(ctx context.Context, conditions ...ConditionFunc) (QueryResult, error) {
+			return QueryResult{Data: [][]byte{room.Byte()}}, nil
+		},
+	}
+
+	values := make(chan things.Value, 10)
+	w := &ThingsWriterMock{
+		AddValueFunc: func(ctx context.Context, t things.Thing, m things.Value) error {
+			values <- m
+			return nil
+		},
+		UpdateThingFunc: func(ctx context.Context, t things.Thing) error {
+			return nil
+		},
+	}
+
+	published := make(chan messaging.TopicMessage, 10)
+	m := &messaging.MsgContextMock{
+		PublishOnTopicFunc: func(ctx context.Context, message messaging.TopicMessage) error {
+			published <- message
+			return nil
+		},
+	}
+
+	a := New(ctx, r, w, m)
+	is.NoErr(a.LoadConfig(ctx, strings.NewReader("publisher:\n  window: 20ms\n")))
+
+	body := strings.TrimSuffix(temperatureMsg, "}") + `,"correlationId":"trace-001"}`
+	NewMeasurementsHandler(a, m)(ctx, msgMock(body), slog.Default())
+
+	v := <-values
+	is.Equal(v.CorrelationID, "trace-001")
+
+	select {
+	case msg := <-published:
+		updated, ok := msg.(*types.ThingUpdated)
+		is.True(ok)
+		is.Equal(updated.ID, "room-001")
+		is.Equal(updated.CorrelationID, "trace-001")
+	case <-ctx.Done():
+		t.Fatal("timed out waiting for message")
+	}
+}
+
+func TestCorrelationIDIsGeneratedWhenMissing(t *testing.T) {
+	ctx := context.Background()
+	is := is.New(t)
+
+	pack := senml.Pack{}
+	is.NoErr(json.Unmarshal([]byte(mixedTemperaturePack), &pack))
+
+	measurements, _, err := convPack(ctx, pack)
+	is.NoErr(err)
+	is.Equal(measurements[0].CorrelationID, "")
+
+	var ids []string
+	a := &ThingsAppMock{
+		HandleMeasurementsFunc: func(ctx context.Context, measurements []things.Measurement) IngestResult {
+			for _, m := range measurements {
+				ids = append(ids, m.CorrelationID)
+			}
+			return IngestResult{}
+		},
+	}
+
+	NewMeasurementsHandler(a, msgCtxMock())(ctx, msgMock(temperatureMsg), slog.Default())
+
+	is.True(len(ids) > 0)
+	is.True(ids[0] != "")
+	for _, id := range ids {
+		is.Equal(id, ids[0]) // all measurements in a message share the same id
+	}
+}
+
 func appMock(ctx context.Context, t things.Thing, store map[string]things.Thing, values map[string][]things.Value) ThingsApp {
 	store[t.ID()] = t
 
+	// measurements are handled by workers while the next ones are dispatched, so the maps are guarded
+	mu := sync.Mutex{}
+
 	r := &ThingsReaderMock{
 		QueryThingsFunc: func(ctx context.Context, conditions ...ConditionFunc) (QueryResult, error) {
+			mu.Lock()
+			defer mu.Unlock()
 			return QueryResult{
 				Data: [][]byte{store[t.ID()].Byte()},
 			}, nil
@@ -147,12 +407,16 @@ func appMock(ctx context.Context, t things.Thing, store map[string]things.Thing,
 	}
 	w := &ThingsWriterMock{
 		AddValueFunc: func(ctx context.Context, t things.Thing, m things.Value) error {
+			mu.Lock()
+			defer mu.Unlock()
 			if values != nil {
 				values[t.ID()] = append(values[t.ID()], m)
 			}
 			return nil
 		},
 		UpdateThingFunc: func(ctx context.Context, u things.Thing) error {
+			mu.Lock()
+			defer mu.Unlock()
 			if store != nil {
 				store[u.ID()] = u
 			}
@@ -190,7 +454,12 @@ func msgMock(body string) *messaging.IncomingTopicMessageMock {
 }
 
 var (
-	temperatureMsg  = `{"pack":[{"bn":"c5a2ae17c239/3303/","bt":1730124834,"n":"0","vs":"urn:oma:lwm2m:ext:3303"},{"n":"5700","u":"Cel","v":21},{"u":"lat","v":0},{"u":"lon","v":0},{"n":"tenant","vs":"default"}],"timestamp":"2024-10-28T14:13:54.532480028Z"}`
-	distanceMsg     = `{"pack":[{"bn":"9fb5801ebafc/3330/","bt":1730124849,"n":"0","vs":"urn:oma:lwm2m:ext:3330"},{"n":"5700","u":"m","v":2.51},{"n":"5701","vs":"metre"},{"u":"lat","v":62},{"u":"lon","v":17},{"n":"tenant","vs":"default"}],"timestamp":"2024-10-28T14:14:09.424249918Z"}`
-	digitalInputMsg = `{"pack":[{"bn":"ce3acc09ab62/3200/","bt":%d,"n":"0","vs":"urn:oma:lwm2m:ext:3200"},{"n":"5500","vb":%s},{"n":"5501","v":5},{"u":"lat","v":0},{"u":"lon","v":0},{"n":"tenant","vs":"default"}],"timestamp":"2024-10-29T01:40:34.003076718Z"}`
+	mixedTemperaturePack         = `[{"bn":"c5a2ae17c239/3303/","bt":1730124834,"n":"0","vs":"urn:oma:lwm2m:ext:3303"},{"n":"5700","u":"Cel","v":21},{"n":"5701","vs":"Cel"},{"n":"5601","u":"Cel","v":18}]`
+	temperatureMsg               = `{"pack":[{"bn":"c5a2ae17c239/3303/","bt":1730124834,"n":"0","vs":"urn:oma:lwm2m:ext:3303"},{"n":"5700","u":"Cel","v":21},{"u":"lat","v":0},{"u":"lon","v":0},{"n":"tenant","vs":"default"}],"timestamp":"2024-10-28T14:13:54.532480028Z"}`
+	estimatedTemperatureMsg      = `{"pack":[{"bn":"c5a2ae17c239/3303/","bt":1730124834,"n":"0","vs":"urn:oma:lwm2m:ext:3303"},{"n":"5700","u":"Cel","v":21},{"n":"quality","vs":"estimated"},{"n":"tenant","vs":"default"}],"timestamp":"2024-10-28T14:13:54.532480028Z"}`
+	fullyQualifiedTemperatureMsg = `{"pack":[{"bt":1730124834,"n":"c5a2ae17c239/3303/0","vs":"urn:oma:lwm2m:ext:3303"},{"n":"c5a2ae17c239/3303/5700","u":"Cel","v":21},{"n":"tenant","vs":"default"}],"timestamp":"2024-10-28T14:13:54.532480028Z"}`
+	fullyQualifiedPack           = `[{"bt":1730124834,"n":"c5a2ae17c239/3303/5700","u":"Cel","v":21},{"n":"c5a2ae17c239/3304/0/5700","u":"%RH","v":45},{"n":"tenant","vs":"default"}]`
+	temperatureWithLocationMsg   = `{"pack":[{"bn":"c5a2ae17c239/3303/","bt":1730124834,"n":"0","vs":"urn:oma:lwm2m:ext:3303"},{"n":"5700","u":"Cel","v":21},{"u":"lat","v":62.5},{"u":"lon","v":17.5},{"n":"tenant","vs":"default"}],"timestamp":"2024-10-28T14:13:54.532480028Z"}`
+	distanceMsg                  = `{"pack":[{"bn":"9fb5801ebafc/3330/","bt":1730124849,"n":"0","vs":"urn:oma:lwm2m:ext:3330"},{"n":"5700","u":"m","v":2.51},{"n":"5701","vs":"metre"},{"u":"lat","v":62},{"u":"lon","v":17},{"n":"tenant","vs":"default"}],"timestamp":"2024-10-28T14:14:09.424249918Z"}`
+	digitalInputMsg              = `{"pack":[{"bn":"ce3acc09ab62/3200/","bt":%d,"n":"0","vs":"urn:oma:lwm2m:ext:3200"},{"n":"5500","vb":%s},{"n":"5501","v":5},{"u":"lat","v":0},{"u":"lon","v":0},{"n":"tenant","vs":"default"}],"timestamp":"2024-10-29T01:40:34.003076718Z"}`
 )
@@ -0,0 +1,102 @@
+package iotthings
+
+import (
+	"context"
+	"errors"
+	"hash/fnv"
+
+	"github.com/diwise/iot-things/internal/app/iot-things/things"
+)
+
+// ingestConfig configures how measurements are handled
+type ingestConfig struct {
+	// Workers is the number of things handled concurrently (default 1). Measurements for the same thing
+	// are always handled by the same worker, one at a time and in the order they were received.
+	Workers int `json:"workers,omitempty" yaml:"workers,omitempty"`
+}
+
+const (
+	workerQueueSize int = 100
+	pubBufferSize   int = 100
+)
+
+var errStopped = errors.New("measurements are no longer handled")
+
+type ingestJob struct {
+	ctx     context.Context
+	thingID string
+	m       things.Measurement
+	done    chan<- ingestResult
+}
+
+type ingestResult struct {
+	thingID string
+	changed bool
+	err     error
+}
+
+func (a *app) ingestConfig() ingestConfig {
+	a.cfgMu.RLock()
+	defer a.cfgMu.RUnlock()
+
+	if a.cfg == nil {
+		return ingestConfig{}
+	}
+	return a.cfg.Ingest
+}
+
+// startWorkers starts the workers handling measurements, sized by the configuration when the first measurement is handled
+func (a *app) startWorkers() {
+	n := max(a.ingestConfig().Workers, 1)
+
+	a.workers = make([]chan ingestJob, n)
+	for i := range a.workers {
+		a.workers[i] = make(chan ingestJob, workerQueueSize)
+		go a.worker(a.workers[i])
+	}
+}
+
+// worker handles the jobs of its things until the app is stopped
+func (a *app) worker(jobs <-chan ingestJob) {
+	for {
+		select {
+		case <-a.done:
+			return
+		case job := <-jobs:
+			changed, err := a.handleThing(job.ctx, job.thingID, job.m)
+			job.done <- ingestResult{thingID: job.thingID, changed: changed, err: err}
+		}
+	}
+}
+
+// enqueue queues a measurement for a thing on the worker of the thing and returns a channel receiving the result
+func (a *app) enqueue(ctx context.Context, thingID string, m things.Measurement) <-chan ingestResult {
+	a.workersOnce.Do(a.startWorkers)
+
+	done := make(chan ingestResult, 1)
+
+	select {
+	case a.workers[workerIndex(thingID, len(a.workers))] <- ingestJob{ctx: ctx, thingID: thingID, m: m, done: done}:
+	case <-a.done:
+		done <- ingestResult{thingID: thingID, err: errStopped}
+	}
+
+	return done
+}
+
+// wait waits for the result of a job, or for the app to be stopped
+func (a *app) wait(done <-chan ingestResult) ingestResult {
+	select {
+	case r := <-done:
+		return r
+	case <-a.done:
+		return ingestResult{err: errStopped}
+	}
+}
+
+// workerIndex returns which of n workers handles a thing
+func workerIndex(thingID string, n int) int {
+	h := fnv.New32a()
+	h.Write([]byte(thingID))
+	return int(h.Sum32() % uint32(n))
+}
@@ -0,0 +1,86 @@
+package iotthings
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+type instruments struct {
+	measurementsHandled metric.Int64Counter
+	thingErrors         metric.Int64Counter
+	valuesWritten       metric.Int64Counter
+	thingsPublished     metric.Int64Counter
+	publishLag          metric.Float64Histogram
+}
+
+// current holds the instruments in use. The global meter provider only delegates to the first provider
+// set as global, so the provider metrics are exported from is given explicitly to InitMetrics.
+var current atomic.Pointer[instruments]
+
+func init() {
+	current.Store(newInstruments(otel.GetMeterProvider().Meter("iot-things")))
+}
+
+// InitMetrics creates the instruments of the app from the meter provider the metrics are exported from
+func InitMetrics(mp metric.MeterProvider) {
+	current.Store(newInstruments(mp.Meter("iot-things")))
+}
+
+func newInstruments(meter metric.Meter) *instruments {
+	i := &instruments{}
+
+	i.measurementsHandled, _ = meter.Int64Counter(
+		"things.measurements.handled",
+		metric.WithDescription("number of handled measurements, by status (stored, skipped or error)"),
+	)
+
+	i.thingErrors, _ = meter.Int64Counter(
+		"things.handle.errors",
+		metric.WithDescription("number of connected things that failed to handle a measurement"),
+	)
+
+	i.valuesWritten, _ = meter.Int64Counter(
+		"things.values.written",
+		metric.WithDescription("number of values written to storage, by status (ok or error)"),
+	)
+
+	i.thingsPublished, _ = meter.Int64Counter(
+		"things.published",
+		metric.WithDescription("number of published updates of things, by status (ok or error)"),
+	)
+
+	i.publishLag, _ = meter.Float64Histogram(
+		"things.publish.lag",
+		metric.WithDescription("time from a thing being changed until the change is published"),
+		metric.WithUnit("s"),
+	)
+
+	return i
+}
+
+func meters() *instruments {
+	return current.Load()
+}
+
+func withStatus(status string) metric.MeasurementOption {
+	return metric.WithAttributes(attribute.String("status", status))
+}
+
+func statusOf(err error) metric.MeasurementOption {
+	if err != nil {
+		return withStatus("error")
+	}
+	return withStatus("ok")
+}
+
+func observePublished(ctx context.Context, changedAt time.Time, err error) {
+	meters().thingsPublished.Add(ctx, 1, statusOf(err))
+	if err == nil && !changedAt.IsZero() {
+		meters().publishLag.Record(ctx, time.Since(changedAt).Seconds())
+	}
+}
@@ -6,6 +6,7 @@ package iotthings
 import (
 	"context"
 	"sync"
+	"time"
 )
 
 // Ensure, that ThingsReaderMock does implement ThingsReader.
@@ -18,9 +19,24 @@ var _ ThingsReader = &ThingsReaderMock{}
 //
 //		// make and configure a mocked ThingsReader
 //		mockedThingsReader := &ThingsReaderMock{
+//			CountByTenantFunc: func(ctx context.Context) ([]TenantCount, error) {
+//				panic("mock out the CountByTenant method")
+//			},
+//			CountThingsFunc: func(ctx context.Context, tenants []string) ([]ThingCount, error) {
+//				panic("mock out the CountThings method")
+//			},
 //			GetTagsFunc: func(ctx context.Context, tenants []string) ([]string, error) {
 //				panic("mock out the GetTags method")
 //			},
+//			GetUrnsFunc: func(ctx context.Context, thingID string) ([]string, error) {
+//				panic("mock out the GetUrns method")
+//			},
+//			GetValueBucketsFunc: func(ctx context.Context, thingID string, from time.Time, to time.Time, interval time.Duration) ([]time.Time, error) {
+//				panic("mock out the GetValueBuckets method")
+//			},
+//			GetValueRangeFunc: func(ctx context.Context, thingID string) (ValueRange, error) {
+//				panic("mock out the GetValueRange method")
+//			},
 //			QueryThingsFunc: func(ctx context.Context, conditions ...ConditionFunc) (QueryResult, error) {
 //				panic("mock out the QueryThings method")
 //			},
@@ -34,9 +50,24 @@ var _ ThingsReader = &ThingsReaderMock{}
 //
 //	}
 type ThingsReaderMock struct {
+	// CountByTenantFunc mocks the CountByTenant method.
+	CountByTenantFunc func(ctx context.Context) ([]TenantCount, error)
+
+	// CountThingsFunc mocks the CountThings method.
+	CountThingsFunc func(ctx context.Context, tenants []string) ([]ThingCount, error)
+
 	// GetTagsFunc mocks the GetTags method.
 	GetTagsFunc func(ctx context.Context, tenants []string) ([]string, error)
 
+	// GetUrnsFunc mocks the GetUrns method.
+	GetUrnsFunc func(ctx context.Context, thingID string) ([]string, error)
+
+	// GetValueBucketsFunc mocks the GetValueBuckets method.
+	GetValueBucketsFunc func(ctx context.Context, thingID string, from time.Time, to time.Time, interval time.Duration) ([]time.Time, error)
+
+	// GetValueRangeFunc mocks the GetValueRange method.
+	GetValueRangeFunc func(ctx context.Context, thingID string) (ValueRange, error)
+
 	// QueryThingsFunc mocks the QueryThings method.
 	QueryThingsFunc func(ctx context.Context, conditions ...ConditionFunc) (QueryResult, error)
 
@@ -45,6 +76,18 @@ type ThingsReaderMock struct {
 
 	// calls tracks calls to the methods.
 	calls struct {
+		// CountByTenant holds details about calls to the CountByTenant method.
+		CountByTenant []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+		}
+		// CountThings holds details about calls to the CountThings method.
+		CountThings []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// Tenants is the tenants argument value.
+			Tenants []string
+		}
 		// GetTags holds details about calls to the GetTags method.
 		GetTags []struct {
 			// Ctx is the ctx argument value.
@@ -52,6 +95,33 @@ type ThingsReaderMock struct {
 			// Tenants is the tenants argument value.
 			Tenants []string
 		}
+		// GetUrns holds details about calls to the GetUrns method.
+		GetUrns []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// ThingID is the thingID argument value.
+			ThingID string
+		}
+		// GetValueBuckets holds details about calls to the GetValueBuckets method.
+		GetValueBuckets []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// ThingID is the thingID argument value.
+			ThingID string
+			// From is the from argument value.
+			From time.Time
+			// To is the to argument value.
+			To time.Time
+			// Interval is the interval argument value.
+			Interval time.Duration
+		}
+		// GetValueRange holds details about calls to the GetValueRange method.
+		GetValueRange []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// ThingID is the thingID argument value.
+			ThingID string
+		}
 		// QueryThings holds details about calls to the QueryThings method.
 		QueryThings []struct {
 			// Ctx is the ctx argument value.
@@ -67,9 +137,82 @@ type ThingsReaderMock struct {
 			Conditions []ConditionFunc
 		}
 	}
-	lockGetTags     sync.RWMutex
-	lockQueryThings sync.RWMutex
-	lockQueryValues sync.RWMutex
+	lockCountByTenant   sync.RWMutex
+	lockCountThings     sync.RWMutex
+	lockGetTags         sync.RWMutex
+	lockGetUrns         sync.RWMutex
+	lockGetValueBuckets sync.RWMutex
+	lockGetValueRange   sync.RWMutex
+	lockQueryThings     sync.RWMutex
+	lockQueryValues     sync.RWMutex
+}
+
+// CountByTenant calls CountByTenantFunc.
+func (mock *ThingsReaderMock) CountByTenant(ctx context.Context) ([]TenantCount, error) {
+	if mock.CountByTenantFunc == nil {
+		panic("ThingsReaderMock.CountByTenantFunc: method is nil but ThingsReader.CountByTenant was just called")
+	}
+	callInfo := struct {
+		Ctx context.Context
+	}{
+		Ctx: ctx,
+	}
+	mock.lockCountByTenant.Lock()
+	mock.calls.CountByTenant = append(mock.calls.CountByTenant, callInfo)
+	mock.lockCountByTenant.Unlock()
+	return mock.CountByTenantFunc(ctx)
+}
+
+// CountByTenantCalls gets all the calls that were made to CountByTenant.
+// Check the length with:
+//
+//	len(mockedThingsReader.CountByTenantCalls())
+func (mock *ThingsReaderMock) CountByTenantCalls() []struct {
+	Ctx context.Context
+} {
+	var calls []struct {
+		Ctx context.Context
+	}
+	mock.lockCountByTenant.RLock()
+	calls = mock.calls.CountByTenant
+	mock.lockCountByTenant.RUnlock()
+	return calls
+}
+
+// CountThings calls CountThingsFunc.
+func (mock *ThingsReaderMock) CountThings(ctx context.Context, tenants []string) ([]ThingCount, error) {
+	if mock.CountThingsFunc == nil {
+		panic("ThingsReaderMock.CountThingsFunc: method is nil but ThingsReader.CountThings was just called")
+	}
+	callInfo := struct {
+		Ctx     context.Context
+		Tenants []string
+	}{
+		Ctx:     ctx,
+		Tenants: tenants,
+	}
+	mock.lockCountThings.Lock()
+	mock.calls.CountThings = append(mock.calls.CountThings, callInfo)
+	mock.lockCountThings.Unlock()
+	return mock.CountThingsFunc(ctx, tenants)
+}
+
+// CountThingsCalls gets all the calls that were made to CountThings.
+// Check the length with:
+//
+//	len(mockedThingsReader.CountThingsCalls())
+func (mock *ThingsReaderMock) CountThingsCalls() []struct {
+	Ctx     context.Context
+	Tenants []string
+} {
+	var calls []struct {
+		Ctx     context.Context
+		Tenants []string
+	}
+	mock.lockCountThings.RLock()
+	calls = mock.calls.CountThings
+	mock.lockCountThings.RUnlock()
+	return calls
 }
 
 // GetTags calls GetTagsFunc.
@@ -108,6 +251,126 @@ func (mock *ThingsReaderMock) GetTagsCalls() []struct {
 	return calls
 }
 
+// GetUrns calls GetUrnsFunc.
+func (mock *ThingsReaderMock) GetUrns(ctx context.Context, thingID string) ([]string, error) {
+	if mock.GetUrnsFunc == nil {
+		panic("ThingsReaderMock.GetUrnsFunc: method is nil but ThingsReader.GetUrns was just called")
+	}
+	callInfo := struct {
+		Ctx     context.Context
+		ThingID string
+	}{
+		Ctx:     ctx,
+		ThingID: thingID,
+	}
+	mock.lockGetUrns.Lock()
+	mock.calls.GetUrns = append(mock.calls.GetUrns, callInfo)
+	mock.lockGetUrns.Unlock()
+	return mock.GetUrnsFunc(ctx, thingID)
+}
+
+// GetUrnsCalls gets all the calls that were made to GetUrns.
+// Check the length with:
+//
+//	len(mockedThingsReader.GetUrnsCalls())
+func (mock *ThingsReaderMock) GetUrnsCalls() []struct {
+	Ctx     context.Context
+	ThingID string
+} {
+	var calls []struct {
+		Ctx     context.Context
+		ThingID string
+	}
+	mock.lockGetUrns.RLock()
+	calls = mock.calls.GetUrns
+	mock.lockGetUrns.RUnlock()
+	return calls
+}
+
+// GetValueBuckets calls GetValueBucketsFunc.
+func (mock *ThingsReaderMock) GetValueBuckets(ctx context.Context, thingID string, from time.Time, to time.Time, interval time.Duration) ([]time.Time, error) {
+	if mock.GetValueBucketsFunc == nil {
+		panic("ThingsReaderMock.GetValueBucketsFunc: method is nil but ThingsReader.GetValueBuckets was just called")
+	}
+	callInfo := struct {
+		Ctx      context.Context
+		ThingID  string
+		From     time.Time
+		To       time.Time
+		Interval time.Duration
+	}{
+		Ctx:      ctx,
+		ThingID:  thingID,
+		From:     from,
+		To:       to,
+		Interval: interval,
+	}
+	mock.lockGetValueBuckets.Lock()
+	mock.calls.GetValueBuckets = append(mock.calls.GetValueBuckets, callInfo)
+	mock.lockGetValueBuckets.Unlock()
+	return mock.GetValueBucketsFunc(ctx, thingID, from, to, interval)
+}
+
+// GetValueBucketsCalls gets all the calls that were made to GetValueBuckets.
+// Check the length with:
+//
+//	len(mockedThingsReader.GetValueBucketsCalls())
+func (mock *ThingsReaderMock) GetValueBucketsCalls() []struct {
+	Ctx      context.Context
+	ThingID  string
+	From     time.Time
+	To       time.Time
+	Interval time.Duration
+} {
+	var calls []struct {
+		Ctx      context.Context
+		ThingID  string
+		From     time.Time
+		To       time.Time
+		Interval time.Duration
+	}
+	mock.lockGetValueBuckets.RLock()
+	calls = mock.calls.GetValueBuckets
+	mock.lockGetValueBuckets.RUnlock()
+	return calls
+}
+
+// GetValueRange calls GetValueRangeFunc.
+func (mock *ThingsReaderMock) GetValueRange(ctx context.Context, thingID string) (ValueRange, error) {
+	if mock.GetValueRangeFunc == nil {
+		panic("ThingsReaderMock.GetValueRangeFunc: method is nil but ThingsReader.GetValueRange was just called")
+	}
+	callInfo := struct {
+		Ctx     context.Context
+		ThingID string
+	}{
+		Ctx:     ctx,
+		ThingID: thingID,
+	}
+	mock.lockGetValueRange.Lock()
+	mock.calls.GetValueRange = append(mock.calls.GetValueRange, callInfo)
+	mock.lockGetValueRange.Unlock()
+	return mock.GetValueRangeFunc(ctx, thingID)
+}
+
+// GetValueRangeCalls gets all the calls that were made to GetValueRange.
+// Check the length with:
+//
+//	len(mockedThingsReader.GetValueRangeCalls())
+func (mock *ThingsReaderMock) GetValueRangeCalls() []struct {
+	Ctx     context.Context
+	ThingID string
+} {
+	var calls []struct {
+		Ctx     context.Context
+		ThingID string
+	}
+	mock.lockGetValueRange.RLock()
+	calls = mock.calls.GetValueRange
+	mock.lockGetValueRange.RUnlock()
+	return calls
+}
+
 // QueryThings calls QueryThingsFunc.
 func (mock *ThingsReaderMock) QueryThings(ctx context.Context, conditions ...ConditionFunc) (QueryResult, error) {
 	if mock.QueryThingsFunc == nil {
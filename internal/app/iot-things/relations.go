@@ -0,0 +1,132 @@
+package iotthings
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/diwise/iot-things/internal/app/iot-things/things"
+)
+
+// RelationRequest is the body of a request to add a child to a thing
+type RelationRequest struct {
+	ID string `json:"id"`
+}
+
+// AddRelatedThing adds the thing given in b as a child of thingID, e.g. a Room to the Building containing
+// it. The relation is stored on both things, and removed from the other thing when one of them is deleted. Both things must belong to the same tenant, a thing cannot be
+// related to itself and a thing cannot become a child of one of its own children.
+func (a *app) AddRelatedThing(ctx context.Context, thingID string, b []byte, tenants []string) error {
+	if len(tenants) == 0 {
+		return ErrMissingThingTenant
+	}
+
+	req := RelationRequest{}
+	err := json.Unmarshal(b, &req)
+	if err != nil {
+		return err
+	}
+	if req.ID == "" {
+		return ErrMissingThingID
+	}
+	if req.ID == thingID {
+		return fmt.Errorf("%w: a thing cannot be related to itself", ErrInvalidRelation)
+	}
+
+	parent, err := a.getThing(ctx, thingID, tenants)
+	if err != nil {
+		return err
+	}
+	child, err := a.getThing(ctx, req.ID, tenants)
+	if err != nil {
+		return err
+	}
+
+	if parent.Tenant() != child.Tenant() {
+		return fmt.Errorf("%w: %s and %s belong to different tenants", ErrInvalidRelation, thingID, req.ID)
+	}
+
+	ancestor, err := a.isAncestor(ctx, child.ID(), parent, tenants)
+	if err != nil {
+		return err
+	}
+	if ancestor {
+		return fmt.Errorf("%w: %s is already a parent of %s", ErrInvalidRelation, req.ID, thingID)
+	}
+
+	return a.writer.AddRelations(ctx, map[string]things.Relation{
+		parent.ID(): {ID: child.ID(), Type: child.Type(), Relation: things.RelationChild},
+		child.ID():  {ID: parent.ID(), Type: parent.Type(), Relation: things.RelationParent},
+	})
+}
+
+// GetRelatedThings returns the parents and children of a thing
+func (a *app) GetRelatedThings(ctx context.Context, thingID string, tenants []string) ([]things.Relation, error) {
+	if len(tenants) == 0 {
+		return nil, ErrMissingThingTenant
+	}
+
+	t, err := a.getThing(ctx, thingID, tenants)
+	if err != nil {
+		return nil, err
+	}
+
+	relations := t.Relations()
+	if relations == nil {
+		relations = []things.Relation{}
+	}
+
+	return relations, nil
+}
+
+// isAncestor reports whether thingID is a parent of t, or a parent of any of its parents
+func (a *app) isAncestor(ctx context.Context, thingID string, t things.Thing, tenants []string) (bool, error) {
+	visited := []string{t.ID()}
+	queue := []things.Thing{t}
+
+	for len(queue) > 0 {
+		current := queue[0]
+		queue = queue[1:]
+
+		for _, r := range current.Relations() {
+			if r.Relation != things.RelationParent || slices.Contains(visited, r.ID) {
+				continue
+			}
+			if r.ID == thingID {
+				return true, nil
+			}
+			visited = append(visited, r.ID)
+
+			parent, err := a.getThing(ctx, r.ID, tenants)
+			if errors.Is(err, ErrThingNotFound) {
+				continue // the parent has been deleted
+			}
+			if err != nil {
+				return false, err
+			}
+			queue = append(queue, parent)
+		}
+	}
+
+	return false, nil
+}
+
+// withoutRelations removes the relations from a thing given by a client. Relations are only added by
+// AddRelatedThing, and the stored relations are kept when a thing is updated.
+func withoutRelations(b []byte) ([]byte, error) {
+	m := make(map[string]any)
+	err := json.Unmarshal(b, &m)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, ok := m["relations"]; !ok {
+		return b, nil
+	}
+
+	delete(m, "relations")
+
+	return json.Marshal(m)
+}
@@ -0,0 +1,100 @@
+package iotthings
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/diwise/iot-things/internal/app/iot-things/things"
+	"github.com/matryer/is"
+)
+
+func TestAddRelatedThing(t *testing.T) {
+	ctx := context.Background()
+	is := is.New(t)
+
+	stored := map[string]things.Thing{}
+	for _, thing := range []things.Thing{
+		things.NewBuilding("building-001", things.DefaultLocation, "default"),
+		things.NewRoom("room-001", things.DefaultLocation, "default"),
+		things.NewRoom("room-002", things.DefaultLocation, "default"),
+		things.NewRoom("room-003", things.DefaultLocation, "other"),
+	} {
+		stored[thing.ID()] = thing
+	}
+
+	r := &ThingsReaderMock{
+		QueryThingsFunc: func(ctx context.Context, conditions ...ConditionFunc) (QueryResult, error) {
+			c := newConditions(conditions...)
+			if thing, ok := stored[c["id"].(string)]; ok && slices.Contains(c["tenants"].([]string), thing.Tenant()) {
+				return QueryResult{Data: [][]byte{thing.Byte()}}, nil
+			}
+			return QueryResult{}, nil
+		},
+	}
+	w := &ThingsWriterMock{
+		AddRelationsFunc: func(ctx context.Context, relations map[string]things.Relation) error {
+			for thingID, r := range relations {
+				stored[thingID].AddRelation(r)
+			}
+			return nil
+		},
+	}
+
+	app := New(ctx, r, w, msgCtxMock())
+	tenants := []string{"default", "other"}
+
+	is.NoErr(app.AddRelatedThing(ctx, "building-001", []byte(`{"id":"room-001"}`), tenants))
+	is.NoErr(app.AddRelatedThing(ctx, "room-001", []byte(`{"id":"room-002"}`), tenants))
+
+	relations, err := app.GetRelatedThings(ctx, "room-001", tenants)
+	is.NoErr(err)
+	is.Equal(relations, []things.Relation{
+		{ID: "building-001", Type: "Building", Relation: things.RelationParent},
+		{ID: "room-002", Type: "Room", Relation: things.RelationChild},
+	})
+
+	err = app.AddRelatedThing(ctx, "room-002", []byte(`{"id":"building-001"}`), tenants)
+	is.True(errors.Is(err, ErrInvalidRelation)) // a cycle through room-001
+
+	err = app.AddRelatedThing(ctx, "room-001", []byte(`{"id":"room-001"}`), tenants)
+	is.True(errors.Is(err, ErrInvalidRelation))
+
+	err = app.AddRelatedThing(ctx, "building-001", []byte(`{"id":"room-003"}`), tenants)
+	is.True(errors.Is(err, ErrInvalidRelation)) // different tenants
+
+	err = app.AddRelatedThing(ctx, "building-001", []byte(`{"id":"room-003"}`), []string{"default"})
+	is.True(errors.Is(err, ErrThingNotFound))
+
+	is.Equal(len(w.AddRelationsCalls()), 2)
+}
+
+func TestRelationsAreNotUpdatedByClients(t *testing.T) {
+	ctx := context.Background()
+	is := is.New(t)
+
+	room := things.NewRoom("room-001", things.DefaultLocation, "default")
+	room.AddRelation(things.Relation{ID: "building-001", Type: "Building", Relation: things.RelationParent})
+
+	r := &ThingsReaderMock{
+		QueryThingsFunc: func(ctx context.Context, conditions ...ConditionFunc) (QueryResult, error) {
+			return QueryResult{Data: [][]byte{room.Byte()}, Count: 1}, nil
+		},
+	}
+	w := &ThingsWriterMock{
+		UpdateThingFunc: func(ctx context.Context, t things.Thing) error {
+			return nil
+		},
+	}
+
+	app := New(ctx, r, w, msgCtxMock())
+	tenants := []string{"default"}
+
+	body := []byte(`{"id":"room-001","type":"Room","tenant":"default","relations":[{"id":"building-002","type":"Building","relation":"parent"}]}`)
+	is.NoErr(app.UpdateThing(ctx, body, tenants))
+	is.Equal(len(w.UpdateThingCalls()[0].T.Relations()), 0)
+
+	is.NoErr(app.MergeThing(ctx, "room-001", []byte(`{"name":"Room 1","relations":[]}`), tenants))
+	is.Equal(w.UpdateThingCalls()[1].T.Relations(), room.Relations())
+}
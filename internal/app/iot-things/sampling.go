@@ -0,0 +1,121 @@
+package iotthings
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/diwise/iot-things/internal/app/iot-things/things"
+)
+
+// samplingRule thins the values stored for high frequency sensors. Only every Nth value, and/or
+// values at least MinInterval apart, are stored. Derived thing state is still updated from every reading.
+type samplingRule struct {
+	Urn         string        `json:"urn,omitempty" yaml:"urn,omitempty"`     // applies to all urns if empty
+	Type        string        `json:"type,omitempty" yaml:"type,omitempty"`   // applies to all types if empty
+	Thing       string        `json:"thing,omitempty" yaml:"thing,omitempty"` // applies to all things if empty
+	Every       int           `json:"every,omitempty" yaml:"every,omitempty"`
+	MinInterval time.Duration `json:"minInterval,omitempty" yaml:"minInterval,omitempty"`
+}
+
+func (r samplingRule) applies(t things.Thing, v things.Value) bool {
+	return (r.Urn == "" || r.Urn == v.Urn) &&
+		(r.Type == "" || strings.EqualFold(r.Type, t.Type())) &&
+		(r.Thing == "" || r.Thing == t.ID())
+}
+
+type sampleState struct {
+	seen     int
+	stored   time.Time
+	lastSeen time.Time     // when the value was last seen, by the clock of the sampler
+	keep     time.Duration // how long the state is kept after the value was last seen
+}
+
+const (
+	// defaultSampleRetention is how long the state of a value sampled by a rule without MinInterval is kept
+	defaultSampleRetention time.Duration = time.Hour
+	samplePruneInterval    time.Duration = time.Minute
+)
+
+// sampler keeps track of the values seen and stored per value id. The state of values that are no longer
+// seen is evicted once it no longer affects sampling, or after defaultSampleRetention for rules counting values.
+type sampler struct {
+	mu     sync.Mutex
+	values map[string]*sampleState
+	pruned time.Time
+	now    func() time.Time
+}
+
+func newSampler() *sampler {
+	return &sampler{
+		values: map[string]*sampleState{},
+		now:    time.Now,
+	}
+}
+
+func (a *app) samplingRules() []samplingRule {
+	a.cfgMu.RLock()
+	defer a.cfgMu.RUnlock()
+
+	if a.cfg == nil {
+		return nil
+	}
+	return a.cfg.Values.Sampling
+}
+
+// sample returns false if the value should not be stored according to the first matching sampling rule
+func (a *app) sample(t things.Thing, v things.Value) bool {
+	for _, r := range a.samplingRules() {
+		if r.applies(t, v) {
+			return a.sampler.sample(r, v)
+		}
+	}
+	return true
+}
+
+func (s *sampler) sample(r samplingRule, v things.Value) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	if now.Sub(s.pruned) >= samplePruneInterval {
+		s.prune(now)
+	}
+
+	state, ok := s.values[v.ID]
+	if !ok {
+		state = &sampleState{}
+		s.values[v.ID] = state
+	}
+
+	state.lastSeen = now
+	state.keep = defaultSampleRetention
+	if r.MinInterval > 0 {
+		state.keep = r.MinInterval
+	}
+
+	n := state.seen
+	state.seen++
+
+	if r.Every > 1 && n%r.Every != 0 {
+		return false
+	}
+
+	if r.MinInterval > 0 && !state.stored.IsZero() && v.Timestamp.Sub(state.stored) < r.MinInterval {
+		return false
+	}
+
+	state.stored = v.Timestamp
+
+	return true
+}
+
+// prune evicts the state of values not seen for longer than it is kept
+func (s *sampler) prune(now time.Time) {
+	for id, state := range s.values {
+		if now.Sub(state.lastSeen) > state.keep {
+			delete(s.values, id)
+		}
+	}
+	s.pruned = now
+}
@@ -0,0 +1,115 @@
+package iotthings
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/diwise/iot-things/internal/app/iot-things/things"
+	"github.com/matryer/is"
+)
+
+func TestSamplingThinsStoredValues(t *testing.T) {
+	ctx := context.Background()
+	is := is.New(t)
+
+	room := things.NewRoom("room-001", things.DefaultLocation, "default")
+	room.AddDevice("c5a2ae17c239")
+
+	stored := []things.Value{}
+
+	r := &ThingsReaderMock{
+		QueryThingsFunc: func(ctx context.Context, conditions ...ConditionFunc) (QueryResult, error) {
+			return QueryResult{Data: [][]byte{room.Byte()}}, nil
+		},
+	}
+	w := &ThingsWriterMock{
+		AddValueFunc: func(ctx context.Context, t things.Thing, m things.Value) error {
+			stored = append(stored, m)
+			return nil
+		},
+		UpdateThingFunc: func(ctx context.Context, t things.Thing) error {
+			room = t
+			return nil
+		},
+	}
+
+	a := New(ctx, r, w, msgCtxMock()).(*app)
+	err := a.LoadConfig(ctx, strings.NewReader(`
+values:
+  sampling:
+    - urn: urn:oma:lwm2m:ext:3303
+      type: Room
+      every: 5
+`))
+	is.NoErr(err)
+
+	ts := time.Now().Add(-1 * time.Hour)
+
+	for i := range 10 {
+		v := 20.0 + float64(i)
+		_, rr := a.handle(ctx, things.Measurement{ID: "c5a2ae17c239/3303/5700", Urn: things.TemperatureURN, Value: &v, Unit: "Cel", Timestamp: ts.Add(time.Duration(i) * time.Second)})
+		is.Equal(rr.Status, RecordStored)
+	}
+
+	// every fifth value is stored
+	is.Equal(len(stored), 2)
+	is.Equal(*stored[0].Value, 20.0)
+	is.Equal(*stored[1].Value, 25.0)
+
+	// but the derived state follows every reading, not only the stored ones
+	is.True(room.(*things.Room).Temperature > 28.0)
+}
+
+func TestSamplingByMinInterval(t *testing.T) {
+	is := is.New(t)
+
+	s := newSampler()
+	r := samplingRule{MinInterval: time.Minute}
+
+	ts := time.Now()
+	stored := 0
+
+	// a value every 10 seconds for 5 minutes
+	for i := range 30 {
+		v := things.NewTemperature("room-001", "device", 20, ts.Add(time.Duration(i)*10*time.Second)).Value
+		if s.sample(r, v) {
+			stored++
+		}
+	}
+
+	is.Equal(stored, 5)
+
+	// values of other things and urns are sampled on their own
+	is.True(s.sample(r, things.NewHumidity("room-001", "device", 50, ts).Value))
+	is.True(s.sample(r, things.NewTemperature("room-002", "device", 20, ts).Value))
+
+	rule := samplingRule{Urn: things.TemperatureURN, Type: "Room"}
+	is.True(rule.applies(things.NewRoom("room-001", things.DefaultLocation, "default"), things.NewTemperature("room-001", "device", 20, ts).Value))
+	is.True(!rule.applies(things.NewBuilding("building-001", things.DefaultLocation, "default"), things.NewTemperature("building-001", "device", 20, ts).Value))
+	is.True(!rule.applies(things.NewRoom("room-001", things.DefaultLocation, "default"), things.NewHumidity("room-001", "device", 50, ts).Value))
+}
+
+func TestSamplerEvictsValuesNoLongerSeen(t *testing.T) {
+	is := is.New(t)
+
+	now := time.Now()
+
+	s := newSampler()
+	s.now = func() time.Time { return now }
+
+	ts := time.Now()
+	is.True(s.sample(samplingRule{MinInterval: time.Minute}, things.NewTemperature("room-001", "device", 20, ts).Value))
+	is.True(s.sample(samplingRule{Every: 5}, things.NewTemperature("room-002", "device", 20, ts).Value))
+	is.Equal(len(s.values), 2)
+
+	// the state of a value sampled by interval is kept for the interval, a counted value for longer
+	now = now.Add(2 * time.Minute)
+	is.True(!s.sample(samplingRule{Every: 5}, things.NewTemperature("room-002", "device", 20, ts).Value))
+	is.Equal(len(s.values), 1)
+
+	now = now.Add(defaultSampleRetention + time.Minute)
+	is.True(s.sample(samplingRule{MinInterval: time.Minute}, things.NewTemperature("room-001", "device", 20, ts).Value))
+	is.Equal(len(s.values), 1)
+}
@@ -0,0 +1,111 @@
+package iotthings
+
+import (
+	"encoding/json"
+	"strings"
+
+	"github.com/diwise/iot-things/internal/app/iot-things/things"
+)
+
+// tagRule tags things while a field of their state matches, e.g. "overflowing" while a sewer
+// has overflowObserved == true. The tag is removed when the field no longer matches.
+type tagRule struct {
+	Type     string `json:"type,omitempty" yaml:"type,omitempty"` // applies to all types if empty
+	Tag      string `json:"tag" yaml:"tag"`
+	Field    string `json:"field" yaml:"field"`
+	Operator string `json:"operator" yaml:"operator"` // eq (default), ne, gt or lt
+	Value    any    `json:"value" yaml:"value"`
+}
+
+func (a *app) tagRules() []tagRule {
+	a.cfgMu.RLock()
+	defer a.cfgMu.RUnlock()
+
+	if a.cfg == nil {
+		return nil
+	}
+	return a.cfg.TagRules
+}
+
+// applyTagRules adds or removes the tags of the rules matching the type of t
+func (a *app) applyTagRules(t things.Thing) {
+	rules := a.tagRules()
+	if len(rules) == 0 {
+		return
+	}
+
+	state := make(map[string]any)
+	err := json.Unmarshal(t.Byte(), &state)
+	if err != nil {
+		return
+	}
+
+	for _, r := range rules {
+		if r.Type != "" && !strings.EqualFold(r.Type, t.Type()) {
+			continue
+		}
+
+		if r.matches(state) {
+			t.AddTag(r.Tag)
+		} else {
+			t.RemoveTag(r.Tag)
+		}
+	}
+}
+
+func (r tagRule) matches(state map[string]any) bool {
+	v, ok := state[r.Field]
+	if !ok || v == nil {
+		return false
+	}
+
+	switch r.Operator {
+	case "", "eq":
+		return equals(v, r.Value)
+	case "ne":
+		return !equals(v, r.Value)
+	case "gt", "lt":
+		a, aok := toFloat(v)
+		b, bok := toFloat(r.Value)
+		if !aok || !bok {
+			return false
+		}
+		if r.Operator == "gt" {
+			return a > b
+		}
+		return a < b
+	}
+
+	return false
+}
+
+func equals(a, b any) bool {
+	af, aok := toFloat(a)
+	bf, bok := toFloat(b)
+	if aok && bok {
+		return af == bf
+	}
+
+	switch av := a.(type) {
+	case bool:
+		bv, ok := b.(bool)
+		return ok && av == bv
+	case string:
+		bv, ok := b.(string)
+		return ok && av == bv
+	}
+
+	return false
+}
+
+func toFloat(v any) (float64, bool) {
+	switch n := v.(type) {
+	case float64:
+		return n, true
+	case int:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	}
+	return 0, false
+}
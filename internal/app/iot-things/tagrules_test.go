@@ -0,0 +1,69 @@
+package iotthings
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/diwise/iot-things/internal/app/iot-things/things"
+	"github.com/matryer/is"
+)
+
+func TestTagRulesOnStateTransitions(t *testing.T) {
+	ctx := context.Background()
+	is := is.New(t)
+
+	sewer := things.NewSewer("sewer-001", things.DefaultLocation, "default")
+	sewer.AddDevice("ce3acc09ab62")
+	sewer.AddTag("manual")
+
+	s := map[string]things.Thing{}
+	a := appMock(ctx, sewer, s, nil)
+
+	yamlConfig := `
+tagRules:
+  - type: Sewer
+    tag: overflowing
+    field: overflowObserved
+    value: true
+  - type: Room
+    tag: warm
+    field: temperature
+    operator: gt
+    value: 25
+`
+	err := a.LoadConfig(ctx, strings.NewReader(yamlConfig))
+	is.NoErr(err)
+
+	tags := func() []string {
+		return s[sewer.ID()].(*things.Sewer).Tags
+	}
+
+	h := NewMeasurementsHandler(a, msgCtxMock())
+	now := time.Now()
+
+	h(ctx, msgMock(fmt.Sprintf(digitalInputMsg, now.Unix(), "true")), slog.Default())
+	is.True(slices.Contains(tags(), "overflowing"))
+	is.True(!slices.Contains(tags(), "warm")) // rules for other types do not apply
+
+	h(ctx, msgMock(fmt.Sprintf(digitalInputMsg, now.Add(1*time.Minute).Unix(), "false")), slog.Default())
+	is.True(!slices.Contains(tags(), "overflowing"))
+	is.True(slices.Contains(tags(), "manual")) // tags not covered by rules are kept
+}
+
+func TestTagRuleMatches(t *testing.T) {
+	is := is.New(t)
+
+	state := map[string]any{"temperature": 21.5, "overflowObserved": false, "refDevices": []any{}}
+
+	is.True(tagRule{Field: "temperature", Operator: "gt", Value: 20}.matches(state))
+	is.True(!tagRule{Field: "temperature", Operator: "lt", Value: 20}.matches(state))
+	is.True(tagRule{Field: "overflowObserved", Value: false}.matches(state))
+	is.True(tagRule{Field: "overflowObserved", Operator: "ne", Value: true}.matches(state))
+	is.True(!tagRule{Field: "refDevices", Value: []any{}}.matches(state))
+	is.True(!tagRule{Field: "missing", Value: 1}.matches(state))
+}
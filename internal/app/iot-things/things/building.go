@@ -34,7 +34,7 @@ func (building *Building) handle(m Measurement, onchange func(m ValueProvider) e
 		previousValue := building.Energy
 		value := *m.Value / 3600000.0 // convert from Joule to kWh
 
-		if hasChanged(previousValue, value) {
+		if building.hasValueChanged(m.Urn, previousValue, value) {
 			building.Energy = value
 			energy := NewEnergy(building.ID(), m.ID, building.Energy, m.Timestamp)
 			return onchange(energy)
@@ -45,7 +45,7 @@ func (building *Building) handle(m Measurement, onchange func(m ValueProvider) e
 		previousValue := building.Power
 		value := *m.Value / 1000.0 // convert from Watt to kW
 
-		if hasChanged(previousValue, value) {
+		if building.hasValueChanged(m.Urn, previousValue, value) {
 			building.Power = value
 			power := NewPower(building.ID(), m.ID, building.Power, m.Timestamp)
 			return onchange(power)
@@ -53,7 +53,7 @@ func (building *Building) handle(m Measurement, onchange func(m ValueProvider) e
 	}
 
 	if hasTemperature(&m) {
-		if !hasChanged(building.Temperature, *m.Value) {
+		if !building.hasValueChanged(m.Urn, building.Temperature, *m.Value) {
 			return nil
 		}
 
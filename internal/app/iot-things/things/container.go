@@ -48,7 +48,7 @@ func (c *Container) handle(m Measurement, onchange func(m ValueProvider) error)
 		return nil
 	}
 
-	level, err := functions.NewLevel(c.Angle, c.MaxDistance, c.MaxLevel, c.MeanLevel, c.Offset, c.CurrentLevel)
+	level, err := functions.NewLevel(c.Angle, c.MaxDistance, c.MaxLevel, c.MeanLevel, c.Offset, c.Clamp, c.CurrentLevel)
 	if err != nil {
 		return err
 	}
@@ -60,6 +60,10 @@ func (c *Container) handle(m Measurement, onchange func(m ValueProvider) error)
 
 	fillingLevel := NewFillingLevel(c.ID(), m.ID, level.Percent(), level.Current(), m.Timestamp)
 
+	if c.isOutOfOrder(m) {
+		return onchange(fillingLevel)
+	}
+
 	d := *m.Value
 	n := 1
 
@@ -75,7 +79,7 @@ func (c *Container) handle(m Measurement, onchange func(m ValueProvider) error)
 	}
 
 	avg_distance := d / float64(n)
-	avg_level, _ := functions.NewLevel(c.Angle, c.MaxDistance, c.MaxLevel, c.MeanLevel, c.Offset, c.CurrentLevel)
+	avg_level, _ := functions.NewLevel(c.Angle, c.MaxDistance, c.MaxLevel, c.MeanLevel, c.Offset, c.Clamp, c.CurrentLevel)
 	avg_level.Calc(avg_distance, m.Timestamp)
 
 	c.CurrentLevel = avg_level.Current()
@@ -3,18 +3,36 @@ package things
 import (
 	"encoding/json"
 	"errors"
+	"time"
+
+	"github.com/diwise/iot-things/internal/app/iot-things/functions"
 )
 
 type Desk struct {
 	thingImpl
-	Presence bool `json:"presence"`
+
+	Presence       bool          `json:"presence"` // same as Occupied, kept for clients reading presence
+	Occupied       bool          `json:"occupied"`
+	OccupiedAt     *time.Time    `json:"occupiedAt,omitempty"` // when the current occupancy started, nil when not occupied
+	OccupancyToday time.Duration `json:"occupancyToday"`       // time occupied during the current day (UTC)
+
+	Day time.Time            `json:"_day"`
+	Sw  *functions.Stopwatch `json:"_stopwatch"`
 }
 
 func NewDesk(id string, l Location, tenant string) Thing {
 	thing := newThingImpl(id, "Desk", l, tenant)
-	return &Lifebuoy{
+	return &Desk{
 		thingImpl: thing,
+		Sw:        functions.NewStopwatch(),
+	}
+}
+
+func (d *Desk) stopWatch() *functions.Stopwatch {
+	if d.Sw == nil {
+		d.Sw = functions.NewStopwatch()
 	}
+	return d.Sw
 }
 
 func (d *Desk) Handle(m []Measurement, onchange func(m ValueProvider) error) error {
@@ -32,17 +50,50 @@ func (d *Desk) handle(m Measurement, onchange func(m ValueProvider) error) error
 		return nil
 	}
 
-	if !hasChanged(d.Presence, *m.BoolValue) {
-		return nil
-	}
+	d.startDay(m.Timestamp)
 
-	d.Presence = *m.BoolValue
-	presence := NewPresence(d.ID(), m.ID, d.Presence, m.Timestamp)
+	var err error
 
-	return onchange(presence)
+	d.stopWatch().Push(*m.BoolValue, m.Timestamp, func(sw functions.Stopwatch) error {
+		switch sw.CurrentEvent {
+		case functions.Started:
+			d.Occupied = true
+			d.Presence = true
+			d.OccupiedAt = sw.StartTime
+			err = onchange(NewPresence(d.ID(), m.ID, true, m.Timestamp))
+		case functions.Stopped:
+			d.Occupied = false
+			d.Presence = false
+			d.OccupiedAt = nil
+			d.OccupancyToday += d.occupiedToday(*sw.StartTime, *sw.Duration)
+			err = onchange(NewPresence(d.ID(), m.ID, false, m.Timestamp))
+		}
+		return err
+	})
+
+	return err
+}
+
+// startDay resets the daily occupancy when ts is on a later day than the current one
+func (d *Desk) startDay(ts time.Time) {
+	ts = ts.UTC()
+	day := time.Date(ts.Year(), ts.Month(), ts.Day(), 0, 0, 0, 0, time.UTC)
+
+	if day.After(d.Day) {
+		d.Day = day
+		d.OccupancyToday = 0
+	}
+}
+
+// occupiedToday is the part of an occupancy, started at start and lasting for duration, within the current day
+func (d *Desk) occupiedToday(start time.Time, duration time.Duration) time.Duration {
+	if start.Before(d.Day) {
+		return duration - d.Day.Sub(start)
+	}
+	return duration
 }
 
-func (l *Desk) Byte() []byte {
-	b, _ := json.Marshal(l)
+func (d *Desk) Byte() []byte {
+	b, _ := json.Marshal(d)
 	return b
 }
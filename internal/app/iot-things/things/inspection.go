@@ -0,0 +1,15 @@
+package things
+
+import "time"
+
+// Inspection tracks the periodic inspection of equipment, e.g. safety equipment such as lifebuoys.
+// It is embedded in the types that need inspecting and is set by patching the thing.
+type Inspection struct {
+	LastInspectedAt   *time.Time `json:"lastInspectedAt,omitempty"`
+	NextInspectionDue *time.Time `json:"nextInspectionDue,omitempty"`
+}
+
+// InspectionDue returns true if the next inspection is due at or before now
+func (i Inspection) InspectionDue(now time.Time) bool {
+	return i.NextInspectionDue != nil && !i.NextInspectionDue.After(now)
+}
@@ -7,6 +7,7 @@ import (
 
 type Lifebuoy struct {
 	thingImpl
+	Inspection
 	Presence bool `json:"presence"`
 }
 
@@ -12,7 +12,33 @@ type Passage struct {
 	PassagesToday             int   `json:"passagesToday"`
 	CurrentState              bool  `json:"currentState"`
 
-	Passages map[int]int `json:"_passages"`
+	// OuterBeam and InnerBeam pair two ref devices so that the direction of a passage
+	// can be inferred from the order in which the beams are broken.
+	OuterBeam     string   `json:"outerBeam,omitempty"`
+	InnerBeam     string   `json:"innerBeam,omitempty"`
+	PairingWindow *float64 `json:"pairingWindow,omitempty"` // max seconds between the two breaks
+	PassagesIn    int64    `json:"passagesIn"`
+	PassagesOut   int64    `json:"passagesOut"`
+
+	// PassagesRetention is the number of days of daily passages to keep
+	PassagesRetention *int `json:"passagesRetention,omitempty"`
+
+	Passages      map[int]int `json:"_passages"` // passages per day, keyed by year*1000 + day of year
+	LastBeamBreak *beamBreak  `json:"_lastBeamBreak,omitempty"`
+}
+
+type beamBreak struct {
+	DeviceID  string    `json:"deviceID"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+const (
+	defaultPairingWindow     float64 = 10.0
+	defaultPassagesRetention int     = 370
+)
+
+func dayKey(ts time.Time) int {
+	return ts.Year()*1000 + ts.YearDay()
 }
 
 func NewPassage(id string, l Location, tenant string) Thing {
@@ -28,16 +54,29 @@ func (p *Passage) increasePassages(ts time.Time) {
 		p.Passages = make(map[int]int)
 	}
 
-	dayNr := ts.Year() + ts.YearDay()
-	if _, ok := p.Passages[dayNr]; !ok {
-		p.Passages[dayNr] = 0
-	}
+	p.Passages[dayKey(ts)]++
+
+	now := time.Now()
 
-	p.Passages[dayNr]++
+	p.prunePassages(now)
+	p.PassagesToday = p.Passages[dayKey(now)]
+}
+
+// prunePassages removes daily passages older than the retention window. Keys
+// stored in the previous format (year + day of year) are always older.
+func (p *Passage) prunePassages(now time.Time) {
+	retention := defaultPassagesRetention
+	if p.PassagesRetention != nil && *p.PassagesRetention > 0 {
+		retention = *p.PassagesRetention
+	}
 
-	today := time.Now().Year() + time.Now().YearDay()
+	oldest := dayKey(now.AddDate(0, 0, -retention))
 
-	p.PassagesToday = p.Passages[today]
+	for day := range p.Passages {
+		if day < oldest {
+			delete(p.Passages, day)
+		}
+	}
 }
 
 func (p *Passage) Handle(m []Measurement, onchange func(m ValueProvider) error) error {
@@ -55,6 +94,10 @@ func (p *Passage) handle(m Measurement, onchange func(m ValueProvider) error) er
 		return nil
 	}
 
+	if p.isDirectional() {
+		return p.handleBeam(m, onchange)
+	}
+
 	if !hasChanged(p.CurrentState, *m.BoolValue) {
 		return nil
 	}
@@ -79,6 +122,62 @@ func (p *Passage) handle(m Measurement, onchange func(m ValueProvider) error) er
 	return onchange(door)
 }
 
+func (p *Passage) isDirectional() bool {
+	return p.OuterBeam != "" && p.InnerBeam != "" && p.OuterBeam != p.InnerBeam
+}
+
+// handleBeam counts a passage when both beams have been broken within the pairing window.
+// Outer before inner is counted as in, inner before outer as out.
+func (p *Passage) handleBeam(m Measurement, onchange func(m ValueProvider) error) error {
+	deviceID := m.DeviceID()
+
+	if deviceID != p.OuterBeam && deviceID != p.InnerBeam {
+		return nil
+	}
+
+	if !p.beamChanged(deviceID, m) || !*m.BoolValue {
+		return nil
+	}
+
+	window := defaultPairingWindow
+	if p.PairingWindow != nil {
+		window = *p.PairingWindow
+	}
+
+	last := p.LastBeamBreak
+	if last == nil || last.DeviceID == deviceID || m.Timestamp.Before(last.Timestamp) || m.Timestamp.Sub(last.Timestamp).Seconds() > window {
+		p.LastBeamBreak = &beamBreak{DeviceID: deviceID, Timestamp: m.Timestamp}
+		return nil
+	}
+
+	p.LastBeamBreak = nil
+
+	if deviceID == p.InnerBeam {
+		p.PassagesIn++
+	} else {
+		p.PassagesOut++
+	}
+
+	p.increasePassages(m.Timestamp)
+
+	peopleCounter := NewPeopleCounter(p.ID(), m.ID, p.PassagesToday, p.CumulatedNumberOfPassages, m.Timestamp)
+
+	return onchange(peopleCounter)
+}
+
+func (p *Passage) beamChanged(deviceID string, m Measurement) bool {
+	for _, ref := range p.RefDevices {
+		if ref.DeviceID != deviceID {
+			continue
+		}
+		if prev, ok := ref.Measurements[m.ID]; ok && prev.BoolValue != nil {
+			return hasChanged(*prev.BoolValue, *m.BoolValue)
+		}
+	}
+
+	return true
+}
+
 func (p *Passage) Byte() []byte {
 	b, _ := json.Marshal(p)
 	return b
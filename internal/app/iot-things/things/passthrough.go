@@ -0,0 +1,63 @@
+package things
+
+import (
+	"encoding/json"
+	"errors"
+	"slices"
+	"strings"
+)
+
+// Passthrough is a thing of a type that is not known. Measurements are stored as values as they
+// are reported, without any type specific derivation, and the urns seen are added to its valid urns.
+type Passthrough struct {
+	thingImpl
+}
+
+func (p *Passthrough) Handle(m []Measurement, onchange func(m ValueProvider) error) error {
+	errs := []error{}
+
+	for _, v := range m {
+		errs = append(errs, p.handle(v, onchange))
+	}
+
+	return errors.Join(errs...)
+}
+
+func (p *Passthrough) handle(m Measurement, onchange func(m ValueProvider) error) error {
+	if m.Value == nil && m.BoolValue == nil && m.StringValue == nil {
+		return nil
+	}
+
+	if !slices.Contains(p.ValidURN, m.Urn) {
+		p.ValidURN = append(p.ValidURN, m.Urn)
+	}
+
+	// the device id of the measurement is replaced by the id of the thing
+	id := p.ID()
+	if _, resource, ok := strings.Cut(m.ID, "/"); ok {
+		id = id + "/" + resource
+	}
+
+	v := Value{
+		Measurement: m,
+		Ref:         m.ID,
+	}
+	v.ID = id
+	v.Location = nil
+	v.Timestamp = m.Timestamp.UTC()
+
+	return onchange(rawValue{v})
+}
+
+type rawValue struct {
+	Value
+}
+
+func (r rawValue) Values() []Value {
+	return []Value{r.Value}
+}
+
+func (p *Passthrough) Byte() []byte {
+	b, _ := json.Marshal(p)
+	return b
+}
@@ -30,7 +30,7 @@ func (poi *PointOfInterest) handle(m Measurement, onchange func(m ValueProvider)
 		return nil
 	}
 
-	if !hasChanged(poi.Temperature, *m.Value) {
+	if !poi.hasValueChanged(m.Urn, poi.Temperature, *m.Value) {
 		return nil
 	}
 
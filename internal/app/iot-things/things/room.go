@@ -12,6 +12,8 @@ type Room struct {
 	Humidity    float64 `json:"humidity"`
 	Illuminance float64 `json:"illuminance"`
 	CO2         float64 `json:"co2"`
+	PM10        float64 `json:"pm10"`
+	PM25        float64 `json:"pm25"`
 	//Presence    bool    `json:"presence"`
 }
 
@@ -81,23 +83,40 @@ func (r *Room) handlePresence(m Measurement, onchange func(m ValueProvider) erro
 */
 func (r *Room) handleAirQuality(m Measurement, onchange func(m ValueProvider) error) error {
 
-	const CO2 = "/17"
-
-	if !(strings.HasSuffix(m.ID, CO2)) {
+	const (
+		PM10 = "/1"
+		PM25 = "/3"
+		CO2  = "/17"
+	)
+
+	var current *float64
+	var air ValueProvider
+	var resource string
+
+	switch {
+	case strings.HasSuffix(m.ID, CO2):
+		current, resource = &r.CO2, CO2
+		air = NewAirQuality(r.ID(), m.ID, *m.Value, m.Timestamp)
+	case strings.HasSuffix(m.ID, PM10):
+		current, resource = &r.PM10, PM10
+		air = NewAirQualityPM10(r.ID(), m.ID, *m.Value, m.Timestamp)
+	case strings.HasSuffix(m.ID, PM25):
+		current, resource = &r.PM25, PM25
+		air = NewAirQualityPM25(r.ID(), m.ID, *m.Value, m.Timestamp)
+	default:
 		return nil
 	}
 
-	if !hasChanged(r.CO2, *m.Value) {
+	if !r.hasValueChanged(m.Urn, *current, *m.Value) {
 		return nil
 	}
 
-	air := NewAirQuality(r.ID(), m.ID, *m.Value, m.Timestamp)
 	err := onchange(air)
 	if err != nil {
 		return err
 	}
 
-	r.CO2 = avg(r, m.ID, *m.Value, hasAirQuality)
+	*current = avg(r, m.ID, *m.Value, hasAirQualityResource(resource))
 
 	return nil
 }
@@ -110,7 +129,7 @@ func (r *Room) handleIlluminance(m Measurement, onchange func(m ValueProvider) e
 		return nil
 	}
 
-	if !hasChanged(r.Illuminance, *m.Value) {
+	if !r.hasValueChanged(m.Urn, r.Illuminance, *m.Value) {
 		return nil
 	}
 
@@ -133,7 +152,7 @@ func (r *Room) handleHumidity(m Measurement, onchange func(m ValueProvider) erro
 		return nil
 	}
 
-	if !hasChanged(r.Humidity, *m.Value) {
+	if !r.hasValueChanged(m.Urn, r.Humidity, *m.Value) {
 		return nil
 	}
 
@@ -156,7 +175,7 @@ func (r *Room) handleTemperature(m Measurement, onchange func(m ValueProvider) e
 		return nil
 	}
 
-	if !hasChanged(r.Temperature, *m.Value) {
+	if !r.hasValueChanged(m.Urn, r.Temperature, *m.Value) {
 		return nil
 	}
 
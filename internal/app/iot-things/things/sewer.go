@@ -53,7 +53,7 @@ func (s *Sewer) handle(m Measurement, onchange func(m ValueProvider) error) erro
 }
 
 func (s *Sewer) handleDistance(v Measurement, onchange func(m ValueProvider) error) error {
-	level, err := functions.NewLevel(s.Angle, s.MaxDistance, s.MaxLevel, s.MeanLevel, s.Offset, s.CurrentLevel)
+	level, err := functions.NewLevel(s.Angle, s.MaxDistance, s.MaxLevel, s.MeanLevel, s.Offset, s.Clamp, s.CurrentLevel)
 	if err != nil {
 		return err
 	}
@@ -65,6 +65,10 @@ func (s *Sewer) handleDistance(v Measurement, onchange func(m ValueProvider) err
 
 	fillingLevel := NewFillingLevel(s.ID(), v.ID, level.Percent(), level.Current(), v.Timestamp)
 
+	if s.isOutOfOrder(v) {
+		return onchange(fillingLevel)
+	}
+
 	s.CurrentLevel = level.Current()
 	s.Percent = level.Percent()
 
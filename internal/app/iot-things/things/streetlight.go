@@ -0,0 +1,113 @@
+package things
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/diwise/iot-things/internal/app/iot-things/functions"
+)
+
+type StreetLight struct {
+	thingImpl
+
+	On                 bool          `json:"on"`
+	OnObservedAt       *time.Time    `json:"onObservedAt"`
+	CumulativeOnTime   time.Duration `json:"cumulativeOnTime"`
+	CurrentIlluminance *float64      `json:"currentIlluminance,omitempty"`
+
+	Sw *functions.Stopwatch `json:"_stopwatch"`
+}
+
+func NewStreetLight(id string, l Location, tenant string) Thing {
+	thing := newThingImpl(id, "StreetLight", l, tenant)
+	return &StreetLight{
+		thingImpl: thing,
+		Sw:        functions.NewStopwatch(),
+	}
+}
+
+func (sl *StreetLight) stopWatch() *functions.Stopwatch {
+	if sl.Sw == nil {
+		sl.Sw = functions.NewStopwatch()
+	}
+	return sl.Sw
+}
+
+func (sl *StreetLight) Handle(m []Measurement, onchange func(m ValueProvider) error) error {
+	errs := []error{}
+
+	for _, v := range m {
+		errs = append(errs, sl.handle(v, onchange))
+	}
+
+	return errors.Join(errs...)
+}
+
+func (sl *StreetLight) handle(m Measurement, onchange func(m ValueProvider) error) error {
+	if hasDigitalInput(&m) {
+		return sl.handleDigitalInput(m, onchange)
+	}
+
+	if hasIlluminance(&m) {
+		return sl.handleIlluminance(m, onchange)
+	}
+
+	return nil
+}
+
+// handleDigitalInput accumulates the time the light has been lit, the digital input being on while lit
+func (sl *StreetLight) handleDigitalInput(m Measurement, onchange func(m ValueProvider) error) error {
+	return sl.stopWatch().Push(*m.BoolValue, m.Timestamp, func(sw functions.Stopwatch) error {
+		sl.On = sw.State
+		sl.OnObservedAt = &m.Timestamp
+
+		var z, sec float64
+		if sw.Duration != nil {
+			sec = sw.Duration.Seconds()
+		}
+
+		switch sw.CurrentEvent {
+		case functions.Started:
+			return onchange(NewStopwatch(sl.ID(), m.ID, &z, true, m.Timestamp))
+		case functions.Updated:
+			return onchange(NewStopwatch(sl.ID(), m.ID, &sec, sl.On, m.Timestamp))
+		case functions.Stopped:
+			sl.CumulativeOnTime += *sw.Duration
+			return onchange(NewStopwatch(sl.ID(), m.ID, &sec, false, m.Timestamp))
+		case functions.InitialState:
+			return onchange(NewStopwatch(sl.ID(), m.ID, &z, false, m.Timestamp))
+		}
+
+		return nil
+	})
+}
+
+func (sl *StreetLight) handleIlluminance(m Measurement, onchange func(m ValueProvider) error) error {
+
+	const SensorValue = "/5700"
+
+	if !(strings.HasSuffix(m.ID, SensorValue)) {
+		return nil
+	}
+
+	if sl.CurrentIlluminance != nil && !sl.hasValueChanged(m.Urn, *sl.CurrentIlluminance, *m.Value) {
+		return nil
+	}
+
+	err := onchange(NewIlluminance(sl.ID(), m.ID, *m.Value, m.Timestamp))
+	if err != nil {
+		return err
+	}
+
+	v := *m.Value
+	sl.CurrentIlluminance = &v
+
+	return nil
+}
+
+func (sl *StreetLight) Byte() []byte {
+	b, _ := json.Marshal(sl)
+	return b
+}
@@ -3,6 +3,7 @@ package things
 import (
 	"encoding/json"
 	"errors"
+	"fmt"
 	"slices"
 	"strings"
 	"time"
@@ -12,14 +13,22 @@ type Thing interface {
 	ID() string
 	Type() string
 	Tenant() string
+	Status() string
 	LatLon() (float64, float64)
 	Handle(m []Measurement, onchange func(m ValueProvider) error) error
 	Byte() []byte
 	Refs() []Device
+	ValidURNs() []string
 
 	SetLastObserved(measurements []Measurement)
+	SetObservedLocation(l Location, ts time.Time, precedence string)
+	SetOutOfOrderPolicy(policy string)
+	SetChangeThresholds(thresholds map[string]float64)
 	AddDevice(deviceID string)
 	AddTag(tag string)
+	RemoveTag(tag string)
+	Relations() []Relation
+	AddRelation(r Relation)
 }
 
 type ThingType struct {
@@ -49,14 +58,70 @@ type thingImpl struct {
 	RefDevices      []Device      `json:"refDevices,omitempty"`
 	Tags            []string      `json:"tags,omitempty"`
 	Tenant_         string        `json:"tenant"`
+	Status_         string        `json:"status,omitempty"`
 	ObservedAt      time.Time     `json:"observedAt"`
+	CommissionedAt  *time.Time    `json:"commissionedAt,omitempty"` // when the thing was installed, not when it was added
 	ValidURN        []string      `json:"validURN,omitempty"`
+	Relations_      []Relation    `json:"relations,omitempty"`
+
+	ObservedLocation *Location  `json:"_observedLocation,omitempty"`
+	LocationAt       *time.Time `json:"_locationAt,omitempty"` // when a measurement last set the location
+
+	outOfOrderPolicy string
+	changeThresholds map[string]float64
+}
+
+const (
+	RelationParent string = "parent"
+	RelationChild  string = "child"
+)
+
+// Relation links a thing to another thing, e.g. a Room to the Building containing it. Relation is the role
+// of the other thing, parent or child.
+type Relation struct {
+	ID       string `json:"id"`
+	Type     string `json:"type"`
+	Relation string `json:"relation"`
 }
 
 type Point []float64     // [x, y]
 type Line []Point        // [Point, Point]
 type LineSegments []Line // [Line, Line, ...]
 
+// Polygon joins the segments, in order, into a closed ring of [x, y] positions as used by GeoJSON
+// Polygon coordinates. Segments are expected to connect end to start. Returns false if the segments
+// do not enclose an area.
+func (ls LineSegments) Polygon() ([][][]float64, bool) {
+	ring := make([][]float64, 0, len(ls)+1)
+
+	add := func(p Point) {
+		if len(p) < 2 {
+			return
+		}
+		if len(ring) > 0 && slices.Equal(ring[len(ring)-1], p[:2]) {
+			return
+		}
+		ring = append(ring, []float64{p[0], p[1]})
+	}
+
+	for _, l := range ls {
+		for _, p := range l {
+			add(p)
+		}
+	}
+
+	if len(ring) > 0 && !slices.Equal(ring[0], ring[len(ring)-1]) {
+		ring = append(ring, ring[0])
+	}
+
+	// a linear ring needs at least three distinct positions
+	if len(ring) < 4 {
+		return nil, false
+	}
+
+	return [][][]float64{ring}, true
+}
+
 type Location struct {
 	Latitude  float64 `json:"latitude"`
 	Longitude float64 `json:"longitude"`
@@ -64,9 +129,69 @@ type Location struct {
 
 var DefaultLocation = Location{Latitude: 0, Longitude: 0}
 
+var ErrUnknownType = errors.New("unknown thing type")
+
+const (
+	LocationPrecedenceThing       string = "thing"       // always use the configured location
+	LocationPrecedenceMeasurement string = "measurement" // prefer the location reported by measurements
+	LocationPrecedenceLatest      string = "latest"      // use the most recently measured location
+)
+
+const (
+	OutOfOrderSkip  string = "skip"  // store the value but leave derived state untouched
+	OutOfOrderApply string = "apply" // update derived state regardless of measurement order
+)
+
+const (
+	StatusActive   string = "active"
+	StatusInactive string = "inactive" // decommissioned but kept for history
+)
+
+func IsValidStatus(status string) bool {
+	return status == "" || status == StatusActive || status == StatusInactive
+}
+
+// ConfigArgs are the type specific properties that configure a thing, as opposed to its observed state
+var ConfigArgs = []string{"maxd", "maxl", "meanl", "offset", "angle", "clamp", "pairingWindow", "passagesRetention", "alternativeName", "outerBeam", "innerBeam", "volumeUnit"}
+
 type Device struct {
 	DeviceID     string                 `json:"deviceID"`
 	Measurements map[string]Measurement `json:"measurements,omitempty"`
+	Calibration  *Calibration           `json:"calibration,omitempty"`
+}
+
+// Calibration is a linear correction, v' = scale*v + offset, of the values reported by a device
+type Calibration struct {
+	Urn    string   `json:"urn,omitempty"`   // only values of this urn are calibrated, or all numeric values if empty
+	Scale  *float64 `json:"scale,omitempty"` // 1 if omitted
+	Offset float64  `json:"offset,omitempty"`
+}
+
+func (c Calibration) Apply(m Measurement) Measurement {
+	if m.Value == nil || (c.Urn != "" && c.Urn != m.Urn) {
+		return m
+	}
+
+	scale := 1.0
+	if c.Scale != nil {
+		scale = *c.Scale
+	}
+
+	v := scale*(*m.Value) + c.Offset
+	m.Value = &v
+	m.Calibrated = true
+
+	return m
+}
+
+// Calibrate applies the calibration of the ref device that reported the measurement, if any
+func Calibrate(t Thing, m Measurement) Measurement {
+	for _, d := range t.Refs() {
+		if d.DeviceID == m.DeviceID() && d.Calibration != nil {
+			return d.Calibration.Apply(m)
+		}
+	}
+	return m
 }
 
 func (t *thingImpl) ID() string {
@@ -78,9 +203,53 @@ func (t *thingImpl) Type() string {
 func (t *thingImpl) Tenant() string {
 	return t.Tenant_
 }
+func (t *thingImpl) Status() string {
+	if t.Status_ == "" {
+		return StatusActive
+	}
+	return t.Status_
+}
 func (t *thingImpl) LatLon() (float64, float64) {
+	if t.ObservedLocation != nil {
+		return t.ObservedLocation.Latitude, t.ObservedLocation.Longitude
+	}
 	return t.Location.Latitude, t.Location.Longitude
 }
+func (t *thingImpl) SetObservedLocation(l Location, ts time.Time, precedence string) {
+	switch precedence {
+	case LocationPrecedenceMeasurement:
+		t.ObservedLocation = &l
+	case LocationPrecedenceLatest:
+		// a location measured before the current location was set does not replace it
+		if t.LocationAt != nil && ts.Before(*t.LocationAt) {
+			return
+		}
+		t.Location = l
+		t.LocationAt = &ts
+		t.ObservedLocation = nil
+	default:
+		t.ObservedLocation = nil
+	}
+}
+
+func (t *thingImpl) SetOutOfOrderPolicy(policy string) {
+	t.outOfOrderPolicy = policy
+}
+
+// SetChangeThresholds sets the minimum difference, per urn, for a numeric value to be considered changed.
+// Urns without a threshold use the default of 0.001.
+func (t *thingImpl) SetChangeThresholds(thresholds map[string]float64) {
+	t.changeThresholds = thresholds
+}
+
+// isOutOfOrder reports whether m is older than the last observation and should not update derived state
+func (t *thingImpl) isOutOfOrder(m Measurement) bool {
+	if t.outOfOrderPolicy == OutOfOrderApply || t.ObservedAt.IsZero() {
+		return false
+	}
+	return m.Timestamp.Before(t.ObservedAt)
+}
+
 func (t *thingImpl) AddDevice(deviceID string) {
 	exists := slices.ContainsFunc(t.RefDevices, func(device Device) bool {
 		return device.DeviceID == deviceID
@@ -93,13 +262,39 @@ func (t *thingImpl) Refs() []Device {
 	return t.RefDevices
 }
 
+func (t *thingImpl) ValidURNs() []string {
+	return t.ValidURN
+}
+
 func (t *thingImpl) AddTag(tag string) {
+	tag = strings.TrimSpace(tag)
+	if tag == "" {
+		return
+	}
 	exists := slices.Contains(t.Tags, tag)
 	if !exists {
 		t.Tags = append(t.Tags, tag)
 	}
 }
 
+func (t *thingImpl) RemoveTag(tag string) {
+	tag = strings.TrimSpace(tag)
+	t.Tags = slices.DeleteFunc(t.Tags, func(s string) bool { return s == tag })
+}
+
+func (t *thingImpl) Relations() []Relation {
+	return t.Relations_
+}
+
+func (t *thingImpl) AddRelation(r Relation) {
+	exists := slices.ContainsFunc(t.Relations_, func(existing Relation) bool {
+		return existing.ID == r.ID && existing.Relation == r.Relation
+	})
+	if !exists {
+		t.Relations_ = append(t.Relations_, r)
+	}
+}
+
 func (c *thingImpl) SetLastObserved(measurements []Measurement) {
 	lastObserved := c.ObservedAt
 
@@ -169,7 +364,9 @@ func newBoolValue(id, urn, ref, unit string, ts time.Time, value bool) Value {
 
 type Value struct {
 	Measurement
-	Ref string `json:"ref,omitempty"`
+	Ref        string     `json:"ref,omitempty"`
+	RedactedOn *time.Time `json:"redactedOn,omitempty"`
+	Integer    bool       `json:"-"` // a whole number value of an urn configured to be stored as integers
 }
 
 type Measurement struct {
@@ -180,8 +377,20 @@ type Measurement struct {
 	Value       *float64  `json:"v,omitempty"`
 	Unit        string    `json:"unit,omitempty"`
 	Timestamp   time.Time `json:"timestamp"`
+	Location    *Location `json:"location,omitempty"`
+	Quality     string    `json:"quality,omitempty"` // e.g. "estimated" or "faulty" when flagged by the device
+	Calibrated  bool      `json:"calibrated,omitempty"`
+
+	// CorrelationID traces the measurement from ingestion to stored values and published updates
+	CorrelationID string `json:"correlationId,omitempty"`
 }
 
+const (
+	QualityGood      string = "good"
+	QualityEstimated string = "estimated"
+	QualityFaulty    string = "faulty"
+)
+
 func hasDistance(m *Measurement) bool {
 	return m.Urn == DistanceURN && m.Value != nil
 }
@@ -203,6 +412,13 @@ func hasIlluminance(m *Measurement) bool {
 func hasAirQuality(m *Measurement) bool {
 	return m.Urn == AirQualityURN && m.Value != nil
 }
+
+// hasAirQualityResource returns a func matching air quality values of a resource, e.g. /17 for CO2
+func hasAirQualityResource(resource string) func(m *Measurement) bool {
+	return func(m *Measurement) bool {
+		return hasAirQuality(m) && strings.HasSuffix(m.ID, resource)
+	}
+}
 func hasPower(m *Measurement) bool {
 	return m.Urn == PowerURN && m.Value != nil
 }
@@ -234,7 +450,17 @@ func (m Measurement) DeviceID() string {
 	return strings.Split(m.ID, "/")[0]
 }
 
+// ConvToThing converts b to a thing of its type and fails for types that are not known
 func ConvToThing(b []byte) (Thing, error) {
+	return convToThing(b, false)
+}
+
+// ConvToThingOrPassthrough converts b like ConvToThing, but converts things of unknown types to Passthrough things
+func ConvToThingOrPassthrough(b []byte) (Thing, error) {
+	return convToThing(b, true)
+}
+
+func convToThing(b []byte, passthrough bool) (Thing, error) {
 	t := struct {
 		Type string `json:"type"`
 	}{}
@@ -276,6 +502,10 @@ func ConvToThing(b []byte) (Thing, error) {
 		s, err := unmarshal[Sewer](b)
 		s.ValidURN = SewerURNs
 		return &s, err
+	case "streetlight":
+		sl, err := unmarshal[StreetLight](b)
+		sl.ValidURN = StreetLightURNs
+		return &sl, err
 	case "watermeter":
 		l, err := unmarshal[Watermeter](b)
 		l.ValidURN = WaterMeterURNs
@@ -285,10 +515,62 @@ func ConvToThing(b []byte) (Thing, error) {
 		d.ValidURN = DeskURNs
 		return &d, err
 	default:
-		return nil, errors.New("unknown thing type [" + t.Type + "]")
+		if passthrough {
+			p, err := unmarshal[Passthrough](b)
+			return &p, err
+		}
+		return nil, fmt.Errorf("%w [%s]", ErrUnknownType, t.Type)
 	}
 }
 
+// MapFieldNames renames fields in a thing payload according to fieldNames (foreign name -> canonical name)
+// so that payloads from other schemas, e.g. using "organisation" instead of "tenant", can be converted.
+// A canonical field that is already set is not overwritten.
+func MapFieldNames(b []byte, fieldNames map[string]string) ([]byte, error) {
+	if len(fieldNames) == 0 {
+		return b, nil
+	}
+
+	m := make(map[string]any)
+	err := json.Unmarshal(b, &m)
+	if err != nil {
+		return nil, err
+	}
+
+	for foreign, canonical := range fieldNames {
+		v, ok := m[foreign]
+		if !ok || foreign == canonical {
+			continue
+		}
+
+		if current, ok := m[canonical]; !ok || current == nil || current == "" {
+			m[canonical] = v
+		}
+
+		delete(m, foreign)
+	}
+
+	return json.Marshal(m)
+}
+
+// NormalizeTags trims whitespace from tags, optionally lowercases them and removes empty and duplicate tags
+func NormalizeTags(tags []string, lowercase bool) []string {
+	normalized := make([]string, 0, len(tags))
+
+	for _, tag := range tags {
+		tag = strings.TrimSpace(tag)
+		if lowercase {
+			tag = strings.ToLower(tag)
+		}
+		if tag == "" || slices.Contains(normalized, tag) {
+			continue
+		}
+		normalized = append(normalized, tag)
+	}
+
+	return normalized
+}
+
 func unmarshal[T any](b []byte) (T, error) {
 	var m T
 	err := json.Unmarshal(b, &m)
@@ -1,9 +1,14 @@
 package things
 
 import (
+	"encoding/json"
+	"errors"
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/diwise/iot-things/internal/app/iot-things/functions"
+	"github.com/diwise/senml"
 	"github.com/matryer/is"
 )
 
@@ -153,6 +158,39 @@ func TestSewer(t *testing.T) {
 	is.Equal(sewer.OverflowCumulativeTime, 2*time.Hour)
 }
 
+func TestSewerRejectsImpossibleDistance(t *testing.T) {
+	is := is.New(t)
+
+	thing := NewSewer("id", Location{Latitude: 62, Longitude: 17}, "default")
+	sewer := thing.(*Sewer)
+
+	maxd := 0.94
+	maxl := 0.79
+	clamp := functions.ClampReject
+	sewer.MaxDistance = &maxd
+	sewer.MaxLevel = &maxl
+	sewer.Clamp = &clamp
+
+	distance := func(v float64) error {
+		m := Measurement{
+			ID:        "device/3330/5700",
+			Urn:       "urn:oma:lwm2m:ext:3330",
+			Value:     &v,
+			Timestamp: time.Now(),
+		}
+		return sewer.Handle([]Measurement{m}, func(m ValueProvider) error {
+			return nil
+		})
+	}
+
+	is.NoErr(distance(0.54))
+	is.Equal(sewer.CurrentLevel, 0.4)
+
+	err := distance(1.2)
+	is.True(errors.Is(err, functions.ErrImpossibleDistance))
+	is.Equal(sewer.CurrentLevel, 0.4)
+}
+
 func TestSewerDigitalInput(t *testing.T) {
 	is := is.New(t)
 
@@ -223,7 +261,6 @@ func TestPumpingStation(t *testing.T) {
 	is.NoErr(err)
 }
 
-
 func TestPumpingStationFalse(t *testing.T) {
 	is := is.New(t)
 
@@ -245,6 +282,61 @@ func TestPumpingStationFalse(t *testing.T) {
 
 	is.NoErr(err)
 }
+func TestStreetLight(t *testing.T) {
+	is := is.New(t)
+
+	thing := NewStreetLight("id", Location{Latitude: 62, Longitude: 17}, "default")
+	streetlight := thing.(*StreetLight)
+
+	values := []Value{}
+	onchange := func(m ValueProvider) error {
+		values = append(values, m.Values()...)
+		return nil
+	}
+
+	digitalInput := func(on bool, ts time.Time) Measurement {
+		return Measurement{ID: "device/3200/5500", Urn: DigitalInputURN, BoolValue: &on, Timestamp: ts}
+	}
+
+	day := time.Date(2024, 12, 1, 0, 0, 0, 0, time.UTC)
+
+	// lit from dusk until dawn, twice during the day
+	is.NoErr(streetlight.Handle([]Measurement{digitalInput(false, day.Add(12*time.Hour))}, onchange))
+	is.True(!streetlight.On)
+
+	is.NoErr(streetlight.Handle([]Measurement{digitalInput(true, day.Add(15*time.Hour))}, onchange))
+	is.True(streetlight.On)
+
+	is.NoErr(streetlight.Handle([]Measurement{digitalInput(true, day.Add(20*time.Hour))}, onchange))
+	is.True(streetlight.On)
+	is.Equal(streetlight.CumulativeOnTime, time.Duration(0)) // accumulated when switched off
+
+	is.NoErr(streetlight.Handle([]Measurement{digitalInput(false, day.Add(24*time.Hour))}, onchange))
+	is.True(!streetlight.On)
+	is.Equal(streetlight.CumulativeOnTime, 9*time.Hour)
+
+	is.NoErr(streetlight.Handle([]Measurement{digitalInput(true, day.Add(29*time.Hour))}, onchange))
+	is.NoErr(streetlight.Handle([]Measurement{digitalInput(false, day.Add(32*time.Hour))}, onchange))
+	is.Equal(streetlight.CumulativeOnTime, 12*time.Hour)
+	is.Equal(*streetlight.OnObservedAt, day.Add(32*time.Hour))
+
+	is.Equal(values[len(values)-2].ID, "id/3350/5544")
+	is.Equal(*values[len(values)-2].Value, 3*time.Hour.Seconds())
+
+	is.True(streetlight.CurrentIlluminance == nil)
+
+	lux := 3.5
+	is.NoErr(streetlight.Handle([]Measurement{{ID: "device/3301/5700", Urn: IlluminanceURN, Value: &lux, Timestamp: day.Add(32 * time.Hour)}}, onchange))
+	is.Equal(*streetlight.CurrentIlluminance, 3.5)
+	is.Equal(values[len(values)-1].Urn, IlluminanceURN)
+
+	b := streetlight.Byte()
+	converted, err := ConvToThing(b)
+	is.NoErr(err)
+	is.Equal(converted.(*StreetLight).CumulativeOnTime, 12*time.Hour)
+	is.Equal(converted.ValidURNs(), StreetLightURNs)
+}
+
 func TestRoom(t *testing.T) {
 	is := is.New(t)
 
@@ -304,3 +396,590 @@ func TestRoom(t *testing.T) {
 
 	is.Equal(room.CO2, 0.5)
 }
+
+func TestRoomParticulateMatter(t *testing.T) {
+	is := is.New(t)
+
+	thing := NewRoom("id", Location{Latitude: 62, Longitude: 17}, "default")
+	room := thing.(*Room)
+
+	v := 12.5
+	pm25 := Measurement{
+		ID:        "device/3428/3",
+		Urn:       "urn:oma:lwm2m:ext:3428",
+		Value:     &v,
+		Timestamp: time.Now(),
+	}
+
+	values := []Value{}
+	err := room.Handle([]Measurement{pm25}, func(m ValueProvider) error {
+		values = append(values, m.Values()...)
+		return nil
+	})
+	is.NoErr(err)
+
+	is.Equal(room.PM25, 12.5)
+	is.Equal(room.CO2, 0.0) // a PM2.5 reading is not CO2
+	is.Equal(len(values), 1)
+	is.Equal(values[0].ID, "id/3428/3")
+	is.Equal(values[0].Unit, "ug/m3")
+}
+
+func TestPassageDirection(t *testing.T) {
+	is := is.New(t)
+
+	thing := NewPassage("id", Location{Latitude: 62, Longitude: 17}, "default")
+	passage := thing.(*Passage)
+	passage.ValidURN = PassageURNs
+	passage.AddDevice("outer")
+	passage.AddDevice("inner")
+	passage.OuterBeam = "outer"
+	passage.InnerBeam = "inner"
+
+	now := time.Now()
+
+	beam := func(deviceID string, state bool, ts time.Time) {
+		m := Measurement{
+			ID:        deviceID + "/3200/5500",
+			Urn:       "urn:oma:lwm2m:ext:3200",
+			BoolValue: &state,
+			Timestamp: ts,
+		}
+		passage.Handle([]Measurement{m}, func(m ValueProvider) error {
+			return nil
+		})
+		passage.SetLastObserved([]Measurement{m})
+	}
+
+	// in: outer beam broken before inner beam
+	beam("outer", true, now)
+	beam("inner", true, now.Add(1*time.Second))
+	beam("outer", false, now.Add(2*time.Second))
+	beam("inner", false, now.Add(3*time.Second))
+
+	is.Equal(passage.PassagesIn, int64(1))
+	is.Equal(passage.PassagesOut, int64(0))
+
+	// out: inner beam broken before outer beam
+	beam("inner", true, now.Add(10*time.Second))
+	beam("outer", true, now.Add(11*time.Second))
+	beam("inner", false, now.Add(12*time.Second))
+	beam("outer", false, now.Add(13*time.Second))
+
+	is.Equal(passage.PassagesIn, int64(1))
+	is.Equal(passage.PassagesOut, int64(1))
+
+	// breaks too far apart are not paired
+	beam("outer", true, now.Add(20*time.Second))
+	beam("outer", false, now.Add(21*time.Second))
+	beam("inner", true, now.Add(60*time.Second))
+	beam("inner", false, now.Add(61*time.Second))
+
+	is.Equal(passage.PassagesIn, int64(1))
+	is.Equal(passage.PassagesOut, int64(1))
+	is.Equal(passage.CumulatedNumberOfPassages, int64(2))
+}
+
+func TestLocationPrecedence(t *testing.T) {
+	is := is.New(t)
+
+	configured := Location{Latitude: 62, Longitude: 17}
+	measured := Location{Latitude: 63, Longitude: 18}
+	ts := time.Now()
+
+	thing := NewRoom("id", configured, "default")
+	thing.SetObservedLocation(measured, ts, LocationPrecedenceThing)
+	lat, lon := thing.LatLon()
+	is.Equal(lat, 62.0)
+	is.Equal(lon, 17.0)
+
+	thing = NewRoom("id", configured, "default")
+	thing.SetObservedLocation(measured, ts, LocationPrecedenceMeasurement)
+	lat, lon = thing.LatLon()
+	is.Equal(lat, 63.0)
+	is.Equal(lon, 18.0)
+	is.Equal(thing.(*Room).Location, configured)
+
+	thing = NewRoom("id", configured, "default")
+	thing.SetObservedLocation(measured, ts, LocationPrecedenceLatest)
+	lat, lon = thing.LatLon()
+	is.Equal(lat, 63.0)
+	is.Equal(lon, 18.0)
+	is.Equal(thing.(*Room).Location, measured)
+
+	// a location measured earlier, but received later, does not replace a more recent one
+	earlier := Location{Latitude: 61, Longitude: 16}
+	thing.SetObservedLocation(earlier, ts.Add(-time.Minute), LocationPrecedenceLatest)
+	is.Equal(thing.(*Room).Location, measured)
+
+	later := Location{Latitude: 64, Longitude: 19}
+	thing.SetObservedLocation(later, ts.Add(time.Minute), LocationPrecedenceLatest)
+	is.Equal(thing.(*Room).Location, later)
+}
+
+func TestWatermeterConsumption(t *testing.T) {
+	is := is.New(t)
+
+	thing := NewWatermeter("id", Location{Latitude: 62, Longitude: 17}, "default")
+	wm := thing.(*Watermeter)
+
+	volume := func(v float64, ts time.Time) {
+		m := Measurement{
+			ID:        "device/3424/1",
+			Urn:       WaterMeterURN,
+			Value:     &v,
+			Timestamp: ts,
+		}
+		wm.Handle([]Measurement{m}, func(m ValueProvider) error {
+			return nil
+		})
+	}
+
+	day1 := time.Date(2024, 10, 31, 22, 0, 0, 0, time.UTC)
+	day2 := time.Date(2024, 11, 1, 6, 0, 0, 0, time.UTC)
+
+	volume(100.0, day1)
+	volume(101.5, day1.Add(1*time.Hour))
+
+	is.Equal(wm.DailyConsumption, 1.5)
+	is.Equal(wm.MonthlyConsumption, 1.5)
+
+	// crossing a day (and month) boundary starts new buckets
+	volume(102.0, day2)
+	is.Equal(wm.DailyConsumption, 0.5)
+	is.Equal(wm.MonthlyConsumption, 0.5)
+
+	// meter reset, consumption since reset is the new reading
+	volume(0.25, day2.Add(1*time.Hour))
+	is.Equal(wm.DailyConsumption, 0.75)
+	is.Equal(wm.MonthlyConsumption, 0.75)
+
+	volume(1.25, day2.Add(2*time.Hour))
+	is.Equal(wm.DailyConsumption, 1.75)
+	is.Equal(wm.MonthlyConsumption, 1.75)
+	is.Equal(wm.CumulativeVolume, 1.25)
+}
+
+func TestWatermeterVolumeUnits(t *testing.T) {
+	is := is.New(t)
+
+	thing := NewWatermeter("id", Location{Latitude: 62, Longitude: 17}, "default")
+	wm := thing.(*Watermeter)
+
+	var values []Value
+	volume := func(v float64, unit string, ts time.Time) error {
+		m := Measurement{
+			ID:        "device/3424/1",
+			Urn:       WaterMeterURN,
+			Value:     &v,
+			Unit:      unit,
+			Timestamp: ts,
+		}
+		return wm.Handle([]Measurement{m}, func(m ValueProvider) error {
+			for _, v := range m.Values() {
+				if strings.HasSuffix(v.ID, "/daily") {
+					values = append(values, v)
+				}
+			}
+			return nil
+		})
+	}
+
+	ts := time.Date(2024, 11, 1, 6, 0, 0, 0, time.UTC)
+
+	is.NoErr(volume(100.0, senml.UnitCubicMeter, ts))
+	is.NoErr(volume(100500, senml.UnitLiter, ts.Add(1*time.Hour))) // the meter switched to reporting liters
+
+	is.Equal(wm.CumulativeVolume, 100.5)
+	is.Equal(wm.DailyConsumption, 0.5)
+	is.Equal(wm.ObservedUnit, senml.UnitLiter)
+	is.Equal(values[len(values)-1].Unit, senml.UnitCubicMeter)
+
+	// changing the canonical unit rescales the stored state so that deltas stay consistent
+	wm.VolumeUnit = senml.UnitLiter
+	is.NoErr(volume(100.75, senml.UnitCubicMeter, ts.Add(2*time.Hour)))
+
+	is.Equal(wm.CumulativeVolume, 100750.0)
+	is.Equal(wm.DailyConsumption, 750.0)
+	is.Equal(values[len(values)-1].Unit, senml.UnitLiter)
+
+	is.True(volume(1, "gal", ts.Add(3*time.Hour)) != nil)
+}
+
+func TestContainerOutOfOrderMeasurement(t *testing.T) {
+	is := is.New(t)
+
+	thing := NewContainer("id", Location{Latitude: 62, Longitude: 17}, "default")
+	container := thing.(*Container)
+
+	maxd := 0.94
+	maxl := 0.79
+	container.MaxDistance = &maxd
+	container.MaxLevel = &maxl
+
+	now := time.Now()
+
+	distance := func(v float64, ts time.Time) Measurement {
+		return Measurement{
+			ID:        "device/3330/5700",
+			Urn:       "urn:oma:lwm2m:ext:3330",
+			Value:     &v,
+			Timestamp: ts,
+		}
+	}
+
+	current := distance(0.54, now)
+	container.Handle([]Measurement{current}, func(m ValueProvider) error {
+		return nil
+	})
+	container.SetLastObserved([]Measurement{current})
+
+	stored := 0
+	old := distance(0.15, now.Add(-1*time.Hour))
+	container.Handle([]Measurement{old}, func(m ValueProvider) error {
+		stored += len(m.Values())
+		return nil
+	})
+
+	is.Equal(stored, 2)                   // raw values are still stored
+	is.Equal(container.CurrentLevel, 0.4) // derived state is untouched
+	is.Equal(int(container.Percent), 50)
+
+	container.SetOutOfOrderPolicy(OutOfOrderApply)
+	container.Handle([]Measurement{old}, func(m ValueProvider) error {
+		return nil
+	})
+
+	is.True(container.CurrentLevel > 0.4)
+}
+
+func TestPassagesArePruned(t *testing.T) {
+	is := is.New(t)
+
+	thing := NewPassage("id", Location{Latitude: 62, Longitude: 17}, "default")
+	passage := thing.(*Passage)
+
+	now := time.Now()
+	retention := 30
+	passage.PassagesRetention = &retention
+	passage.Passages = map[int]int{
+		now.Year() + now.YearDay():     5, // previous key format
+		dayKey(now.AddDate(0, 0, -31)): 3,
+		dayKey(now.AddDate(0, 0, -1)):  2,
+		dayKey(now):                    1,
+	}
+
+	on, off := true, false
+	measurements := []Measurement{
+		{ID: "device/3200/5500", Urn: DigitalInputURN, BoolValue: &on, Timestamp: now},
+		{ID: "device/3200/5500", Urn: DigitalInputURN, BoolValue: &off, Timestamp: now},
+	}
+
+	passage.Handle(measurements, func(m ValueProvider) error {
+		return nil
+	})
+
+	is.Equal(len(passage.Passages), 2)
+	is.Equal(passage.Passages[dayKey(now.AddDate(0, 0, -1))], 2)
+	is.Equal(passage.PassagesToday, 2)
+}
+
+func TestChangeThresholdsAreSetPerThing(t *testing.T) {
+	is := is.New(t)
+
+	strict := NewRoom("strict", Location{Latitude: 62, Longitude: 17}, "default").(*Room)
+	strict.SetChangeThresholds(map[string]float64{TemperatureURN: 1})
+
+	other := NewRoom("other", Location{Latitude: 62, Longitude: 17}, "default").(*Room)
+
+	is.True(!strict.hasValueChanged(TemperatureURN, 20.0, 20.5))
+	is.True(strict.hasValueChanged(TemperatureURN, 20.0, 21.0))
+	is.True(other.hasValueChanged(TemperatureURN, 20.0, 20.5))
+	is.True(!other.hasValueChanged(TemperatureURN, 20.0, 20.0005))
+}
+
+func TestChangeThresholdsPerUrn(t *testing.T) {
+	is := is.New(t)
+
+	thing := NewRoom("id", Location{Latitude: 62, Longitude: 17}, "default")
+	room := thing.(*Room)
+	room.SetChangeThresholds(map[string]float64{
+		TemperatureURN: 0.01,
+		IlluminanceURN: 10,
+	})
+
+	stored := 0
+	handle := func(urn string, v float64) {
+		m := Measurement{
+			ID:        "device/" + urn[len(urn)-4:] + "/5700",
+			Urn:       urn,
+			Value:     &v,
+			Timestamp: time.Now(),
+		}
+		room.Handle([]Measurement{m}, func(m ValueProvider) error {
+			stored++
+			return nil
+		})
+		room.SetLastObserved([]Measurement{m})
+	}
+
+	handle(TemperatureURN, 20.0)
+	handle(TemperatureURN, 20.005) // below threshold
+	handle(TemperatureURN, 20.02)
+	is.Equal(stored, 2)
+
+	handle(IlluminanceURN, 400)
+	handle(IlluminanceURN, 405) // below threshold
+	handle(IlluminanceURN, 420)
+	is.Equal(stored, 4)
+
+	handle(HumidityURN, 50.0)
+	handle(HumidityURN, 50.002) // default threshold
+	is.Equal(stored, 6)
+}
+
+func TestAreaAsPolygon(t *testing.T) {
+	is := is.New(t)
+
+	// a parking lot bounded by four segments
+	area := LineSegments{
+		{{17.30, 62.39}, {17.31, 62.39}},
+		{{17.31, 62.39}, {17.31, 62.40}},
+		{{17.31, 62.40}, {17.30, 62.40}},
+		{{17.30, 62.40}, {17.30, 62.39}},
+	}
+
+	polygon, ok := area.Polygon()
+	is.True(ok)
+	is.Equal(len(polygon), 1)
+	is.Equal(polygon[0], [][]float64{{17.30, 62.39}, {17.31, 62.39}, {17.31, 62.40}, {17.30, 62.40}, {17.30, 62.39}})
+
+	// an open boundary is closed
+	polygon, ok = area[:3].Polygon()
+	is.True(ok)
+	is.Equal(polygon[0][0], polygon[0][len(polygon[0])-1])
+	is.Equal(len(polygon[0]), 5)
+
+	_, ok = area[:1].Polygon()
+	is.True(!ok)
+
+	_, ok = LineSegments{}.Polygon()
+	is.True(!ok)
+
+	b := []byte(`{"id":"parking-001","type":"PointOfInterest","tenant":"default","area":[[[17.30,62.39],[17.31,62.39]],[[17.31,62.39],[17.31,62.40]],[[17.31,62.40],[17.30,62.39]]]}`)
+	thing := struct {
+		Area *LineSegments `json:"area"`
+	}{}
+	is.NoErr(json.Unmarshal(b, &thing))
+	polygon, ok = thing.Area.Polygon()
+	is.True(ok)
+	is.Equal(len(polygon[0]), 4)
+}
+
+func TestCalibration(t *testing.T) {
+	is := is.New(t)
+
+	v := 2.5
+	m := Measurement{ID: "device/3330/5700", Urn: DistanceURN, Value: &v, Timestamp: time.Now()}
+
+	scale := 2.0
+	c := Calibration{Urn: DistanceURN, Scale: &scale, Offset: -0.5}
+
+	calibrated := c.Apply(m)
+	is.Equal(*calibrated.Value, 4.5)
+	is.True(calibrated.Calibrated)
+	is.Equal(*m.Value, 2.5) // the raw measurement is left untouched
+
+	// offset only
+	is.Equal(*Calibration{Offset: 0.1}.Apply(m).Value, 2.6)
+
+	// other urns and values without a numeric value are not calibrated
+	other := Calibration{Urn: TemperatureURN, Offset: 1}.Apply(m)
+	is.Equal(*other.Value, 2.5)
+	is.True(!other.Calibrated)
+
+	b := true
+	is.True(!c.Apply(Measurement{ID: "device/3200/5500", Urn: DigitalInputURN, BoolValue: &b}).Calibrated)
+
+	// the calibration of the reporting device is used
+	container := NewContainer("container-001", DefaultLocation, "default")
+	container.AddDevice("other")
+	container.AddDevice("device")
+	container.(*Container).RefDevices[1].Calibration = &c
+
+	is.Equal(*Calibrate(container, m).Value, 4.5)
+	is.Equal(*Calibrate(container, Measurement{ID: "other/3330/5700", Urn: DistanceURN, Value: &v}).Value, 2.5)
+}
+
+func TestConvToThingWithUnknownType(t *testing.T) {
+	is := is.New(t)
+
+	b := []byte(`{"id":"bench-001","type":"Bench","tenant":"default","description":"by the lake"}`)
+
+	_, err := ConvToThing(b)
+	is.True(err != nil)
+
+	thing, err := ConvToThingOrPassthrough(b)
+	is.NoErr(err)
+	is.Equal(thing.Type(), "Bench")
+
+	_, ok := thing.(*Passthrough)
+	is.True(ok)
+	is.True(strings.Contains(string(thing.Byte()), `"description":"by the lake"`))
+}
+
+func TestInspectionDue(t *testing.T) {
+	is := is.New(t)
+
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	overdue := now.Add(-24 * time.Hour)
+	notDue := now.Add(30 * 24 * time.Hour)
+
+	is.True(Inspection{NextInspectionDue: &overdue}.InspectionDue(now))
+	is.True(Inspection{NextInspectionDue: &now}.InspectionDue(now))
+	is.True(!Inspection{NextInspectionDue: &notDue}.InspectionDue(now))
+	is.True(!Inspection{}.InspectionDue(now))
+
+	b := []byte(`{"id":"lifebuoy-001","type":"Lifebuoy","tenant":"default","lastInspectedAt":"2024-05-01T08:00:00Z","nextInspectionDue":"2024-05-31T00:00:00Z"}`)
+	thing, err := ConvToThing(b)
+	is.NoErr(err)
+
+	lifebuoy := thing.(*Lifebuoy)
+	is.True(lifebuoy.InspectionDue(now))
+	is.True(strings.Contains(string(thing.Byte()), `"nextInspectionDue":"2024-05-31T00:00:00Z"`))
+}
+
+func TestNormalizeUnit(t *testing.T) {
+	is := is.New(t)
+
+	unit, err := NormalizeUnit(TemperatureURN, "device/3303/5700", senml.UnitCelsius)
+	is.NoErr(err)
+	is.Equal(unit, senml.UnitCelsius)
+
+	unit, err = NormalizeUnit(TemperatureURN, "device/3303/5700", "Celsius")
+	is.NoErr(err)
+	is.Equal(unit, senml.UnitCelsius)
+
+	unit, err = NormalizeUnit(FillingLevelURN, "device/3435/3", "metre")
+	is.NoErr(err)
+	is.Equal(unit, senml.UnitMeter)
+
+	unit, err = NormalizeUnit(HumidityURN, "device/3304/5700", senml.UnitRelativeHumidity) // an alias is stored as the unit
+	is.NoErr(err)
+	is.Equal(unit, "%")
+
+	unit, err = NormalizeUnit(IlluminanceURN, "device/3301/5700", senml.UnitLux)
+	is.NoErr(err)
+	is.Equal(unit, "lux")
+
+	unit, err = NormalizeUnit(WaterMeterURN, "device/3424/1", senml.UnitLiter) // an alternative unit is kept as is
+	is.NoErr(err)
+	is.Equal(unit, senml.UnitLiter)
+
+	unit, err = NormalizeUnit(WaterMeterURN, "device/3424/1", "litre")
+	is.NoErr(err)
+	is.Equal(unit, senml.UnitLiter)
+
+	unit, err = NormalizeUnit(PeopleCounterURN, "device/3434/5", "") // values without unit are not checked
+	is.NoErr(err)
+	is.Equal(unit, "")
+
+	unit, err = NormalizeUnit("urn:oma:lwm2m:ext:9999", "device/9999/1", "whatever")
+	is.NoErr(err)
+	is.Equal(unit, "whatever")
+
+	_, err = NormalizeUnit(TemperatureURN, "device/3303/5700", "kWh")
+	is.True(errors.Is(err, ErrUnexpectedUnit))
+	is.True(strings.Contains(err.Error(), "expected Cel"))
+
+	_, err = NormalizeUnit(PresenceURN, "device/3302/5500", "Cel")
+	is.True(errors.Is(err, ErrUnexpectedUnit))
+}
+
+func TestWatermeterAlert(t *testing.T) {
+	is := is.New(t)
+
+	thing := NewWatermeter("id", Location{Latitude: 62, Longitude: 17}, "default")
+	wm := thing.(*Watermeter)
+
+	var alerts []WaterMeterAlert
+	flag := func(suffix string, b bool) error {
+		m := Measurement{
+			ID:        "device/3424" + suffix,
+			Urn:       WaterMeterURN,
+			BoolValue: &b,
+			Timestamp: time.Now(),
+		}
+		return wm.Handle([]Measurement{m}, func(vp ValueProvider) error {
+			if alert, ok := vp.(WaterMeterAlert); ok {
+				alerts = append(alerts, alert)
+			}
+			return nil
+		})
+	}
+
+	is.NoErr(flag(LeakageSuffix, true))
+	is.NoErr(flag(LeakageSuffix, true)) // still leaking, no new alert
+	is.NoErr(flag(BackflowSuffix, false))
+	is.NoErr(flag(LeakageSuffix, false))
+	is.NoErr(flag(FraudSuffix, true))
+
+	is.Equal(len(alerts), 2)
+	is.Equal(alerts[0].Kind, WaterMeterAlertLeak)
+	is.Equal(alerts[0].ThingID, "id")
+	is.Equal(alerts[1].Kind, WaterMeterAlertFraud)
+}
+
+func TestDesk(t *testing.T) {
+	is := is.New(t)
+
+	thing := NewDesk("id", Location{Latitude: 62, Longitude: 17}, "default")
+	desk := thing.(*Desk)
+
+	var values []Value
+	occupied := func(urn, id string, state bool, ts time.Time) {
+		m := Measurement{
+			ID:        id,
+			Urn:       urn,
+			BoolValue: &state,
+			Timestamp: ts,
+		}
+		is.NoErr(desk.Handle([]Measurement{m}, func(vp ValueProvider) error {
+			values = append(values, vp.Values()...)
+			return nil
+		}))
+	}
+
+	morning := time.Date(2024, 11, 1, 8, 0, 0, 0, time.UTC)
+
+	occupied(PresenceURN, "device/3302/5500", true, morning)
+	is.Equal(desk.Occupied, true)
+	is.Equal(desk.Presence, true)
+	is.Equal(*desk.OccupiedAt, morning)
+
+	occupied(PresenceURN, "device/3302/5500", true, morning.Add(30*time.Minute)) // still occupied
+	occupied(PresenceURN, "device/3302/5500", false, morning.Add(1*time.Hour))
+	is.Equal(desk.Occupied, false)
+	is.Equal(desk.Presence, false)
+	is.True(desk.OccupiedAt == nil)
+	is.Equal(desk.OccupancyToday, 1*time.Hour)
+
+	occupied(DigitalInputURN, "device/3200/5500", true, morning.Add(2*time.Hour))
+	occupied(DigitalInputURN, "device/3200/5500", false, morning.Add(2*time.Hour+30*time.Minute))
+	is.Equal(desk.OccupancyToday, 90*time.Minute)
+
+	is.Equal(len(values), 4) // a presence value for each transition
+	is.Equal(*values[0].BoolValue, true)
+	is.Equal(*values[1].BoolValue, false)
+
+	// an occupancy over midnight only counts the time after midnight on the next day
+	occupied(PresenceURN, "device/3302/5500", true, morning.Add(15*time.Hour))
+	occupied(PresenceURN, "device/3302/5500", false, morning.Add(17*time.Hour))
+	is.Equal(desk.OccupancyToday, 1*time.Hour)
+
+	b := desk.Byte()
+	d, err := ConvToThing(b)
+	is.NoErr(err)
+	is.Equal(d.(*Desk).OccupancyToday, 1*time.Hour)
+	is.True(strings.Contains(string(b), `"presence":false`))
+}
@@ -4,8 +4,6 @@ import (
 	"fmt"
 	"math"
 	"time"
-
-	"github.com/diwise/senml"
 )
 
 const (
@@ -38,6 +36,7 @@ var (
 	PumpingStationURNs  = []string{DigitalInputURN}
 	RoomURNs            = []string{TemperatureURN, HumidityURN, IlluminanceURN, AirQualityURN, PresenceURN}
 	SewerURNs           = []string{DistanceURN, DigitalInputURN}
+	StreetLightURNs     = []string{DigitalInputURN, IlluminanceURN}
 	WaterMeterURNs      = []string{WaterMeterURN}
 	DeskURNs            = []string{DigitalInputURN, PresenceURN}
 )
@@ -56,7 +55,19 @@ func hasChanged(a, b any) bool {
 }
 
 func isNotZero(v float64) bool {
-	return (math.Abs(v) >= 0.001)
+	return (math.Abs(v) >= defaultChangeThreshold)
+}
+
+const defaultChangeThreshold float64 = 0.001
+
+// hasValueChanged is like hasChanged for numeric values but uses the change threshold set for the urn
+func (t *thingImpl) hasValueChanged(urn string, a, b float64) bool {
+	threshold, ok := t.changeThresholds[urn]
+	if !ok {
+		return isNotZero(a - b)
+	}
+
+	return math.Abs(a-b) >= threshold
 }
 
 /* --------------------- Filling Level --------------------- */
@@ -195,6 +206,29 @@ func (a AirQuality) Values() []Value {
 	return []Value{a.CO2}
 }
 
+// ParticulateMatter is the concentration of particles, in µg/m³, measured by an air quality sensor
+type ParticulateMatter struct {
+	Value Value
+}
+
+func NewAirQualityPM10(id, ref string, value float64, ts time.Time) ParticulateMatter {
+	id = fmt.Sprintf("%s/%s/%s", id, "3428", "1")
+	return ParticulateMatter{
+		Value: newValue(id, AirQualityURN, ref, "ug/m3", ts, value),
+	}
+}
+
+func NewAirQualityPM25(id, ref string, value float64, ts time.Time) ParticulateMatter {
+	id = fmt.Sprintf("%s/%s/%s", id, "3428", "3")
+	return ParticulateMatter{
+		Value: newValue(id, AirQualityURN, ref, "ug/m3", ts, value),
+	}
+}
+
+func (p ParticulateMatter) Values() []Value {
+	return []Value{p.Value}
+}
+
 /* --------------------- Presence --------------------- */
 
 type Presence struct {
@@ -293,8 +327,8 @@ type WaterMeter struct {
 	FraudDetected        Value
 }
 
-func NewWaterMeter(id, ref string, v float64, l, b, f bool, ts time.Time) WaterMeter {
-	vol := newValue(fmt.Sprintf("%s/%s/%s", id, "3424", "1"), WaterMeterURN, ref, senml.UnitCubicMeter, ts, v)
+func NewWaterMeter(id, ref string, v float64, unit string, l, b, f bool, ts time.Time) WaterMeter {
+	vol := newValue(fmt.Sprintf("%s/%s/%s", id, "3424", "1"), WaterMeterURN, ref, unit, ts, v)
 	leak := newBoolValue(fmt.Sprintf("%s/%s/%s", id, "3424", "10"), WaterMeterURN, ref, "", ts, l)
 	backflow := newBoolValue(fmt.Sprintf("%s/%s/%s", id, "3424", "11"), WaterMeterURN, ref, "", ts, b)
 	fraud := newBoolValue(fmt.Sprintf("%s/%s/%s", id, "3424", "13"), WaterMeterURN, ref, "", ts, f)
@@ -315,3 +349,55 @@ func (p WaterMeter) Values() []Value {
 		p.FraudDetected,
 	}
 }
+
+/* --------------------- WaterMeter alert --------------------- */
+
+const (
+	WaterMeterAlertLeak     string = "leak"
+	WaterMeterAlertBackflow string = "backflow"
+	WaterMeterAlertFraud    string = "fraud"
+)
+
+// WaterMeterAlert is raised when the leak, backflow or fraud flag of a water meter goes from false to true.
+// It carries no values to store.
+type WaterMeterAlert struct {
+	ThingID   string
+	Kind      string
+	Timestamp time.Time
+}
+
+func NewWaterMeterAlert(id, kind string, ts time.Time) WaterMeterAlert {
+	return WaterMeterAlert{
+		ThingID:   id,
+		Kind:      kind,
+		Timestamp: ts.UTC(),
+	}
+}
+
+func (a WaterMeterAlert) Values() []Value {
+	return []Value{}
+}
+
+/* --------------------- Water Consumption --------------------- */
+
+type WaterConsumption struct {
+	Daily   Value
+	Monthly Value
+}
+
+func NewWaterConsumption(id, ref string, daily, monthly float64, unit string, ts time.Time) WaterConsumption {
+	d := newValue(fmt.Sprintf("%s/%s/%s", id, "3424", "daily"), WaterMeterURN, ref, unit, ts, daily)
+	m := newValue(fmt.Sprintf("%s/%s/%s", id, "3424", "monthly"), WaterMeterURN, ref, unit, ts, monthly)
+
+	return WaterConsumption{
+		Daily:   d,
+		Monthly: m,
+	}
+}
+
+func (c WaterConsumption) Values() []Value {
+	return []Value{
+		c.Daily,
+		c.Monthly,
+	}
+}
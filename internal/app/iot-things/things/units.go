@@ -0,0 +1,125 @@
+package things
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/diwise/senml"
+)
+
+var ErrUnexpectedUnit = errors.New("unexpected unit")
+
+// UnitDescriptor describes the unit of a value stored for an object/resource. Unit is the unit the value
+// is stored with, and Aliases are other names of the same unit, e.g. the SenML unit name. Alternatives are
+// other units a value of the resource may be stored in, e.g. l for water meters configured to report in liters.
+type UnitDescriptor struct {
+	Urn          string   `json:"urn"`
+	Resource     string   `json:"resource"` // object/resource, e.g. 3303/5700
+	Name         string   `json:"name"`
+	Unit         string   `json:"unit,omitempty"` // empty for counters and boolean values
+	Aliases      []string `json:"aliases,omitempty"`
+	Alternatives []string `json:"alternatives,omitempty"`
+	Boolean      bool     `json:"boolean,omitempty"`
+}
+
+// units must be kept in line with the value constructors in types.go
+var units = []UnitDescriptor{
+	{Urn: DigitalInputURN, Resource: "3200/5500", Name: "digital input state", Boolean: true},
+	{Urn: IlluminanceURN, Resource: "3301/5700", Name: "illuminance", Unit: "lux", Aliases: []string{senml.UnitLux}},
+	{Urn: PresenceURN, Resource: "3302/5500", Name: "presence", Boolean: true},
+	{Urn: TemperatureURN, Resource: "3303/5700", Name: "temperature", Unit: senml.UnitCelsius},
+	{Urn: HumidityURN, Resource: "3304/5700", Name: "humidity", Unit: "%", Aliases: []string{senml.UnitRelativeHumidity}},
+	{Urn: PowerURN, Resource: "3328/5700", Name: "power", Unit: "kW"},
+	{Urn: EnergyURN, Resource: "3331/5700", Name: "energy", Unit: "kWh"},
+	{Urn: StopwatchURN, Resource: "3350/5544", Name: "cumulative time", Unit: senml.UnitSecond},
+	{Urn: StopwatchURN, Resource: "3350/5850", Name: "on/off", Boolean: true},
+	{Urn: WaterMeterURN, Resource: "3424/1", Name: "cumulated water volume", Unit: senml.UnitCubicMeter, Alternatives: []string{senml.UnitLiter}},
+	{Urn: WaterMeterURN, Resource: "3424/10", Name: "leak", Boolean: true},
+	{Urn: WaterMeterURN, Resource: "3424/11", Name: "backflow", Boolean: true},
+	{Urn: WaterMeterURN, Resource: "3424/13", Name: "fraud", Boolean: true},
+	{Urn: WaterMeterURN, Resource: "3424/daily", Name: "daily water consumption", Unit: senml.UnitCubicMeter, Alternatives: []string{senml.UnitLiter}},
+	{Urn: WaterMeterURN, Resource: "3424/monthly", Name: "monthly water consumption", Unit: senml.UnitCubicMeter, Alternatives: []string{senml.UnitLiter}},
+	{Urn: AirQualityURN, Resource: "3428/1", Name: "PM10", Unit: "ug/m3", Aliases: []string{"µg/m3"}},
+	{Urn: AirQualityURN, Resource: "3428/3", Name: "PM2.5", Unit: "ug/m3", Aliases: []string{"µg/m3"}},
+	{Urn: AirQualityURN, Resource: "3428/17", Name: "CO2", Unit: "ppm"},
+	{Urn: PeopleCounterURN, Resource: "3434/5", Name: "daily number of passages"},
+	{Urn: PeopleCounterURN, Resource: "3434/6", Name: "cumulated number of passages"},
+	{Urn: FillingLevelURN, Resource: "3435/2", Name: "actual filling percentage", Unit: "%"},
+	{Urn: FillingLevelURN, Resource: "3435/3", Name: "actual filling level", Unit: senml.UnitMeter},
+	{Urn: DoorURN, Resource: "10351/50", Name: "door state", Boolean: true},
+}
+
+// Units returns the unit descriptors of all known values, ordered by object and resource
+func Units() []UnitDescriptor {
+	return slices.Clone(units)
+}
+
+// unitSpellings maps other spellings of units, in lower case, to the unit names used in units
+var unitSpellings = map[string]string{
+	"celsius": senml.UnitCelsius,
+	"°c":      senml.UnitCelsius,
+	"degc":    senml.UnitCelsius,
+	"m":       senml.UnitMeter,
+	"metre":   senml.UnitMeter,
+	"metres":  senml.UnitMeter,
+	"meter":   senml.UnitMeter,
+	"meters":  senml.UnitMeter,
+	"lux":     "lux",
+	"lx":      "lux",
+	"percent": "%",
+	"%rh":     senml.UnitRelativeHumidity,
+	"s":       senml.UnitSecond,
+	"sec":     senml.UnitSecond,
+	"seconds": senml.UnitSecond,
+	"kw":      "kW",
+	"kwh":     "kWh",
+	"ppm":     "ppm",
+	"ug/m3":   "ug/m3",
+	"ug/m³":   "ug/m3",
+	"µg/m3":   "ug/m3",
+	"µg/m³":   "ug/m3",
+	"m3":      senml.UnitCubicMeter,
+	"m³":      senml.UnitCubicMeter,
+	"l":       senml.UnitLiter,
+	"litre":   senml.UnitLiter,
+	"liter":   senml.UnitLiter,
+}
+
+// NormalizeUnit returns the unit to store a value of urn with measurement id with, given the unit it was
+// reported with. Aliases and other spellings of the expected unit, e.g. lx for lux or Celsius for Cel, are
+// replaced by the expected unit, while alternative units are kept. Values that are not described in units,
+// or that have no unit, are not checked.
+func NormalizeUnit(urn, id, unit string) (string, error) {
+	if unit == "" {
+		return unit, nil
+	}
+
+	idx := slices.IndexFunc(units, func(d UnitDescriptor) bool {
+		return d.Urn == urn && (id == d.Resource || strings.HasSuffix(id, "/"+d.Resource))
+	})
+	if idx < 0 {
+		return unit, nil
+	}
+	d := units[idx]
+
+	if d.Unit == "" {
+		return "", fmt.Errorf("%w: %s has no unit, got %s", ErrUnexpectedUnit, d.Name, unit)
+	}
+
+	spelled, ok := unitSpellings[strings.ToLower(unit)]
+	if !ok {
+		spelled = unit
+	}
+
+	if unit == d.Unit || slices.Contains(d.Aliases, unit) || spelled == d.Unit || slices.Contains(d.Aliases, spelled) {
+		return d.Unit, nil
+	}
+	if slices.Contains(d.Alternatives, spelled) {
+		return spelled, nil
+	}
+
+	expected := append([]string{d.Unit}, d.Alternatives...)
+	return "", fmt.Errorf("%w: %s is not a unit of %s, expected %s", ErrUnexpectedUnit, unit, d.Name, strings.Join(expected, " or "))
+}
@@ -3,7 +3,11 @@ package things
 import (
 	"encoding/json"
 	"errors"
+	"fmt"
 	"strings"
+	"time"
+
+	"github.com/diwise/senml"
 )
 
 const (
@@ -20,11 +24,88 @@ type Watermeter struct {
 	Burst            bool    `json:"burst"`
 	Backflow         bool    `json:"backflow"`
 	Fraud            bool    `json:"fraud"`
+
+	DailyConsumption   float64 `json:"dailyConsumption"`
+	MonthlyConsumption float64 `json:"monthlyConsumption"`
+
+	// volumes are stored in VolumeUnit, m3 (default) or l, whatever unit the meter reports in ObservedUnit
+	VolumeUnit   string `json:"volumeUnit,omitempty"`
+	ObservedUnit string `json:"observedUnit,omitempty"`
+
+	Consumption *consumption `json:"_consumption,omitempty"`
+}
+
+type consumption struct {
+	LastVolume float64   `json:"lastVolume"`
+	Day        time.Time `json:"day"`
+	Month      time.Time `json:"month"`
+	Unit       string    `json:"unit,omitempty"` // the unit of the stored volumes, m3 if empty
+}
+
+var volumeUnits = map[string]float64{
+	senml.UnitCubicMeter: 1,
+	senml.UnitLiter:      0.001,
+}
+
+// convertVolume converts v from one volume unit to another. A missing unit is assumed to be the unit converted to.
+func convertVolume(v float64, from, to string) (float64, error) {
+	if from == "" || from == to {
+		return v, nil
+	}
+
+	f, ok := volumeUnits[from]
+	if !ok {
+		return 0, fmt.Errorf("unsupported volume unit %s", from)
+	}
+	t, ok := volumeUnits[to]
+	if !ok {
+		return 0, fmt.Errorf("unsupported volume unit %s", to)
+	}
+
+	return v * f / t, nil
+}
+
+func (wm *Watermeter) volumeUnit() string {
+	if wm.VolumeUnit == "" {
+		return senml.UnitCubicMeter
+	}
+	return wm.VolumeUnit
+}
+
+// rescale converts the stored volumes if the volume unit of the meter has been changed
+func (wm *Watermeter) rescale(unit string) error {
+	from := senml.UnitCubicMeter
+	if wm.Consumption != nil && wm.Consumption.Unit != "" {
+		from = wm.Consumption.Unit
+	}
+
+	if from == unit {
+		return nil
+	}
+
+	for _, v := range []*float64{&wm.CumulativeVolume, &wm.DailyConsumption, &wm.MonthlyConsumption} {
+		converted, err := convertVolume(*v, from, unit)
+		if err != nil {
+			return err
+		}
+		*v = converted
+	}
+
+	if wm.Consumption != nil {
+		last, err := convertVolume(wm.Consumption.LastVolume, from, unit)
+		if err != nil {
+			return err
+		}
+		wm.Consumption.LastVolume = last
+		wm.Consumption.Unit = unit
+	}
+
+	return nil
 }
 
 func NewWatermeter(id string, l Location, tenant string) Thing {
 	return &Watermeter{
-		thingImpl: newThingImpl(id, "Room", l, tenant),
+		thingImpl: newThingImpl(id, "WaterMeter", l, tenant),
 	}
 }
 
@@ -45,32 +126,119 @@ func (wm *Watermeter) handle(m Measurement, onchange func(m ValueProvider) error
 
 	changed := false
 
+	unit := wm.volumeUnit()
+
 	if strings.HasSuffix(m.ID, CumulatedWaterVolumeSuffix) {
-		changed = hasChanged(wm.CumulativeVolume, *m.Value)
-		wm.CumulativeVolume = *m.Value
+		volume, err := convertVolume(*m.Value, m.Unit, unit)
+		if err != nil {
+			return err
+		}
+
+		err = wm.rescale(unit)
+		if err != nil {
+			return err
+		}
+
+		if m.Unit != "" {
+			wm.ObservedUnit = m.Unit
+		}
+
+		changed = wm.hasValueChanged(m.Urn, wm.CumulativeVolume, volume)
+		wm.CumulativeVolume = volume
+
+		if wm.updateConsumption(volume, unit, m.Timestamp) {
+			c := NewWaterConsumption(wm.ID(), m.ID, wm.DailyConsumption, wm.MonthlyConsumption, unit, m.Timestamp)
+			err := onchange(c)
+			if err != nil {
+				return err
+			}
+		}
 	}
 
+	alert := "" // set when a flag goes from false to true
+
 	if strings.HasSuffix(m.ID, LeakageSuffix) {
 		changed = hasChanged(wm.Leakage, *m.BoolValue)
+		alert = raised(WaterMeterAlertLeak, wm.Leakage, *m.BoolValue)
 		wm.Leakage = *m.BoolValue
 	}
 	if strings.HasSuffix(m.ID, BackflowSuffix) {
 		changed = hasChanged(wm.Backflow, *m.BoolValue)
+		alert = raised(WaterMeterAlertBackflow, wm.Backflow, *m.BoolValue)
 		wm.Backflow = *m.BoolValue
 	}
 	if strings.HasSuffix(m.ID, FraudSuffix) {
 		changed = hasChanged(wm.Fraud, *m.BoolValue)
+		alert = raised(WaterMeterAlertFraud, wm.Fraud, *m.BoolValue)
 		wm.Fraud = *m.BoolValue
 	}
 
 	if changed {
-		wm := NewWaterMeter(wm.ID(), m.ID, wm.CumulativeVolume, wm.Leakage, wm.Backflow, wm.Fraud, m.Timestamp)
-		return onchange(wm)
+		v := NewWaterMeter(wm.ID(), m.ID, wm.CumulativeVolume, unit, wm.Leakage, wm.Backflow, wm.Fraud, m.Timestamp)
+		err := onchange(v)
+		if err != nil {
+			return err
+		}
+	}
+
+	if alert != "" {
+		return onchange(NewWaterMeterAlert(wm.ID(), alert, m.Timestamp))
 	}
 
 	return nil
 }
 
+func raised(kind string, current, observed bool) string {
+	if !current && observed {
+		return kind
+	}
+	return ""
+}
+
+// updateConsumption adds the volume consumed since the previous reading to the daily and monthly buckets.
+// A reading lower than the previous one is treated as a meter reset, i.e. the meter started over from zero.
+func (wm *Watermeter) updateConsumption(volume float64, unit string, ts time.Time) bool {
+	ts = ts.UTC()
+	day := time.Date(ts.Year(), ts.Month(), ts.Day(), 0, 0, 0, 0, time.UTC)
+	month := time.Date(ts.Year(), ts.Month(), 1, 0, 0, 0, 0, time.UTC)
+
+	if wm.Consumption == nil {
+		wm.Consumption = &consumption{
+			LastVolume: volume,
+			Day:        day,
+			Month:      month,
+			Unit:       unit,
+		}
+		return false
+	}
+
+	if day.Before(wm.Consumption.Day) {
+		return false
+	}
+
+	delta := volume - wm.Consumption.LastVolume
+	if delta < 0 {
+		delta = volume
+	}
+
+	wm.Consumption.LastVolume = volume
+
+	if day.After(wm.Consumption.Day) {
+		wm.Consumption.Day = day
+		wm.DailyConsumption = 0
+	}
+
+	if month.After(wm.Consumption.Month) {
+		wm.Consumption.Month = month
+		wm.MonthlyConsumption = 0
+	}
+
+	wm.DailyConsumption += delta
+	wm.MonthlyConsumption += delta
+
+	return true
+}
+
 func (wm *Watermeter) Byte() []byte {
 	b, _ := json.Marshal(wm)
 	return b
@@ -0,0 +1,113 @@
+package iotthings
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/diwise/iot-things/internal/app/iot-things/things"
+)
+
+// StateInterval is a period during which a measurement of a thing kept the same boolean state.
+// End is nil for the last known state, whose Duration is counted until the end of the timeline.
+// Duration is in seconds.
+type StateInterval struct {
+	ID       string     `json:"id"`
+	Urn      string     `json:"urn"`
+	State    bool       `json:"state"`
+	Start    time.Time  `json:"start"`
+	End      *time.Time `json:"end,omitempty"`
+	Duration float64    `json:"duration"`
+}
+
+const (
+	maxTimelineValues int           = 10000
+	maxTimelineRange  time.Duration = 31 * 24 * time.Hour
+)
+
+// GetTimeline returns the state changes of all boolean measurements of a thing between from and to,
+// collapsed into intervals and ordered by start. Each measurement starts in the state it had before from,
+// and a timeline with more state changes than can be returned is rejected rather than cut short.
+func (a *app) GetTimeline(ctx context.Context, thingID string, from, to time.Time, tenants []string) ([]StateInterval, error) {
+	if to.Sub(from) > maxTimelineRange {
+		return nil, fmt.Errorf("%w: max %s", ErrTimeRangeExceeded, maxTimelineRange)
+	}
+
+	_, err := a.queryThing(ctx, thingID, tenants)
+	if err != nil {
+		return nil, err
+	}
+
+	before, err := a.reader.QueryValues(ctx, WithThingID(thingID), WithStates(), WithTimeRel("before"), WithTimeAt(from.Format(time.RFC3339)), WithShowLatest(true))
+	if err != nil {
+		return nil, err
+	}
+
+	initial, err := unmarshalValues(before.Data)
+	if err != nil {
+		return nil, err
+	}
+
+	// the state before the timeline is the state at its start
+	for i := range initial {
+		initial[i].Timestamp = from
+	}
+
+	within, err := a.reader.QueryValues(ctx, WithThingID(thingID), WithStates(), WithTimeRel("between"), WithTimeAt(from.Format(time.RFC3339)), WithEndTimeAt(to.Format(time.RFC3339)), WithLimit(maxTimelineValues+1))
+	if err != nil {
+		return nil, err
+	}
+
+	if within.Count > maxTimelineValues {
+		return nil, fmt.Errorf("%w: more than %d state changes", ErrTimeRangeExceeded, maxTimelineValues)
+	}
+
+	values, err := unmarshalValues(within.Data)
+	if err != nil {
+		return nil, err
+	}
+
+	return timeline(append(initial, values...), to), nil
+}
+
+// timeline collapses consecutive equal states of each measurement into intervals. Values must be ordered by time.
+func timeline(values []things.Value, to time.Time) []StateInterval {
+	intervals := []StateInterval{}
+	current := map[string]int{}
+
+	for _, v := range values {
+		if v.BoolValue == nil {
+			continue
+		}
+
+		if i, ok := current[v.ID]; ok {
+			if intervals[i].State == *v.BoolValue {
+				continue
+			}
+			end := v.Timestamp
+			intervals[i].End = &end
+			intervals[i].Duration = end.Sub(intervals[i].Start).Seconds()
+		}
+
+		current[v.ID] = len(intervals)
+		intervals = append(intervals, StateInterval{
+			ID:    v.ID,
+			Urn:   v.Urn,
+			State: *v.BoolValue,
+			Start: v.Timestamp,
+		})
+	}
+
+	for _, i := range current {
+		if to.After(intervals[i].Start) {
+			intervals[i].Duration = to.Sub(intervals[i].Start).Seconds()
+		}
+	}
+
+	slices.SortStableFunc(intervals, func(a, b StateInterval) int {
+		return a.Start.Compare(b.Start)
+	})
+
+	return intervals
+}
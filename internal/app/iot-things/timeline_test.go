@@ -0,0 +1,112 @@
+package iotthings
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/diwise/iot-things/internal/app/iot-things/things"
+	"github.com/matryer/is"
+)
+
+func TestTimeline(t *testing.T) {
+	is := is.New(t)
+
+	from := time.Date(2024, 11, 1, 8, 0, 0, 0, time.UTC)
+	to := from.Add(10 * time.Hour)
+
+	state := func(id string, ts time.Time, v bool) things.Value {
+		return things.NewDoor(id, "device", v, ts).Status
+	}
+
+	// open 08:00-10:00 with a repeated open at 09:00, closed from 10:00, and a second door open from 09:30
+	values := []things.Value{
+		state("door-001", from, true),
+		state("door-001", from.Add(1*time.Hour), true),
+		state("door-002", from.Add(90*time.Minute), true),
+		state("door-001", from.Add(2*time.Hour), false),
+	}
+
+	intervals := timeline(values, to)
+	is.Equal(len(intervals), 3)
+
+	is.True(intervals[0].State)
+	is.Equal(intervals[0].Start, from)
+	is.Equal(*intervals[0].End, from.Add(2*time.Hour))
+	is.Equal(intervals[0].Duration, (2 * time.Hour).Seconds())
+
+	is.Equal(intervals[1].ID, values[2].ID)
+	is.True(intervals[1].End == nil)
+	is.Equal(intervals[1].Duration, (510 * time.Minute).Seconds())
+
+	is.True(!intervals[2].State)
+	is.True(intervals[2].End == nil)
+	is.Equal(intervals[2].Duration, (8 * time.Hour).Seconds())
+
+	is.Equal(len(timeline([]things.Value{}, to)), 0)
+}
+
+func TestGetTimeline(t *testing.T) {
+	ctx := context.Background()
+	is := is.New(t)
+
+	from := time.Date(2024, 11, 1, 8, 0, 0, 0, time.UTC)
+	to := from.Add(4 * time.Hour)
+
+	r := &ThingsReaderMock{
+		QueryThingsFunc: func(ctx context.Context, conditions ...ConditionFunc) (QueryResult, error) {
+			desk := things.NewDesk("desk-001", things.DefaultLocation, "default")
+			return QueryResult{Data: [][]byte{desk.Byte()}}, nil
+		},
+		QueryValuesFunc: func(ctx context.Context, conditions ...ConditionFunc) (QueryResult, error) {
+			cond := newConditions(conditions...)
+			is.Equal(cond["states"], true)
+			if _, ok := cond["showlatest"]; ok {
+				is.Equal(cond["timerel"], "before")
+				return QueryResult{Data: [][]byte{presenceValue(from.Add(-2*time.Hour), false)}, Count: 1}, nil
+			}
+			is.Equal(cond["limit"], maxTimelineValues+1)
+			return QueryResult{Data: [][]byte{presenceValue(from.Add(30*time.Minute), true), presenceValue(from.Add(1*time.Hour), false)}, Count: 2}, nil
+		},
+	}
+	w := &ThingsWriterMock{}
+
+	app := New(ctx, r, w, msgCtxMock())
+
+	intervals, err := app.GetTimeline(ctx, "desk-001", from, to, []string{"default"})
+	is.NoErr(err)
+	is.Equal(len(intervals), 3)
+	is.True(!intervals[0].State)
+	is.Equal(intervals[0].Start, from)
+	is.Equal(intervals[0].Duration, (30 * time.Minute).Seconds())
+	is.Equal(intervals[1].Duration, (30 * time.Minute).Seconds())
+	is.Equal(intervals[2].Duration, (3 * time.Hour).Seconds())
+}
+
+func TestGetTimelineIsLimited(t *testing.T) {
+	ctx := context.Background()
+	is := is.New(t)
+
+	from := time.Date(2024, 11, 1, 8, 0, 0, 0, time.UTC)
+
+	r := &ThingsReaderMock{
+		QueryThingsFunc: func(ctx context.Context, conditions ...ConditionFunc) (QueryResult, error) {
+			desk := things.NewDesk("desk-001", things.DefaultLocation, "default")
+			return QueryResult{Data: [][]byte{desk.Byte()}}, nil
+		},
+		QueryValuesFunc: func(ctx context.Context, conditions ...ConditionFunc) (QueryResult, error) {
+			return QueryResult{Count: maxTimelineValues + 1}, nil
+		},
+	}
+	w := &ThingsWriterMock{}
+
+	app := New(ctx, r, w, msgCtxMock())
+
+	_, err := app.GetTimeline(ctx, "desk-001", from, from.Add(maxTimelineRange+time.Hour), []string{"default"})
+	is.True(errors.Is(err, ErrTimeRangeExceeded))
+	is.Equal(len(r.QueryValuesCalls()), 0)
+
+	_, err = app.GetTimeline(ctx, "desk-001", from, from.Add(time.Hour), []string{"default"})
+	is.True(errors.Is(err, ErrTimeRangeExceeded))
+}
@@ -0,0 +1,126 @@
+package iotthings
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/diwise/iot-things/internal/app/iot-things/things"
+)
+
+// Utilization is how large part of a period a thing was occupied, derived from stored presence values.
+// Occupied and Observed are in seconds. Time before the first known presence state is not observed
+// and is excluded, so Utilization is the fraction of the observed time that the thing was occupied.
+type Utilization struct {
+	From        time.Time `json:"from"`
+	To          time.Time `json:"to"`
+	Occupied    float64   `json:"occupied"`
+	Observed    float64   `json:"observed"`
+	Utilization float64   `json:"utilization"`
+}
+
+const (
+	maxUtilizationValues int           = 10000
+	maxUtilizationRange  time.Duration = 31 * 24 * time.Hour
+)
+
+// GetUtilization returns the utilization of a thing between from and to. A period with more presence
+// transitions than can be fetched is rejected rather than computed from a part of them.
+func (a *app) GetUtilization(ctx context.Context, thingID string, from, to time.Time, tenants []string) (Utilization, error) {
+	if to.Sub(from) > maxUtilizationRange {
+		return Utilization{}, fmt.Errorf("%w: max %s", ErrTimeRangeExceeded, maxUtilizationRange)
+	}
+
+	_, err := a.queryThing(ctx, thingID, tenants)
+	if err != nil {
+		return Utilization{}, err
+	}
+
+	presence := []string{things.PresenceURN}
+
+	// the state at the start of the period is given by the last value before it
+	before, err := a.reader.QueryValues(ctx, WithThingID(thingID), WithUrn(presence), WithTimeRel("before"), WithTimeAt(from.Format(time.RFC3339)), WithNewestFirst(), WithLimit(1))
+	if err != nil {
+		return Utilization{}, err
+	}
+
+	within, err := a.reader.QueryValues(ctx, WithThingID(thingID), WithUrn(presence), WithTimeRel("between"), WithTimeAt(from.Format(time.RFC3339)), WithEndTimeAt(to.Format(time.RFC3339)), WithLimit(maxUtilizationValues+1))
+	if err != nil {
+		return Utilization{}, err
+	}
+
+	if within.Count > maxUtilizationValues {
+		return Utilization{}, fmt.Errorf("%w: more than %d presence values", ErrTimeRangeExceeded, maxUtilizationValues)
+	}
+
+	values, err := unmarshalValues(append(before.Data, within.Data...))
+	if err != nil {
+		return Utilization{}, err
+	}
+
+	return utilization(values, from, to), nil
+}
+
+func unmarshalValues(data [][]byte) ([]things.Value, error) {
+	values := make([]things.Value, 0, len(data))
+	for _, b := range data {
+		v := things.Value{}
+		err := json.Unmarshal(b, &v)
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, v)
+	}
+	return values, nil
+}
+
+// utilization sums the time between presence transitions. Values must be ordered by time and
+// values before from only set the initial state.
+func utilization(values []things.Value, from, to time.Time) Utilization {
+	u := Utilization{
+		From: from,
+		To:   to,
+	}
+
+	var state *bool
+	var since time.Time
+
+	add := func(until time.Time) {
+		if state == nil || !until.After(since) {
+			return
+		}
+		d := until.Sub(since).Seconds()
+		u.Observed += d
+		if *state {
+			u.Occupied += d
+		}
+	}
+
+	for _, v := range values {
+		if v.BoolValue == nil {
+			continue
+		}
+
+		ts := v.Timestamp
+		if ts.After(to) {
+			break
+		}
+		if ts.Before(from) {
+			ts = from
+		}
+
+		add(ts)
+
+		state = v.BoolValue
+		since = ts
+	}
+
+	add(to)
+
+	if u.Observed > 0 {
+		u.Utilization = u.Occupied / u.Observed
+	}
+
+	return u
+}
@@ -0,0 +1,107 @@
+package iotthings
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/diwise/iot-things/internal/app/iot-things/things"
+	"github.com/matryer/is"
+)
+
+func TestUtilization(t *testing.T) {
+	is := is.New(t)
+
+	from := time.Date(2024, 11, 1, 8, 0, 0, 0, time.UTC)
+	to := from.Add(10 * time.Hour)
+
+	presence := func(ts time.Time, v bool) things.Value {
+		return things.NewPresence("desk-001", "device", v, ts).Value
+	}
+
+	// occupied since before the period, free 10:00-12:00, occupied 12:00-13:00, free for the rest
+	values := []things.Value{
+		presence(from.Add(-1*time.Hour), true),
+		presence(from.Add(2*time.Hour), false),
+		presence(from.Add(4*time.Hour), true),
+		presence(from.Add(5*time.Hour), false),
+	}
+
+	u := utilization(values, from, to)
+	is.Equal(u.Observed, (10 * time.Hour).Seconds())
+	is.Equal(u.Occupied, (3 * time.Hour).Seconds())
+	is.Equal(u.Utilization, 0.3)
+
+	// no state is known before the first value in the period
+	u = utilization(values[1:], from, to)
+	is.Equal(u.Observed, (8 * time.Hour).Seconds())
+	is.Equal(u.Occupied, (1 * time.Hour).Seconds())
+	is.Equal(u.Utilization, 0.125)
+
+	u = utilization([]things.Value{}, from, to)
+	is.Equal(u.Observed, 0.0)
+	is.Equal(u.Utilization, 0.0)
+}
+
+func TestGetUtilization(t *testing.T) {
+	ctx := context.Background()
+	is := is.New(t)
+
+	from := time.Date(2024, 11, 1, 8, 0, 0, 0, time.UTC)
+	to := from.Add(4 * time.Hour)
+
+	r := &ThingsReaderMock{
+		QueryThingsFunc: func(ctx context.Context, conditions ...ConditionFunc) (QueryResult, error) {
+			desk := things.NewDesk("desk-001", things.DefaultLocation, "default")
+			return QueryResult{Data: [][]byte{desk.Byte()}}, nil
+		},
+		QueryValuesFunc: func(ctx context.Context, conditions ...ConditionFunc) (QueryResult, error) {
+			cond := newConditions(conditions...)
+			if cond["timerel"] == "before" {
+				return QueryResult{Data: [][]byte{presenceValue(from.Add(-1*time.Hour), true)}}, nil
+			}
+			return QueryResult{Data: [][]byte{presenceValue(from.Add(1*time.Hour), false)}}, nil
+		},
+	}
+	w := &ThingsWriterMock{}
+
+	app := New(ctx, r, w, msgCtxMock())
+
+	u, err := app.GetUtilization(ctx, "desk-001", from, to, []string{"default"})
+	is.NoErr(err)
+	is.Equal(u.Utilization, 0.25)
+}
+
+func TestGetUtilizationIsLimited(t *testing.T) {
+	ctx := context.Background()
+	is := is.New(t)
+
+	from := time.Date(2024, 11, 1, 8, 0, 0, 0, time.UTC)
+
+	r := &ThingsReaderMock{
+		QueryThingsFunc: func(ctx context.Context, conditions ...ConditionFunc) (QueryResult, error) {
+			desk := things.NewDesk("desk-001", things.DefaultLocation, "default")
+			return QueryResult{Data: [][]byte{desk.Byte()}}, nil
+		},
+		QueryValuesFunc: func(ctx context.Context, conditions ...ConditionFunc) (QueryResult, error) {
+			return QueryResult{Count: maxUtilizationValues + 1}, nil
+		},
+	}
+	w := &ThingsWriterMock{}
+
+	app := New(ctx, r, w, msgCtxMock())
+
+	_, err := app.GetUtilization(ctx, "desk-001", from, from.Add(maxUtilizationRange+time.Hour), []string{"default"})
+	is.True(errors.Is(err, ErrTimeRangeExceeded))
+	is.Equal(len(r.QueryValuesCalls()), 0)
+
+	_, err = app.GetUtilization(ctx, "desk-001", from, from.Add(time.Hour), []string{"default"})
+	is.True(errors.Is(err, ErrTimeRangeExceeded))
+}
+
+func presenceValue(ts time.Time, v bool) []byte {
+	b, _ := json.Marshal(things.NewPresence("desk-001", "device", v, ts).Value)
+	return b
+}
@@ -7,6 +7,7 @@ import (
 	"context"
 	"github.com/diwise/iot-things/internal/app/iot-things/things"
 	"sync"
+	"time"
 )
 
 // Ensure, that ThingsWriterMock does implement ThingsWriter.
@@ -19,18 +20,51 @@ var _ ThingsWriter = &ThingsWriterMock{}
 //
 //		// make and configure a mocked ThingsWriter
 //		mockedThingsWriter := &ThingsWriterMock{
+//			AddRelationsFunc: func(ctx context.Context, relations map[string]things.Relation) error {
+//				panic("mock out the AddRelations method")
+//			},
 //			AddThingFunc: func(ctx context.Context, t things.Thing) error {
 //				panic("mock out the AddThing method")
 //			},
 //			AddValueFunc: func(ctx context.Context, t things.Thing, m things.Value) error {
 //				panic("mock out the AddValue method")
 //			},
+//			AddValueWithAggregateFunc: func(ctx context.Context, t things.Thing, m things.Value) error {
+//				panic("mock out the AddValueWithAggregate method")
+//			},
+//			AddValuesFunc: func(ctx context.Context, t things.Thing, values []things.Value, aggregate bool) (int64, error) {
+//				panic("mock out the AddValues method")
+//			},
 //			DeleteThingFunc: func(ctx context.Context, thingID string) error {
 //				panic("mock out the DeleteThing method")
 //			},
+//			DeleteValuesFunc: func(ctx context.Context, thingID string, conditions ...ConditionFunc) (int64, error) {
+//				panic("mock out the DeleteValues method")
+//			},
+//			MergeDuplicateFunc: func(ctx context.Context, target things.Thing, sourceID string) error {
+//				panic("mock out the MergeDuplicate method")
+//			},
+//			PurgeDeletedThingsFunc: func(ctx context.Context, deletedBefore time.Time) (int64, int64, error) {
+//				panic("mock out the PurgeDeletedThings method")
+//			},
+//			RedactValuesFunc: func(ctx context.Context, thingID string, conditions ...ConditionFunc) (int64, error) {
+//				panic("mock out the RedactValues method")
+//			},
+//			UndeleteThingFunc: func(ctx context.Context, thingID string) error {
+//				panic("mock out the UndeleteThing method")
+//			},
 //			UpdateThingFunc: func(ctx context.Context, t things.Thing) error {
 //				panic("mock out the UpdateThing method")
 //			},
+//			UpdateThingIfUnchangedFunc: func(ctx context.Context, t things.Thing, modifiedOn time.Time) error {
+//				panic("mock out the UpdateThingIfUnchanged method")
+//			},
+//			UpdateThingsFunc: func(ctx context.Context, t []things.Thing) error {
+//				panic("mock out the UpdateThings method")
+//			},
+//			UpsertThingFunc: func(ctx context.Context, t things.Thing) (bool, error) {
+//				panic("mock out the UpsertThing method")
+//			},
 //		}
 //
 //		// use mockedThingsWriter in code that requires ThingsWriter
@@ -38,20 +72,60 @@ var _ ThingsWriter = &ThingsWriterMock{}
 //
 //	}
 type ThingsWriterMock struct {
+	// AddRelationsFunc mocks the AddRelations method.
+	AddRelationsFunc func(ctx context.Context, relations map[string]things.Relation) error
+
 	// AddThingFunc mocks the AddThing method.
 	AddThingFunc func(ctx context.Context, t things.Thing) error
 
 	// AddValueFunc mocks the AddValue method.
 	AddValueFunc func(ctx context.Context, t things.Thing, m things.Value) error
 
+	// AddValueWithAggregateFunc mocks the AddValueWithAggregate method.
+	AddValueWithAggregateFunc func(ctx context.Context, t things.Thing, m things.Value) error
+
+	// AddValuesFunc mocks the AddValues method.
+	AddValuesFunc func(ctx context.Context, t things.Thing, values []things.Value, aggregate bool) (int64, error)
+
 	// DeleteThingFunc mocks the DeleteThing method.
 	DeleteThingFunc func(ctx context.Context, thingID string) error
 
+	// DeleteValuesFunc mocks the DeleteValues method.
+	DeleteValuesFunc func(ctx context.Context, thingID string, conditions ...ConditionFunc) (int64, error)
+
+	// MergeDuplicateFunc mocks the MergeDuplicate method.
+	MergeDuplicateFunc func(ctx context.Context, target things.Thing, sourceID string) error
+
+	// PurgeDeletedThingsFunc mocks the PurgeDeletedThings method.
+	PurgeDeletedThingsFunc func(ctx context.Context, deletedBefore time.Time) (int64, int64, error)
+
+	// RedactValuesFunc mocks the RedactValues method.
+	RedactValuesFunc func(ctx context.Context, thingID string, conditions ...ConditionFunc) (int64, error)
+
+	// UndeleteThingFunc mocks the UndeleteThing method.
+	UndeleteThingFunc func(ctx context.Context, thingID string) error
+
 	// UpdateThingFunc mocks the UpdateThing method.
 	UpdateThingFunc func(ctx context.Context, t things.Thing) error
 
+	// UpdateThingIfUnchangedFunc mocks the UpdateThingIfUnchanged method.
+	UpdateThingIfUnchangedFunc func(ctx context.Context, t things.Thing, modifiedOn time.Time) error
+
+	// UpdateThingsFunc mocks the UpdateThings method.
+	UpdateThingsFunc func(ctx context.Context, t []things.Thing) error
+
+	// UpsertThingFunc mocks the UpsertThing method.
+	UpsertThingFunc func(ctx context.Context, t things.Thing) (bool, error)
+
 	// calls tracks calls to the methods.
 	calls struct {
+		// AddRelations holds details about calls to the AddRelations method.
+		AddRelations []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// Relations is the relations argument value.
+			Relations map[string]things.Relation
+		}
 		// AddThing holds details about calls to the AddThing method.
 		AddThing []struct {
 			// Ctx is the ctx argument value.
@@ -68,6 +142,26 @@ type ThingsWriterMock struct {
 			// M is the m argument value.
 			M things.Value
 		}
+		// AddValueWithAggregate holds details about calls to the AddValueWithAggregate method.
+		AddValueWithAggregate []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// T is the t argument value.
+			T things.Thing
+			// M is the m argument value.
+			M things.Value
+		}
+		// AddValues holds details about calls to the AddValues method.
+		AddValues []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// T is the t argument value.
+			T things.Thing
+			// Values is the values argument value.
+			Values []things.Value
+			// Aggregate is the aggregate argument value.
+			Aggregate bool
+		}
 		// DeleteThing holds details about calls to the DeleteThing method.
 		DeleteThing []struct {
 			// Ctx is the ctx argument value.
@@ -75,6 +169,47 @@ type ThingsWriterMock struct {
 			// ThingID is the thingID argument value.
 			ThingID string
 		}
+		// DeleteValues holds details about calls to the DeleteValues method.
+		DeleteValues []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// ThingID is the thingID argument value.
+			ThingID string
+			// Conditions is the conditions argument value.
+			Conditions []ConditionFunc
+		}
+		// MergeDuplicate holds details about calls to the MergeDuplicate method.
+		MergeDuplicate []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// Target is the target argument value.
+			Target things.Thing
+			// SourceID is the sourceID argument value.
+			SourceID string
+		}
+		// PurgeDeletedThings holds details about calls to the PurgeDeletedThings method.
+		PurgeDeletedThings []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// DeletedBefore is the deletedBefore argument value.
+			DeletedBefore time.Time
+		}
+		// RedactValues holds details about calls to the RedactValues method.
+		RedactValues []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// ThingID is the thingID argument value.
+			ThingID string
+			// Conditions is the conditions argument value.
+			Conditions []ConditionFunc
+		}
+		// UndeleteThing holds details about calls to the UndeleteThing method.
+		UndeleteThing []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// ThingID is the thingID argument value.
+			ThingID string
+		}
 		// UpdateThing holds details about calls to the UpdateThing method.
 		UpdateThing []struct {
 			// Ctx is the ctx argument value.
@@ -82,11 +217,81 @@ type ThingsWriterMock struct {
 			// T is the t argument value.
 			T things.Thing
 		}
+		// UpdateThingIfUnchanged holds details about calls to the UpdateThingIfUnchanged method.
+		UpdateThingIfUnchanged []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// T is the t argument value.
+			T things.Thing
+			// ModifiedOn is the modifiedOn argument value.
+			ModifiedOn time.Time
+		}
+		// UpdateThings holds details about calls to the UpdateThings method.
+		UpdateThings []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// T is the t argument value.
+			T []things.Thing
+		}
+		// UpsertThing holds details about calls to the UpsertThing method.
+		UpsertThing []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// T is the t argument value.
+			T things.Thing
+		}
 	}
-	lockAddThing    sync.RWMutex
-	lockAddValue    sync.RWMutex
-	lockDeleteThing sync.RWMutex
-	lockUpdateThing sync.RWMutex
+	lockAddRelations           sync.RWMutex
+	lockAddThing               sync.RWMutex
+	lockAddValue               sync.RWMutex
+	lockAddValueWithAggregate  sync.RWMutex
+	lockAddValues              sync.RWMutex
+	lockDeleteThing            sync.RWMutex
+	lockDeleteValues           sync.RWMutex
+	lockMergeDuplicate         sync.RWMutex
+	lockPurgeDeletedThings     sync.RWMutex
+	lockRedactValues           sync.RWMutex
+	lockUndeleteThing          sync.RWMutex
+	lockUpdateThing            sync.RWMutex
+	lockUpdateThingIfUnchanged sync.RWMutex
+	lockUpdateThings           sync.RWMutex
+	lockUpsertThing            sync.RWMutex
+}
+
+// AddRelations calls AddRelationsFunc.
+func (mock *ThingsWriterMock) AddRelations(ctx context.Context, relations map[string]things.Relation) error {
+	if mock.AddRelationsFunc == nil {
+		panic("ThingsWriterMock.AddRelationsFunc: method is nil but ThingsWriter.AddRelations was just called")
+	}
+	callInfo := struct {
+		Ctx       context.Context
+		Relations map[string]things.Relation
+	}{
+		Ctx:       ctx,
+		Relations: relations,
+	}
+	mock.lockAddRelations.Lock()
+	mock.calls.AddRelations = append(mock.calls.AddRelations, callInfo)
+	mock.lockAddRelations.Unlock()
+	return mock.AddRelationsFunc(ctx, relations)
+}
+
+// AddRelationsCalls gets all the calls that were made to AddRelations.
+// Check the length with:
+//
+//	len(mockedThingsWriter.AddRelationsCalls())
+func (mock *ThingsWriterMock) AddRelationsCalls() []struct {
+	Ctx       context.Context
+	Relations map[string]things.Relation
+} {
+	var calls []struct {
+		Ctx       context.Context
+		Relations map[string]things.Relation
+	}
+	mock.lockAddRelations.RLock()
+	calls = mock.calls.AddRelations
+	mock.lockAddRelations.RUnlock()
+	return calls
 }
 
 // AddThing calls AddThingFunc.
@@ -165,6 +370,90 @@ func (mock *ThingsWriterMock) AddValueCalls() []struct {
 	return calls
 }
 
+// AddValueWithAggregate calls AddValueWithAggregateFunc.
+func (mock *ThingsWriterMock) AddValueWithAggregate(ctx context.Context, t things.Thing, m things.Value) error {
+	if mock.AddValueWithAggregateFunc == nil {
+		panic("ThingsWriterMock.AddValueWithAggregateFunc: method is nil but ThingsWriter.AddValueWithAggregate was just called")
+	}
+	callInfo := struct {
+		Ctx context.Context
+		T   things.Thing
+		M   things.Value
+	}{
+		Ctx: ctx,
+		T:   t,
+		M:   m,
+	}
+	mock.lockAddValueWithAggregate.Lock()
+	mock.calls.AddValueWithAggregate = append(mock.calls.AddValueWithAggregate, callInfo)
+	mock.lockAddValueWithAggregate.Unlock()
+	return mock.AddValueWithAggregateFunc(ctx, t, m)
+}
+
+// AddValueWithAggregateCalls gets all the calls that were made to AddValueWithAggregate.
+// Check the length with:
+//
+//	len(mockedThingsWriter.AddValueWithAggregateCalls())
+func (mock *ThingsWriterMock) AddValueWithAggregateCalls() []struct {
+	Ctx context.Context
+	T   things.Thing
+	M   things.Value
+} {
+	var calls []struct {
+		Ctx context.Context
+		T   things.Thing
+		M   things.Value
+	}
+	mock.lockAddValueWithAggregate.RLock()
+	calls = mock.calls.AddValueWithAggregate
+	mock.lockAddValueWithAggregate.RUnlock()
+	return calls
+}
+
+// AddValues calls AddValuesFunc.
+func (mock *ThingsWriterMock) AddValues(ctx context.Context, t things.Thing, values []things.Value, aggregate bool) (int64, error) {
+	if mock.AddValuesFunc == nil {
+		panic("ThingsWriterMock.AddValuesFunc: method is nil but ThingsWriter.AddValues was just called")
+	}
+	callInfo := struct {
+		Ctx       context.Context
+		T         things.Thing
+		Values    []things.Value
+		Aggregate bool
+	}{
+		Ctx:       ctx,
+		T:         t,
+		Values:    values,
+		Aggregate: aggregate,
+	}
+	mock.lockAddValues.Lock()
+	mock.calls.AddValues = append(mock.calls.AddValues, callInfo)
+	mock.lockAddValues.Unlock()
+	return mock.AddValuesFunc(ctx, t, values, aggregate)
+}
+
+// AddValuesCalls gets all the calls that were made to AddValues.
+// Check the length with:
+//
+//	len(mockedThingsWriter.AddValuesCalls())
+func (mock *ThingsWriterMock) AddValuesCalls() []struct {
+	Ctx       context.Context
+	T         things.Thing
+	Values    []things.Value
+	Aggregate bool
+} {
+	var calls []struct {
+		Ctx       context.Context
+		T         things.Thing
+		Values    []things.Value
+		Aggregate bool
+	}
+	mock.lockAddValues.RLock()
+	calls = mock.calls.AddValues
+	mock.lockAddValues.RUnlock()
+	return calls
+}
+
 // DeleteThing calls DeleteThingFunc.
 func (mock *ThingsWriterMock) DeleteThing(ctx context.Context, thingID string) error {
 	if mock.DeleteThingFunc == nil {
@@ -201,6 +490,198 @@ func (mock *ThingsWriterMock) DeleteThingCalls() []struct {
 	return calls
 }
 
+// DeleteValues calls DeleteValuesFunc.
+func (mock *ThingsWriterMock) DeleteValues(ctx context.Context, thingID string, conditions ...ConditionFunc) (int64, error) {
+	if mock.DeleteValuesFunc == nil {
+		panic("ThingsWriterMock.DeleteValuesFunc: method is nil but ThingsWriter.DeleteValues was just called")
+	}
+	callInfo := struct {
+		Ctx        context.Context
+		ThingID    string
+		Conditions []ConditionFunc
+	}{
+		Ctx:        ctx,
+		ThingID:    thingID,
+		Conditions: conditions,
+	}
+	mock.lockDeleteValues.Lock()
+	mock.calls.DeleteValues = append(mock.calls.DeleteValues, callInfo)
+	mock.lockDeleteValues.Unlock()
+	return mock.DeleteValuesFunc(ctx, thingID, conditions...)
+}
+
+// DeleteValuesCalls gets all the calls that were made to DeleteValues.
+// Check the length with:
+//
+//	len(mockedThingsWriter.DeleteValuesCalls())
+func (mock *ThingsWriterMock) DeleteValuesCalls() []struct {
+	Ctx        context.Context
+	ThingID    string
+	Conditions []ConditionFunc
+} {
+	var calls []struct {
+		Ctx        context.Context
+		ThingID    string
+		Conditions []ConditionFunc
+	}
+	mock.lockDeleteValues.RLock()
+	calls = mock.calls.DeleteValues
+	mock.lockDeleteValues.RUnlock()
+	return calls
+}
+
+// MergeDuplicate calls MergeDuplicateFunc.
+func (mock *ThingsWriterMock) MergeDuplicate(ctx context.Context, target things.Thing, sourceID string) error {
+	if mock.MergeDuplicateFunc == nil {
+		panic("ThingsWriterMock.MergeDuplicateFunc: method is nil but ThingsWriter.MergeDuplicate was just called")
+	}
+	callInfo := struct {
+		Ctx      context.Context
+		Target   things.Thing
+		SourceID string
+	}{
+		Ctx:      ctx,
+		Target:   target,
+		SourceID: sourceID,
+	}
+	mock.lockMergeDuplicate.Lock()
+	mock.calls.MergeDuplicate = append(mock.calls.MergeDuplicate, callInfo)
+	mock.lockMergeDuplicate.Unlock()
+	return mock.MergeDuplicateFunc(ctx, target, sourceID)
+}
+
+// MergeDuplicateCalls gets all the calls that were made to MergeDuplicate.
+// Check the length with:
+//
+//	len(mockedThingsWriter.MergeDuplicateCalls())
+func (mock *ThingsWriterMock) MergeDuplicateCalls() []struct {
+	Ctx      context.Context
+	Target   things.Thing
+	SourceID string
+} {
+	var calls []struct {
+		Ctx      context.Context
+		Target   things.Thing
+		SourceID string
+	}
+	mock.lockMergeDuplicate.RLock()
+	calls = mock.calls.MergeDuplicate
+	mock.lockMergeDuplicate.RUnlock()
+	return calls
+}
+
+// PurgeDeletedThings calls PurgeDeletedThingsFunc.
+func (mock *ThingsWriterMock) PurgeDeletedThings(ctx context.Context, deletedBefore time.Time) (int64, int64, error) {
+	if mock.PurgeDeletedThingsFunc == nil {
+		panic("ThingsWriterMock.PurgeDeletedThingsFunc: method is nil but ThingsWriter.PurgeDeletedThings was just called")
+	}
+	callInfo := struct {
+		Ctx           context.Context
+		DeletedBefore time.Time
+	}{
+		Ctx:           ctx,
+		DeletedBefore: deletedBefore,
+	}
+	mock.lockPurgeDeletedThings.Lock()
+	mock.calls.PurgeDeletedThings = append(mock.calls.PurgeDeletedThings, callInfo)
+	mock.lockPurgeDeletedThings.Unlock()
+	return mock.PurgeDeletedThingsFunc(ctx, deletedBefore)
+}
+
+// PurgeDeletedThingsCalls gets all the calls that were made to PurgeDeletedThings.
+// Check the length with:
+//
+//	len(mockedThingsWriter.PurgeDeletedThingsCalls())
+func (mock *ThingsWriterMock) PurgeDeletedThingsCalls() []struct {
+	Ctx           context.Context
+	DeletedBefore time.Time
+} {
+	var calls []struct {
+		Ctx           context.Context
+		DeletedBefore time.Time
+	}
+	mock.lockPurgeDeletedThings.RLock()
+	calls = mock.calls.PurgeDeletedThings
+	mock.lockPurgeDeletedThings.RUnlock()
+	return calls
+}
+
+// RedactValues calls RedactValuesFunc.
+func (mock *ThingsWriterMock) RedactValues(ctx context.Context, thingID string, conditions ...ConditionFunc) (int64, error) {
+	if mock.RedactValuesFunc == nil {
+		panic("ThingsWriterMock.RedactValuesFunc: method is nil but ThingsWriter.RedactValues was just called")
+	}
+	callInfo := struct {
+		Ctx        context.Context
+		ThingID    string
+		Conditions []ConditionFunc
+	}{
+		Ctx:        ctx,
+		ThingID:    thingID,
+		Conditions: conditions,
+	}
+	mock.lockRedactValues.Lock()
+	mock.calls.RedactValues = append(mock.calls.RedactValues, callInfo)
+	mock.lockRedactValues.Unlock()
+	return mock.RedactValuesFunc(ctx, thingID, conditions...)
+}
+
+// RedactValuesCalls gets all the calls that were made to RedactValues.
+// Check the length with:
+//
+//	len(mockedThingsWriter.RedactValuesCalls())
+func (mock *ThingsWriterMock) RedactValuesCalls() []struct {
+	Ctx        context.Context
+	ThingID    string
+	Conditions []ConditionFunc
+} {
+	var calls []struct {
+		Ctx        context.Context
+		ThingID    string
+		Conditions []ConditionFunc
+	}
+	mock.lockRedactValues.RLock()
+	calls = mock.calls.RedactValues
+	mock.lockRedactValues.RUnlock()
+	return calls
+}
+
+// UndeleteThing calls UndeleteThingFunc.
+func (mock *ThingsWriterMock) UndeleteThing(ctx context.Context, thingID string) error {
+	if mock.UndeleteThingFunc == nil {
+		panic("ThingsWriterMock.UndeleteThingFunc: method is nil but ThingsWriter.UndeleteThing was just called")
+	}
+	callInfo := struct {
+		Ctx     context.Context
+		ThingID string
+	}{
+		Ctx:     ctx,
+		ThingID: thingID,
+	}
+	mock.lockUndeleteThing.Lock()
+	mock.calls.UndeleteThing = append(mock.calls.UndeleteThing, callInfo)
+	mock.lockUndeleteThing.Unlock()
+	return mock.UndeleteThingFunc(ctx, thingID)
+}
+
+// UndeleteThingCalls gets all the calls that were made to UndeleteThing.
+// Check the length with:
+//
+//	len(mockedThingsWriter.UndeleteThingCalls())
+func (mock *ThingsWriterMock) UndeleteThingCalls() []struct {
+	Ctx     context.Context
+	ThingID string
+} {
+	var calls []struct {
+		Ctx     context.Context
+		ThingID string
+	}
+	mock.lockUndeleteThing.RLock()
+	calls = mock.calls.UndeleteThing
+	mock.lockUndeleteThing.RUnlock()
+	return calls
+}
+
 // UpdateThing calls UpdateThingFunc.
 func (mock *ThingsWriterMock) UpdateThing(ctx context.Context, t things.Thing) error {
 	if mock.UpdateThingFunc == nil {
@@ -236,3 +717,115 @@ func (mock *ThingsWriterMock) UpdateThingCalls() []struct {
 	mock.lockUpdateThing.RUnlock()
 	return calls
 }
+
+// UpdateThingIfUnchanged calls UpdateThingIfUnchangedFunc.
+func (mock *ThingsWriterMock) UpdateThingIfUnchanged(ctx context.Context, t things.Thing, modifiedOn time.Time) error {
+	if mock.UpdateThingIfUnchangedFunc == nil {
+		panic("ThingsWriterMock.UpdateThingIfUnchangedFunc: method is nil but ThingsWriter.UpdateThingIfUnchanged was just called")
+	}
+	callInfo := struct {
+		Ctx        context.Context
+		T          things.Thing
+		ModifiedOn time.Time
+	}{
+		Ctx:        ctx,
+		T:          t,
+		ModifiedOn: modifiedOn,
+	}
+	mock.lockUpdateThingIfUnchanged.Lock()
+	mock.calls.UpdateThingIfUnchanged = append(mock.calls.UpdateThingIfUnchanged, callInfo)
+	mock.lockUpdateThingIfUnchanged.Unlock()
+	return mock.UpdateThingIfUnchangedFunc(ctx, t, modifiedOn)
+}
+
+// UpdateThingIfUnchangedCalls gets all the calls that were made to UpdateThingIfUnchanged.
+// Check the length with:
+//
+//	len(mockedThingsWriter.UpdateThingIfUnchangedCalls())
+func (mock *ThingsWriterMock) UpdateThingIfUnchangedCalls() []struct {
+	Ctx        context.Context
+	T          things.Thing
+	ModifiedOn time.Time
+} {
+	var calls []struct {
+		Ctx        context.Context
+		T          things.Thing
+		ModifiedOn time.Time
+	}
+	mock.lockUpdateThingIfUnchanged.RLock()
+	calls = mock.calls.UpdateThingIfUnchanged
+	mock.lockUpdateThingIfUnchanged.RUnlock()
+	return calls
+}
+
+// UpdateThings calls UpdateThingsFunc.
+func (mock *ThingsWriterMock) UpdateThings(ctx context.Context, t []things.Thing) error {
+	if mock.UpdateThingsFunc == nil {
+		panic("ThingsWriterMock.UpdateThingsFunc: method is nil but ThingsWriter.UpdateThings was just called")
+	}
+	callInfo := struct {
+		Ctx context.Context
+		T   []things.Thing
+	}{
+		Ctx: ctx,
+		T:   t,
+	}
+	mock.lockUpdateThings.Lock()
+	mock.calls.UpdateThings = append(mock.calls.UpdateThings, callInfo)
+	mock.lockUpdateThings.Unlock()
+	return mock.UpdateThingsFunc(ctx, t)
+}
+
+// UpdateThingsCalls gets all the calls that were made to UpdateThings.
+// Check the length with:
+//
+//	len(mockedThingsWriter.UpdateThingsCalls())
+func (mock *ThingsWriterMock) UpdateThingsCalls() []struct {
+	Ctx context.Context
+	T   []things.Thing
+} {
+	var calls []struct {
+		Ctx context.Context
+		T   []things.Thing
+	}
+	mock.lockUpdateThings.RLock()
+	calls = mock.calls.UpdateThings
+	mock.lockUpdateThings.RUnlock()
+	return calls
+}
+
+// UpsertThing calls UpsertThingFunc.
+func (mock *ThingsWriterMock) UpsertThing(ctx context.Context, t things.Thing) (bool, error) {
+	if mock.UpsertThingFunc == nil {
+		panic("ThingsWriterMock.UpsertThingFunc: method is nil but ThingsWriter.UpsertThing was just called")
+	}
+	callInfo := struct {
+		Ctx context.Context
+		T   things.Thing
+	}{
+		Ctx: ctx,
+		T:   t,
+	}
+	mock.lockUpsertThing.Lock()
+	mock.calls.UpsertThing = append(mock.calls.UpsertThing, callInfo)
+	mock.lockUpsertThing.Unlock()
+	return mock.UpsertThingFunc(ctx, t)
+}
+
+// UpsertThingCalls gets all the calls that were made to UpsertThing.
+// Check the length with:
+//
+//	len(mockedThingsWriter.UpsertThingCalls())
+func (mock *ThingsWriterMock) UpsertThingCalls() []struct {
+	Ctx context.Context
+	T   things.Thing
+} {
+	var calls []struct {
+		Ctx context.Context
+		T   things.Thing
+	}
+	mock.lockUpsertThing.RLock()
+	calls = mock.calls.UpsertThing
+	mock.lockUpsertThing.RUnlock()
+	return calls
+}
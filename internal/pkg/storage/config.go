@@ -3,6 +3,9 @@ package storage
 import (
 	"context"
 	"fmt"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/diwise/service-chassis/pkg/infrastructure/env"
 )
@@ -14,6 +17,9 @@ type Config struct {
 	port     string
 	dbname   string
 	sslmode  string
+
+	chunkInterval string // chunk interval of the values hypertable, e.g. "1 day". TimescaleDB default if empty
+	compressAfter string // age of chunks of the values hypertable to compress, e.g. "30 days". No compression if empty
 }
 
 func NewConfig(host, user, password, port, dbname, sslmode string) Config {
@@ -35,9 +41,51 @@ func LoadConfiguration(ctx context.Context) Config {
 		port:     env.GetVariableOrDefault(ctx, "POSTGRES_PORT", "5432"),
 		dbname:   env.GetVariableOrDefault(ctx, "POSTGRES_DBNAME", "diwise"),
 		sslmode:  env.GetVariableOrDefault(ctx, "POSTGRES_SSLMODE", "disable"),
+
+		chunkInterval: env.GetVariableOrDefault(ctx, "CHUNK_INTERVAL", ""),
+		compressAfter: env.GetVariableOrDefault(ctx, "COMPRESS_AFTER", ""),
 	}
 }
 
 func (c Config) ConnStr() string {
 	return fmt.Sprintf("postgres://%s:%s@%s:%s/%s?sslmode=%s", c.user, c.password, c.host, c.port, c.dbname, c.sslmode)
 }
+
+var intervalUnits = map[string]time.Duration{
+	"second": time.Second,
+	"minute": time.Minute,
+	"hour":   time.Hour,
+	"day":    24 * time.Hour,
+	"week":   7 * 24 * time.Hour,
+}
+
+// parseInterval parses an interval given as a number and a unit, e.g. "1 day" or "12 hours", or as a Go
+// duration, e.g. "24h". An empty interval is zero.
+func parseInterval(s string) (time.Duration, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return 0, nil
+	}
+
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		fields := strings.Fields(strings.ToLower(s))
+		if len(fields) != 2 {
+			return 0, fmt.Errorf("invalid interval %q", s)
+		}
+
+		n, err := strconv.Atoi(fields[0])
+		unit, ok := intervalUnits[strings.TrimSuffix(fields[1], "s")]
+		if err != nil || !ok {
+			return 0, fmt.Errorf("invalid interval %q", s)
+		}
+
+		d = time.Duration(n) * unit
+	}
+
+	if d <= 0 {
+		return 0, fmt.Errorf("invalid interval %q, must be positive", s)
+	}
+
+	return d, nil
+}
@@ -0,0 +1,34 @@
+package storage
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+// queryDuration is created from the global meter provider until InitMetrics is given the provider
+// metrics are exported from, since the global provider only delegates to the first provider set
+var queryDuration atomic.Pointer[metric.Float64Histogram]
+
+func init() {
+	InitMetrics(otel.GetMeterProvider())
+}
+
+// InitMetrics creates the instruments of storage from the meter provider the metrics are exported from
+func InitMetrics(mp metric.MeterProvider) {
+	h, _ := mp.Meter("iot-things/storage").Float64Histogram(
+		"storage.query.duration",
+		metric.WithDescription("time taken to query things or values"),
+		metric.WithUnit("s"),
+	)
+	queryDuration.Store(&h)
+}
+
+// observeQuery records the time since start as the duration of a query of things or values
+func observeQuery(ctx context.Context, query string, start time.Time) {
+	(*queryDuration.Load()).Record(ctx, time.Since(start).Seconds(), metric.WithAttributes(attribute.String("query", query)))
+}
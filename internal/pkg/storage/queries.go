@@ -3,13 +3,44 @@ package storage
 import (
 	"encoding/json"
 	"fmt"
+	"math"
 	"strconv"
 	"strings"
 
 	app "github.com/diwise/iot-things/internal/app/iot-things"
+	"github.com/diwise/iot-things/internal/app/iot-things/things"
 	"github.com/jackc/pgx/v5"
 )
 
+// accented letters are replaced by the unaccented letter at the same position when matching names, so that
+// a search for "forradet" finds "Förrådet". Case is handled by ILIKE.
+const (
+	accented   string = "àáâãäåèéêëìíîïòóôõöøùúûüýÿñçÀÁÂÃÄÅÈÉÊËÌÍÎÏÒÓÔÕÖØÙÚÛÜÝŸÑÇ"
+	unaccented string = "aaaaaaeeeeiiiioooooouuuuyyncAAAAAAEEEEIIIIOOOOOOUUUUYYNC"
+)
+
+const (
+	earthRadius     float64 = 6371008.8 // mean radius in meters
+	metersPerDegree float64 = earthRadius * math.Pi / 180
+)
+
+// haversine is the great circle distance in meters between the location of a thing and @near_lat, @near_lon
+var haversine = fmt.Sprintf(`(2 * %f * asin(least(1, sqrt(
+	power(sin(radians(location[1] - @near_lat) / 2), 2) +
+	cos(radians(@near_lat)) * cos(radians(location[1])) * power(sin(radians(location[0] - @near_lon) / 2), 2)))))`, earthRadius)
+
+// escapeLike escapes the wildcards of a LIKE pattern, so that s is matched literally
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(s)
+}
+
+// thingPrefix is a LIKE pattern matching the ids of the values of a thing, which are prefixed with the id of
+// the thing. Values are matched to things in queries by an exact prefix instead, as thing ids may contain
+// wildcards, i.e. left(things_values.id, length(things.id) + 1) = things.id || '/'
+func thingPrefix(thingID string) string {
+	return escapeLike(thingID) + "/%"
+}
+
 func newConditions(conditions ...app.ConditionFunc) map[string]any {
 	m := make(map[string]any)
 
@@ -34,6 +65,10 @@ func newQueryThingsParams(conditions ...app.ConditionFunc) (string, pgx.NamedArg
 	query := "WHERE deleted_on IS NULL"
 	args := pgx.NamedArgs{}
 
+	if _, ok := c["includedeleted"]; ok {
+		query = "WHERE true"
+	}
+
 	if id, ok := c["id"]; ok {
 		query += " AND id=@id"
 		args["id"] = id
@@ -54,16 +89,107 @@ func newQueryThingsParams(conditions ...app.ConditionFunc) (string, pgx.NamedArg
 		args["sub_type"] = subType
 	}
 
+	if name, ok := c["name"]; ok {
+		query += " AND translate(data->>'name', @accented, @unaccented) ILIKE '%' || translate(@name, @accented, @unaccented) || '%'"
+		args["name"] = escapeLike(fmt.Sprintf("%s", name))
+		args["accented"] = accented
+		args["unaccented"] = unaccented
+	}
+
+	// locations are stored as point(lon,lat)
+	if bounds, ok := c["bounds"].([]float64); ok {
+		query += " AND location <@ box(point(@min_lon,@min_lat), point(@max_lon,@max_lat))"
+		args["min_lat"], args["min_lon"], args["max_lat"], args["max_lon"] = bounds[0], bounds[1], bounds[2], bounds[3]
+	}
+
+	if near, ok := c["near"].([]float64); ok {
+		lat, lon, radius := near[0], near[1], near[2]
+
+		// a bounding box around the circle lets the location index do most of the work before the exact distance is computed
+		dLat := radius / metersPerDegree
+		dLon := 180.0
+		if cos := math.Cos(lat * math.Pi / 180); cos > 0.01 {
+			dLon = math.Min(dLat/cos, 180.0)
+		}
+
+		query += " AND location <@ box(point(@near_min_lon,@near_min_lat), point(@near_max_lon,@near_max_lat))"
+		query += " AND " + haversine + " <= @near_radius"
+		args["near_lat"], args["near_lon"], args["near_radius"] = lat, lon, radius
+		args["near_min_lat"], args["near_min_lon"] = lat-dLat, lon-dLon
+		args["near_max_lat"], args["near_max_lon"] = lat+dLat, lon+dLon
+	}
+
+	if status, ok := c["status"]; ok {
+		query += " AND status=@status"
+		args["status"] = status
+	}
+
 	if tags, ok := c["tags"]; ok {
-		query += " AND data ? 'tags' and data->'tags' @> (@tags)"
-		b, _ := json.Marshal(tags)
-		args["tags"] = string(b)
+		if c["tagmode"] == app.TagModeAny {
+			query += " AND data ? 'tags' and data->'tags' ?| (@tags)"
+			args["tags"] = tags
+		} else {
+			query += " AND data ? 'tags' and data->'tags' @> (@tags)"
+			b, _ := json.Marshal(tags)
+			args["tags"] = string(b)
+		}
 	}
 
 	if refDevice, ok := c["refdevice"]; ok {
 		query += fmt.Sprintf(` AND data ? 'refDevices' AND data->'refDevices' @> '[{"deviceID": "%s"}]'`, refDevice)
 	}
 
+	// things without refDevices have zero connected devices
+	const numberOfDevices = "(CASE WHEN jsonb_typeof(data->'refDevices') = 'array' THEN jsonb_array_length(data->'refDevices') ELSE 0 END)"
+
+	if has, ok := c["hasrecentvalues"]; ok {
+		exists := "EXISTS (SELECT 1 FROM things_values tv WHERE left(tv.id, length(things.id) + 1) = things.id || '/' AND tv.time > @recent_since)"
+		if has == false {
+			exists = "NOT " + exists
+		}
+		query += " AND " + exists
+		args["recent_since"] = c["recentsince"]
+	}
+
+	if due, ok := c["inspectiondue"]; ok {
+		overdue := "(data ? 'nextInspectionDue' AND (data->>'nextInspectionDue')::timestamptz <= @inspection_at)"
+		if due == false {
+			overdue = "NOT " + overdue
+		}
+		query += " AND " + overdue
+		args["inspection_at"] = c["inspectionat"]
+	}
+
+	if before, ok := c["commissionedbefore"]; ok {
+		query += " AND data ? 'commissionedAt' AND (data->>'commissionedAt')::timestamptz < @commissioned_before"
+		args["commissioned_before"] = before
+	}
+
+	if after, ok := c["commissionedafter"]; ok {
+		query += " AND data ? 'commissionedAt' AND (data->>'commissionedAt')::timestamptz > @commissioned_after"
+		args["commissioned_after"] = after
+	}
+
+	if before, ok := c["observedbefore"]; ok {
+		query += " AND (data->>'observedAt')::timestamptz < @observed_before"
+		args["observed_before"] = before
+	}
+
+	if after, ok := c["observedafter"]; ok {
+		query += " AND (data->>'observedAt')::timestamptz > @observed_after"
+		args["observed_after"] = after
+	}
+
+	if minDevices, ok := c["mindevices"]; ok {
+		query += " AND " + numberOfDevices + " >= @min_devices"
+		args["min_devices"] = minDevices
+	}
+
+	if maxDevices, ok := c["maxdevices"]; ok {
+		query += " AND " + numberOfDevices + " <= @max_devices"
+		args["max_devices"] = maxDevices
+	}
+
 	for k, v := range c {
 		if strings.HasPrefix(k, "<") && strings.HasSuffix(k, ">") {
 			fieldname := k[1 : len(k)-1]
@@ -99,7 +225,8 @@ func newQueryThingsParams(conditions ...app.ConditionFunc) (string, pgx.NamedArg
 		}
 	}
 
-	query += " ORDER BY type ASC, data->>'subType' ASC, data->>'name' ASC"
+	// id makes the order total, so that paging with offset neither skips nor repeats things
+	query += " ORDER BY type ASC, data->>'subType' ASC, data->>'name' ASC, id ASC"
 
 	if offset, ok := c["offset"]; ok {
 		query += " OFFSET @offset"
@@ -117,6 +244,70 @@ func newQueryThingsParams(conditions ...app.ConditionFunc) (string, pgx.NamedArg
 func newQueryValuesParams(conditions ...app.ConditionFunc) (string, pgx.NamedArgs) {
 	c := newConditions(conditions...)
 
+	query, args := newValuesFilter(c)
+
+	// the latest value of each measurement of a thing is selected within the same filter, e.g. the last state before a point in time
+	if _, ok := c["showlatest"]; ok {
+		if thingID, ok := c["thingid"]; ok {
+			args["showlatest"] = true
+			args["thingid"] = fmt.Sprintf("%s", thingID)
+			return query, args
+		}
+	}
+
+	// if timeunit is present, we are counting rows gouped by timeunit (hour, day)
+	if timeunit, ok := c["timeunit"]; ok {
+		args["timeunit"] = timeunit
+		if aggr, ok := c["aggr"]; ok {
+			args["aggr"] = aggr
+		}
+	} else {
+		_, newestFirst := c["newestfirst"]
+
+		// values are ordered by time and id, so that values sharing a timestamp keep a stable order
+		// and a cursor can continue right after the last value of the previous page. The plain
+		// comparison on time lets the planner skip chunks that the row comparison alone would not.
+		cursorTs, hasCursor := c["cursorts"]
+		if hasCursor {
+			if newestFirst {
+				query += " AND time <= @cursor_ts AND (time, id) < (@cursor_ts, @cursor_id)"
+			} else {
+				query += " AND time >= @cursor_ts AND (time, id) > (@cursor_ts, @cursor_id)"
+			}
+			args["cursor_ts"] = cursorTs
+			args["cursor_id"] = c["cursorid"]
+		}
+
+		if newestFirst {
+			query += " ORDER BY time DESC, id DESC"
+		} else {
+			query += " ORDER BY time ASC, id ASC"
+		}
+
+		if offset, ok := c["offset"]; ok && !hasCursor {
+			query += " OFFSET @offset"
+			args["offset"] = offset
+		}
+
+		// with a cursor one more value than asked for tells whether there is a next page
+		if limit, ok := c["limit"]; ok {
+			if hasCursor {
+				query += " LIMIT @limit + 1"
+			} else {
+				query += " LIMIT @limit"
+			}
+			args["limit"] = limit
+		}
+	}
+
+	return query, args
+}
+
+// valueOperators maps the operators of value predicates to SQL
+var valueOperators = map[string]string{"eq": "=", "ne": "<>", "gt": ">", "lt": "<"}
+
+// newValuesFilter builds the WHERE clause shared by queries and deletes of values
+func newValuesFilter(c map[string]any) (string, pgx.NamedArgs) {
 	query := "WHERE 1=1"
 	args := pgx.NamedArgs{}
 
@@ -125,8 +316,27 @@ func newQueryValuesParams(conditions ...app.ConditionFunc) (string, pgx.NamedArg
 		args["id"] = id
 	}
 
+	// the values of a thing have ids prefixed with the id of the thing, which may contain wildcards or quotes
 	if thingID, ok := c["thingid"]; ok {
-		query += fmt.Sprintf(" AND id LIKE '%s/%%'", thingID)
+		query += " AND id LIKE @thing_prefix"
+		args["thing_prefix"] = thingPrefix(fmt.Sprintf("%s", thingID))
+	}
+
+	// values are stored without tenant and type, so these are resolved from the things they belong to
+	thingsFilter := ""
+
+	if tenants, ok := c["tenants"]; ok {
+		thingsFilter += " AND things.tenant=ANY(@tenants)"
+		args["tenants"] = tenants
+	}
+
+	if types, ok := c["types"]; ok {
+		thingsFilter += " AND things.type=ANY(@types)"
+		args["types"] = types
+	}
+
+	if thingsFilter != "" {
+		query += fmt.Sprintf(" AND EXISTS (SELECT 1 FROM things WHERE things.deleted_on IS NULL%s AND left(things_values.id, length(things.id) + 1) = things.id || '/')", thingsFilter)
 	}
 
 	if urn, ok := c["urn"]; ok {
@@ -134,6 +344,16 @@ func newQueryValuesParams(conditions ...app.ConditionFunc) (string, pgx.NamedArg
 		args["urn"] = urn
 	}
 
+	if prefix, ok := c["urnprefix"]; ok {
+		query += " AND urn LIKE @urn_prefix || '%'"
+		args["urn_prefix"] = prefix
+	}
+
+	if unit, ok := c["unit"]; ok {
+		query += " AND unit=ANY(@unit)"
+		args["unit"] = unit
+	}
+
 	if timerel, ok := c["timerel"]; ok {
 		switch timerel {
 		case "before":
@@ -154,57 +374,55 @@ func newQueryValuesParams(conditions ...app.ConditionFunc) (string, pgx.NamedArg
 		if opOk {
 			switch op {
 			case "eq":
-				query += " AND v IS NOT NULL AND v=@v"
+				query += " AND " + numericValue + " IS NOT NULL AND " + numericValue + "=@v"
 				args["v"] = v
 			case "gt":
-				query += " AND v IS NOT NULL AND v>@v"
+				query += " AND " + numericValue + " IS NOT NULL AND " + numericValue + ">@v"
 				args["v"] = v
 			case "lt":
-				query += " AND v IS NOT NULL AND v<@v"
+				query += " AND " + numericValue + " IS NOT NULL AND " + numericValue + "<@v"
 				args["v"] = v
 			case "ne":
-				query += " AND v IS NOT NULL AND v<>@v"
+				query += " AND " + numericValue + " IS NOT NULL AND " + numericValue + "<>@v"
 				args["v"] = v
 			}
 		}
 	}
 
+	if predicates, ok := c["valuepredicates"].([]app.ValuePredicate); ok {
+		for i, p := range predicates {
+			arg := fmt.Sprintf("v%d", i)
+			query += fmt.Sprintf(" AND %s IS NOT NULL AND %s%s@%s", numericValue, numericValue, valueOperators[p.Operator], arg)
+			args[arg] = p.Value
+		}
+	}
+
+	if quality, ok := c["quality"]; ok {
+		if quality == things.QualityGood {
+			query += " AND (quality IS NULL OR quality=@quality)"
+		} else {
+			query += " AND quality=@quality"
+		}
+		args["quality"] = quality
+	}
+
 	if vb, ok := c["vb"]; ok {
 		query += " AND vb IS NOT NULL AND vb=@vb"
 		args["vb"] = vb
 	}
 
+	if _, ok := c["states"]; ok {
+		query += " AND vb IS NOT NULL"
+	}
+
 	if ref, ok := c["refdevice"]; ok {
 		query += " AND ref=@ref"
 		args["ref"] = ref
 	}
 
 	if n, ok := c["n"]; ok {
-		query += fmt.Sprintf(" AND id LIKE '%%/%s'", n)
-	}
-
-	// if timeunit is present, we are counting rows gouped by timeunit (hour, day)
-	if timeunit, ok := c["timeunit"]; ok {
-		args["timeunit"] = timeunit
-	} else {
-		query += " ORDER BY time ASC"
-
-		if offset, ok := c["offset"]; ok {
-			query += " OFFSET @offset"
-			args["offset"] = offset
-		}
-
-		if limit, ok := c["limit"]; ok {
-			query += " LIMIT @limit"
-			args["limit"] = limit
-		}
-	}
-
-	if _, ok := c["showlatest"]; ok {
-		if thingID, ok := c["thingid"]; ok {
-			args["showlatest"] = true
-			args["thingid"] = fmt.Sprintf("%s", thingID)
-		}		
+		query += " AND id LIKE @value_suffix"
+		args["value_suffix"] = "%/" + escapeLike(fmt.Sprintf("%s", n))
 	}
 
 	return query, args
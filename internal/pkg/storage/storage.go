@@ -5,7 +5,9 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"math"
 	"slices"
+	"sync"
 	"time"
 
 	app "github.com/diwise/iot-things/internal/app/iot-things"
@@ -17,6 +19,9 @@ import (
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
+// numericValue is the numeric value of a row, stored in v or, for integer urns, in vi
+const numericValue string = "COALESCE(v, vi)"
+
 type database struct {
 	pool *pgxpool.Pool
 }
@@ -28,16 +33,35 @@ type Storage interface {
 }
 
 func New(ctx context.Context, cfg Config) (Storage, error) {
+	chunkInterval, err := parseInterval(cfg.chunkInterval)
+	if err != nil {
+		return database{}, fmt.Errorf("CHUNK_INTERVAL: %w", err)
+	}
+
 	p, err := connect(ctx, cfg)
 	if err != nil {
 		return database{}, err
 	}
 
-	err = initialize(ctx, p)
+	err = initialize(ctx, p, chunkInterval)
 	if err != nil {
 		return database{}, err
 	}
 
+	if chunkInterval > 0 {
+		err = setChunkInterval(ctx, p, chunkInterval)
+		if err != nil {
+			return database{}, err
+		}
+	}
+
+	if cfg.compressAfter != "" {
+		err = enableCompression(ctx, p, cfg.compressAfter)
+		if err != nil {
+			return database{}, err
+		}
+	}
+
 	return database{
 		pool: p,
 	}, nil
@@ -47,7 +71,9 @@ func (db database) Close() {
 	db.pool.Close()
 }
 
-func initialize(ctx context.Context, pool *pgxpool.Pool) error {
+// initialize creates the tables if they do not exist. The values table is created as a hypertable with
+// chunks of chunkInterval, or the TimescaleDB default if zero.
+func initialize(ctx context.Context, pool *pgxpool.Pool, chunkInterval time.Duration) error {
 	log := logging.GetFromContext(ctx)
 
 	ddl := `
@@ -66,6 +92,9 @@ func initialize(ctx context.Context, pool *pgxpool.Pool) error {
 		CREATE INDEX IF NOT EXISTS thing_type_idx ON things (type, id);
 		CREATE INDEX IF NOT EXISTS thing_location_idx ON things USING GIST(location);
 
+		ALTER TABLE things ADD COLUMN IF NOT EXISTS status TEXT NOT NULL DEFAULT 'active';
+		CREATE INDEX IF NOT EXISTS thing_status_idx ON things (status);
+
 		CREATE TABLE IF NOT EXISTS things_values (
 			time 		TIMESTAMPTZ NOT NULL,
 			id  		TEXT NOT NULL,
@@ -79,21 +108,33 @@ func initialize(ctx context.Context, pool *pgxpool.Pool) error {
 			created_on  timestamp with time zone NOT NULL DEFAULT CURRENT_TIMESTAMP,			
 			UNIQUE ("time", "id"));
 
+		ALTER TABLE things_values ADD COLUMN IF NOT EXISTS redacted_on timestamp with time zone NULL;
+		ALTER TABLE things_values ADD COLUMN IF NOT EXISTS quality TEXT NULL;
+		ALTER TABLE things_values ADD COLUMN IF NOT EXISTS vi BIGINT NULL;
+		ALTER TABLE things_values ADD COLUMN IF NOT EXISTS calibrated BOOLEAN NOT NULL DEFAULT false;
+		ALTER TABLE things_values ADD COLUMN IF NOT EXISTS correlation_id TEXT NULL;
+
+		CREATE TABLE IF NOT EXISTS things_values_daily (
+			day		DATE NOT NULL,
+			id		TEXT NOT NULL,
+			urn		TEXT NOT NULL,
+			n		BIGINT NOT NULL DEFAULT 0,
+			sum		NUMERIC NOT NULL DEFAULT 0,
+			avg		NUMERIC NOT NULL DEFAULT 0,
+			unit	TEXT NOT NULL DEFAULT '',
+			modified_on timestamp with time zone NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			PRIMARY KEY (id, day));
 
-		DO $$
-		DECLARE
-			n INTEGER;
-		BEGIN			
-			SELECT COUNT(*) INTO n
-			FROM timescaledb_information.hypertables
-			WHERE hypertable_name = 'things_values';
-			
-			IF n = 0 THEN				
-				PERFORM create_hypertable('things_values', 'time');				
-			END IF;
-		END $$;
 	`
 
+	hypertable := `SELECT create_hypertable('things_values', 'time', if_not_exists => TRUE);`
+	args := pgx.NamedArgs{}
+
+	if chunkInterval > 0 {
+		hypertable = `SELECT create_hypertable('things_values', 'time', chunk_time_interval => @chunk_interval::interval, if_not_exists => TRUE);`
+		args["chunk_interval"] = chunkInterval
+	}
+
 	tx, err := pool.Begin(ctx)
 	if err != nil {
 		log.Error("could not begin transaction", "err", err.Error())
@@ -107,6 +148,13 @@ func initialize(ctx context.Context, pool *pgxpool.Pool) error {
 		return err
 	}
 
+	_, err = tx.Exec(ctx, hypertable, args)
+	if err != nil {
+		log.Error("could not create hypertable", "err", err.Error())
+		tx.Rollback(ctx)
+		return err
+	}
+
 	err = tx.Commit(ctx)
 	if err != nil {
 		log.Error("could not commit transaction", "err", err.Error())
@@ -116,6 +164,146 @@ func initialize(ctx context.Context, pool *pgxpool.Pool) error {
 	return nil
 }
 
+// setChunkInterval sets the chunk interval of the values hypertable. It applies to chunks created from now on,
+// existing chunks keep the interval they were created with.
+func setChunkInterval(ctx context.Context, pool *pgxpool.Pool, interval time.Duration) error {
+	log := logging.GetFromContext(ctx)
+
+	_, err := pool.Exec(ctx, `SELECT set_chunk_time_interval('things_values', @interval::interval);`, pgx.NamedArgs{
+		"interval": interval,
+	})
+	if err != nil {
+		log.Error("could not set chunk interval", "interval", interval.String(), "err", err.Error())
+		return err
+	}
+
+	return nil
+}
+
+// enableCompression turns on native compression of the values hypertable, segmented by thing, and adds a
+// policy that compresses chunks older than compressAfter. It does nothing if both are already in place, and
+// replaces the policy if it compresses after another interval.
+func enableCompression(ctx context.Context, pool *pgxpool.Pool, compressAfter string) error {
+	log := logging.GetFromContext(ctx)
+
+	var enabled bool
+	err := pool.QueryRow(ctx, `
+		SELECT compression_enabled
+		FROM timescaledb_information.hypertables
+		WHERE hypertable_name = 'things_values';`).Scan(&enabled)
+	if err != nil {
+		log.Error("could not query compression settings", "err", err.Error())
+		return err
+	}
+
+	if !enabled {
+		_, err = pool.Exec(ctx, `ALTER TABLE things_values SET (timescaledb.compress, timescaledb.compress_segmentby = 'id', timescaledb.compress_orderby = 'time DESC');`)
+		if err != nil {
+			log.Error("could not enable compression", "err", err.Error())
+			return err
+		}
+	}
+
+	var current *string
+	err = pool.QueryRow(ctx, `
+		SELECT config->>'compress_after'
+		FROM timescaledb_information.jobs
+		WHERE proc_name = 'policy_compression' AND hypertable_name = 'things_values';`).Scan(&current)
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		log.Error("could not query compression policy", "err", err.Error())
+		return err
+	}
+
+	if current != nil {
+		var same bool
+		err = pool.QueryRow(ctx, `SELECT @current::interval = @after::interval;`, pgx.NamedArgs{
+			"current": *current,
+			"after":   compressAfter,
+		}).Scan(&same)
+		if err != nil {
+			log.Error("could not compare compression intervals", "compress_after", compressAfter, "err", err.Error())
+			return err
+		}
+		if same {
+			return nil
+		}
+
+		_, err = pool.Exec(ctx, `SELECT remove_compression_policy('things_values', if_exists => true);`)
+		if err != nil {
+			log.Error("could not remove compression policy", "err", err.Error())
+			return err
+		}
+	}
+
+	_, err = pool.Exec(ctx, `SELECT add_compression_policy('things_values', @after::interval);`, pgx.NamedArgs{
+		"after": compressAfter,
+	})
+	if err != nil {
+		log.Error("could not add compression policy", "compress_after", compressAfter, "err", err.Error())
+		return err
+	}
+
+	return nil
+}
+
+// query runs a query that may be slow, e.g. an analytics query. If ctx has a deadline the statement_timeout
+// of the connection is set to the time left, so that the database stops working on the query when the
+// request has timed out.
+func (db database) query(ctx context.Context, query string, args ...any) (pgx.Rows, error) {
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		return db.pool.Query(ctx, query, args...)
+	}
+
+	timeout := time.Until(deadline).Milliseconds()
+	if timeout <= 0 {
+		return nil, context.DeadlineExceeded
+	}
+
+	conn, err := db.pool.Acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = conn.Exec(ctx, fmt.Sprintf("SET statement_timeout = %d", timeout))
+	if err != nil {
+		conn.Release()
+		return nil, err
+	}
+
+	rows, err := conn.Query(ctx, query, args...)
+	if err != nil {
+		resetStatementTimeout(conn)
+		return nil, err
+	}
+
+	return &statementTimeoutRows{Rows: rows, conn: conn}, nil
+}
+
+// statementTimeoutRows resets the statement_timeout and releases the connection when the rows are closed
+type statementTimeoutRows struct {
+	pgx.Rows
+	conn *pgxpool.Conn
+	once sync.Once
+}
+
+func (r *statementTimeoutRows) Close() {
+	r.Rows.Close()
+	r.once.Do(func() {
+		resetStatementTimeout(r.conn)
+	})
+}
+
+func resetStatementTimeout(conn *pgxpool.Conn) {
+	_, err := conn.Exec(context.Background(), "RESET statement_timeout")
+	if err != nil {
+		// the connection can not be returned to the pool with the timeout still set
+		conn.Hijack().Close(context.Background())
+		return
+	}
+	conn.Release()
+}
+
 func connect(ctx context.Context, cfg Config) (*pgxpool.Pool, error) {
 	conn, err := pgxpool.New(ctx, cfg.ConnStr())
 	if err != nil {
@@ -135,7 +323,7 @@ func (db database) AddThing(ctx context.Context, t things.Thing) error {
 
 	lat, lon := t.LatLon()
 
-	insert := `INSERT INTO things(id, type, location, data, tenant) VALUES (@id, @thing_type, point(@lon,@lat), @data, @tenant);`
+	insert := `INSERT INTO things(id, type, location, data, tenant, status) VALUES (@id, @thing_type, point(@lon,@lat), @data, @tenant, @status);`
 	_, err := db.pool.Exec(ctx, insert, pgx.NamedArgs{
 		"id":         t.ID(),
 		"thing_type": t.Type(),
@@ -143,6 +331,7 @@ func (db database) AddThing(ctx context.Context, t things.Thing) error {
 		"lat":        lat,
 		"data":       string(t.Byte()),
 		"tenant":     t.Tenant(),
+		"status":     t.Status(),
 	})
 	if err != nil {
 		var pgErr *pgconn.PgError
@@ -162,20 +351,204 @@ func (db database) AddThing(ctx context.Context, t things.Thing) error {
 	return nil
 }
 
-func (db database) UpdateThing(ctx context.Context, t things.Thing) error {
+// upsertThingStatement inserts a thing or updates it if it exists, keeping its stored relations. Things that
+// belong to another tenant, or that have been deleted, are left as they are and no row is returned.
+const upsertThingStatement string = `
+	INSERT INTO things(id, type, location, data, tenant, status) VALUES (@id, @thing_type, point(@lon,@lat), @data, @tenant, @status)
+	ON CONFLICT (id) DO UPDATE SET location=EXCLUDED.location, data=(EXCLUDED.data - 'relations') || jsonb_strip_nulls(jsonb_build_object('relations', things.data->'relations')), status=EXCLUDED.status, modified_on=CURRENT_TIMESTAMP
+	WHERE things.tenant=EXCLUDED.tenant AND things.deleted_on IS NULL
+	RETURNING (xmax = 0);`
+
+// UpsertThing adds t, or updates it if it already exists, and reports whether it was added
+func (db database) UpsertThing(ctx context.Context, t things.Thing) (bool, error) {
 	log := logging.GetFromContext(ctx)
 
 	lat, lon := t.LatLon()
 
-	update := `UPDATE things SET location=point(@lon,@lat), data=@data, modified_on=CURRENT_TIMESTAMP WHERE id=@id;`
-	_, err := db.pool.Exec(ctx, update, pgx.NamedArgs{
-		"id":   t.ID(),
-		"lon":  lon,
-		"lat":  lat,
-		"data": string(t.Byte()),
+	var created bool
+	err := db.pool.QueryRow(ctx, upsertThingStatement, pgx.NamedArgs{
+		"id":         t.ID(),
+		"thing_type": t.Type(),
+		"lon":        lon,
+		"lat":        lat,
+		"data":       string(t.Byte()),
+		"tenant":     t.Tenant(),
+		"status":     t.Status(),
+	}).Scan(&created)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			log.Debug("thing exists in another tenant or has been deleted", "thing_id", t.ID())
+			return false, app.ErrAlreadyExists
+		}
+
+		log.Error("could not execute statement", "err", err.Error())
+		return false, err
+	}
+
+	return created, nil
+}
+
+// keepRelations replaces the data of a thing but keeps its stored relations, that are only changed by
+// AddRelations and when a related thing is deleted, so that an update can not undo a concurrent relation
+const keepRelations string = `(@data::jsonb - 'relations') || jsonb_strip_nulls(jsonb_build_object('relations', things.data->'relations'))`
+
+const updateThingStatement string = `UPDATE things SET location=point(@lon,@lat), data=` + keepRelations + `, status=@status, modified_on=CURRENT_TIMESTAMP WHERE id=@id;`
+
+const updateThingIfUnchangedStatement string = `UPDATE things SET location=point(@lon,@lat), data=` + keepRelations + `, status=@status, modified_on=CURRENT_TIMESTAMP WHERE id=@id AND modified_on=@modified_on;`
+
+// addRelationStatement appends a relation to the relations of a thing, unless it is already related the same way
+const addRelationStatement string = `
+	UPDATE things SET data=jsonb_set(data, '{relations}', COALESCE(data->'relations', '[]'::jsonb) || @relation::jsonb), modified_on=CURRENT_TIMESTAMP
+	WHERE id=@id AND NOT COALESCE(data->'relations', '[]'::jsonb) @> @match::jsonb;`
+
+// deleteThingStatement deletes a thing, and its relations, and removes it from the relations of other things
+const deleteThingStatement string = `
+	WITH deleted AS (
+		UPDATE things SET deleted_on=CURRENT_TIMESTAMP, data=data - 'relations' WHERE id=@id RETURNING id
+	)
+	UPDATE things SET data=jsonb_set(data, '{relations}', COALESCE((
+			SELECT jsonb_agg(r) FROM jsonb_array_elements(things.data->'relations') r WHERE r->>'id' <> @id
+		), '[]'::jsonb)), modified_on=CURRENT_TIMESTAMP
+	WHERE data->'relations' @> jsonb_build_array(jsonb_build_object('id', @id::text)) AND id <> @id;`
+
+func updateThingArgs(t things.Thing) pgx.NamedArgs {
+	lat, lon := t.LatLon()
+
+	return pgx.NamedArgs{
+		"id":     t.ID(),
+		"lon":    lon,
+		"lat":    lat,
+		"data":   string(t.Byte()),
+		"status": t.Status(),
+	}
+}
+
+func (db database) UpdateThing(ctx context.Context, t things.Thing) error {
+	log := logging.GetFromContext(ctx)
+
+	_, err := db.pool.Exec(ctx, updateThingStatement, updateThingArgs(t))
+	if err != nil {
+		log.Error("could not execute statement", "err", err.Error())
+		return err
+	}
+
+	return nil
+}
+
+// UpdateThingIfUnchanged updates the thing only if it has not been modified since modifiedOn, otherwise
+// app.ErrPreconditionFailed is returned
+func (db database) UpdateThingIfUnchanged(ctx context.Context, t things.Thing, modifiedOn time.Time) error {
+	log := logging.GetFromContext(ctx)
+
+	args := updateThingArgs(t)
+	args["modified_on"] = modifiedOn
+
+	tag, err := db.pool.Exec(ctx, updateThingIfUnchangedStatement, args)
+	if err != nil {
+		log.Error("could not execute statement", "err", err.Error())
+		return err
+	}
+
+	if tag.RowsAffected() == 0 {
+		return app.ErrPreconditionFailed
+	}
+
+	return nil
+}
+
+// UpdateThings updates all things within one transaction, either all of them are updated or none
+func (db database) UpdateThings(ctx context.Context, ts []things.Thing) error {
+	log := logging.GetFromContext(ctx)
+
+	tx, err := db.pool.Begin(ctx)
+	if err != nil {
+		log.Error("could not begin transaction", "err", err.Error())
+		return err
+	}
+
+	for _, t := range ts {
+		_, err = tx.Exec(ctx, updateThingStatement, updateThingArgs(t))
+		if err != nil {
+			log.Error("could not execute statement", "err", err.Error())
+			tx.Rollback(ctx)
+			return err
+		}
+	}
+
+	err = tx.Commit(ctx)
+	if err != nil {
+		log.Error("could not commit transaction", "err", err.Error())
+		return err
+	}
+
+	return nil
+}
+
+// AddRelations adds each relation to the thing with the given id within one transaction, either all of them
+// are added or none. Relations are appended to those stored, so concurrent updates of a thing are not lost.
+func (db database) AddRelations(ctx context.Context, relations map[string]things.Relation) error {
+	log := logging.GetFromContext(ctx)
+
+	tx, err := db.pool.Begin(ctx)
+	if err != nil {
+		log.Error("could not begin transaction", "err", err.Error())
+		return err
+	}
+
+	for thingID, r := range relations {
+		relation, _ := json.Marshal([]things.Relation{r})
+		match, _ := json.Marshal([]map[string]string{{"id": r.ID, "relation": r.Relation}})
+
+		_, err = tx.Exec(ctx, addRelationStatement, pgx.NamedArgs{
+			"id":       thingID,
+			"relation": string(relation),
+			"match":    string(match),
+		})
+		if err != nil {
+			log.Error("could not execute statement", "err", err.Error())
+			tx.Rollback(ctx)
+			return err
+		}
+	}
+
+	err = tx.Commit(ctx)
+	if err != nil {
+		log.Error("could not commit transaction", "err", err.Error())
+		return err
+	}
+
+	return nil
+}
+
+// MergeDuplicate updates the target and deletes the source within one transaction, either both or neither
+func (db database) MergeDuplicate(ctx context.Context, target things.Thing, sourceID string) error {
+	log := logging.GetFromContext(ctx)
+
+	tx, err := db.pool.Begin(ctx)
+	if err != nil {
+		log.Error("could not begin transaction", "err", err.Error())
+		return err
+	}
+
+	_, err = tx.Exec(ctx, updateThingStatement, updateThingArgs(target))
+	if err != nil {
+		log.Error("could not execute statement", "err", err.Error())
+		tx.Rollback(ctx)
+		return err
+	}
+
+	_, err = tx.Exec(ctx, deleteThingStatement, pgx.NamedArgs{
+		"id": sourceID,
 	})
 	if err != nil {
 		log.Error("could not execute statement", "err", err.Error())
+		tx.Rollback(ctx)
+		return err
+	}
+
+	err = tx.Commit(ctx)
+	if err != nil {
+		log.Error("could not commit transaction", "err", err.Error())
 		return err
 	}
 
@@ -185,8 +558,22 @@ func (db database) UpdateThing(ctx context.Context, t things.Thing) error {
 func (db database) DeleteThing(ctx context.Context, id string) error {
 	log := logging.GetFromContext(ctx)
 
-	delete := `UPDATE things SET deleted_on=CURRENT_TIMESTAMP WHERE id=@id;`
-	_, err := db.pool.Exec(ctx, delete, pgx.NamedArgs{
+	_, err := db.pool.Exec(ctx, deleteThingStatement, pgx.NamedArgs{
+		"id": id,
+	})
+	if err != nil {
+		log.Error("could not execute statement", "err", err.Error())
+		return err
+	}
+
+	return nil
+}
+
+func (db database) UndeleteThing(ctx context.Context, id string) error {
+	log := logging.GetFromContext(ctx)
+
+	undelete := `UPDATE things SET deleted_on=NULL WHERE id=@id;`
+	_, err := db.pool.Exec(ctx, undelete, pgx.NamedArgs{
 		"id": id,
 	})
 	if err != nil {
@@ -198,12 +585,16 @@ func (db database) DeleteThing(ctx context.Context, id string) error {
 }
 
 func (db database) QueryThings(ctx context.Context, conditions ...app.ConditionFunc) (app.QueryResult, error) {
+	defer observeQuery(ctx, "things", time.Now())
+
 	where, args := newQueryThingsParams(conditions...)
 	log := logging.GetFromContext(ctx)
 
-	query := fmt.Sprintf("SELECT data, count(*) OVER () AS total FROM things %s", where)
+	// deleted things are only found when asked for, and are then returned with the time they were deleted
+	query := fmt.Sprintf(`SELECT CASE WHEN deleted_on IS NULL THEN data ELSE data || jsonb_build_object('deletedOn', deleted_on) END,
+		modified_on, count(*) OVER () AS total FROM things %s`, where)
 
-	rows, err := db.pool.Query(ctx, query, args)
+	rows, err := db.query(ctx, query, args)
 	if err != nil {
 		log.Error("could not execute query", "err", err.Error())
 		return app.QueryResult{}, err
@@ -212,9 +603,13 @@ func (db database) QueryThings(ctx context.Context, conditions ...app.ConditionF
 	var t [][]byte
 	var total int64
 	var data []byte
+	var modifiedOn, lastModified time.Time
 
-	_, err = pgx.ForEachRow(rows, []any{&data, &total}, func() error {
+	_, err = pgx.ForEachRow(rows, []any{&data, &modifiedOn, &total}, func() error {
 		t = append(t, data)
+		if modifiedOn.After(lastModified) {
+			lastModified = modifiedOn
+		}
 		return nil
 	})
 	if err != nil {
@@ -222,36 +617,55 @@ func (db database) QueryThings(ctx context.Context, conditions ...app.ConditionF
 	}
 
 	return app.QueryResult{
-		Data:       t,
-		Count:      len(t),
-		TotalCount: total,
-		Limit:      args["limit"].(int),
-		Offset:     args["offset"].(int),
+		Data:         t,
+		Count:        len(t),
+		TotalCount:   total,
+		Limit:        args["limit"].(int),
+		Offset:       args["offset"].(int),
+		LastModified: lastModified.UTC(),
 	}, nil
 }
 
 func (db database) QueryValues(ctx context.Context, conditions ...app.ConditionFunc) (app.QueryResult, error) {
+	defer observeQuery(ctx, "values", time.Now())
+
 	where, args := newQueryValuesParams(conditions...)
 	log := logging.GetFromContext(ctx)
 
+	if _, ok := args["aggr"]; ok {
+		return db.aggregateValues(ctx, where, args)
+	}
+
 	if _, ok := args["timeunit"]; ok {
 		return db.countValues(ctx, where, args)
 	}
 
-	if _,ok := args["showlatest"]; ok {
-		return db.showLatest(ctx, args["thingid"].(string))
+	if _, ok := args["showlatest"]; ok {
+		return db.showLatest(ctx, where, args)
 	}
 
-	query := fmt.Sprintf("SELECT time,id,urn,location,v,vs,vb,unit,ref, count(*) OVER () AS total FROM things_values %s ", where)
+	// paging by cursor does not count the total, which would mean reading every value after the cursor
+	_, hasCursor := args["cursor_ts"]
 
-	rows, err := db.pool.Query(ctx, query, args)
+	total := "count(*) OVER ()"
+	if hasCursor {
+		total = "0"
+	}
+
+	query := fmt.Sprintf("SELECT time,id,urn,location,%s AS v,vs,vb,unit,ref,redacted_on,quality,calibrated,correlation_id, %s AS total FROM things_values %s ", numericValue, total, where)
+
+	rows, err := db.query(ctx, query, args)
 	if err != nil {
 		log.Error("could not execute query", "err", err.Error())
 		return app.QueryResult{}, err
 	}
 
+	limit := args["limit"].(int)
+
 	var t [][]byte
-	var total int64
+	var totalCount int64
+	var lastTs time.Time
+	var lastID string
 
 	var ts time.Time
 	var id, urn, unit, ref string
@@ -259,8 +673,12 @@ func (db database) QueryValues(ctx context.Context, conditions ...app.ConditionF
 	var v *float64
 	var vb *bool
 	var vs *string
+	var redactedOn *time.Time
+	var quality *string
+	var calibrated bool
+	var correlationID *string
 
-	_, err = pgx.ForEachRow(rows, []any{&ts, &id, &urn, &location, &v, &vs, &vb, &unit, &ref, &total}, func() error {
+	_, err = pgx.ForEachRow(rows, []any{&ts, &id, &urn, &location, &v, &vs, &vb, &unit, &ref, &redactedOn, &quality, &calibrated, &correlationID, &totalCount}, func() error {
 		m := things.Value{
 			Measurement: things.Measurement{
 				ID:          id,
@@ -269,12 +687,24 @@ func (db database) QueryValues(ctx context.Context, conditions ...app.ConditionF
 				StringValue: vs,
 				Value:       v,
 				Unit:        unit,
-				Timestamp:   ts.UTC()},
-			Ref: ref,
+				Timestamp:   ts.UTC(),
+				Calibrated:  calibrated},
+			Ref:        ref,
+			RedactedOn: redactedOn,
+		}
+
+		if quality != nil {
+			m.Quality = *quality
+		}
+		if correlationID != nil {
+			m.CorrelationID = *correlationID
 		}
 
 		b, _ := json.Marshal(m)
 		t = append(t, b)
+		if len(t) <= limit {
+			lastTs, lastID = ts, id
+		}
 
 		return nil
 	})
@@ -282,28 +712,40 @@ func (db database) QueryValues(ctx context.Context, conditions ...app.ConditionF
 		return app.QueryResult{}, err
 	}
 
+	offset, _ := args["offset"].(int)
+
+	nextCursor := ""
+	if hasCursor {
+		// with a cursor there is no offset and no total, one more value than the limit means there is a next page
+		if len(t) > limit {
+			t = t[:limit]
+			nextCursor = app.EncodeCursor(lastTs, lastID)
+		}
+	} else if len(t) > 0 && int64(offset+len(t)) < totalCount {
+		nextCursor = app.EncodeCursor(lastTs, lastID)
+	}
+
 	return app.QueryResult{
 		Data:       t,
 		Count:      len(t),
-		TotalCount: total,
-		Limit:      args["limit"].(int),
-		Offset:     args["offset"].(int),
+		TotalCount: totalCount,
+		Limit:      limit,
+		Offset:     offset,
+		NextCursor: nextCursor,
 	}, nil
 }
 
-func (db database) showLatest(ctx context.Context, thingID string) (app.QueryResult, error) {
+func (db database) showLatest(ctx context.Context, where string, args pgx.NamedArgs) (app.QueryResult, error) {
 	log := logging.GetFromContext(ctx)
 
-	thingID = fmt.Sprintf("%s/%%", thingID)
-
 	query := fmt.Sprintf(`
-		SELECT DISTINCT ON (id) time, id, urn, v, vs, vb, unit, ref
+		SELECT DISTINCT ON (id) time, id, urn, %s AS v, vs, vb, unit, COALESCE(ref, '')
 		FROM things_values
-		WHERE id LIKE '%s'
+		%s
 		ORDER BY id, "time" DESC;	
-	`, thingID)
+	`, numericValue, where)
 
-	rows, err := db.pool.Query(ctx, query)
+	rows, err := db.query(ctx, query, args)
 	if err != nil {
 		log.Error("could not execute query", "err", err.Error())
 		return app.QueryResult{}, err
@@ -365,7 +807,7 @@ func (db database) countValues(ctx context.Context, where string, args pgx.Named
 		ORDER BY e ASC;
 	`, timeUnit, where)
 
-	rows, err := db.pool.Query(ctx, query, args)
+	rows, err := db.query(ctx, query, args)
 	if err != nil {
 		log.Error("could not execute query", "err", err.Error())
 		return app.QueryResult{}, err
@@ -408,27 +850,117 @@ func (db database) countValues(ctx context.Context, where string, args pgx.Named
 	}, nil
 }
 
-func (db database) GetTags(ctx context.Context, tenants []string) ([]string, error) {
+// aggregateValues aggregates numeric values per timeunit, urn and value name across all things matching the filter
+func (db database) aggregateValues(ctx context.Context, where string, args pgx.NamedArgs) (app.QueryResult, error) {
 	log := logging.GetFromContext(ctx)
 
-	query := `
-		SELECT DISTINCT tag
-		FROM things,
-		LATERAL jsonb_array_elements_text(data->'tags') AS tag
-		WHERE data ? 'tags' AND tenant=ANY(@tenants)
-		ORDER BY tag ASC;`
+	timeUnit := args["timeunit"].(string)
 
-	args := pgx.NamedArgs{
-		"tenants": tenants,
+	if !slices.Contains([]string{"hour", "day"}, timeUnit) {
+		timeUnit = "hour"
 	}
-	rows, err := db.pool.Query(ctx, query, args)
+
+	stats, _ := args["aggr"].([]string)
+
+	if len(stats) == 0 {
+		stats = []string{"sum"}
+	}
+
+	query := fmt.Sprintf(`
+		SELECT DATE_TRUNC('%s', time) e, urn, substring(id from '[^/]+$') n, sum(%s), avg(%s), min(%s), max(%s), count(*) c
+		FROM things_values
+		%s AND %s IS NOT NULL
+		GROUP BY e, urn, n
+		ORDER BY e ASC, urn ASC, n ASC;
+	`, timeUnit, numericValue, numericValue, numericValue, numericValue, where, numericValue)
+
+	rows, err := db.query(ctx, query, args)
 	if err != nil {
 		log.Error("could not execute query", "err", err.Error())
-		return []string{}, err
+		return app.QueryResult{}, err
 	}
 
-	tags := make([]string, 0)
-	var tag string
+	var t [][]byte
+
+	var ts time.Time
+	var urn, n string
+	var sumV, avgV, minV, maxV float64
+	var c int64
+
+	stat := func(name string, v float64) *float64 {
+		if !slices.Contains(stats, name) {
+			return nil
+		}
+		return &v
+	}
+
+	_, err = pgx.ForEachRow(rows, []any{&ts, &urn, &n, &sumV, &avgV, &minV, &maxV, &c}, func() error {
+		values := map[string]float64{"sum": sumV, "avg": avgV, "min": minV, "max": maxV}
+
+		// aggr and v are the first requested aggregate, the others are added by name
+		aggregate := struct {
+			Urn       string    `json:"urn"`
+			Name      string    `json:"n"`
+			Aggregate string    `json:"aggr"`
+			Value     float64   `json:"v"`
+			Sum       *float64  `json:"sum,omitempty"`
+			Avg       *float64  `json:"avg,omitempty"`
+			Min       *float64  `json:"min,omitempty"`
+			Max       *float64  `json:"max,omitempty"`
+			Count     int64     `json:"count"`
+			Timestamp time.Time `json:"timestamp"`
+		}{
+			Urn:       urn,
+			Name:      n,
+			Aggregate: stats[0],
+			Value:     values[stats[0]],
+			Sum:       stat("sum", sumV),
+			Avg:       stat("avg", avgV),
+			Min:       stat("min", minV),
+			Max:       stat("max", maxV),
+			Count:     c,
+			Timestamp: ts.UTC(),
+		}
+
+		b, _ := json.Marshal(aggregate)
+		t = append(t, b)
+
+		return nil
+	})
+	if err != nil {
+		return app.QueryResult{}, err
+	}
+
+	return app.QueryResult{
+		Data:       t,
+		Count:      len(t),
+		TotalCount: int64(len(t)),
+		Limit:      len(t),
+		Offset:     0,
+	}, nil
+}
+
+func (db database) GetTags(ctx context.Context, tenants []string) ([]string, error) {
+	log := logging.GetFromContext(ctx)
+
+	query := `
+		SELECT DISTINCT tag
+		FROM things,
+		LATERAL jsonb_array_elements_text(data->'tags') AS tag
+		WHERE data ? 'tags' AND tenant=ANY(@tenants)
+		ORDER BY tag ASC;`
+
+	args := pgx.NamedArgs{
+		"tenants": tenants,
+	}
+	rows, err := db.pool.Query(ctx, query, args)
+	if err != nil {
+		log.Error("could not execute query", "err", err.Error())
+		return []string{}, err
+	}
+
+	tags := make([]string, 0)
+	var tag string
 
 	_, err = pgx.ForEachRow(rows, []any{&tag}, func() error {
 		tags = append(tags, tag)
@@ -441,39 +973,557 @@ func (db database) GetTags(ctx context.Context, tenants []string) ([]string, err
 	return tags, nil
 }
 
+func (db database) GetUrns(ctx context.Context, thingID string) ([]string, error) {
+	log := logging.GetFromContext(ctx)
+
+	query := `
+		SELECT DISTINCT urn
+		FROM things_values
+		WHERE id LIKE @thing_prefix
+		ORDER BY urn ASC;`
+
+	rows, err := db.pool.Query(ctx, query, pgx.NamedArgs{
+		"thing_prefix": thingPrefix(thingID),
+	})
+	if err != nil {
+		log.Error("could not execute query", "err", err.Error())
+		return []string{}, err
+	}
+
+	urns := make([]string, 0)
+	var urn string
+
+	_, err = pgx.ForEachRow(rows, []any{&urn}, func() error {
+		urns = append(urns, urn)
+		return nil
+	})
+	if err != nil {
+		return []string{}, err
+	}
+
+	return urns, nil
+}
+
+func (db database) GetValueBuckets(ctx context.Context, thingID string, from, to time.Time, interval time.Duration) ([]time.Time, error) {
+	log := logging.GetFromContext(ctx)
+
+	query := `
+		SELECT time_bucket(@interval::interval, time, @from::timestamptz) AS bucket
+		FROM things_values
+		WHERE id LIKE @thing_prefix AND time >= @from AND time < @to AND redacted_on IS NULL
+		GROUP BY bucket
+		ORDER BY bucket ASC;`
+
+	rows, err := db.pool.Query(ctx, query, pgx.NamedArgs{
+		"thing_prefix": thingPrefix(thingID),
+		"from":         from,
+		"to":           to,
+		"interval":     fmt.Sprintf("%d milliseconds", interval.Milliseconds()),
+	})
+	if err != nil {
+		log.Error("could not execute query", "err", err.Error())
+		return nil, err
+	}
+
+	buckets := make([]time.Time, 0)
+	var bucket time.Time
+
+	_, err = pgx.ForEachRow(rows, []any{&bucket}, func() error {
+		buckets = append(buckets, bucket.UTC())
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return buckets, nil
+}
+
+func (db database) GetValueRange(ctx context.Context, thingID string) (app.ValueRange, error) {
+	log := logging.GetFromContext(ctx)
+
+	query := `
+		SELECT min(time), max(time), count(*)
+		FROM things_values
+		WHERE id LIKE @thing_prefix;`
+
+	r := app.ValueRange{}
+
+	err := db.pool.QueryRow(ctx, query, pgx.NamedArgs{
+		"thing_prefix": thingPrefix(thingID),
+	}).Scan(&r.Earliest, &r.Latest, &r.Count)
+	if err != nil {
+		log.Error("could not execute query", "err", err.Error())
+		return app.ValueRange{}, err
+	}
+
+	if r.Earliest != nil {
+		earliest, latest := r.Earliest.UTC(), r.Latest.UTC()
+		r.Earliest, r.Latest = &earliest, &latest
+	}
+
+	return r, nil
+}
+
+func (db database) CountByTenant(ctx context.Context) ([]app.TenantCount, error) {
+	log := logging.GetFromContext(ctx)
+
+	query := `
+		SELECT t.tenant, count(*), COALESCE(sum(v.n), 0)::bigint
+		FROM things t
+		LEFT JOIN LATERAL (SELECT count(*) n FROM things_values WHERE left(things_values.id, length(t.id) + 1) = t.id || '/') v ON true
+		WHERE t.deleted_on IS NULL
+		GROUP BY t.tenant
+		ORDER BY t.tenant ASC;`
+
+	rows, err := db.pool.Query(ctx, query)
+	if err != nil {
+		log.Error("could not execute query", "err", err.Error())
+		return nil, err
+	}
+
+	counts := []app.TenantCount{}
+
+	var tenant string
+	var nThings, nValues int64
+
+	_, err = pgx.ForEachRow(rows, []any{&tenant, &nThings, &nValues}, func() error {
+		counts = append(counts, app.TenantCount{
+			Tenant: tenant,
+			Things: nThings,
+			Values: nValues,
+		})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return counts, nil
+}
+
+func (db database) CountThings(ctx context.Context, tenants []string) ([]app.ThingCount, error) {
+	log := logging.GetFromContext(ctx)
+
+	query := `
+		SELECT type, tenant, count(*)
+		FROM things
+		WHERE deleted_on IS NULL AND tenant=ANY(@tenants)
+		GROUP BY type, tenant
+		ORDER BY type ASC, tenant ASC;`
+
+	rows, err := db.pool.Query(ctx, query, pgx.NamedArgs{
+		"tenants": tenants,
+	})
+	if err != nil {
+		log.Error("could not execute query", "err", err.Error())
+		return nil, err
+	}
+
+	counts := []app.ThingCount{}
+
+	var thingType, tenant string
+	var n int64
+
+	_, err = pgx.ForEachRow(rows, []any{&thingType, &tenant, &n}, func() error {
+		counts = append(counts, app.ThingCount{
+			Type:   thingType,
+			Tenant: tenant,
+			Count:  n,
+		})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return counts, nil
+}
+
 func (db database) AddValue(ctx context.Context, t things.Thing, m things.Value) error {
 	log := logging.GetFromContext(ctx)
 
+	_, err := insertValue(ctx, db.pool, t, m)
+	if err != nil {
+		log.Error("could not execute statement", "err", err.Error())
+		return err
+	}
+
+	return nil
+}
+
+// AddValueWithAggregate adds the value and, in the same transaction, updates the daily aggregate
+// of the value id in things_values_daily. Values already stored (same time and id) and values
+// without a numeric value are not aggregated.
+func (db database) AddValueWithAggregate(ctx context.Context, t things.Thing, m things.Value) error {
+	log := logging.GetFromContext(ctx)
+
+	tx, err := db.pool.Begin(ctx)
+	if err != nil {
+		log.Error("could not begin transaction", "err", err.Error())
+		return err
+	}
+
+	inserted, err := insertValue(ctx, tx, t, m)
+	if err != nil {
+		log.Error("could not execute statement", "err", err.Error())
+		tx.Rollback(ctx)
+		return err
+	}
+
+	if inserted && m.Value != nil {
+		upsert := `
+			INSERT INTO things_values_daily(day, id, urn, n, sum, avg, unit)
+			VALUES (@day, @id, @urn, 1, @v, @v, @unit)
+			ON CONFLICT (id, day) DO UPDATE SET
+				n = things_values_daily.n + 1,
+				sum = things_values_daily.sum + EXCLUDED.sum,
+				avg = (things_values_daily.sum + EXCLUDED.sum) / (things_values_daily.n + 1),
+				modified_on = CURRENT_TIMESTAMP;`
+
+		_, err = tx.Exec(ctx, upsert, pgx.NamedArgs{
+			"day":  m.Timestamp.UTC().Format(time.DateOnly),
+			"id":   m.ID,
+			"urn":  m.Urn,
+			"v":    *m.Value,
+			"unit": m.Unit,
+		})
+		if err != nil {
+			log.Error("could not execute statement", "err", err.Error())
+			tx.Rollback(ctx)
+			return err
+		}
+	}
+
+	err = tx.Commit(ctx)
+	if err != nil {
+		log.Error("could not commit transaction", "err", err.Error())
+		return err
+	}
+
+	return nil
+}
+
+// addValuesStatement inserts values given as arrays, one per column, skipping values already stored. With
+// @aggregate the daily aggregates of the inserted numeric values are updated in the same statement.
+const addValuesStatement string = `
+	WITH inserted AS (
+		INSERT INTO things_values(time, id, urn, location, v, vi, vs, vb, unit, ref, quality, calibrated, correlation_id)
+		SELECT t.time, t.id, t.urn, point(@lon,@lat), t.v, t.vi, t.vs, t.vb, t.unit, t.ref, t.quality, t.calibrated, t.correlation_id
+		FROM unnest(@time::timestamptz[], @id::text[], @urn::text[], @v::numeric[], @vi::bigint[], @vs::text[], @vb::boolean[],
+			@unit::text[], @ref::text[], @quality::text[], @calibrated::boolean[], @correlation_id::text[])
+			AS t(time, id, urn, v, vi, vs, vb, unit, ref, quality, calibrated, correlation_id)
+		ON CONFLICT (time, id) DO NOTHING
+		RETURNING time, id, urn, COALESCE(v, vi) AS v, unit
+	), daily AS (
+		INSERT INTO things_values_daily(day, id, urn, n, sum, avg, unit)
+		SELECT (time AT TIME ZONE 'UTC')::date, id, min(urn), count(*), sum(v), avg(v), min(unit)
+		FROM inserted
+		WHERE @aggregate AND v IS NOT NULL
+		GROUP BY 1, 2
+		ON CONFLICT (id, day) DO UPDATE SET
+			n = things_values_daily.n + EXCLUDED.n,
+			sum = things_values_daily.sum + EXCLUDED.sum,
+			avg = (things_values_daily.sum + EXCLUDED.sum) / (things_values_daily.n + EXCLUDED.n),
+			modified_on = CURRENT_TIMESTAMP
+	)
+	SELECT count(*) FROM inserted;`
+
+// AddValues adds values of a thing in one statement, either all of them are added or none. Values already
+// stored (same time and id) are skipped. It returns the number of values added.
+func (db database) AddValues(ctx context.Context, t things.Thing, values []things.Value, aggregate bool) (int64, error) {
+	log := logging.GetFromContext(ctx)
+
+	if len(values) == 0 {
+		return 0, nil
+	}
+
+	lat, lon := t.LatLon()
+
+	var (
+		times                    []time.Time
+		ids, urns, units         []string
+		v                        []*float64
+		vi                       []*int64
+		vs, refs, qualities, cid []*string
+		vb                       []*bool
+		calibrated               []bool
+	)
+
+	for _, m := range values {
+		row := valueArgs(m)
+		times = append(times, row["time"].(time.Time))
+		ids = append(ids, m.ID)
+		urns = append(urns, m.Urn)
+		v = append(v, row["v"].(*float64))
+		vi = append(vi, row["vi"].(*int64))
+		vs = append(vs, m.StringValue)
+		vb = append(vb, m.BoolValue)
+		units = append(units, m.Unit)
+		refs = append(refs, row["ref"].(*string))
+		qualities = append(qualities, row["quality"].(*string))
+		calibrated = append(calibrated, m.Calibrated)
+		cid = append(cid, row["correlation_id"].(*string))
+	}
+
+	args := pgx.NamedArgs{
+		"lon":            lon,
+		"lat":            lat,
+		"aggregate":      aggregate,
+		"time":           times,
+		"id":             ids,
+		"urn":            urns,
+		"v":              v,
+		"vi":             vi,
+		"vs":             vs,
+		"vb":             vb,
+		"unit":           units,
+		"ref":            refs,
+		"quality":        qualities,
+		"calibrated":     calibrated,
+		"correlation_id": cid,
+	}
+
+	var n int64
+	err := db.pool.QueryRow(ctx, addValuesStatement, args).Scan(&n)
+	if err != nil {
+		log.Error("could not execute statement", "err", err.Error())
+		return 0, err
+	}
+
+	return n, nil
+}
+
+type execer interface {
+	Exec(ctx context.Context, sql string, arguments ...any) (pgconn.CommandTag, error)
+}
+
+// insertValue returns false if the value was already stored
+func insertValue(ctx context.Context, e execer, t things.Thing, m things.Value) (bool, error) {
 	insert := `
-		INSERT INTO things_values(time, id, urn, location, v, vs, vb, unit, ref)
-		VALUES (@time, @id, @urn, point(@lon,@lat), @v, @vs, @vb, @unit, @ref)
+		INSERT INTO things_values(time, id, urn, location, v, vi, vs, vb, unit, ref, quality, calibrated, correlation_id)
+		VALUES (@time, @id, @urn, point(@lon,@lat), @v, @vi, @vs, @vb, @unit, @ref, @quality, @calibrated, @correlation_id)
 		ON CONFLICT (time, id) DO NOTHING;`
 
 	lat, lon := t.LatLon()
 
+	args := valueArgs(m)
+	args["lon"] = lon
+	args["lat"] = lat
+
+	tag, err := e.Exec(ctx, insert, args)
+	if err != nil {
+		return false, err
+	}
+
+	return tag.RowsAffected() > 0, nil
+}
+
+// valueArgs returns the columns of a value to insert, except its location
+func valueArgs(m things.Value) pgx.NamedArgs {
+	// whole numbers of integer urns are stored in vi, anything else keeps its precision in v
+	v := m.Value
+	var vi *int64
+	if v != nil && m.Integer && *v == math.Trunc(*v) && math.Abs(*v) < math.MaxInt64 {
+		i := int64(*v)
+		vi = &i
+		v = nil
+	}
+
 	var ref *string
 	if m.Ref != "" {
 		ref = &m.Ref
 	}
 
-	_, err := db.pool.Exec(ctx, insert, pgx.NamedArgs{
-		"time": m.Timestamp.UTC(),
-		"id":   m.ID,
-		"urn":  m.Urn,
-		"lon":  lon,
-		"lat":  lat,
-		"v":    m.Value,
-		"vs":   m.StringValue,
-		"vb":   m.BoolValue,
-		"unit": m.Unit,
-		"ref":  ref,
-	})
+	var quality *string
+	if m.Quality != "" {
+		quality = &m.Quality
+	}
+
+	var correlationID *string
+	if m.CorrelationID != "" {
+		correlationID = &m.CorrelationID
+	}
+
+	return pgx.NamedArgs{
+		"time":           m.Timestamp.UTC(),
+		"id":             m.ID,
+		"urn":            m.Urn,
+		"v":              v,
+		"vi":             vi,
+		"vs":             m.StringValue,
+		"vb":             m.BoolValue,
+		"unit":           m.Unit,
+		"ref":            ref,
+		"quality":        quality,
+		"calibrated":     m.Calibrated,
+		"correlation_id": correlationID,
+	}
+}
+
+func (db database) PurgeDeletedThings(ctx context.Context, deletedBefore time.Time) (int64, int64, error) {
+	log := logging.GetFromContext(ctx)
+
+	tx, err := db.pool.Begin(ctx)
+	if err != nil {
+		log.Error("could not begin transaction", "err", err.Error())
+		return 0, 0, err
+	}
+
+	args := pgx.NamedArgs{
+		"deleted_before": deletedBefore,
+	}
+
+	deleteValues := `
+		DELETE FROM things_values
+		USING things
+		WHERE things.deleted_on IS NOT NULL AND things.deleted_on < @deleted_before
+		  AND left(things_values.id, length(things.id) + 1) = things.id || '/';`
+
+	deletedValues, err := tx.Exec(ctx, deleteValues, args)
 	if err != nil {
 		log.Error("could not execute statement", "err", err.Error())
-		return err
+		tx.Rollback(ctx)
+		return 0, 0, err
 	}
 
-	return nil
+	deleteDaily := `
+		DELETE FROM things_values_daily
+		USING things
+		WHERE things.deleted_on IS NOT NULL AND things.deleted_on < @deleted_before
+		  AND left(things_values_daily.id, length(things.id) + 1) = things.id || '/';`
+
+	_, err = tx.Exec(ctx, deleteDaily, args)
+	if err != nil {
+		log.Error("could not execute statement", "err", err.Error())
+		tx.Rollback(ctx)
+		return 0, 0, err
+	}
+
+	deleteThings := `DELETE FROM things WHERE deleted_on IS NOT NULL AND deleted_on < @deleted_before;`
+
+	deletedThings, err := tx.Exec(ctx, deleteThings, args)
+	if err != nil {
+		log.Error("could not execute statement", "err", err.Error())
+		tx.Rollback(ctx)
+		return 0, 0, err
+	}
+
+	err = tx.Commit(ctx)
+	if err != nil {
+		log.Error("could not commit transaction", "err", err.Error())
+		return 0, 0, err
+	}
+
+	return deletedThings.RowsAffected(), deletedValues.RowsAffected(), nil
+}
+
+func (db database) DeleteValues(ctx context.Context, thingID string, conditions ...app.ConditionFunc) (int64, error) {
+	where, args := newValuesFilter(newConditions(append(conditions, app.WithThingID(thingID))...))
+
+	query := fmt.Sprintf("DELETE FROM things_values %s RETURNING id, time;", where)
+
+	return db.changeValues(ctx, query, args)
+}
+
+// RedactValues keeps the rows (time, id, urn etc.) as tombstones for audit but removes the measured values
+func (db database) RedactValues(ctx context.Context, thingID string, conditions ...app.ConditionFunc) (int64, error) {
+	where, args := newValuesFilter(newConditions(append(conditions, app.WithThingID(thingID))...))
+
+	query := fmt.Sprintf("UPDATE things_values SET v=NULL, vi=NULL, vs=NULL, vb=NULL, redacted_on=CURRENT_TIMESTAMP %s AND redacted_on IS NULL RETURNING id, time;", where)
+
+	return db.changeValues(ctx, query, args)
+}
+
+// recomputeDailyStatement recomputes the daily aggregates of the value ids and days given as arrays from
+// the values still stored. Aggregates of days without any numeric values left are removed. Days that were
+// never aggregated, i.e. of types not opted in, are left alone.
+const recomputeDailyStatement string = `
+	WITH affected AS (
+		SELECT a.id, a.day, count(COALESCE(v.v, v.vi)) AS n, COALESCE(sum(COALESCE(v.v, v.vi)), 0) AS sum, COALESCE(avg(COALESCE(v.v, v.vi)), 0) AS avg
+		FROM unnest(@ids::text[], @days::date[]) AS a(id, day)
+		LEFT JOIN things_values v ON v.id = a.id AND v.redacted_on IS NULL
+			AND v.time >= a.day::timestamp AT TIME ZONE 'UTC' AND v.time < (a.day + 1)::timestamp AT TIME ZONE 'UTC'
+		GROUP BY a.id, a.day
+	), updated AS (
+		UPDATE things_values_daily d
+		SET n = affected.n, sum = affected.sum, avg = affected.avg, modified_on = CURRENT_TIMESTAMP
+		FROM affected
+		WHERE d.id = affected.id AND d.day = affected.day AND affected.n > 0
+	)
+	DELETE FROM things_values_daily d
+	USING affected
+	WHERE d.id = affected.id AND d.day = affected.day AND affected.n = 0;`
+
+// changeValues runs statement, that deletes or redacts values and returns their id and time, and recomputes
+// the daily aggregates of the affected days in the same transaction. It returns the number of values changed.
+func (db database) changeValues(ctx context.Context, statement string, args pgx.NamedArgs) (int64, error) {
+	log := logging.GetFromContext(ctx)
+
+	tx, err := db.pool.Begin(ctx)
+	if err != nil {
+		log.Error("could not begin transaction", "err", err.Error())
+		return 0, err
+	}
+
+	rows, err := tx.Query(ctx, statement, args)
+	if err != nil {
+		log.Error("could not execute statement", "err", err.Error())
+		tx.Rollback(ctx)
+		return 0, err
+	}
+
+	var (
+		n        int64
+		ids      []string
+		days     []string
+		affected = map[string]bool{}
+	)
+
+	for rows.Next() {
+		var id string
+		var ts time.Time
+
+		err = rows.Scan(&id, &ts)
+		if err != nil {
+			rows.Close()
+			tx.Rollback(ctx)
+			return 0, err
+		}
+
+		n++
+
+		day := ts.UTC().Format(time.DateOnly)
+		if !affected[id+"@"+day] {
+			affected[id+"@"+day] = true
+			ids = append(ids, id)
+			days = append(days, day)
+		}
+	}
+	rows.Close()
+
+	if err = rows.Err(); err != nil {
+		log.Error("could not execute statement", "err", err.Error())
+		tx.Rollback(ctx)
+		return 0, err
+	}
+
+	if len(ids) > 0 {
+		_, err = tx.Exec(ctx, recomputeDailyStatement, pgx.NamedArgs{"ids": ids, "days": days})
+		if err != nil {
+			log.Error("could not recompute daily aggregates", "err", err.Error())
+			tx.Rollback(ctx)
+			return 0, err
+		}
+	}
+
+	err = tx.Commit(ctx)
+	if err != nil {
+		log.Error("could not commit transaction", "err", err.Error())
+		return 0, err
+	}
+
+	return n, nil
 }
 
 func isDuplicateKeyErr(err error) bool {
@@ -2,13 +2,18 @@ package storage
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
+	"strings"
 	"testing"
 	"time"
+	"unicode/utf8"
 
 	app "github.com/diwise/iot-things/internal/app/iot-things"
 	"github.com/diwise/iot-things/internal/app/iot-things/things"
 	"github.com/diwise/iot-things/internal/pkg/auth"
 	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
 )
 
 func TestAddThing(t *testing.T) {
@@ -47,6 +52,143 @@ func TestAddThingWithDevices(t *testing.T) {
 	}
 }
 
+func TestUpdateThings(t *testing.T) {
+	db, ctx, cancel, err := new()
+	defer cancel()
+
+	if err != nil {
+		t.Log("could not connect to database or create tables, will skip test")
+		t.SkipNow()
+	}
+
+	tenant := uuid.NewString()
+
+	containers := []things.Thing{}
+	for range 3 {
+		container := things.NewContainer(uuid.NewString(), things.Location{Latitude: 17.2, Longitude: 64.3}, tenant)
+		err = db.AddThing(ctx, container)
+		if err != nil {
+			t.Error(err)
+		}
+		container.(*things.Container).Tags = []string{"recycling"}
+		containers = append(containers, container)
+	}
+
+	err = db.UpdateThings(ctx, containers)
+	if err != nil {
+		t.Error(err)
+	}
+
+	result, err := db.QueryThings(ctx, app.WithTenants([]string{tenant}), app.WithTags([]string{"recycling"}))
+	if err != nil {
+		t.Error(err)
+	}
+	if result.Count != 3 {
+		t.Errorf("expected 3 patched things, got %d", result.Count)
+	}
+}
+
+func TestRelations(t *testing.T) {
+	db, ctx, cancel, err := new()
+	defer cancel()
+
+	if err != nil {
+		t.Log("could not connect to database or create tables, will skip test")
+		t.SkipNow()
+	}
+
+	tenant := uuid.NewString()
+	building := things.NewBuilding(uuid.NewString(), things.DefaultLocation, tenant)
+	room := things.NewRoom(uuid.NewString(), things.DefaultLocation, tenant)
+
+	for _, thing := range []things.Thing{building, room} {
+		err = db.AddThing(ctx, thing)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	relate := func() {
+		err := db.AddRelations(ctx, map[string]things.Relation{
+			building.ID(): {ID: room.ID(), Type: room.Type(), Relation: things.RelationChild},
+			room.ID():     {ID: building.ID(), Type: building.Type(), Relation: things.RelationParent},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	relations := func(thingID string) []things.Relation {
+		result, err := db.QueryThings(ctx, app.WithID(thingID), app.WithTenants([]string{tenant}))
+		if err != nil || len(result.Data) != 1 {
+			t.Fatalf("could not query %s: %v", thingID, err)
+		}
+		thing, err := things.ConvToThing(result.Data[0])
+		if err != nil {
+			t.Fatal(err)
+		}
+		return thing.Relations()
+	}
+
+	relate()
+	relate()
+
+	if len(relations(building.ID())) != 1 || len(relations(room.ID())) != 1 {
+		t.Errorf("expected one relation each, got %v and %v", relations(building.ID()), relations(room.ID()))
+	}
+
+	// an update of a thing loaded before it was related keeps its relations
+	building.(*things.Building).Name = "Building 1"
+	err = db.UpdateThing(ctx, building)
+	if err != nil {
+		t.Error(err)
+	}
+	if len(relations(building.ID())) != 1 {
+		t.Errorf("expected the relation to be kept by an update")
+	}
+
+	err = db.DeleteThing(ctx, room.ID())
+	if err != nil {
+		t.Error(err)
+	}
+	if len(relations(building.ID())) != 0 {
+		t.Errorf("expected the relation to a deleted thing to be removed, got %v", relations(building.ID()))
+	}
+}
+
+func TestUpdateThingIfUnchanged(t *testing.T) {
+	db, ctx, cancel, err := new()
+	defer cancel()
+
+	if err != nil {
+		t.Log("could not connect to database or create tables, will skip test")
+		t.SkipNow()
+	}
+
+	room := things.NewRoom(uuid.NewString(), things.DefaultLocation, uuid.NewString())
+	err = db.AddThing(ctx, room)
+	if err != nil {
+		t.Error(err)
+	}
+
+	result, err := db.QueryThings(ctx, app.WithID(room.ID()))
+	if err != nil {
+		t.Error(err)
+	}
+	modifiedOn := result.LastModified
+
+	err = db.UpdateThingIfUnchanged(ctx, room, modifiedOn)
+	if err != nil {
+		t.Error(err)
+	}
+
+	// the first update changed modified_on, so the same version can not be updated again
+	err = db.UpdateThingIfUnchanged(ctx, room, modifiedOn)
+	if !errors.Is(err, app.ErrPreconditionFailed) {
+		t.Errorf("expected ErrPreconditionFailed, got %v", err)
+	}
+}
+
 func TestQueryThings(t *testing.T) {
 	db, ctx, cancel, err := new()
 	defer cancel()
@@ -110,18 +252,1792 @@ func TestQueryThings(t *testing.T) {
 	}
 }
 
-func new() (Storage, context.Context, context.CancelFunc, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
-	ctx = auth.WithAllowedTenants(ctx, []string{"default"})
+func TestQueryValuesWithUrnPrefix(t *testing.T) {
+	db, ctx, cancel, err := new()
+	defer cancel()
 
-	db, err := New(ctx, Config{
-		host:     "localhost",
-		user:     "postgres",
-		password: "password",
-		port:     "5432",
-		dbname:   "postgres",
-		sslmode:  "disable",
+	if err != nil {
+		t.Log("could not connect to database or create tables, will skip test")
+		t.SkipNow()
+	}
+
+	thingID := uuid.NewString()
+	thing := things.NewRoom(thingID, things.Location{Latitude: 17.2, Longitude: 64.3}, "default")
+
+	err = db.AddThing(ctx, thing)
+	if err != nil {
+		t.Error(err)
+	}
+
+	ts := time.Now().UTC()
+	values := []things.Value{
+		things.NewTemperature(thingID, "device", 21.0, ts).Value,
+		things.NewHumidity(thingID, "device", 50.0, ts.Add(1*time.Second)).Value,
+		things.NewPeopleCounter(thingID, "device", 1, 1, ts.Add(2*time.Second)).DailyNumberOfPassages,
+	}
+
+	for _, v := range values {
+		err = db.AddValue(ctx, thing, v)
+		if err != nil {
+			t.Error(err)
+		}
+	}
+
+	result, err := db.QueryValues(ctx, app.WithThingID(thingID), app.WithUrn([]string{"urn:oma:lwm2m:ext:33"}))
+	if err != nil {
+		t.Error(err)
+	}
+	if result.TotalCount != 0 {
+		t.Errorf("exact urn match should not return any values")
+	}
+
+	result, err = db.QueryValues(ctx, app.WithThingID(thingID), app.WithUrnPrefix("urn:oma:lwm2m:ext:33"))
+	if err != nil {
+		t.Error(err)
+	}
+	if result.TotalCount != 3 {
+		t.Errorf("expected 3 values with urn prefix, got %d", result.TotalCount)
+	}
+
+	result, err = db.QueryValues(ctx, app.WithThingID(thingID), app.WithUrnPrefix("urn:oma:lwm2m:ext:330"))
+	if err != nil {
+		t.Error(err)
+	}
+	if result.TotalCount != 2 {
+		t.Errorf("expected 2 values with urn prefix, got %d", result.TotalCount)
+	}
+}
+
+func TestQueryValuesByUnit(t *testing.T) {
+	db, ctx, cancel, err := new()
+	defer cancel()
+
+	if err != nil {
+		t.Log("could not connect to database or create tables, will skip test")
+		t.SkipNow()
+	}
+
+	thingID := uuid.NewString()
+	thing := things.NewRoom(thingID, things.Location{Latitude: 17.2, Longitude: 64.3}, "default")
+
+	err = db.AddThing(ctx, thing)
+	if err != nil {
+		t.Error(err)
+	}
+
+	ts := time.Now().UTC()
+	for i, unit := range []string{"m", "metre", "m"} {
+		v := things.NewTemperature(thingID, "device", float64(10+i), ts.Add(time.Duration(i)*time.Second)).Value
+		v.Unit = unit
+
+		err = db.AddValue(ctx, thing, v)
+		if err != nil {
+			t.Error(err)
+		}
+	}
+
+	result, err := db.QueryValues(ctx, app.WithThingID(thingID), app.WithUnit([]string{"m"}))
+	if err != nil {
+		t.Error(err)
+	}
+	if result.TotalCount != 2 {
+		t.Errorf("expected 2 values in m, got %d", result.TotalCount)
+	}
+
+	result, err = db.QueryValues(ctx, app.WithParams(map[string][]string{"thingid": {thingID}, "unit": {"m"}, "op": {"gt"}, "value": {"10"}})...)
+	if err != nil {
+		t.Error(err)
+	}
+	if result.TotalCount != 1 {
+		t.Errorf("expected 1 value in m greater than 10, got %d", result.TotalCount)
+	}
+}
+
+func TestQueryValuesInBand(t *testing.T) {
+	db, ctx, cancel, err := new()
+	defer cancel()
+
+	if err != nil {
+		t.Log("could not connect to database or create tables, will skip test")
+		t.SkipNow()
+	}
+
+	thingID := uuid.NewString()
+	thing := things.NewRoom(thingID, things.Location{Latitude: 17.2, Longitude: 64.3}, "default")
+
+	err = db.AddThing(ctx, thing)
+	if err != nil {
+		t.Error(err)
+	}
+
+	ts := time.Now().UTC()
+	for i, v := range []float64{5, 12, 18, 25} {
+		err = db.AddValue(ctx, thing, things.NewTemperature(thingID, "device", v, ts.Add(time.Duration(i)*time.Second)).Value)
+		if err != nil {
+			t.Error(err)
+		}
+	}
+
+	result, err := db.QueryValues(ctx, app.WithParams(map[string][]string{"thingid": {thingID}, "op": {"gt", "lt"}, "value": {"10", "20"}})...)
+	if err != nil {
+		t.Error(err)
+	}
+	if result.TotalCount != 2 {
+		t.Errorf("expected 2 values between 10 and 20, got %d", result.TotalCount)
+	}
+}
+
+func TestPurgeDeletedThings(t *testing.T) {
+	db, ctx, cancel, err := new()
+	defer cancel()
+
+	if err != nil {
+		t.Log("could not connect to database or create tables, will skip test")
+		t.SkipNow()
+	}
+
+	oldThing := things.NewRoom(uuid.NewString(), things.Location{Latitude: 17.2, Longitude: 64.3}, "default")
+	recentThing := things.NewRoom(uuid.NewString(), things.Location{Latitude: 17.2, Longitude: 64.3}, "default")
+
+	for _, thing := range []things.Thing{oldThing, recentThing} {
+		err = db.AddThing(ctx, thing)
+		if err != nil {
+			t.Error(err)
+		}
+		err = db.AddValue(ctx, thing, things.NewTemperature(thing.ID(), "device", 21.0, time.Now()).Value)
+		if err != nil {
+			t.Error(err)
+		}
+		err = db.DeleteThing(ctx, thing.ID())
+		if err != nil {
+			t.Error(err)
+		}
+	}
+
+	pool := db.(database).pool
+	_, err = pool.Exec(ctx, "UPDATE things SET deleted_on=@deleted_on WHERE id=@id", pgx.NamedArgs{
+		"id":         oldThing.ID(),
+		"deleted_on": time.Now().Add(-48 * time.Hour),
 	})
+	if err != nil {
+		t.Error(err)
+	}
 
-	return db, ctx, cancel, err
+	_, _, err = db.PurgeDeletedThings(ctx, time.Now().Add(-24*time.Hour))
+	if err != nil {
+		t.Error(err)
+	}
+
+	count := func(query, id string) int {
+		var n int
+		err := pool.QueryRow(ctx, query, pgx.NamedArgs{"id": id}).Scan(&n)
+		if err != nil {
+			t.Error(err)
+		}
+		return n
+	}
+
+	if count("SELECT count(*) FROM things WHERE id=@id", oldThing.ID()) != 0 {
+		t.Errorf("thing deleted before grace period should have been purged")
+	}
+	if count("SELECT count(*) FROM things_values WHERE id LIKE @id || '/%'", oldThing.ID()) != 0 {
+		t.Errorf("values of purged thing should have been deleted")
+	}
+	if count("SELECT count(*) FROM things WHERE id=@id", recentThing.ID()) != 1 {
+		t.Errorf("thing deleted within grace period should not have been purged")
+	}
+	if count("SELECT count(*) FROM things_values WHERE id LIKE @id || '/%'", recentThing.ID()) != 1 {
+		t.Errorf("values of thing deleted within grace period should not have been deleted")
+	}
+}
+
+func TestRestoreDeletedThing(t *testing.T) {
+	db, ctx, cancel, err := new()
+	defer cancel()
+
+	if err != nil {
+		t.Log("could not connect to database or create tables, will skip test")
+		t.SkipNow()
+	}
+
+	thing := things.NewRoom(uuid.NewString(), things.Location{Latitude: 17.2, Longitude: 64.3}, "default")
+
+	err = db.AddThing(ctx, thing)
+	if err != nil {
+		t.Error(err)
+	}
+	err = db.DeleteThing(ctx, thing.ID())
+	if err != nil {
+		t.Error(err)
+	}
+
+	result, err := db.QueryThings(ctx, app.WithID(thing.ID()))
+	if err != nil {
+		t.Error(err)
+	}
+	if result.Count != 0 {
+		t.Error("deleted thing should not be found")
+	}
+
+	result, err = db.QueryThings(ctx, app.WithID(thing.ID()), app.WithIncludeDeleted(true))
+	if err != nil {
+		t.Error(err)
+	}
+	if result.Count != 1 || !strings.Contains(string(result.Data[0]), "deletedOn") {
+		t.Error("deleted thing should be found with a deletedOn timestamp")
+	}
+
+	err = db.UndeleteThing(ctx, thing.ID())
+	if err != nil {
+		t.Error(err)
+	}
+
+	result, err = db.QueryThings(ctx, app.WithID(thing.ID()))
+	if err != nil {
+		t.Error(err)
+	}
+	if result.Count != 1 || strings.Contains(string(result.Data[0]), "deletedOn") {
+		t.Error("restored thing should be found without a deletedOn timestamp")
+	}
+}
+
+func TestRedactValues(t *testing.T) {
+	db, ctx, cancel, err := new()
+	defer cancel()
+
+	if err != nil {
+		t.Log("could not connect to database or create tables, will skip test")
+		t.SkipNow()
+	}
+
+	thingID := uuid.NewString()
+	thing := things.NewRoom(thingID, things.Location{Latitude: 17.2, Longitude: 64.3}, "default")
+
+	err = db.AddThing(ctx, thing)
+	if err != nil {
+		t.Error(err)
+	}
+
+	ts := time.Now().UTC().Add(-1 * time.Hour)
+	for i := range 3 {
+		err = db.AddValue(ctx, thing, things.NewTemperature(thingID, "device", 21.0, ts.Add(time.Duration(i)*time.Minute)).Value)
+		if err != nil {
+			t.Error(err)
+		}
+	}
+
+	n, err := db.RedactValues(ctx, thingID, app.WithTimeRel("before"), app.WithTimeAt(ts.Add(90*time.Second).Format(time.RFC3339)))
+	if err != nil {
+		t.Error(err)
+	}
+	if n != 2 {
+		t.Errorf("expected 2 redacted values, got %d", n)
+	}
+
+	result, err := db.QueryValues(ctx, app.WithThingID(thingID))
+	if err != nil {
+		t.Error(err)
+	}
+	if result.TotalCount != 3 {
+		t.Errorf("redacted values should still be counted, got %d", result.TotalCount)
+	}
+
+	redacted := 0
+	for _, b := range result.Data {
+		v := things.Value{}
+		json.Unmarshal(b, &v)
+		if v.RedactedOn != nil {
+			redacted++
+			if v.Value != nil {
+				t.Errorf("redacted value should be null")
+			}
+		}
+	}
+	if redacted != 2 {
+		t.Errorf("expected 2 redacted values, got %d", redacted)
+	}
+}
+
+func TestValuesFilterBindsThingID(t *testing.T) {
+	thingID := `room_1%'; DROP TABLE things_values; --`
+
+	where, args := newValuesFilter(newConditions(app.WithThingID(thingID), app.WithValueName("5700")))
+	if strings.Contains(where, "DROP") {
+		t.Errorf("thing id should be bound as an argument, got %s", where)
+	}
+	if args["thing_prefix"] != `room\_1\%'; DROP TABLE things\_values; --/%` {
+		t.Errorf("unexpected thing prefix %s", args["thing_prefix"])
+	}
+}
+
+func TestQueryThingsParamsNearBindsBoundingBox(t *testing.T) {
+	query, args := newQueryThingsParams(app.WithNear(62.3908, 17.3069, 1000))
+	if strings.Contains(query, "@near_lon -") || strings.Contains(query, "@near_lat +") {
+		t.Errorf("expected the bounding box to be bound as arguments, got %s", query)
+	}
+	if args["near_min_lat"].(float64) >= 62.3908 || args["near_max_lat"].(float64) <= 62.3908 {
+		t.Errorf("expected the bounding box to contain the latitude, got %v and %v", args["near_min_lat"], args["near_max_lat"])
+	}
+	if args["near_min_lon"].(float64) >= 17.3069 || args["near_max_lon"].(float64) <= 17.3069 {
+		t.Errorf("expected the bounding box to contain the longitude, got %v and %v", args["near_min_lon"], args["near_max_lon"])
+	}
+}
+
+func TestQueryValuesParamsWithCursor(t *testing.T) {
+	ts := time.Date(2024, 11, 1, 12, 0, 0, 0, time.UTC)
+
+	query, args := newQueryValuesParams(app.WithThingID("room-001"), app.WithLimit(10), app.WithCursor(ts, "room-001/3303/5700"))
+	if !strings.Contains(query, "time >= @cursor_ts AND (time, id) > (@cursor_ts, @cursor_id)") {
+		t.Errorf("expected the cursor to bound time on its own, got %s", query)
+	}
+	if !strings.HasSuffix(query, "LIMIT @limit + 1") || args["limit"] != 10 {
+		t.Errorf("expected one more value than the limit, got %s", query)
+	}
+}
+
+func TestParseInterval(t *testing.T) {
+	valid := map[string]time.Duration{
+		"":         0,
+		"1 day":    24 * time.Hour,
+		"12 hours": 12 * time.Hour,
+		"2 Weeks":  14 * 24 * time.Hour,
+		"36h":      36 * time.Hour,
+	}
+	for s, expected := range valid {
+		d, err := parseInterval(s)
+		if err != nil || d != expected {
+			t.Errorf("expected %q to be %s, got %s (%v)", s, expected, d, err)
+		}
+	}
+
+	for _, s := range []string{"1day", "one day", "1 fortnight", "0 days", "-1h"} {
+		if _, err := parseInterval(s); err == nil {
+			t.Errorf("expected %q to be rejected", s)
+		}
+	}
+}
+
+func TestDeleteValuesOfThingWithWildcardsInID(t *testing.T) {
+	db, ctx, cancel, err := new()
+	defer cancel()
+
+	if err != nil {
+		t.Log("could not connect to database or create tables, will skip test")
+		t.SkipNow()
+	}
+
+	suffix := uuid.NewString()
+	thing := things.NewRoom("room_1%'"+suffix, things.Location{Latitude: 17.2, Longitude: 64.3}, "default")
+	other := things.NewRoom("roomX1abc'"+suffix, things.Location{Latitude: 17.2, Longitude: 64.3}, "default")
+
+	ts := time.Now().UTC().Add(-1 * time.Hour)
+	for _, th := range []things.Thing{thing, other} {
+		err = db.AddThing(ctx, th)
+		if err != nil {
+			t.Error(err)
+		}
+		err = db.AddValue(ctx, th, things.NewTemperature(th.ID(), "device", 21.0, ts).Value)
+		if err != nil {
+			t.Error(err)
+		}
+	}
+
+	n, err := db.DeleteValues(ctx, thing.ID())
+	if err != nil {
+		t.Error(err)
+	}
+	if n != 1 {
+		t.Errorf("expected 1 deleted value, got %d", n)
+	}
+
+	result, err := db.QueryValues(ctx, app.WithThingID(other.ID()))
+	if err != nil {
+		t.Error(err)
+	}
+	if result.TotalCount != 1 {
+		t.Errorf("values of other things should be kept, got %d", result.TotalCount)
+	}
+}
+
+func TestQueryValuesWithTenantsMatchesExactThingID(t *testing.T) {
+	db, ctx, cancel, err := new()
+	defer cancel()
+
+	if err != nil {
+		t.Log("could not connect to database or create tables, will skip test")
+		t.SkipNow()
+	}
+
+	suffix := uuid.NewString()
+	tenant := "tenant-" + suffix
+	thing := things.NewRoom("room_1"+suffix, things.Location{Latitude: 17.2, Longitude: 64.3}, tenant)
+	other := things.NewRoom("roomX1"+suffix, things.Location{Latitude: 17.2, Longitude: 64.3}, "other")
+
+	ts := time.Now().UTC().Add(-1 * time.Hour)
+	for _, th := range []things.Thing{thing, other} {
+		err = db.AddThing(ctx, th)
+		if err != nil {
+			t.Error(err)
+		}
+		err = db.AddValue(ctx, th, things.NewTemperature(th.ID(), "device", 21.0, ts).Value)
+		if err != nil {
+			t.Error(err)
+		}
+	}
+
+	result, err := db.QueryValues(ctx, app.WithTenants([]string{tenant}))
+	if err != nil {
+		t.Error(err)
+	}
+	if result.TotalCount != 1 {
+		t.Errorf("values of things in other tenants should not be found, got %d", result.TotalCount)
+	}
+}
+
+func TestAddValueWithAggregate(t *testing.T) {
+	db, ctx, cancel, err := new()
+	defer cancel()
+
+	if err != nil {
+		t.Log("could not connect to database or create tables, will skip test")
+		t.SkipNow()
+	}
+
+	thingID := uuid.NewString()
+	thing := things.NewRoom(thingID, things.Location{Latitude: 17.2, Longitude: 64.3}, "default")
+
+	err = db.AddThing(ctx, thing)
+	if err != nil {
+		t.Error(err)
+	}
+
+	day := time.Now().UTC().Truncate(24 * time.Hour).Add(-48 * time.Hour)
+	timestamps := []time.Time{day.Add(1 * time.Hour), day.Add(2 * time.Hour), day.Add(25 * time.Hour)}
+
+	for i, ts := range timestamps {
+		err = db.AddValueWithAggregate(ctx, thing, things.NewTemperature(thingID, "device", float64(20+i), ts).Value)
+		if err != nil {
+			t.Error(err)
+		}
+	}
+
+	// a value that is already stored must not be aggregated twice
+	err = db.AddValueWithAggregate(ctx, thing, things.NewTemperature(thingID, "device", 20, timestamps[0]).Value)
+	if err != nil {
+		t.Error(err)
+	}
+
+	rows, err := db.(database).pool.Query(ctx, `
+		SELECT d.day, d.n, d.sum, d.avg, r.n, r.sum, r.avg
+		FROM things_values_daily d
+		JOIN (
+			SELECT id, (time AT TIME ZONE 'UTC')::date AS day, COUNT(*) AS n, SUM(v) AS sum, AVG(v) AS avg
+			FROM things_values
+			WHERE id LIKE @thing_id || '/%'
+			GROUP BY id, day
+		) r ON r.id = d.id AND r.day = d.day
+		ORDER BY d.day ASC`, pgx.NamedArgs{"thing_id": thingID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+
+	days := 0
+	for rows.Next() {
+		var d time.Time
+		var n, rawN int64
+		var sum, avg, rawSum, rawAvg float64
+		err = rows.Scan(&d, &n, &sum, &avg, &rawN, &rawSum, &rawAvg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n != rawN || sum != rawSum || avg != rawAvg {
+			t.Errorf("aggregate for %s (%d, %f, %f) does not match raw values (%d, %f, %f)", d.Format(time.DateOnly), n, sum, avg, rawN, rawSum, rawAvg)
+		}
+		days++
+	}
+
+	if days != 2 {
+		t.Errorf("expected aggregates for 2 days, got %d", days)
+	}
+}
+
+func TestDeleteAndRedactValuesRecomputeDailyAggregates(t *testing.T) {
+	db, ctx, cancel, err := new()
+	defer cancel()
+
+	if err != nil {
+		t.Log("could not connect to database or create tables, will skip test")
+		t.SkipNow()
+	}
+
+	thingID := uuid.NewString()
+	thing := things.NewRoom(thingID, things.Location{Latitude: 17.2, Longitude: 64.3}, "default")
+
+	err = db.AddThing(ctx, thing)
+	if err != nil {
+		t.Error(err)
+	}
+
+	day := time.Now().UTC().Truncate(24 * time.Hour).Add(-48 * time.Hour)
+	timestamps := []time.Time{day.Add(1 * time.Hour), day.Add(2 * time.Hour), day.Add(25 * time.Hour)}
+
+	for i, ts := range timestamps {
+		err = db.AddValueWithAggregate(ctx, thing, things.NewTemperature(thingID, "device", float64(20+i), ts).Value)
+		if err != nil {
+			t.Error(err)
+		}
+	}
+
+	daily := func(d time.Time) (n int64, avg float64) {
+		err := db.(database).pool.QueryRow(ctx, `SELECT n, avg FROM things_values_daily WHERE id LIKE @thing_id || '/%' AND day=@day`, pgx.NamedArgs{
+			"thing_id": thingID,
+			"day":      d.Format(time.DateOnly),
+		}).Scan(&n, &avg)
+		if errors.Is(err, pgx.ErrNoRows) {
+			return 0, 0
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		return n, avg
+	}
+
+	_, err = db.RedactValues(ctx, thingID, app.WithTimeRel("between"), app.WithTimeAt(timestamps[0].Format(time.RFC3339)), app.WithEndTimeAt(timestamps[0].Add(time.Minute).Format(time.RFC3339)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if n, avg := daily(day); n != 1 || avg != 21 {
+		t.Errorf("expected aggregate (1, 21) after redact, got (%d, %f)", n, avg)
+	}
+
+	_, err = db.DeleteValues(ctx, thingID, app.WithTimeRel("after"), app.WithTimeAt(day.Add(23*time.Hour).Format(time.RFC3339)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if n, _ := daily(day.Add(24 * time.Hour)); n != 0 {
+		t.Errorf("expected no aggregate for a day without values, got %d", n)
+	}
+	if n, _ := daily(day); n != 1 {
+		t.Errorf("expected aggregate of another day to be kept, got %d", n)
+	}
+}
+
+func TestAddValues(t *testing.T) {
+	db, ctx, cancel, err := new()
+	defer cancel()
+
+	if err != nil {
+		t.Log("could not connect to database or create tables, will skip test")
+		t.SkipNow()
+	}
+
+	thingID := uuid.NewString()
+	thing := things.NewRoom(thingID, things.Location{Latitude: 17.2, Longitude: 64.3}, "default")
+
+	err = db.AddThing(ctx, thing)
+	if err != nil {
+		t.Error(err)
+	}
+
+	ts := time.Date(2020, 1, 1, 12, 0, 0, 0, time.UTC)
+	values := []things.Value{
+		things.NewTemperature(thingID, "device", 20.0, ts).Value,
+		things.NewTemperature(thingID, "device", 22.0, ts.Add(time.Hour)).Value,
+		things.NewHumidity(thingID, "device", 50.0, ts).Value,
+	}
+
+	n, err := db.AddValues(ctx, thing, values, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 3 {
+		t.Errorf("expected 3 values to be added, got %d", n)
+	}
+
+	// values already stored are skipped
+	n, err = db.AddValues(ctx, thing, values[:2], true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 0 {
+		t.Errorf("expected no values to be added again, got %d", n)
+	}
+
+	var count int64
+	var avg float64
+	err = db.(database).pool.QueryRow(ctx, `SELECT n, avg FROM things_values_daily WHERE id=@id AND day='2020-01-01'`, pgx.NamedArgs{
+		"id": values[0].ID,
+	}).Scan(&count, &avg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != 2 || avg != 21.0 {
+		t.Errorf("expected a daily average of 21 from 2 values, got %f from %d", avg, count)
+	}
+}
+
+func TestIntegerValues(t *testing.T) {
+	db, ctx, cancel, err := new()
+	defer cancel()
+
+	if err != nil {
+		t.Log("could not connect to database or create tables, will skip test")
+		t.SkipNow()
+	}
+
+	thingID := uuid.NewString()
+	thing := things.NewPassage(thingID, things.Location{Latitude: 17.2, Longitude: 64.3}, "default")
+
+	err = db.AddThing(ctx, thing)
+	if err != nil {
+		t.Error(err)
+	}
+
+	ts := time.Now().UTC().Add(-1 * time.Hour)
+	for i, n := range []int64{12, 1000000} {
+		v := things.NewPeopleCounter(thingID, "device", 3, n, ts.Add(time.Duration(i)*time.Minute)).CumulatedNumberOfPassages
+		v.Integer = true
+		err = db.AddValue(ctx, thing, v)
+		if err != nil {
+			t.Error(err)
+		}
+	}
+
+	var stored int
+	err = db.(database).pool.QueryRow(ctx, "SELECT count(*) FROM things_values WHERE id LIKE $1 AND vi IS NOT NULL AND v IS NULL", thingID+"/%").Scan(&stored)
+	if err != nil {
+		t.Error(err)
+	}
+	if stored != 2 {
+		t.Errorf("expected 2 values stored as integers, got %d", stored)
+	}
+
+	result, err := db.QueryValues(ctx, app.WithThingID(thingID), app.WithOperator("gt"), app.WithValue("10"))
+	if err != nil {
+		t.Error(err)
+	}
+	if result.Count != 2 {
+		t.Errorf("expected 2 values, got %d", result.Count)
+	}
+
+	v := things.Value{}
+	json.Unmarshal(result.Data[0], &v)
+	if v.Value == nil || *v.Value != 12 {
+		t.Errorf("expected integer value to be returned as v")
+	}
+}
+
+func TestCalibratedValues(t *testing.T) {
+	db, ctx, cancel, err := new()
+	defer cancel()
+
+	if err != nil {
+		t.Log("could not connect to database or create tables, will skip test")
+		t.SkipNow()
+	}
+
+	thingID := uuid.NewString()
+	thing := things.NewRoom(thingID, things.Location{Latitude: 17.2, Longitude: 64.3}, "default")
+
+	err = db.AddThing(ctx, thing)
+	if err != nil {
+		t.Error(err)
+	}
+
+	ts := time.Now().UTC().Add(-1 * time.Hour)
+
+	raw := things.NewTemperature(thingID, "device", 21.0, ts).Value
+	calibrated := things.NewTemperature(thingID, "device", 19.4, ts.Add(time.Minute)).Value
+	calibrated.Calibrated = true
+
+	for _, v := range []things.Value{raw, calibrated} {
+		err = db.AddValue(ctx, thing, v)
+		if err != nil {
+			t.Error(err)
+		}
+	}
+
+	result, err := db.QueryValues(ctx, app.WithThingID(thingID))
+	if err != nil {
+		t.Error(err)
+	}
+	if result.Count != 2 {
+		t.Fatalf("expected 2 values, got %d", result.Count)
+	}
+
+	flags := map[float64]bool{}
+	for _, b := range result.Data {
+		v := things.Value{}
+		json.Unmarshal(b, &v)
+		flags[*v.Value] = v.Calibrated
+	}
+
+	if flags[21.0] || !flags[19.4] {
+		t.Errorf("unexpected calibration flags %v", flags)
+	}
+}
+
+func TestGetUrns(t *testing.T) {
+	db, ctx, cancel, err := new()
+	defer cancel()
+
+	if err != nil {
+		t.Log("could not connect to database or create tables, will skip test")
+		t.SkipNow()
+	}
+
+	thingID := uuid.NewString()
+	thing := things.NewRoom(thingID, things.Location{Latitude: 17.2, Longitude: 64.3}, "default")
+
+	err = db.AddThing(ctx, thing)
+	if err != nil {
+		t.Error(err)
+	}
+
+	ts := time.Now().UTC()
+	values := []things.Value{
+		things.NewTemperature(thingID, "device", 21.0, ts).Value,
+		things.NewTemperature(thingID, "device", 22.0, ts.Add(1*time.Second)).Value,
+		things.NewHumidity(thingID, "device", 50.0, ts).Value,
+	}
+
+	for _, v := range values {
+		err = db.AddValue(ctx, thing, v)
+		if err != nil {
+			t.Error(err)
+		}
+	}
+
+	urns, err := db.GetUrns(ctx, thingID)
+	if err != nil {
+		t.Error(err)
+	}
+	if len(urns) != 2 || urns[0] != things.TemperatureURN || urns[1] != things.HumidityURN {
+		t.Errorf("unexpected urns %v", urns)
+	}
+}
+
+func TestQueryValuesNewestFirst(t *testing.T) {
+	db, ctx, cancel, err := new()
+	defer cancel()
+
+	if err != nil {
+		t.Log("could not connect to database or create tables, will skip test")
+		t.SkipNow()
+	}
+
+	thingID := uuid.NewString()
+	thing := things.NewRoom(thingID, things.Location{Latitude: 17.2, Longitude: 64.3}, "default")
+
+	err = db.AddThing(ctx, thing)
+	if err != nil {
+		t.Error(err)
+	}
+
+	ts := time.Now().UTC()
+	for i := range 5 {
+		err = db.AddValue(ctx, thing, things.NewTemperature(thingID, "device", float64(20+i), ts.Add(time.Duration(i)*time.Second)).Value)
+		if err != nil {
+			t.Error(err)
+		}
+	}
+	err = db.AddValue(ctx, thing, things.NewHumidity(thingID, "device", 50.0, ts.Add(10*time.Second)).Value)
+	if err != nil {
+		t.Error(err)
+	}
+
+	result, err := db.QueryValues(ctx, app.WithThingID(thingID), app.WithUrn([]string{things.TemperatureURN}), app.WithNewestFirst(), app.WithLimit(2))
+	if err != nil {
+		t.Error(err)
+	}
+	if result.Count != 2 {
+		t.Fatalf("expected 2 values, got %d", result.Count)
+	}
+
+	latest := things.Value{}
+	json.Unmarshal(result.Data[0], &latest)
+	if *latest.Value != 24 {
+		t.Errorf("expected most recent temperature first, got %f", *latest.Value)
+	}
+}
+
+func TestQueryValuesWithCursor(t *testing.T) {
+	db, ctx, cancel, err := new()
+	defer cancel()
+
+	if err != nil {
+		t.Log("could not connect to database or create tables, will skip test")
+		t.SkipNow()
+	}
+
+	thingID := uuid.NewString()
+	thing := things.NewRoom(thingID, things.Location{Latitude: 17.2, Longitude: 64.3}, "default")
+
+	err = db.AddThing(ctx, thing)
+	if err != nil {
+		t.Error(err)
+	}
+
+	// temperature and humidity share timestamps, so the order within a timestamp is given by id
+	ts := time.Now().UTC().Truncate(time.Second)
+	for i := range 3 {
+		at := ts.Add(time.Duration(i) * time.Second)
+		if err = db.AddValue(ctx, thing, things.NewTemperature(thingID, "device", float64(20+i), at).Value); err != nil {
+			t.Error(err)
+		}
+		if err = db.AddValue(ctx, thing, things.NewHumidity(thingID, "device", float64(50+i), at).Value); err != nil {
+			t.Error(err)
+		}
+	}
+
+	seen := map[string]bool{}
+	conditions := []app.ConditionFunc{app.WithThingID(thingID), app.WithLimit(2)}
+	pages := 0
+
+	for {
+		result, err := db.QueryValues(ctx, conditions...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		pages++
+
+		for _, b := range result.Data {
+			v := things.Value{}
+			json.Unmarshal(b, &v)
+			key := v.ID + v.Timestamp.String()
+			if seen[key] {
+				t.Errorf("value %s returned more than once", key)
+			}
+			seen[key] = true
+		}
+
+		if result.NextCursor == "" {
+			break
+		}
+
+		cursorTs, cursorID, err := app.DecodeCursor(result.NextCursor)
+		if err != nil {
+			t.Fatal(err)
+		}
+		conditions = []app.ConditionFunc{app.WithThingID(thingID), app.WithLimit(2), app.WithCursor(cursorTs, cursorID)}
+	}
+
+	if len(seen) != 6 || pages != 3 {
+		t.Errorf("expected 6 values on 3 pages, got %d values on %d pages", len(seen), pages)
+	}
+}
+
+func TestQueryThingsByTagMode(t *testing.T) {
+	db, ctx, cancel, err := new()
+	defer cancel()
+
+	if err != nil {
+		t.Log("could not connect to database or create tables, will skip test")
+		t.SkipNow()
+	}
+
+	tenant := uuid.NewString()
+
+	for _, tags := range [][]string{{"north"}, {"south"}, {"north", "south"}, {"east"}} {
+		room := things.NewRoom(uuid.NewString(), things.DefaultLocation, tenant)
+		for _, tag := range tags {
+			room.AddTag(tag)
+		}
+		err = db.AddThing(ctx, room)
+		if err != nil {
+			t.Error(err)
+		}
+	}
+
+	count := func(conditions ...app.ConditionFunc) int {
+		result, err := db.QueryThings(ctx, append(conditions, app.WithTenants([]string{tenant}))...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return result.Count
+	}
+
+	if n := count(app.WithTags([]string{"north", "south"})); n != 1 {
+		t.Errorf("expected 1 thing with both tags, got %d", n)
+	}
+	if n := count(app.WithTags([]string{"north", "south"}), app.WithTagMode(app.TagModeAny)); n != 3 {
+		t.Errorf("expected 3 things with any of the tags, got %d", n)
+	}
+}
+
+func TestQueryThingsByName(t *testing.T) {
+	db, ctx, cancel, err := new()
+	defer cancel()
+
+	if err != nil {
+		t.Log("could not connect to database or create tables, will skip test")
+		t.SkipNow()
+	}
+
+	tenant := uuid.NewString()
+
+	for _, name := range []string{"Förrådet", "Forradet 2", "Pumpstation 10%"} {
+		b, _ := json.Marshal(map[string]any{"id": uuid.NewString(), "type": "Sewer", "tenant": tenant, "name": name})
+		sewer, _ := things.ConvToThing(b)
+		err = db.AddThing(ctx, sewer)
+		if err != nil {
+			t.Error(err)
+		}
+	}
+
+	room := things.NewRoom(uuid.NewString(), things.DefaultLocation, tenant)
+	b, _ := json.Marshal(map[string]any{"id": room.ID(), "type": "Room", "tenant": tenant, "name": "Förrådet"})
+	room, _ = things.ConvToThing(b)
+	err = db.AddThing(ctx, room)
+	if err != nil {
+		t.Error(err)
+	}
+
+	count := func(conditions ...app.ConditionFunc) int {
+		result, err := db.QueryThings(ctx, append(conditions, app.WithTenants([]string{tenant}))...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return result.Count
+	}
+
+	if n := count(app.WithName("förråd")); n != 3 {
+		t.Errorf("expected 3 things matching förråd, got %d", n)
+	}
+	if n := count(app.WithName("FORRADET"), app.WithTypes([]string{"Sewer"})); n != 2 {
+		t.Errorf("expected 2 sewers matching FORRADET, got %d", n)
+	}
+	if n := count(app.WithName("10%")); n != 1 {
+		t.Errorf("expected wildcards to be matched literally, got %d", n)
+	}
+	if n := count(app.WithName("n_1")); n != 0 {
+		t.Errorf("expected wildcards to be matched literally, got %d", n)
+	}
+}
+
+func TestQueryThingsByArea(t *testing.T) {
+	db, ctx, cancel, err := new()
+	defer cancel()
+
+	if err != nil {
+		t.Log("could not connect to database or create tables, will skip test")
+		t.SkipNow()
+	}
+
+	tenant := uuid.NewString()
+
+	// roughly 0, 800 and 5500 meters north of the center of Sundsvall
+	for _, lat := range []float64{62.3908, 62.3980, 62.4400} {
+		err = db.AddThing(ctx, things.NewRoom(uuid.NewString(), things.Location{Latitude: lat, Longitude: 17.3069}, tenant))
+		if err != nil {
+			t.Error(err)
+		}
+	}
+
+	count := func(conditions ...app.ConditionFunc) int {
+		result, err := db.QueryThings(ctx, append(conditions, app.WithTenants([]string{tenant}))...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return result.Count
+	}
+
+	if n := count(app.WithBounds(62.38, 17.30, 62.40, 17.31)); n != 2 {
+		t.Errorf("expected 2 things within bounds, got %d", n)
+	}
+	if n := count(app.WithNear(62.3908, 17.3069, 1000)); n != 2 {
+		t.Errorf("expected 2 things within 1000 meters, got %d", n)
+	}
+	if n := count(app.WithNear(62.3908, 17.3069, 500)); n != 1 {
+		t.Errorf("expected 1 thing within 500 meters, got %d", n)
+	}
+	if n := count(app.WithNear(62.3908, 17.3069, 10000), app.WithTypes([]string{"Room"})); n != 3 {
+		t.Errorf("expected 3 rooms within 10 km, got %d", n)
+	}
+}
+
+func TestAccentedLettersArePositional(t *testing.T) {
+	if utf8.RuneCountInString(accented) != utf8.RuneCountInString(unaccented) {
+		t.Errorf("each accented letter must have an unaccented counterpart")
+	}
+	if escapeLike(`10%_\`) != `10\%\_\\` {
+		t.Errorf("unexpected escaped pattern %s", escapeLike(`10%_\`))
+	}
+}
+
+func TestQueryThingsByStatus(t *testing.T) {
+	db, ctx, cancel, err := new()
+	defer cancel()
+
+	if err != nil {
+		t.Log("could not connect to database or create tables, will skip test")
+		t.SkipNow()
+	}
+
+	thingID := uuid.NewString()
+	thing := things.NewRoom(thingID, things.Location{Latitude: 17.2, Longitude: 64.3}, "default")
+
+	err = db.AddThing(ctx, thing)
+	if err != nil {
+		t.Error(err)
+	}
+
+	result, err := db.QueryThings(ctx, app.WithID(thingID), app.WithStatus(things.StatusActive))
+	if err != nil {
+		t.Error(err)
+	}
+	if result.Count != 1 {
+		t.Errorf("expected new thing to be active")
+	}
+
+	b, _ := json.Marshal(map[string]any{"id": thingID, "type": "Room", "tenant": "default", "status": things.StatusInactive})
+	inactive, _ := things.ConvToThing(b)
+
+	err = db.UpdateThing(ctx, inactive)
+	if err != nil {
+		t.Error(err)
+	}
+
+	result, err = db.QueryThings(ctx, app.WithID(thingID), app.WithStatus(things.StatusActive))
+	if err != nil {
+		t.Error(err)
+	}
+	if result.Count != 0 {
+		t.Errorf("expected inactive thing to be filtered out")
+	}
+
+	result, err = db.QueryThings(ctx, app.WithID(thingID), app.WithStatus(things.StatusInactive))
+	if err != nil {
+		t.Error(err)
+	}
+	if result.Count != 1 {
+		t.Errorf("expected inactive thing to be found")
+	}
+}
+
+func TestAggregateValuesByType(t *testing.T) {
+	db, ctx, cancel, err := new()
+	defer cancel()
+
+	if err != nil {
+		t.Log("could not connect to database or create tables, will skip test")
+		t.SkipNow()
+	}
+
+	tenant := uuid.NewString()
+	ts := time.Now().UTC().Truncate(time.Hour)
+
+	for i, daily := range []int{3, 4} {
+		passage := things.NewPassage(uuid.NewString(), things.Location{Latitude: 17.2, Longitude: 64.3}, tenant)
+		err = db.AddThing(ctx, passage)
+		if err != nil {
+			t.Error(err)
+		}
+
+		err = db.AddValue(ctx, passage, things.NewPeopleCounter(passage.ID(), "device", daily, int64(daily), ts.Add(time.Duration(i)*time.Second)).DailyNumberOfPassages)
+		if err != nil {
+			t.Error(err)
+		}
+	}
+
+	room := things.NewRoom(uuid.NewString(), things.Location{Latitude: 17.2, Longitude: 64.3}, tenant)
+	err = db.AddThing(ctx, room)
+	if err != nil {
+		t.Error(err)
+	}
+	err = db.AddValue(ctx, room, things.NewPeopleCounter(room.ID(), "device", 100, 100, ts).DailyNumberOfPassages)
+	if err != nil {
+		t.Error(err)
+	}
+
+	result, err := db.QueryValues(ctx, app.WithTenants([]string{tenant}), app.WithTypes([]string{"Passage"}), app.WithValueName("5"), app.WithTimeUnit("day"), app.WithAggregate([]string{"sum"}))
+	if err != nil {
+		t.Error(err)
+	}
+	if result.Count != 1 {
+		t.Fatalf("expected one aggregate, got %d", result.Count)
+	}
+
+	aggregate := struct {
+		Value float64 `json:"v"`
+		Count int64   `json:"count"`
+	}{}
+	json.Unmarshal(result.Data[0], &aggregate)
+
+	if aggregate.Value != 7 || aggregate.Count != 2 {
+		t.Errorf("expected sum 7 of 2 values, got %f of %d", aggregate.Value, aggregate.Count)
+	}
+}
+
+func TestAggregateValuesWithSeveralAggregates(t *testing.T) {
+	db, ctx, cancel, err := new()
+	defer cancel()
+
+	if err != nil {
+		t.Log("could not connect to database or create tables, will skip test")
+		t.SkipNow()
+	}
+
+	thingID := uuid.NewString()
+	thing := things.NewRoom(thingID, things.Location{Latitude: 17.2, Longitude: 64.3}, "default")
+
+	err = db.AddThing(ctx, thing)
+	if err != nil {
+		t.Error(err)
+	}
+
+	day := time.Now().UTC().Truncate(24 * time.Hour).Add(-48 * time.Hour)
+
+	for d, temperatures := range [][]float64{{18, 20, 22}, {10, 14}} {
+		for i, temp := range temperatures {
+			ts := day.Add(time.Duration(d)*24*time.Hour + time.Duration(i)*time.Hour)
+			err = db.AddValue(ctx, thing, things.NewTemperature(thingID, "device", temp, ts).Value)
+			if err != nil {
+				t.Error(err)
+			}
+		}
+	}
+
+	result, err := db.QueryValues(ctx, app.WithThingID(thingID), app.WithTimeUnit("day"), app.WithAggregate([]string{"avg", "max"}))
+	if err != nil {
+		t.Error(err)
+	}
+	if result.Count != 2 {
+		t.Fatalf("expected aggregates for two days, got %d", result.Count)
+	}
+
+	stats := []map[string]any{}
+	for _, b := range result.Data {
+		m := map[string]any{}
+		json.Unmarshal(b, &m)
+		stats = append(stats, m)
+	}
+
+	if stats[0]["avg"] != 20.0 || stats[0]["max"] != 22.0 || stats[0]["count"] != 3.0 {
+		t.Errorf("unexpected aggregates for first day: %v", stats[0])
+	}
+	if stats[1]["avg"] != 12.0 || stats[1]["max"] != 14.0 || stats[1]["count"] != 2.0 {
+		t.Errorf("unexpected aggregates for second day: %v", stats[1])
+	}
+	if stats[0]["aggr"] != "avg" || stats[0]["v"] != 20.0 {
+		t.Errorf("expected the first requested aggregate as aggr and v, got %v", stats[0])
+	}
+	if _, ok := stats[0]["min"]; ok {
+		t.Errorf("expected only requested aggregates")
+	}
+}
+
+func TestGetValueBuckets(t *testing.T) {
+	db, ctx, cancel, err := new()
+	defer cancel()
+
+	if err != nil {
+		t.Log("could not connect to database or create tables, will skip test")
+		t.SkipNow()
+	}
+
+	thingID := uuid.NewString()
+	thing := things.NewRoom(thingID, things.Location{Latitude: 17.2, Longitude: 64.3}, "default")
+
+	err = db.AddThing(ctx, thing)
+	if err != nil {
+		t.Error(err)
+	}
+
+	from := time.Now().UTC().Truncate(time.Hour).Add(-6 * time.Hour)
+
+	// reported every 15 minutes during the first, third and fourth hour only
+	for _, h := range []int{0, 2, 3} {
+		for m := 0; m < 60; m += 15 {
+			ts := from.Add(time.Duration(h)*time.Hour + time.Duration(m)*time.Minute)
+			err = db.AddValue(ctx, thing, things.NewTemperature(thingID, "device", 20.0+float64(m), ts).Value)
+			if err != nil {
+				t.Error(err)
+			}
+		}
+	}
+
+	buckets, err := db.GetValueBuckets(ctx, thingID, from, from.Add(6*time.Hour), time.Hour)
+	if err != nil {
+		t.Error(err)
+	}
+
+	if len(buckets) != 3 {
+		t.Fatalf("expected 3 buckets, got %v", buckets)
+	}
+	for i, h := range []int{0, 2, 3} {
+		if !buckets[i].Equal(from.Add(time.Duration(h) * time.Hour)) {
+			t.Errorf("unexpected bucket %v, expected %v", buckets[i], from.Add(time.Duration(h)*time.Hour))
+		}
+	}
+}
+
+func TestGetValueRange(t *testing.T) {
+	db, ctx, cancel, err := new()
+	defer cancel()
+
+	if err != nil {
+		t.Log("could not connect to database or create tables, will skip test")
+		t.SkipNow()
+	}
+
+	thingID := uuid.NewString()
+	thing := things.NewRoom(thingID, things.Location{Latitude: 17.2, Longitude: 64.3}, "default")
+
+	err = db.AddThing(ctx, thing)
+	if err != nil {
+		t.Error(err)
+	}
+
+	r, err := db.GetValueRange(ctx, thingID)
+	if err != nil {
+		t.Error(err)
+	}
+	if r.Earliest != nil || r.Latest != nil || r.Count != 0 {
+		t.Errorf("expected an empty range, got %v", r)
+	}
+
+	ts := time.Now().UTC().Truncate(time.Second).Add(-3 * time.Hour)
+	for i := range 3 {
+		err = db.AddValue(ctx, thing, things.NewTemperature(thingID, "device", 20.0+float64(i), ts.Add(time.Duration(i)*time.Hour)).Value)
+		if err != nil {
+			t.Error(err)
+		}
+	}
+
+	r, err = db.GetValueRange(ctx, thingID)
+	if err != nil {
+		t.Error(err)
+	}
+	if r.Count != 3 {
+		t.Errorf("expected 3 values, got %d", r.Count)
+	}
+	if r.Earliest == nil || !r.Earliest.Equal(ts) {
+		t.Errorf("expected earliest %v, got %v", ts, r.Earliest)
+	}
+	if r.Latest == nil || !r.Latest.Equal(ts.Add(2*time.Hour)) {
+		t.Errorf("expected latest %v, got %v", ts.Add(2*time.Hour), r.Latest)
+	}
+}
+
+func TestCountByTenant(t *testing.T) {
+	db, ctx, cancel, err := new()
+	defer cancel()
+
+	if err != nil {
+		t.Log("could not connect to database or create tables, will skip test")
+		t.SkipNow()
+	}
+
+	tenant := uuid.NewString()
+	room := things.NewRoom(uuid.NewString(), things.Location{Latitude: 17.2, Longitude: 64.3}, tenant)
+
+	err = db.AddThing(ctx, room)
+	if err != nil {
+		t.Error(err)
+	}
+	err = db.AddValue(ctx, room, things.NewTemperature(room.ID(), "device", 21.0, time.Now().UTC()).Value)
+	if err != nil {
+		t.Error(err)
+	}
+
+	counts, err := db.CountByTenant(ctx)
+	if err != nil {
+		t.Error(err)
+	}
+
+	for _, c := range counts {
+		if c.Tenant == tenant {
+			if c.Things != 1 || c.Values != 1 {
+				t.Errorf("expected 1 thing and 1 value, got %d and %d", c.Things, c.Values)
+			}
+			return
+		}
+	}
+
+	t.Errorf("tenant %s not found", tenant)
+}
+
+func TestCountThings(t *testing.T) {
+	db, ctx, cancel, err := new()
+	defer cancel()
+
+	if err != nil {
+		t.Log("could not connect to database or create tables, will skip test")
+		t.SkipNow()
+	}
+
+	tenant := uuid.NewString()
+
+	for _, thing := range []things.Thing{
+		things.NewRoom(uuid.NewString(), things.DefaultLocation, tenant),
+		things.NewRoom(uuid.NewString(), things.DefaultLocation, tenant),
+		things.NewSewer(uuid.NewString(), things.DefaultLocation, tenant),
+	} {
+		err = db.AddThing(ctx, thing)
+		if err != nil {
+			t.Error(err)
+		}
+	}
+
+	counts, err := db.CountThings(ctx, []string{tenant})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(counts) != 2 {
+		t.Fatalf("expected counts of 2 types, got %d", len(counts))
+	}
+	if counts[0] != (app.ThingCount{Type: "Room", Tenant: tenant, Count: 2}) {
+		t.Errorf("expected 2 rooms, got %v", counts[0])
+	}
+}
+
+func TestQueryValuesByQuality(t *testing.T) {
+	db, ctx, cancel, err := new()
+	defer cancel()
+
+	if err != nil {
+		t.Log("could not connect to database or create tables, will skip test")
+		t.SkipNow()
+	}
+
+	thingID := uuid.NewString()
+	thing := things.NewRoom(thingID, things.Location{Latitude: 17.2, Longitude: 64.3}, "default")
+
+	err = db.AddThing(ctx, thing)
+	if err != nil {
+		t.Error(err)
+	}
+
+	ts := time.Now().UTC()
+	good := things.NewTemperature(thingID, "device", 21.0, ts).Value
+	estimated := things.NewTemperature(thingID, "device", 22.0, ts.Add(1*time.Second)).Value
+	estimated.Quality = things.QualityEstimated
+
+	for _, v := range []things.Value{good, estimated} {
+		err = db.AddValue(ctx, thing, v)
+		if err != nil {
+			t.Error(err)
+		}
+	}
+
+	result, err := db.QueryValues(ctx, app.WithThingID(thingID), app.WithQuality(things.QualityGood))
+	if err != nil {
+		t.Error(err)
+	}
+	if result.Count != 1 {
+		t.Errorf("expected 1 good value, got %d", result.Count)
+	}
+
+	result, err = db.QueryValues(ctx, app.WithThingID(thingID), app.WithQuality(things.QualityEstimated))
+	if err != nil {
+		t.Error(err)
+	}
+	if result.Count != 1 {
+		t.Fatalf("expected 1 estimated value, got %d", result.Count)
+	}
+
+	v := things.Value{}
+	json.Unmarshal(result.Data[0], &v)
+	if v.Quality != things.QualityEstimated {
+		t.Errorf("expected quality to be returned, got %s", v.Quality)
+	}
+}
+
+func TestQueryValuesWithStates(t *testing.T) {
+	db, ctx, cancel, err := new()
+	defer cancel()
+
+	if err != nil {
+		t.Log("could not connect to database or create tables, will skip test")
+		t.SkipNow()
+	}
+
+	thingID := uuid.NewString()
+	thing := things.NewRoom(thingID, things.Location{Latitude: 17.2, Longitude: 64.3}, "default")
+
+	err = db.AddThing(ctx, thing)
+	if err != nil {
+		t.Error(err)
+	}
+
+	ts := time.Now().UTC()
+	temperature := things.NewTemperature(thingID, "device", 21.0, ts).Value
+	door := things.NewDoor(thingID, "device", true, ts.Add(1*time.Second)).Status
+
+	for _, v := range []things.Value{temperature, door} {
+		err = db.AddValue(ctx, thing, v)
+		if err != nil {
+			t.Error(err)
+		}
+	}
+
+	result, err := db.QueryValues(ctx, app.WithThingID(thingID), app.WithStates())
+	if err != nil {
+		t.Error(err)
+	}
+	if result.Count != 1 {
+		t.Fatalf("expected 1 state value, got %d", result.Count)
+	}
+}
+
+func TestQueryThingsByNumberOfDevices(t *testing.T) {
+	db, ctx, cancel, err := new()
+	defer cancel()
+
+	if err != nil {
+		t.Log("could not connect to database or create tables, will skip test")
+		t.SkipNow()
+	}
+
+	tenant := uuid.NewString()
+
+	for n := range 3 {
+		thing := things.NewRoom(uuid.NewString(), things.Location{Latitude: 17.2, Longitude: 64.3}, tenant)
+		for range n {
+			thing.AddDevice(uuid.NewString())
+		}
+		err = db.AddThing(ctx, thing)
+		if err != nil {
+			t.Error(err)
+		}
+	}
+
+	count := func(conditions ...app.ConditionFunc) int {
+		result, err := db.QueryThings(ctx, append(conditions, app.WithTenants([]string{tenant}))...)
+		if err != nil {
+			t.Error(err)
+		}
+		return result.Count
+	}
+
+	if n := count(app.WithMaxDevices(0)); n != 1 {
+		t.Errorf("expected 1 thing without devices, got %d", n)
+	}
+	if n := count(app.WithMinDevices(1)); n != 2 {
+		t.Errorf("expected 2 things with at least one device, got %d", n)
+	}
+	if n := count(app.WithMinDevices(1), app.WithMaxDevices(1)); n != 1 {
+		t.Errorf("expected 1 thing with exactly one device, got %d", n)
+	}
+	if n := count(app.WithMinDevices(3)); n != 0 {
+		t.Errorf("expected no things with three devices, got %d", n)
+	}
+}
+
+func TestQueryThingsByCommissionedAt(t *testing.T) {
+	db, ctx, cancel, err := new()
+	defer cancel()
+
+	if err != nil {
+		t.Log("could not connect to database or create tables, will skip test")
+		t.SkipNow()
+	}
+
+	tenant := uuid.NewString()
+
+	for _, commissionedAt := range []string{"2022-03-01T00:00:00Z", "2024-06-01T00:00:00Z", ""} {
+		m := map[string]any{"id": uuid.NewString(), "type": "Room", "tenant": tenant}
+		if commissionedAt != "" {
+			m["commissionedAt"] = commissionedAt
+		}
+		b, _ := json.Marshal(m)
+		thing, _ := things.ConvToThing(b)
+
+		err = db.AddThing(ctx, thing)
+		if err != nil {
+			t.Error(err)
+		}
+	}
+
+	count := func(conditions ...app.ConditionFunc) int {
+		result, err := db.QueryThings(ctx, append(conditions, app.WithTenants([]string{tenant}))...)
+		if err != nil {
+			t.Error(err)
+		}
+		return result.Count
+	}
+
+	if n := count(app.WithCommissionedBefore(time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC))); n != 1 {
+		t.Errorf("expected 1 thing commissioned before 2023, got %d", n)
+	}
+	if n := count(app.WithCommissionedAfter(time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC))); n != 2 {
+		t.Errorf("expected 2 things commissioned after 2020, got %d", n)
+	}
+}
+
+func TestQueryThingsByObservedAt(t *testing.T) {
+	db, ctx, cancel, err := new()
+	defer cancel()
+
+	if err != nil {
+		t.Log("could not connect to database or create tables, will skip test")
+		t.SkipNow()
+	}
+
+	tenant := uuid.NewString()
+	now := time.Now().UTC()
+
+	for _, observedAt := range []time.Time{now.Add(-48 * time.Hour), now.Add(-1 * time.Hour), {}} {
+		m := map[string]any{"id": uuid.NewString(), "type": "Room", "tenant": tenant}
+		if !observedAt.IsZero() {
+			m["observedAt"] = observedAt
+		}
+		b, _ := json.Marshal(m)
+		thing, _ := things.ConvToThing(b)
+
+		err = db.AddThing(ctx, thing)
+		if err != nil {
+			t.Error(err)
+		}
+	}
+
+	count := func(conditions ...app.ConditionFunc) int {
+		result, err := db.QueryThings(ctx, append(conditions, app.WithTenants([]string{tenant}))...)
+		if err != nil {
+			t.Error(err)
+		}
+		return result.Count
+	}
+
+	if n := count(app.WithObservedBefore(now.Add(-24 * time.Hour))); n != 2 {
+		t.Errorf("expected 2 things not observed for a day, including the one never observed, got %d", n)
+	}
+	if n := count(app.WithObservedAfter(now.Add(-24 * time.Hour))); n != 1 {
+		t.Errorf("expected 1 thing observed within a day, got %d", n)
+	}
+}
+
+func TestQueryThingsWithRecentValues(t *testing.T) {
+	db, ctx, cancel, err := new()
+	defer cancel()
+
+	if err != nil {
+		t.Log("could not connect to database or create tables, will skip test")
+		t.SkipNow()
+	}
+
+	tenant := uuid.NewString()
+	now := time.Now().UTC()
+
+	// one thing with a recent value, one with only an old value and one without values
+	for _, age := range []time.Duration{time.Hour, 72 * time.Hour, 0} {
+		thingID := uuid.NewString()
+		thing := things.NewRoom(thingID, things.Location{Latitude: 17.2, Longitude: 64.3}, tenant)
+
+		err = db.AddThing(ctx, thing)
+		if err != nil {
+			t.Error(err)
+		}
+
+		if age > 0 {
+			err = db.AddValue(ctx, thing, things.NewTemperature(thingID, "device", 21.0, now.Add(-age)).Value)
+			if err != nil {
+				t.Error(err)
+			}
+		}
+	}
+
+	count := func(conditions ...app.ConditionFunc) int {
+		result, err := db.QueryThings(ctx, append(conditions, app.WithTenants([]string{tenant}))...)
+		if err != nil {
+			t.Error(err)
+		}
+		return result.Count
+	}
+
+	if n := count(app.WithRecentValues(true, now.Add(-24*time.Hour))); n != 1 {
+		t.Errorf("expected 1 thing with values within 24h, got %d", n)
+	}
+	if n := count(app.WithRecentValues(false, now.Add(-24*time.Hour))); n != 2 {
+		t.Errorf("expected 2 things without values within 24h, got %d", n)
+	}
+	if n := count(app.WithRecentValues(true, now.Add(-7*24*time.Hour))); n != 2 {
+		t.Errorf("expected 2 things with values within a week, got %d", n)
+	}
+}
+
+func TestQueryThingsByInspectionDue(t *testing.T) {
+	db, ctx, cancel, err := new()
+	defer cancel()
+
+	if err != nil {
+		t.Log("could not connect to database or create tables, will skip test")
+		t.SkipNow()
+	}
+
+	tenant := uuid.NewString()
+	now := time.Now().UTC()
+
+	// one overdue lifebuoy, one not yet due and one never scheduled for inspection
+	for _, due := range []string{now.Add(-24 * time.Hour).Format(time.RFC3339), now.Add(30 * 24 * time.Hour).Format(time.RFC3339), ""} {
+		m := map[string]any{"id": uuid.NewString(), "type": "Lifebuoy", "tenant": tenant}
+		if due != "" {
+			m["nextInspectionDue"] = due
+		}
+		b, _ := json.Marshal(m)
+		thing, _ := things.ConvToThing(b)
+
+		err = db.AddThing(ctx, thing)
+		if err != nil {
+			t.Error(err)
+		}
+	}
+
+	count := func(conditions ...app.ConditionFunc) int {
+		result, err := db.QueryThings(ctx, append(conditions, app.WithTenants([]string{tenant}))...)
+		if err != nil {
+			t.Error(err)
+		}
+		return result.Count
+	}
+
+	if n := count(app.WithInspectionDue(true, now)); n != 1 {
+		t.Errorf("expected 1 overdue thing, got %d", n)
+	}
+	if n := count(app.WithInspectionDue(false, now)); n != 2 {
+		t.Errorf("expected 2 things not due for inspection, got %d", n)
+	}
+}
+
+func TestChunkInterval(t *testing.T) {
+	_, ctx, cancel, err := new()
+	defer cancel()
+
+	if err != nil {
+		t.Log("could not connect to database or create tables, will skip test")
+		t.SkipNow()
+	}
+
+	cfg := testConfig()
+	cfg.chunkInterval = "12 hours"
+
+	db, err := New(ctx, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var seconds int64
+	err = db.(database).pool.QueryRow(ctx, `
+		SELECT EXTRACT(EPOCH FROM time_interval)::bigint
+		FROM timescaledb_information.dimensions
+		WHERE hypertable_name = 'things_values' AND column_name = 'time';`).Scan(&seconds)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if interval := time.Duration(seconds) * time.Second; interval != 12*time.Hour {
+		t.Errorf("expected a chunk interval of 12h, got %s", interval)
+	}
+
+	// restore the default interval of seven days for the other tests
+	cfg.chunkInterval = "7 days"
+	_, err = New(ctx, cfg)
+	if err != nil {
+		t.Error(err)
+	}
+}
+
+func TestCompression(t *testing.T) {
+	_, ctx, cancel, err := new()
+	defer cancel()
+
+	if err != nil {
+		t.Log("could not connect to database or create tables, will skip test")
+		t.SkipNow()
+	}
+
+	cfg := testConfig()
+	cfg.compressAfter = "90 days"
+
+	// a second start with the same configuration should leave it as it is
+	var db Storage
+	for range 2 {
+		db, err = New(ctx, cfg)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var enabled bool
+	err = db.(database).pool.QueryRow(ctx, `
+		SELECT compression_enabled
+		FROM timescaledb_information.hypertables
+		WHERE hypertable_name = 'things_values';`).Scan(&enabled)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !enabled {
+		t.Error("expected compression to be enabled")
+	}
+
+	var policies int
+	var days int64
+	err = db.(database).pool.QueryRow(ctx, `
+		SELECT count(*), max(EXTRACT(DAY FROM (config->>'compress_after')::interval))::bigint
+		FROM timescaledb_information.jobs
+		WHERE proc_name = 'policy_compression' AND hypertable_name = 'things_values';`).Scan(&policies, &days)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if policies != 1 || days != 90 {
+		t.Errorf("expected one policy compressing after 90 days, got %d after %d days", policies, days)
+	}
+}
+
+func TestStatementTimeout(t *testing.T) {
+	db, ctx, cancel, err := new()
+	defer cancel()
+
+	if err != nil {
+		t.Log("could not connect to database or create tables, will skip test")
+		t.SkipNow()
+	}
+
+	d := db.(database)
+
+	timeoutCtx, cancelTimeout := context.WithTimeout(ctx, 200*time.Millisecond)
+	defer cancelTimeout()
+
+	rows, err := d.query(timeoutCtx, "SELECT pg_sleep(2)")
+	if err == nil {
+		_, err = pgx.ForEachRow(rows, []any{nil}, func() error { return nil })
+	}
+	if err == nil {
+		t.Error("expected the query to be cancelled by the statement timeout")
+	}
+
+	// the timeout is reset before the connection is returned to the pool
+	var timeout string
+	err = d.pool.QueryRow(ctx, "SHOW statement_timeout").Scan(&timeout)
+	if err != nil {
+		t.Error(err)
+	}
+	if timeout != "0" {
+		t.Errorf("expected no statement timeout, got %s", timeout)
+	}
+}
+
+func new() (Storage, context.Context, context.CancelFunc, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	ctx = auth.WithAllowedTenants(ctx, []string{"default"})
+
+	db, err := New(ctx, testConfig())
+
+	return db, ctx, cancel, err
+}
+
+func testConfig() Config {
+	return Config{
+		host:     "localhost",
+		user:     "postgres",
+		password: "password",
+		port:     "5432",
+		dbname:   "postgres",
+		sslmode:  "disable",
+	}
 }
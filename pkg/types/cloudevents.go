@@ -0,0 +1,63 @@
+package types
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const CloudEventsSpecVersion string = "1.0"
+
+// CloudEvent is a message wrapped in a CloudEvents (https://cloudevents.io) envelope, using the
+// structured content mode. It is published on the same topic as the message it wraps.
+type CloudEvent struct {
+	SpecVersion     string          `json:"specversion"`
+	Type            string          `json:"type"`
+	Source          string          `json:"source"`
+	ID              string          `json:"id"`
+	Time            time.Time       `json:"time"`
+	Subject         string          `json:"subject,omitempty"`
+	DataContentType string          `json:"datacontenttype,omitempty"`
+	Data            json.RawMessage `json:"data"`
+
+	topic string
+}
+
+type message interface {
+	Body() []byte
+	ContentType() string
+	TopicName() string
+}
+
+// NewCloudEvent wraps msg in a CloudEvent from source. The event type is the topic of the
+// message prefixed with "diwise.", e.g. diwise.thing.updated.
+func NewCloudEvent(msg message, source string) *CloudEvent {
+	e := &CloudEvent{
+		SpecVersion:     CloudEventsSpecVersion,
+		Type:            "diwise." + msg.TopicName(),
+		Source:          source,
+		ID:              uuid.NewString(),
+		Time:            time.Now().UTC(),
+		DataContentType: msg.ContentType(),
+		Data:            msg.Body(),
+		topic:           msg.TopicName(),
+	}
+
+	if t, ok := msg.(*ThingUpdated); ok {
+		e.Subject = t.ID
+	}
+
+	return e
+}
+
+func (e *CloudEvent) Body() []byte {
+	b, _ := json.Marshal(e)
+	return b
+}
+func (e *CloudEvent) ContentType() string {
+	return "application/cloudevents+json"
+}
+func (e *CloudEvent) TopicName() string {
+	return e.topic
+}
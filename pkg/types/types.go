@@ -13,6 +13,9 @@ type ThingUpdated struct {
 	Thing     any       `json:"thing,omitempty"`
 	Tenant    string    `json:"tenant"`
 	Timestamp time.Time `json:"timestamp"`
+
+	// CorrelationID is the correlation id of the measurement that caused the update, if any
+	CorrelationID string `json:"correlationId,omitempty"`
 }
 
 func (t *ThingUpdated) Body() []byte {
@@ -25,3 +28,75 @@ func (t *ThingUpdated) ContentType() string {
 func (t *ThingUpdated) TopicName() string {
 	return "thing.updated"
 }
+
+type ThingsUpdated struct {
+	IDs       []string  `json:"ids"`
+	Tenant    string    `json:"tenant"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+func (t *ThingsUpdated) Body() []byte {
+	b, _ := json.Marshal(t)
+	return b
+}
+func (t *ThingsUpdated) ContentType() string {
+	return "application/vnd.diwise.thingsupdated+json"
+}
+func (t *ThingsUpdated) TopicName() string {
+	return "things.updated"
+}
+
+type SeedCompleted struct {
+	Created   int       `json:"created"`
+	Updated   int       `json:"updated"`
+	Tenants   []string  `json:"tenants"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+func (s *SeedCompleted) Body() []byte {
+	b, _ := json.Marshal(s)
+	return b
+}
+func (s *SeedCompleted) ContentType() string {
+	return "application/vnd.diwise.seedcompleted+json"
+}
+func (s *SeedCompleted) TopicName() string {
+	return "seed.completed"
+}
+
+type ThingDeleted struct {
+	ID        string    `json:"id"`
+	Type      string    `json:"type"`
+	Tenant    string    `json:"tenant"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+func (t *ThingDeleted) Body() []byte {
+	b, _ := json.Marshal(t)
+	return b
+}
+func (t *ThingDeleted) ContentType() string {
+	return "application/vnd.diwise.thingdeleted+json"
+}
+func (t *ThingDeleted) TopicName() string {
+	return "thing.deleted"
+}
+
+// WaterMeterAlert is published when a water meter starts reporting a leak, backflow or fraud
+type WaterMeterAlert struct {
+	ID        string    `json:"id"`
+	Kind      string    `json:"kind"` // leak, backflow or fraud
+	Tenant    string    `json:"tenant"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+func (w *WaterMeterAlert) Body() []byte {
+	b, _ := json.Marshal(w)
+	return b
+}
+func (w *WaterMeterAlert) ContentType() string {
+	return "application/vnd.diwise.watermeteralert+json"
+}
+func (w *WaterMeterAlert) TopicName() string {
+	return "watermeter.alert"
+}